@@ -1,24 +1,52 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/mylxsw/asteria/log"
+	"github.com/supremeagent/executor/internal/grpcapi"
 	"github.com/supremeagent/executor/internal/httpapi"
+	"github.com/supremeagent/executor/pkg/executor"
+	"github.com/supremeagent/executor/pkg/executorpb"
 	"github.com/supremeagent/executor/pkg/sdk"
+	"google.golang.org/grpc"
 )
 
 func main() {
 	addr := flag.String("addr", "0.0.0.0:8080", "Server address")
+	authTokens := flag.String("auth-tokens", os.Getenv("EXECUTOR_AUTH_TOKENS"), "Comma separated bearer tokens required on /api routes (disabled when empty)")
+	executeRateLimit := flag.Int("execute-rate-limit", 0, "Max POST /api/execute requests per minute per client (0 disables)")
+	grpcAddr := flag.String("grpc-addr", "", "gRPC event stream address (disabled when empty)")
+	workingDirRoot := flag.String("working-dir-root", os.Getenv("EXECUTOR_WORKING_DIR_ROOT"), "Directory every session's working_dir must resolve inside (any directory when empty)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 10*time.Second, "How long SIGINT/SIGTERM waits for sessions to stop and connections to drain")
+	allowedExecutors := flag.String("allowed-executors", os.Getenv("EXECUTOR_ALLOWED_EXECUTORS"), "Comma separated executors sessions may use (all registered executors when empty)")
+	launcher := flag.String("launcher", os.Getenv("EXECUTOR_LAUNCHER"), "Command that runs the executors' npm packages, e.g. \"pnpm dlx\" or \"bunx\" (npx when empty)")
 	flag.Parse()
 
-	client := sdk.New()
-	handler := httpapi.NewHandler(client)
+	var allowed []executor.ExecutorType
+	for _, name := range strings.Split(*allowedExecutors, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			allowed = append(allowed, executor.ExecutorType(name))
+		}
+	}
+	client := sdk.NewWithOptions(sdk.ClientOptions{
+		WorkingDirRoot:   *workingDirRoot,
+		AllowedExecutors: allowed,
+		Launcher:         *launcher,
+	})
+	handler := httpapi.NewHandlerWithOptions(client, httpapi.HandlerOptions{
+		BearerTokens:     strings.Split(*authTokens, ","),
+		ExecuteRateLimit: *executeRateLimit,
+	})
 	router := httpapi.NewRouter(handler)
 
 	server := &http.Server{Addr: *addr, Handler: router}
@@ -31,11 +59,66 @@ func main() {
 		}
 	}()
 
+	var grpcServer *grpc.Server
+	if *grpcAddr != "" {
+		listener, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gRPC listen error: %v\n", err)
+			os.Exit(1)
+		}
+		grpcServer = grpc.NewServer()
+		executorpb.RegisterEventServiceServer(grpcServer, grpcapi.NewServerWithOptions(client, grpcapi.ServerOptions{
+			BearerTokens: strings.Split(*authTokens, ","),
+		}))
+		go func() {
+			log.Infof("Starting gRPC server on %s", *grpcAddr)
+			if err := grpcServer.Serve(listener); err != nil {
+				fmt.Fprintf(os.Stderr, "gRPC server error: %v\n", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	log.Info("Shutting down server...")
-	client.Shutdown()
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+
+	// Stop accepting requests right away; Shutdown and GracefulStop then
+	// wait for in-flight ones, including event streams, to finish.
+	serverDone := make(chan error, 1)
+	go func() { serverDone <- server.Shutdown(ctx) }()
+	grpcDone := make(chan struct{})
+	if grpcServer != nil {
+		go func() {
+			grpcServer.GracefulStop()
+			close(grpcDone)
+		}()
+	} else {
+		close(grpcDone)
+	}
+
+	// Stopping the sessions first lets connected streams deliver their final
+	// events. Sessions get half of the budget, so that the streams still open
+	// afterwards can be ended with a done event before the drain gives up.
+	sessionsCtx, cancelSessions := context.WithTimeout(ctx, *shutdownTimeout/2)
+	report := client.ShutdownContext(sessionsCtx)
+	cancelSessions()
+	if report.Drained+report.ForceClosed > 0 {
+		log.Infof("Sessions stopped: drained=%d force_closed=%d", report.Drained, report.ForceClosed)
+	}
+	handler.CloseStreams()
+	if err := <-serverDone; err != nil {
+		log.Warningf("HTTP server shutdown: %v", err)
+	}
+	select {
+	case <-grpcDone:
+	case <-ctx.Done():
+		log.Warning("gRPC server shutdown: streams still open; closing them")
+		grpcServer.Stop()
+	}
 	log.Info("Server stopped")
 }
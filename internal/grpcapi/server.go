@@ -0,0 +1,226 @@
+// Package grpcapi serves session events over gRPC for service-to-service
+// consumers, as a lower-overhead alternative to the HTTP SSE and WebSocket
+// streams.
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/mylxsw/asteria/log"
+	"github.com/supremeagent/executor/pkg/executor"
+	"github.com/supremeagent/executor/pkg/executorpb"
+	"github.com/supremeagent/executor/pkg/sdk"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ServerOptions configures optional Server behaviour.
+type ServerOptions struct {
+	// BearerTokens, when any are non-empty, must be presented in the
+	// "authorization: Bearer <token>" metadata of every call.
+	BearerTokens []string
+	// Authorizer, when set, is consulted before subscribing to a session and
+	// before forwarding each control message to it, like the HTTP API's
+	// Authorizer. Denied calls fail with PermissionDenied.
+	Authorizer func(ctx context.Context, sessionID string) bool
+}
+
+// Server implements executorpb.EventService on top of an SDK client.
+type Server struct {
+	executorpb.UnimplementedEventServiceServer
+
+	client     *sdk.Client
+	tokens     [][]byte
+	authorizer func(ctx context.Context, sessionID string) bool
+}
+
+// NewServer creates an EventService server without authentication.
+func NewServer(client *sdk.Client) *Server {
+	return NewServerWithOptions(client, ServerOptions{})
+}
+
+// NewServerWithOptions creates an EventService server with opts applied.
+func NewServerWithOptions(client *sdk.Client, opts ServerOptions) *Server {
+	s := &Server{client: client, authorizer: opts.Authorizer}
+	for _, token := range opts.BearerTokens {
+		if token = strings.TrimSpace(token); token != "" {
+			s.tokens = append(s.tokens, []byte(token))
+		}
+	}
+	return s
+}
+
+// Events streams the events of the session named by the first (Subscribe)
+// message, replaying stored events after after_seq before following the live
+// run. The stream ends when the run finishes. Control messages sent on the
+// same stream are forwarded to the session as approval responses.
+func (s *Server) Events(stream executorpb.EventService_EventsServer) error {
+	ctx := stream.Context()
+	if err := s.authenticate(ctx); err != nil {
+		return err
+	}
+
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	sub := req.GetSubscribe()
+	if sub == nil || sub.GetSessionId() == "" {
+		return status.Error(codes.InvalidArgument, "the first message must subscribe to a session")
+	}
+	sessionID := sub.GetSessionId()
+	if err := s.authorize(ctx, sessionID); err != nil {
+		return err
+	}
+	if _, ok := s.client.GetSession(sessionID); !ok {
+		return status.Error(codes.NotFound, executor.ErrSessionNotFound.Error())
+	}
+
+	events, unsubscribe := s.client.Subscribe(sessionID, executor.SubscribeOptions{
+		ReturnAll:    true,
+		AfterSeq:     sub.GetAfterSeq(),
+		IncludeDebug: sub.GetIncludeDebug(),
+	})
+	defer unsubscribe()
+
+	received := make(chan error, 1)
+	go func() { received <- s.receiveControls(ctx, stream, sessionID) }()
+
+	for {
+		select {
+		case evt, ok := <-events:
+			if !ok {
+				return nil
+			}
+			msg, err := toProtoEvent(evt)
+			if err != nil {
+				return status.Errorf(codes.Internal, "encode event %d: %v", evt.Seq, err)
+			}
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		case err := <-received:
+			if err != nil {
+				return err
+			}
+			// The client closed its side; keep streaming events.
+			received = nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// receiveControls forwards control messages to the session until the client
+// closes its side of the stream (nil) or sends an invalid message.
+func (s *Server) receiveControls(ctx context.Context, stream executorpb.EventService_EventsServer, sessionID string) error {
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		control := req.GetControl()
+		if control == nil {
+			return status.Error(codes.InvalidArgument, "only control messages may follow subscribe")
+		}
+		response := executor.ControlResponse{
+			RequestID: control.GetRequestId(),
+			Decision:  executor.ControlDecision(control.GetDecision()),
+			Reason:    control.GetReason(),
+		}
+		if response.RequestID == "" {
+			return status.Error(codes.InvalidArgument, "request_id is required")
+		}
+		if response.Decision != executor.ControlDecisionApprove && response.Decision != executor.ControlDecisionDeny {
+			return status.Error(codes.InvalidArgument, "decision must be approve or deny")
+		}
+		if err := s.authorize(ctx, sessionID); err != nil {
+			return err
+		}
+		if err := s.client.RespondControl(ctx, sessionID, response); err != nil {
+			log.Warningf("grpc Events: respond control %s for session %s failed: %v", response.RequestID, sessionID, err)
+		}
+	}
+}
+
+func (s *Server) authenticate(ctx context.Context) error {
+	if len(s.tokens) == 0 {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		scheme, token, ok := strings.Cut(value, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			continue
+		}
+		token = strings.TrimSpace(token)
+		for _, allowed := range s.tokens {
+			if subtle.ConstantTimeCompare([]byte(token), allowed) == 1 {
+				return nil
+			}
+		}
+	}
+	return status.Error(codes.Unauthenticated, "unauthorized")
+}
+
+// authorize fails with PermissionDenied when the caller may not access
+// sessionID.
+func (s *Server) authorize(ctx context.Context, sessionID string) error {
+	if s.authorizer == nil || s.authorizer(ctx, sessionID) {
+		return nil
+	}
+	return status.Error(codes.PermissionDenied, "forbidden")
+}
+
+// toProtoEvent converts evt, keeping its content as JSON and, for normalized
+// events, as a typed Content message.
+func toProtoEvent(evt executor.Event) (*executorpb.Event, error) {
+	data, err := json.Marshal(evt.Content)
+	if err != nil {
+		return nil, err
+	}
+	msg := &executorpb.Event{
+		SessionId:   evt.SessionID,
+		Executor:    evt.Executor,
+		Seq:         evt.Seq,
+		Type:        evt.Type,
+		Normalized:  evt.Normalized,
+		Truncated:   evt.Truncated,
+		ContentJson: data,
+	}
+	if !evt.Timestamp.IsZero() {
+		msg.Timestamp = timestamppb.New(evt.Timestamp)
+	}
+	if evt.Normalized {
+		var content executor.UnifiedContent
+		if err := json.Unmarshal(data, &content); err == nil {
+			msg.Content = &executorpb.Content{
+				Source:     content.Source,
+				SourceType: content.SourceType,
+				Category:   content.Category,
+				Action:     content.Action,
+				Phase:      content.Phase,
+				Summary:    content.Summary,
+				Target:     content.Target,
+				Text:       content.Text,
+				ToolName:   content.ToolName,
+				RequestId:  content.RequestID,
+				Status:     content.Status,
+				ErrorKind:  content.ErrorKind,
+				ErrorCode:  content.ErrorCode,
+				RetryAfter: int32(content.RetryAfter),
+			}
+		}
+	}
+	return msg, nil
+}
@@ -0,0 +1,301 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/supremeagent/executor/pkg/executor"
+	"github.com/supremeagent/executor/pkg/executorpb"
+	"github.com/supremeagent/executor/pkg/sdk"
+	"github.com/supremeagent/executor/pkg/store"
+	"github.com/supremeagent/executor/pkg/streaming"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestEvents(t *testing.T) {
+	client, exec := newTestClient()
+	conn := dialTestServer(t, NewServer(client))
+	events := executorpb.NewEventServiceClient(conn)
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: "grpc_executor"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	stream, err := events.Events(ctx)
+	if err != nil {
+		t.Fatalf("open stream failed: %v", err)
+	}
+	if err := stream.Send(&executorpb.EventsRequest{Request: &executorpb.EventsRequest_Subscribe{
+		Subscribe: &executorpb.Subscribe{SessionId: resp.SessionID},
+	}}); err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+
+	started, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("recv failed: %v", err)
+	}
+	if started.GetSeq() != 1 || !started.GetNormalized() || started.GetContent().GetSourceType() != sdk.SessionStartedSourceType {
+		t.Fatalf("expected session_started event first, got %v", started)
+	}
+	if started.GetTimestamp() == nil || started.GetSessionId() != resp.SessionID {
+		t.Fatalf("expected session id and timestamp, got %v", started)
+	}
+
+	exec.logs <- executor.Log{Type: "stdout", Content: "hello from executor"}
+	evt, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("recv failed: %v", err)
+	}
+	if evt.GetType() != "stdout" || evt.GetSeq() != 2 || len(evt.GetContentJson()) == 0 {
+		t.Fatalf("unexpected event: %v", evt)
+	}
+
+	if err := stream.Send(&executorpb.EventsRequest{Request: &executorpb.EventsRequest_Control{
+		Control: &executorpb.ControlResponse{RequestId: "req-1", Decision: "approve"},
+	}}); err != nil {
+		t.Fatalf("send control failed: %v", err)
+	}
+	waitFor(t, func() bool { return exec.control().RequestID == "req-1" })
+	if exec.control().Decision != executor.ControlDecisionApprove {
+		t.Fatalf("unexpected control response: %+v", exec.control())
+	}
+
+	exec.logs <- executor.Log{Type: "done", Content: "finished"}
+	var last *executorpb.Event
+	for {
+		evt, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("expected stream to end cleanly, got %v", err)
+		}
+		last = evt
+	}
+	if last.GetType() != "done" {
+		t.Fatalf("expected done as the last event, got %v", last)
+	}
+
+	t.Run("AfterSeq", func(t *testing.T) {
+		stream, err := events.Events(ctx)
+		if err != nil {
+			t.Fatalf("open stream failed: %v", err)
+		}
+		_ = stream.Send(&executorpb.EventsRequest{Request: &executorpb.EventsRequest_Subscribe{
+			Subscribe: &executorpb.Subscribe{SessionId: resp.SessionID, AfterSeq: 1},
+		}})
+		evt, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("recv failed: %v", err)
+		}
+		if evt.GetSeq() != 2 {
+			t.Fatalf("expected replay to start after seq 1, got %v", evt)
+		}
+	})
+
+	t.Run("InvalidControl", func(t *testing.T) {
+		stream, err := events.Events(ctx)
+		if err != nil {
+			t.Fatalf("open stream failed: %v", err)
+		}
+		_ = stream.Send(&executorpb.EventsRequest{Request: &executorpb.EventsRequest_Control{
+			Control: &executorpb.ControlResponse{RequestId: "req-2", Decision: "approve"},
+		}})
+		if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("expected InvalidArgument without subscribe, got %v", err)
+		}
+	})
+
+	t.Run("UnknownSession", func(t *testing.T) {
+		stream, err := events.Events(ctx)
+		if err != nil {
+			t.Fatalf("open stream failed: %v", err)
+		}
+		_ = stream.Send(&executorpb.EventsRequest{Request: &executorpb.EventsRequest_Subscribe{
+			Subscribe: &executorpb.Subscribe{SessionId: "missing"},
+		}})
+		if _, err := stream.Recv(); status.Code(err) != codes.NotFound {
+			t.Fatalf("expected NotFound, got %v", err)
+		}
+	})
+}
+
+func TestEvents_BearerTokens(t *testing.T) {
+	client, _ := newTestClient()
+	conn := dialTestServer(t, NewServerWithOptions(client, ServerOptions{BearerTokens: []string{"secret"}}))
+	events := executorpb.NewEventServiceClient(conn)
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: "grpc_executor"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	subscribe := &executorpb.EventsRequest{Request: &executorpb.EventsRequest_Subscribe{
+		Subscribe: &executorpb.Subscribe{SessionId: resp.SessionID},
+	}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	stream, err := events.Events(ctx)
+	if err != nil {
+		t.Fatalf("open stream failed: %v", err)
+	}
+	_ = stream.Send(subscribe)
+	if _, err := stream.Recv(); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated without token, got %v", err)
+	}
+
+	stream, err = events.Events(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret"))
+	if err != nil {
+		t.Fatalf("open stream failed: %v", err)
+	}
+	_ = stream.Send(subscribe)
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("expected event with valid token, got %v", err)
+	}
+}
+
+func TestEvents_Authorizer(t *testing.T) {
+	client, exec := newTestClient()
+	var allowed atomic.Bool
+	conn := dialTestServer(t, NewServerWithOptions(client, ServerOptions{
+		Authorizer: func(ctx context.Context, sessionID string) bool { return allowed.Load() },
+	}))
+	events := executorpb.NewEventServiceClient(conn)
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: "grpc_executor"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	subscribe := &executorpb.EventsRequest{Request: &executorpb.EventsRequest_Subscribe{
+		Subscribe: &executorpb.Subscribe{SessionId: resp.SessionID},
+	}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	stream, err := events.Events(ctx)
+	if err != nil {
+		t.Fatalf("open stream failed: %v", err)
+	}
+	_ = stream.Send(subscribe)
+	if _, err := stream.Recv(); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied for a denied subscribe, got %v", err)
+	}
+
+	allowed.Store(true)
+	stream, err = events.Events(ctx)
+	if err != nil {
+		t.Fatalf("open stream failed: %v", err)
+	}
+	_ = stream.Send(subscribe)
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("expected event for an allowed subscribe, got %v", err)
+	}
+
+	// Access revoked after subscribing still stops control messages.
+	allowed.Store(false)
+	_ = stream.Send(&executorpb.EventsRequest{Request: &executorpb.EventsRequest_Control{
+		Control: &executorpb.ControlResponse{RequestId: "req-1", Decision: "approve"},
+	}})
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if status.Code(err) != codes.PermissionDenied {
+				t.Fatalf("expected PermissionDenied for a denied control, got %v", err)
+			}
+			break
+		}
+	}
+	if exec.control().RequestID != "" {
+		t.Fatalf("expected the denied control not to reach the executor, got %+v", exec.control())
+	}
+}
+
+func newTestClient() (*sdk.Client, *grpcExecutor) {
+	registry := executor.NewRegistry()
+	client := sdk.NewWithOptions(sdk.ClientOptions{
+		Registry:      registry,
+		StreamManager: streaming.NewManager(),
+		EventStore:    store.NewMemoryEventStore(),
+	})
+	exec := &grpcExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register("grpc_executor", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	return client, exec
+}
+
+func dialTestServer(t *testing.T, srv *Server) *grpc.ClientConn {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	executorpb.RegisterEventServiceServer(server, srv)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("condition not met before deadline")
+}
+
+type grpcExecutor struct {
+	logs        chan executor.Log
+	mu          sync.Mutex
+	lastControl executor.ControlResponse
+	closeOnce   sync.Once
+}
+
+func (m *grpcExecutor) Start(ctx context.Context, prompt string, opts executor.Options) error {
+	return nil
+}
+func (m *grpcExecutor) Interrupt() error                                      { return nil }
+func (m *grpcExecutor) SendMessage(ctx context.Context, message string) error { return nil }
+func (m *grpcExecutor) RespondControl(ctx context.Context, response executor.ControlResponse) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastControl = response
+	return nil
+}
+func (m *grpcExecutor) control() executor.ControlResponse {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastControl
+}
+func (m *grpcExecutor) Wait() error               { return nil }
+func (m *grpcExecutor) Logs() <-chan executor.Log { return m.logs }
+func (m *grpcExecutor) Done() <-chan struct{}     { return nil }
+func (m *grpcExecutor) Close() error {
+	m.closeOnce.Do(func() { close(m.logs) })
+	return nil
+}
@@ -0,0 +1,41 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/supremeagent/executor/pkg/sdk"
+)
+
+// Authorizer decides whether the caller identified by ctx may access a
+// session. Returning false makes session handlers respond 403.
+type Authorizer func(ctx context.Context, sessionID string) bool
+
+// LabelAuthorizer allows access when the session's label key equals the
+// subject returned for the request context, e.g. an "owner" label set at
+// execute time and a user ID placed in the context by auth middleware.
+// Sessions without the label and requests without a subject are denied.
+func LabelAuthorizer(client *sdk.Client, key string, subject func(ctx context.Context) string) Authorizer {
+	return func(ctx context.Context, sessionID string) bool {
+		who := subject(ctx)
+		if who == "" {
+			return false
+		}
+		session, ok := client.GetSession(sessionID)
+		if !ok {
+			return false
+		}
+		owner, ok := session.Labels[key]
+		return ok && owner == who
+	}
+}
+
+// authorize reports whether the request may access sessionID, writing a 403
+// response when it may not.
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request, sessionID string) bool {
+	if h.authorizer == nil || h.authorizer(r.Context(), sessionID) {
+		return true
+	}
+	http.Error(w, "forbidden", http.StatusForbidden)
+	return false
+}
@@ -0,0 +1,139 @@
+package httpapi
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mylxsw/asteria/log"
+	"github.com/supremeagent/executor/pkg/executor"
+	"github.com/supremeagent/executor/pkg/sdk"
+	"github.com/supremeagent/executor/pkg/store"
+)
+
+// exportBatchSize is the number of events read from the store, written and
+// flushed per batch while exporting.
+const exportBatchSize = 500
+
+// HandleExport streams every stored event of a session as NDJSON (one JSON
+// event per line). When the client sends Accept-Encoding: gzip the stream is
+// gzip-compressed; the compressor is flushed after every batch so the
+// response stays incremental instead of being buffered to the end. A session
+// that is unknown and has no stored events is a 404.
+func (h *Handler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["session_id"]
+	if !h.authorize(w, r, sessionID) {
+		return
+	}
+
+	listBatch := func(afterSeq uint64) ([]executor.Event, error) {
+		return h.client.ListEventsWithOptions(r.Context(), sessionID, store.ListOptions{
+			AfterSeq: afterSeq,
+			Limit:    exportBatchSize,
+		})
+	}
+	// The first batch is read before the headers are committed, so a missing
+	// session or a failing store still gets a proper status.
+	events, err := listBatch(0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(events) == 0 {
+		if _, ok := h.client.GetSession(sessionID); !ok {
+			http.Error(w, executor.ErrSessionNotFound.Error(), http.StatusNotFound)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	var out io.Writer = w
+	var gz *gzip.Writer
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz = gzip.NewWriter(w)
+		defer gz.Close()
+		out = gz
+	}
+	flusher, _ := w.(http.Flusher)
+
+	enc := json.NewEncoder(out)
+	var afterSeq uint64
+	for {
+		for _, evt := range events {
+			if err := enc.Encode(evt); err != nil {
+				return
+			}
+			afterSeq = evt.Seq
+		}
+
+		if gz != nil {
+			if err := gz.Flush(); err != nil {
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if len(events) < exportBatchSize || r.Context().Err() != nil {
+			return
+		}
+
+		events, err = listBatch(afterSeq)
+		if err != nil {
+			// Headers are already committed; the truncated stream signals the failure.
+			log.Warningf("HandleExport: failed to list events for %s: %v", sessionID, err)
+			return
+		}
+	}
+}
+
+// HandleTranscript renders a session as a shareable transcript. format=json
+// (the default) returns the session, prompt and ordered events; format=md
+// returns a Markdown document.
+func (h *Handler) HandleTranscript(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["session_id"]
+	if !h.authorize(w, r, sessionID) {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	data, err := h.client.Transcript(r.Context(), sessionID, format)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, executor.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		} else if errors.Is(err, sdk.ErrUnsupportedTranscriptFormat) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if format == sdk.TranscriptFormatMarkdown {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	_, _ = w.Write(data)
+}
+
+// acceptsGzip reports whether the request's Accept-Encoding allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
@@ -6,20 +6,83 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/mylxsw/asteria/log"
 	"github.com/supremeagent/executor/pkg/executor"
 	"github.com/supremeagent/executor/pkg/sdk"
+	"github.com/supremeagent/executor/pkg/store"
 )
 
+// DefaultMaxEventsPerPage is the number of events HandleEvents returns when
+// the request does not set limit.
+const DefaultMaxEventsPerPage = 1000
+
+// MaxPollWait caps the wait parameter of HandlePollEvents.
+const MaxPollWait = 60 * time.Second
+
 // Handler handles HTTP API requests.
 type Handler struct {
-	client *sdk.Client
+	client        *sdk.Client
+	authorizer    Authorizer
+	maxEventsPage int
+	bearerTokens  []string
+	executeLimit  *RateLimiter
+
+	streamsClosed chan struct{}
+	closeStreams  sync.Once
+}
+
+// HandlerOptions configures optional Handler behavior.
+type HandlerOptions struct {
+	// Authorizer, when set, is consulted before serving or controlling a
+	// session; denied requests get 403 and ListSessions hides the session.
+	Authorizer Authorizer
+	// MaxEventsPerPage caps HandleEvents responses that do not set limit.
+	// Defaults to DefaultMaxEventsPerPage when <= 0.
+	MaxEventsPerPage int
+	// BearerTokens, when non-empty, makes NewRouter require one of these
+	// tokens on every /api route (see RequireBearerToken).
+	BearerTokens []string
+	// ExecuteRateLimit caps POST /api/execute to this many requests per minute
+	// per identity; <= 0 disables limiting.
+	ExecuteRateLimit int
+	// ExecuteRateLimitKey picks the identity ExecuteRateLimit is keyed by.
+	// Defaults to RequestIdentity (verified bearer token, else remote IP).
+	ExecuteRateLimitKey RateLimitKeyFunc
 }
 
 func NewHandler(client *sdk.Client) *Handler {
-	return &Handler{client: client}
+	return NewHandlerWithOptions(client, HandlerOptions{})
+}
+
+// NewHandlerWithOptions creates a Handler with optional behavior such as
+// per-session authorization.
+func NewHandlerWithOptions(client *sdk.Client, opts HandlerOptions) *Handler {
+	if opts.MaxEventsPerPage <= 0 {
+		opts.MaxEventsPerPage = DefaultMaxEventsPerPage
+	}
+	return &Handler{
+		client:        client,
+		authorizer:    opts.Authorizer,
+		maxEventsPage: opts.MaxEventsPerPage,
+		bearerTokens:  nonEmptyTokens(opts.BearerTokens),
+		executeLimit:  NewRateLimiter(opts.ExecuteRateLimit, opts.ExecuteRateLimitKey),
+		streamsClosed: make(chan struct{}),
+	}
+}
+
+// CloseStreams ends every open event stream, for server shutdown: SSE
+// streams flush a final done event and return, and WebSocket connections are
+// closed with a going-away close frame. Streams opened afterwards end right
+// away. Call it once sessions have stopped, so that clients received their
+// sessions' final events, and well before the context given to
+// http.Server.Shutdown expires, since Shutdown waits for these requests.
+func (h *Handler) CloseStreams() {
+	h.closeStreams.Do(func() { close(h.streamsClosed) })
 }
 
 func (h *Handler) HandleExecute(w http.ResponseWriter, r *http.Request) {
@@ -29,11 +92,23 @@ func (h *Handler) HandleExecute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp, err := h.client.Execute(r.Context(), req)
+	// A client that disconnects stops waiting for a slot, but a started
+	// session must outlive this request.
+	resp, err := h.client.Execute(sdk.DetachSession(r.Context()), req)
 	if err != nil {
 		status := http.StatusInternalServerError
-		if errors.Is(err, sdk.ErrPromptRequired) || errors.Is(err, executor.ErrUnknownExecutorType) {
+		if errors.Is(err, sdk.ErrPromptRequired) || errors.Is(err, executor.ErrUnknownExecutorType) ||
+			errors.Is(err, sdk.ErrContextFileNotAllowed) || errors.Is(err, sdk.ErrContextFileTooLarge) ||
+			errors.Is(err, executor.ErrInvalidOption) || errors.Is(err, sdk.ErrInvalidWorkingDir) {
 			status = http.StatusBadRequest
+		} else if errors.Is(err, executor.ErrTooManySessions) {
+			status = http.StatusTooManyRequests
+		} else if errors.Is(err, sdk.ErrWorkingDirNotAllowed) || errors.Is(err, sdk.ErrExecutorNotAllowed) {
+			status = http.StatusForbidden
+		} else if errors.Is(err, sdk.ErrWorkingDirBusy) || errors.Is(err, sdk.ErrSessionExists) {
+			status = http.StatusConflict
+		} else if errors.Is(err, executor.ErrExecutorNotInstalled) {
+			status = http.StatusFailedDependency
 		}
 		http.Error(w, err.Error(), status)
 		return
@@ -45,6 +120,9 @@ func (h *Handler) HandleExecute(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) HandleContinue(w http.ResponseWriter, r *http.Request) {
 	sessionID := mux.Vars(r)["session_id"]
+	if !h.authorize(w, r, sessionID) {
+		return
+	}
 
 	var req ContinueRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -52,12 +130,18 @@ func (h *Handler) HandleContinue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.client.ContinueTask(r.Context(), sessionID, req.Message); err != nil {
+	if err := h.client.ContinueTaskWithOptions(r.Context(), sessionID, req); err != nil {
 		status := http.StatusInternalServerError
 		if errors.Is(err, executor.ErrSessionNotFound) {
 			status = http.StatusNotFound
-		} else if errors.Is(err, sdk.ErrResumeUnavailable) {
+		} else if errors.Is(err, sdk.ErrInvalidWorkingDir) {
+			status = http.StatusBadRequest
+		} else if errors.Is(err, sdk.ErrWorkingDirNotAllowed) || errors.Is(err, sdk.ErrExecutorNotAllowed) {
+			status = http.StatusForbidden
+		} else if errors.Is(err, sdk.ErrResumeUnavailable) || errors.Is(err, sdk.ErrWorkingDirBusy) {
 			status = http.StatusConflict
+		} else if errors.Is(err, executor.ErrTooManySessions) {
+			status = http.StatusTooManyRequests
 		}
 		http.Error(w, fmt.Sprintf("failed to continue: %v", err), status)
 		return
@@ -69,6 +153,9 @@ func (h *Handler) HandleContinue(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) HandleInterrupt(w http.ResponseWriter, r *http.Request) {
 	sessionID := mux.Vars(r)["session_id"]
+	if !h.authorize(w, r, sessionID) {
+		return
+	}
 
 	if err := h.client.PauseTask(sessionID); err != nil {
 		status := http.StatusInternalServerError
@@ -85,17 +172,16 @@ func (h *Handler) HandleInterrupt(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) HandleControl(w http.ResponseWriter, r *http.Request) {
 	sessionID := mux.Vars(r)["session_id"]
+	if !h.authorize(w, r, sessionID) {
+		return
+	}
 	var req ControlResponse
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
 		return
 	}
-	if req.RequestID == "" {
-		http.Error(w, "request_id is required", http.StatusBadRequest)
-		return
-	}
-	if req.Decision != executor.ControlDecisionApprove && req.Decision != executor.ControlDecisionDeny {
-		http.Error(w, "decision must be approve or deny", http.StatusBadRequest)
+	if err := validateControlResponse(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -112,6 +198,16 @@ func (h *Handler) HandleControl(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
 }
 
+func validateControlResponse(req ControlResponse) error {
+	if req.RequestID == "" {
+		return errors.New("request_id is required")
+	}
+	if req.Decision != executor.ControlDecisionApprove && req.Decision != executor.ControlDecisionDeny {
+		return errors.New("decision must be approve or deny")
+	}
+	return nil
+}
+
 func (h *Handler) HandleStream(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if err := recover(); err != nil {
@@ -120,8 +216,19 @@ func (h *Handler) HandleStream(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	sessionID := mux.Vars(r)["session_id"]
+	if !h.authorize(w, r, sessionID) {
+		return
+	}
 	debugEnabled, _ := strconv.ParseBool(r.URL.Query().Get("debug"))
 	returnAll, _ := strconv.ParseBool(r.URL.Query().Get("return_all"))
+	view, ok := h.streamView(w, r)
+	if !ok {
+		return
+	}
+	flushInterval, ok := streamFlushInterval(w, r)
+	if !ok {
+		return
+	}
 
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
@@ -137,56 +244,286 @@ func (h *Handler) HandleStream(w http.ResponseWriter, r *http.Request) {
 	events, unsubscribe := h.client.Subscribe(sessionID, executor.SubscribeOptions{
 		ReturnAll:    returnAll,
 		IncludeDebug: debugEnabled,
+		Categories:   splitCommaList(r.URL.Query().Get("categories")),
+		View:         view,
 	})
 	defer unsubscribe()
 
+	sse := newSSEWriter(w, flusher)
+	// With a flush interval, buffered frames wait for the timer, which is
+	// armed by the first frame after a flush.
+	var timer *time.Timer
+	var timerC <-chan time.Time
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	flush := func() error {
+		if timerC != nil && !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timerC = nil
+		return sse.Flush()
+	}
+
 	for {
 		select {
 		case evt, ok := <-events:
 			if !ok {
+				_ = flush()
 				return
 			}
 
-			data, _ := json.Marshal(evt)
-			_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", evt.Type, data)
-			flusher.Flush()
+			if err := sse.WriteEvent(evt); err != nil {
+				return
+			}
+			// Terminal events and events the client has to act on always
+			// flush. Otherwise flush at frame boundaries once no further
+			// event is queued, so a burst goes out together, or when the
+			// flush interval ends.
+			terminal := evt.Type == "done" || evt.Type == sdk.EventTypeDeleted
+			urgent := terminal || evt.Type == "approval" || evt.Type == "error"
+			switch {
+			case urgent || (flushInterval == 0 && len(events) == 0):
+				if err := flush(); err != nil {
+					return
+				}
+			case flushInterval > 0 && timerC == nil:
+				if timer == nil {
+					timer = time.NewTimer(flushInterval)
+				} else {
+					timer.Reset(flushInterval)
+				}
+				timerC = timer.C
+			}
 
-			if evt.Type == "done" {
+			if terminal {
+				return
+			}
+		case <-timerC:
+			timerC = nil
+			if err := sse.Flush(); err != nil {
 				return
 			}
+		case <-h.streamsClosed:
+			// The server is shutting down; end the stream like a finished
+			// session so clients do not wait on a dropped connection.
+			if err := sse.WriteEvent(executor.Event{SessionID: sessionID, Timestamp: time.Now(), Type: "done", Content: map[string]any{}}); err == nil {
+				_ = flush()
+			}
+			return
 		case <-r.Context().Done():
 			return
 		}
 	}
 }
 
+// streamFlushInterval returns the ?flush_interval_ms= query parameter,
+// writing 400 when it is not a non-negative integer. Zero flushes per burst.
+func streamFlushInterval(w http.ResponseWriter, r *http.Request) (time.Duration, bool) {
+	value := r.URL.Query().Get("flush_interval_ms")
+	if value == "" {
+		return 0, true
+	}
+	ms, err := strconv.Atoi(value)
+	if err != nil || ms < 0 {
+		http.Error(w, "flush_interval_ms must be a non-negative integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// streamView returns the ?view= query parameter, writing 400 when it names a
+// view the client does not know.
+func (h *Handler) streamView(w http.ResponseWriter, r *http.Request) (string, bool) {
+	view := r.URL.Query().Get("view")
+	if view != "" && !h.client.HasView(view) {
+		http.Error(w, fmt.Sprintf("unknown view %q", view), http.StatusBadRequest)
+		return "", false
+	}
+	return view, true
+}
+
 func (h *Handler) HandleEvents(w http.ResponseWriter, r *http.Request) {
 	sessionID := mux.Vars(r)["session_id"]
+	if !h.authorize(w, r, sessionID) {
+		return
+	}
 
 	afterSeq, err := strconv.ParseUint(r.URL.Query().Get("after_seq"), 10, 64)
 	if err != nil {
 		afterSeq = 0
 	}
+	untilSeq, err := strconv.ParseUint(r.URL.Query().Get("until_seq"), 10, 64)
+	if err != nil {
+		untilSeq = 0
+	}
 	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = h.maxEventsPage
+	}
+	typesParam := r.URL.Query().Get("types")
+	if typesParam == "" {
+		typesParam = r.URL.Query().Get("type")
+	}
+	// tail returns the latest events instead of a page and overrides
+	// after_seq; it is capped like limit.
+	tail, err := strconv.Atoi(r.URL.Query().Get("tail"))
+	if err != nil || tail < 0 {
+		tail = 0
+	}
+	tail = min(tail, h.maxEventsPage)
+
+	opts := store.ListOptions{
+		AfterSeq: afterSeq,
+		UntilSeq: untilSeq,
+		Limit:    limit + 1,
+		Types:    splitCommaList(typesParam),
+		Tail:     tail,
+	}
+	events, err := h.client.ListEventsWithOptions(r.Context(), sessionID, opts)
 	if err != nil {
-		limit = 0
+		http.Error(w, fmt.Sprintf("failed to list events: %v", err), http.StatusInternalServerError)
+		return
+	}
+	// One extra event is fetched to tell whether another page exists. A
+	// tail ends at the latest event, so nothing follows it.
+	hasMore := tail == 0 && len(events) > limit
+	if hasMore {
+		events = events[:limit]
+	}
+	if tail > 0 {
+		afterSeq = 0
+	}
+
+	// next_seq is the after_seq to pass for the following page.
+	nextSeq := afterSeq
+	if len(events) > 0 {
+		nextSeq = events[len(events)-1].Seq
 	}
 
-	events, err := h.client.ListEvents(r.Context(), sessionID, afterSeq, limit)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"session_id": sessionID,
+		"events":     events,
+		"next_seq":   nextSeq,
+		"has_more":   hasMore,
+	})
+}
+
+// HandlePollEvents is a long-polling alternative to HandleStream for clients
+// that can use neither SSE nor WebSockets. It returns the stored events after
+// after_seq right away when there are any. Otherwise it waits up to wait
+// (e.g. "10s", capped at MaxPollWait) for the session to store a new event
+// and returns what is stored by then, which may be nothing. next_seq is the
+// after_seq of the following poll.
+func (h *Handler) HandlePollEvents(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["session_id"]
+	if !h.authorize(w, r, sessionID) {
+		return
+	}
+
+	afterSeq, err := strconv.ParseUint(r.URL.Query().Get("after_seq"), 10, 64)
+	if err != nil {
+		afterSeq = 0
+	}
+	var wait time.Duration
+	if value := r.URL.Query().Get("wait"); value != "" {
+		wait, err = time.ParseDuration(value)
+		if err != nil || wait < 0 {
+			http.Error(w, "wait must be a non-negative duration such as 10s", http.StatusBadRequest)
+			return
+		}
+		wait = min(wait, MaxPollWait)
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > h.maxEventsPage {
+		limit = h.maxEventsPage
+	}
+	opts := store.ListOptions{
+		AfterSeq: afterSeq,
+		Limit:    limit + 1,
+		Types:    splitCommaList(r.URL.Query().Get("types")),
+	}
+
+	// Subscribe before listing, so an event stored in between still ends
+	// the wait.
+	live, unsubscribe := h.client.Subscribe(sessionID, executor.SubscribeOptions{IncludeDebug: true})
+	defer unsubscribe()
+
+	events, err := h.client.ListEventsWithOptions(r.Context(), sessionID, opts)
+	if err == nil && len(events) == 0 && wait > 0 && h.client.SessionRunning(sessionID) {
+		timer := time.NewTimer(wait)
+		select {
+		case <-live:
+		case <-timer.C:
+		case <-h.streamsClosed:
+		case <-r.Context().Done():
+		}
+		timer.Stop()
+		events, err = h.client.ListEventsWithOptions(r.Context(), sessionID, opts)
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("failed to list events: %v", err), http.StatusInternalServerError)
 		return
 	}
+	hasMore := len(events) > limit
+	if hasMore {
+		events = events[:limit]
+	}
+	nextSeq := afterSeq
+	if len(events) > 0 {
+		nextSeq = events[len(events)-1].Seq
+	} else {
+		events = []executor.Event{}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]any{
 		"session_id": sessionID,
 		"events":     events,
+		"next_seq":   nextSeq,
+		"has_more":   hasMore,
 	})
 }
 
+func splitCommaList(value string) []string {
+	var out []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
 func (h *Handler) HandleSessions(w http.ResponseWriter, r *http.Request) {
-	sessions := h.client.ListSessions(r.Context())
+	opts := sdk.ListSessionsOptions{Kind: r.URL.Query().Get("kind")}
+	for _, label := range r.URL.Query()["label"] {
+		key, value, ok := strings.Cut(label, "=")
+		if !ok || key == "" {
+			http.Error(w, fmt.Sprintf("invalid label filter %q: want key=value", label), http.StatusBadRequest)
+			return
+		}
+		if opts.Labels == nil {
+			opts.Labels = make(map[string]string)
+		}
+		opts.Labels[key] = value
+	}
+	sessions := h.client.ListSessionsWithOptions(r.Context(), opts)
+	if h.authorizer != nil {
+		visible := sessions[:0]
+		for _, session := range sessions {
+			if h.authorizer(r.Context(), session.SessionID) {
+				visible = append(visible, session)
+			}
+		}
+		sessions = visible
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]any{
@@ -194,6 +531,97 @@ func (h *Handler) HandleSessions(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// HandleSession returns one session summary, or 404 when it is unknown.
+func (h *Handler) HandleSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["session_id"]
+	if !h.authorize(w, r, sessionID) {
+		return
+	}
+
+	session, ok := h.client.GetSession(sessionID)
+	if !ok {
+		http.Error(w, executor.ErrSessionNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	resp := SessionResponse{Session: session}
+	if cost, ok := h.client.SessionCost(sessionID); ok {
+		resp.Cost = &cost
+	}
+	if upstream, ok := h.client.ResumeState(sessionID); ok {
+		resp.Upstream = &upstream
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// HandleSessionMetrics returns the metrics recorded when a session ended,
+// 404 for unknown sessions and 409 while the session is still running.
+func (h *Handler) HandleSessionMetrics(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["session_id"]
+	if !h.authorize(w, r, sessionID) {
+		return
+	}
+
+	metrics, err := h.client.SessionMetrics(sessionID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, executor.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		} else if errors.Is(err, sdk.ErrMetricsUnavailable) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(metrics)
+}
+
+// HandleSessionTools returns the distinct tool names a session used, or 404
+// when it is unknown.
+func (h *Handler) HandleSessionTools(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["session_id"]
+	if !h.authorize(w, r, sessionID) {
+		return
+	}
+
+	tools, err := h.client.SessionTools(r.Context(), sessionID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, executor.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"tools": tools,
+	})
+}
+
+// HandleDeleteSession stops and deletes a session, responding 204, or 404
+// when it is unknown.
+func (h *Handler) HandleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["session_id"]
+	if !h.authorize(w, r, sessionID) {
+		return
+	}
+
+	if err := h.client.DeleteSession(r.Context(), sessionID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, executor.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // HandleExecutors returns the list of available executors
 func (h *Handler) HandleExecutors(w http.ResponseWriter, r *http.Request) {
 	executorsList := h.client.Executors()
@@ -203,3 +631,29 @@ func (h *Handler) HandleExecutors(w http.ResponseWriter, r *http.Request) {
 		"executors": executorsList,
 	})
 }
+
+// HandleExecutorModels lists the model names an executor accepts, or
+// responds 404 when the executor is not registered and 501 when it has no
+// model list.
+func (h *Handler) HandleExecutorModels(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	models, err := h.client.Models(r.Context(), name)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, executor.ErrUnknownExecutorType) {
+			status = http.StatusNotFound
+		} else if errors.Is(err, sdk.ErrExecutorNotAllowed) {
+			status = http.StatusForbidden
+		} else if errors.Is(err, executor.ErrModelsUnavailable) {
+			status = http.StatusNotImplemented
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"models": models,
+	})
+}
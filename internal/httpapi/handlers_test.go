@@ -1,18 +1,26 @@
 package httpapi
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os/exec"
+	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/supremeagent/executor/pkg/executor"
+	"github.com/supremeagent/executor/pkg/executor/droid"
 	"github.com/supremeagent/executor/pkg/sdk"
 	"github.com/supremeagent/executor/pkg/store"
 	"github.com/supremeagent/executor/pkg/streaming"
@@ -73,6 +81,36 @@ func TestHandlers(t *testing.T) {
 		}
 	})
 
+	t.Run("HandleExecute_WithToolLists", func(t *testing.T) {
+		capture := &mockExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
+		registry.Register("capture_tools", executor.FactoryFunc(func() (executor.Executor, error) { return capture, nil }))
+
+		reqBody, _ := json.Marshal(ExecuteRequest{
+			Prompt:             "hello",
+			Executor:           "capture_tools",
+			AllowedTools:       []string{"mcp__github__*"},
+			ClaudeAllowedTools: []string{"Read", "Grep"},
+			DisallowedTools:    []string{"Edit", "Bash"},
+		})
+		req, _ := http.NewRequest(http.MethodPost, "/execute", bytes.NewBuffer(reqBody))
+		rr := httptest.NewRecorder()
+
+		handler.HandleExecute(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rr.Code)
+		}
+		if strings.Join(capture.lastOpts.ApprovalPolicy.AllowedTools, ",") != "mcp__github__*" {
+			t.Fatalf("expected allowed_tools in the approval policy, got %v", capture.lastOpts.ApprovalPolicy.AllowedTools)
+		}
+		if strings.Join(capture.lastOpts.AllowedTools, ",") != "Read,Grep" {
+			t.Fatalf("expected only claude_allowed_tools to reach the CLI, got %v", capture.lastOpts.AllowedTools)
+		}
+		if strings.Join(capture.lastOpts.DisallowedTools, ",") != "Edit,Bash" {
+			t.Fatalf("expected disallowed tools in executor options, got %v", capture.lastOpts.DisallowedTools)
+		}
+	})
+
 	t.Run("HandleContinue", func(t *testing.T) {
 		sessionID := "test-session-continue"
 		_, _ = registry.CreateSession(sessionID, string(executor.ExecutorClaudeCode), executor.Options{})
@@ -238,6 +276,32 @@ func TestHandlers(t *testing.T) {
 		}
 	})
 
+	t.Run("HandleExecute_InvalidOption", func(t *testing.T) {
+		registry.Register("validating_executor", executor.FactoryFunc(func() (executor.Executor, error) {
+			return &mockValidatingExecutor{mockExecutor: mockExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}}, nil
+		}))
+		reqBody, _ := json.Marshal(ExecuteRequest{Prompt: "hello", Executor: "validating_executor", Sandbox: "everything"})
+		req, _ := http.NewRequest(http.MethodPost, "/execute", bytes.NewBuffer(reqBody))
+		rr := httptest.NewRecorder()
+		handler.HandleExecute(rr, req)
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "sandbox") {
+			t.Fatalf("expected 400 naming the field, got %d: %s", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("HandleExecute_NotInstalled", func(t *testing.T) {
+		registry.Register("missing_cli", executor.FactoryFunc(func() (executor.Executor, error) {
+			return droid.NewClient(func(string, ...string) *exec.Cmd { return exec.Command("/nonexistent/droid") }), nil
+		}))
+		reqBody, _ := json.Marshal(ExecuteRequest{Prompt: "hello", Executor: "missing_cli"})
+		req, _ := http.NewRequest(http.MethodPost, "/execute", bytes.NewBuffer(reqBody))
+		rr := httptest.NewRecorder()
+		handler.HandleExecute(rr, req)
+		if rr.Code != http.StatusFailedDependency {
+			t.Fatalf("expected 424, got %d: %s", rr.Code, rr.Body.String())
+		}
+	})
+
 	t.Run("HandleContinue_NotFound", func(t *testing.T) {
 		reqBody, _ := json.Marshal(ContinueRequest{Message: "hello"})
 		req, _ := http.NewRequest(http.MethodPost, "/continue/not-found", bytes.NewBuffer(reqBody))
@@ -275,6 +339,32 @@ func TestHandlers(t *testing.T) {
 		}
 	})
 
+	t.Run("HandleExecute_SuppliedSessionID", func(t *testing.T) {
+		execute := func(id string) *httptest.ResponseRecorder {
+			reqBody, _ := json.Marshal(ExecuteRequest{SessionID: id, Prompt: "hello", Executor: executor.ExecutorCodex})
+			req, _ := http.NewRequest(http.MethodPost, "/execute", bytes.NewBuffer(reqBody))
+			rr := httptest.NewRecorder()
+			handler.HandleExecute(rr, req)
+			return rr
+		}
+
+		rr := execute("ticket-42")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var resp ExecuteResponse
+		_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+		if resp.SessionID != "ticket-42" {
+			t.Fatalf("expected the supplied session id, got %q", resp.SessionID)
+		}
+		if rr := execute("ticket-42"); rr.Code != http.StatusConflict {
+			t.Fatalf("expected 409 for a duplicate session id, got %d", rr.Code)
+		}
+		if rr := execute("../escape"); rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for an invalid session id, got %d", rr.Code)
+		}
+	})
+
 	t.Run("HandleInterrupt_NotFound", func(t *testing.T) {
 		req, _ := http.NewRequest(http.MethodPost, "/interrupt/not-found", nil)
 		req = mux.SetURLVars(req, map[string]string{"session_id": "not-found"})
@@ -332,6 +422,223 @@ func TestHandlers(t *testing.T) {
 		}
 	})
 
+	t.Run("HandleStream_CompactView", func(t *testing.T) {
+		sessionID := "test-session-stream-view"
+		_, _ = store.Append(context.Background(), executor.Event{
+			SessionID:  sessionID,
+			Type:       "tool",
+			Normalized: true,
+			Content: executor.UnifiedContent{
+				Category: "tool",
+				Summary:  "Reading handler.go",
+				Raw:      map[string]any{"secret": "payload"},
+			},
+		})
+
+		req, _ := http.NewRequest(http.MethodGet, "/stream/"+sessionID+"?return_all=true&view=compact", nil)
+		req = mux.SetURLVars(req, map[string]string{"session_id": sessionID})
+		rr := httptest.NewRecorder()
+		handler.HandleStream(rr, req)
+
+		body := rr.Body.String()
+		if !strings.Contains(body, `"summary":"Reading handler.go"`) || strings.Contains(body, `"raw"`) {
+			t.Fatalf("expected compact view to keep summary and drop raw, got %s", body)
+		}
+
+		req, _ = http.NewRequest(http.MethodGet, "/stream/"+sessionID+"?return_all=true&view=full", nil)
+		req = mux.SetURLVars(req, map[string]string{"session_id": sessionID})
+		rr = httptest.NewRecorder()
+		handler.HandleStream(rr, req)
+		if !strings.Contains(rr.Body.String(), `"raw"`) {
+			t.Fatalf("expected full view to keep raw, got %s", rr.Body.String())
+		}
+
+		req, _ = http.NewRequest(http.MethodGet, "/stream/"+sessionID+"?view=bogus", nil)
+		req = mux.SetURLVars(req, map[string]string{"session_id": sessionID})
+		rr = httptest.NewRecorder()
+		handler.HandleStream(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for unknown view, got %d", rr.Code)
+		}
+	})
+
+	t.Run("HandleStream_FlushInterval", func(t *testing.T) {
+		stream := func(sessionID, query string) *flushCountingRecorder {
+			_, _ = registry.CreateSession(sessionID, string(executor.ExecutorClaudeCode), executor.Options{})
+			req, _ := http.NewRequest(http.MethodGet, "/stream/"+sessionID+query, nil)
+			req = mux.SetURLVars(req, map[string]string{"session_id": sessionID})
+			rr := &flushCountingRecorder{ResponseRecorder: httptest.NewRecorder()}
+
+			finished := make(chan struct{})
+			go func() {
+				defer close(finished)
+				handler.HandleStream(rr, req)
+			}()
+			time.Sleep(50 * time.Millisecond)
+			for i := 0; i < 20; i++ {
+				sseMgr.AppendLog(sessionID, streaming.LogEntry{Type: "progress", Content: fmt.Sprintf("delta %d", i)})
+				time.Sleep(2 * time.Millisecond)
+			}
+			sseMgr.AppendLog(sessionID, streaming.LogEntry{Type: "done", Content: "done"})
+			<-finished
+			return rr
+		}
+
+		perEvent := stream("test-session-stream-flush-default", "")
+		batched := stream("test-session-stream-flush-batched", "?flush_interval_ms=1000")
+		if got := strings.Count(batched.Body.String(), "event: progress"); got != 20 {
+			t.Fatalf("expected 20 batched progress frames, got %d", got)
+		}
+		if batched.flushes > 2 || batched.flushes >= perEvent.flushes {
+			t.Fatalf("expected batching to reduce flushes, got %d batched vs %d per event", batched.flushes, perEvent.flushes)
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, "/stream/test-session-stream-flush-batched?flush_interval_ms=-1", nil)
+		req = mux.SetURLVars(req, map[string]string{"session_id": "test-session-stream-flush-batched"})
+		rr := httptest.NewRecorder()
+		handler.HandleStream(rr, req)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for negative flush interval, got %d", rr.Code)
+		}
+	})
+
+	t.Run("HandleExport_Gzip", func(t *testing.T) {
+		sessionID := "test-session-export"
+		total := exportBatchSize + 3
+		for i := 0; i < total; i++ {
+			_, _ = store.Append(context.Background(), executor.Event{SessionID: sessionID, Type: "stdout", Content: fmt.Sprintf("line %d", i)})
+		}
+
+		req, _ := http.NewRequest(http.MethodGet, "/export/"+sessionID, nil)
+		req.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+		req = mux.SetURLVars(req, map[string]string{"session_id": sessionID})
+		rr := httptest.NewRecorder()
+		handler.HandleExport(rr, req)
+		if rr.Code != http.StatusOK || rr.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("expected gzip response, got %d %v", rr.Code, rr.Header())
+		}
+
+		gz, err := gzip.NewReader(rr.Body)
+		if err != nil {
+			t.Fatalf("invalid gzip stream: %v", err)
+		}
+		scanner := bufio.NewScanner(gz)
+		var count int
+		for scanner.Scan() {
+			var evt executor.Event
+			if err := json.Unmarshal(scanner.Bytes(), &evt); err != nil {
+				t.Fatalf("invalid NDJSON line %q: %v", scanner.Text(), err)
+			}
+			count++
+			if evt.Seq != uint64(count) {
+				t.Fatalf("expected seq %d, got %d", count, evt.Seq)
+			}
+		}
+		if count != total {
+			t.Fatalf("expected %d events, got %d", total, count)
+		}
+
+		plain := httptest.NewRecorder()
+		req.Header.Set("Accept-Encoding", "gzip;q=0")
+		handler.HandleExport(plain, req)
+		if plain.Header().Get("Content-Encoding") != "" || strings.Count(plain.Body.String(), "\n") != total {
+			t.Fatalf("expected uncompressed NDJSON when gzip is refused")
+		}
+
+		missing, _ := http.NewRequest(http.MethodGet, "/export/unknown-session", nil)
+		missing = mux.SetURLVars(missing, map[string]string{"session_id": "unknown-session"})
+		rr = httptest.NewRecorder()
+		handler.HandleExport(rr, missing)
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("expected 404 for an unknown session, got %d", rr.Code)
+		}
+	})
+
+	t.Run("HandleEvents_DefaultMaxAndHasMore", func(t *testing.T) {
+		sessionID := "test-session-events-huge"
+		total := DefaultMaxEventsPerPage + 5
+		for i := 0; i < total; i++ {
+			_, _ = store.Append(context.Background(), executor.Event{SessionID: sessionID, Type: "stdout"})
+		}
+
+		fetch := func(query string) (count int, nextSeq uint64, hasMore bool) {
+			req, _ := http.NewRequest(http.MethodGet, "/events/"+sessionID+"?"+query, nil)
+			req = mux.SetURLVars(req, map[string]string{"session_id": sessionID})
+			rr := httptest.NewRecorder()
+			handler.HandleEvents(rr, req)
+			var resp struct {
+				Events  []executor.Event `json:"events"`
+				NextSeq uint64           `json:"next_seq"`
+				HasMore bool             `json:"has_more"`
+			}
+			_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+			return len(resp.Events), resp.NextSeq, resp.HasMore
+		}
+
+		count, next, more := fetch("")
+		if count != DefaultMaxEventsPerPage || !more || next != uint64(DefaultMaxEventsPerPage) {
+			t.Fatalf("expected first page capped at default, got count=%d next=%d more=%v", count, next, more)
+		}
+		count, next, more = fetch(fmt.Sprintf("after_seq=%d", next))
+		if count != 5 || more || next != uint64(total) {
+			t.Fatalf("expected remaining events on second page, got count=%d next=%d more=%v", count, next, more)
+		}
+	})
+
+	t.Run("HandleEvents_TypesAndCursor", func(t *testing.T) {
+		sessionID := "test-session-events-page"
+		for _, typ := range []string{"stdout", "tool", "message", "error", "tool", "done"} {
+			_, _ = store.Append(context.Background(), executor.Event{SessionID: sessionID, Type: typ})
+		}
+
+		fetch := func(query string) (seqs []uint64, nextSeq uint64) {
+			req, _ := http.NewRequest(http.MethodGet, "/events/"+sessionID+"?"+query, nil)
+			req = mux.SetURLVars(req, map[string]string{"session_id": sessionID})
+			rr := httptest.NewRecorder()
+			handler.HandleEvents(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", rr.Code)
+			}
+			var resp struct {
+				Events  []executor.Event `json:"events"`
+				NextSeq uint64           `json:"next_seq"`
+			}
+			_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+			for _, evt := range resp.Events {
+				if evt.Type != "tool" && evt.Type != "error" && strings.Contains(query, "type") {
+					t.Fatalf("unexpected event type %q for %s", evt.Type, query)
+				}
+				seqs = append(seqs, evt.Seq)
+			}
+			return seqs, resp.NextSeq
+		}
+
+		seqs, next := fetch("types=tool,error&limit=2")
+		if fmt.Sprint(seqs) != "[2 4]" || next != 4 {
+			t.Fatalf("unexpected first page %v next=%d", seqs, next)
+		}
+		seqs, next = fetch(fmt.Sprintf("type=tool,error&limit=2&after_seq=%d", next))
+		if fmt.Sprint(seqs) != "[5]" || next != 5 {
+			t.Fatalf("unexpected second page %v next=%d", seqs, next)
+		}
+		seqs, next = fetch("types=tool,error&after_seq=5")
+		if len(seqs) != 0 || next != 5 {
+			t.Fatalf("expected empty last page keeping cursor, got %v next=%d", seqs, next)
+		}
+		seqs, _ = fetch("after_seq=1&until_seq=3")
+		if fmt.Sprint(seqs) != "[2 3]" {
+			t.Fatalf("unexpected until_seq window %v", seqs)
+		}
+		seqs, next = fetch("tail=2")
+		if fmt.Sprint(seqs) != "[5 6]" || next != 6 {
+			t.Fatalf("unexpected tail %v next=%d", seqs, next)
+		}
+		seqs, _ = fetch("types=tool,error&tail=50&after_seq=4")
+		if fmt.Sprint(seqs) != "[2 4 5]" {
+			t.Fatalf("expected tail to cover every filtered event and ignore after_seq, got %v", seqs)
+		}
+	})
+
 	t.Run("HandleSessions", func(t *testing.T) {
 		reqBody, _ := json.Marshal(ExecuteRequest{
 			Prompt:   "session list test",
@@ -357,6 +664,284 @@ func TestHandlers(t *testing.T) {
 		}
 	})
 
+	t.Run("HandleSession", func(t *testing.T) {
+		reqBody, _ := json.Marshal(ExecuteRequest{Prompt: "single session", Executor: executor.ExecutorClaudeCode})
+		rrExec := httptest.NewRecorder()
+		handler.HandleExecute(rrExec, httptest.NewRequest(http.MethodPost, "/execute", bytes.NewBuffer(reqBody)))
+		var execResp executor.ExecuteResponse
+		if err := json.Unmarshal(rrExec.Body.Bytes(), &execResp); err != nil {
+			t.Fatalf("decode execute response: %v", err)
+		}
+
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/sessions/"+execResp.SessionID, nil), map[string]string{"session_id": execResp.SessionID})
+		rr := httptest.NewRecorder()
+		handler.HandleSession(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rr.Code)
+		}
+		var session executor.Session
+		if err := json.Unmarshal(rr.Body.Bytes(), &session); err != nil {
+			t.Fatalf("decode session: %v", err)
+		}
+		if session.SessionID != execResp.SessionID || session.Title != "single session" || session.EventCount == 0 || session.LastEventType == "" {
+			t.Fatalf("unexpected session payload: %s", rr.Body.String())
+		}
+
+		req = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/sessions/missing", nil), map[string]string{"session_id": "missing"})
+		rr = httptest.NewRecorder()
+		handler.HandleSession(rr, req)
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("expected 404 for unknown session, got %d", rr.Code)
+		}
+	})
+
+	t.Run("HandleSession_Upstream", func(t *testing.T) {
+		registry.Register("resume_executor", executor.FactoryFunc(func() (executor.Executor, error) {
+			return &mockResumeExecutor{mockExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}}, nil
+		}))
+		reqBody, _ := json.Marshal(ExecuteRequest{Prompt: "correlate me", Executor: "resume_executor"})
+		rrExec := httptest.NewRecorder()
+		handler.HandleExecute(rrExec, httptest.NewRequest(http.MethodPost, "/execute", bytes.NewBuffer(reqBody)))
+		var execResp executor.ExecuteResponse
+		if err := json.Unmarshal(rrExec.Body.Bytes(), &execResp); err != nil {
+			t.Fatalf("decode execute response: %v", err)
+		}
+		_ = client.WaitContext(context.Background(), execResp.SessionID)
+
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/sessions/"+execResp.SessionID, nil), map[string]string{"session_id": execResp.SessionID})
+		rr := httptest.NewRecorder()
+		handler.HandleSession(rr, req)
+		var resp SessionResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode session: %v", err)
+		}
+		if resp.SessionID != execResp.SessionID || resp.Upstream == nil || resp.Upstream.ID != "vendor-1" {
+			t.Fatalf("expected the upstream session id, got: %s", rr.Body.String())
+		}
+		if resp.Cost != nil {
+			t.Fatalf("expected no cost estimate without usage, got: %s", rr.Body.String())
+		}
+	})
+
+	t.Run("HandleTranscript", func(t *testing.T) {
+		reqBody, _ := json.Marshal(ExecuteRequest{Prompt: "share me", Executor: executor.ExecutorClaudeCode})
+		rrExec := httptest.NewRecorder()
+		handler.HandleExecute(rrExec, httptest.NewRequest(http.MethodPost, "/execute", bytes.NewBuffer(reqBody)))
+		var execResp executor.ExecuteResponse
+		if err := json.Unmarshal(rrExec.Body.Bytes(), &execResp); err != nil {
+			t.Fatalf("decode execute response: %v", err)
+		}
+
+		get := func(sessionID, query string) *httptest.ResponseRecorder {
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/sessions/"+sessionID+"/transcript"+query, nil), map[string]string{"session_id": sessionID})
+			rr := httptest.NewRecorder()
+			handler.HandleTranscript(rr, req)
+			return rr
+		}
+
+		rr := get(execResp.SessionID, "")
+		var transcript sdk.Transcript
+		if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/json" {
+			t.Fatalf("expected JSON transcript, got %d %v", rr.Code, rr.Header())
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &transcript); err != nil || transcript.Prompt != "share me" || len(transcript.Events) == 0 {
+			t.Fatalf("unexpected JSON transcript (%v): %s", err, rr.Body.String())
+		}
+
+		rr = get(execResp.SessionID, "?format=md")
+		if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/markdown") || !strings.Contains(rr.Body.String(), "## Prompt\n\nshare me") {
+			t.Fatalf("unexpected Markdown transcript %d: %s", rr.Code, rr.Body.String())
+		}
+
+		if rr := get(execResp.SessionID, "?format=pdf"); rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for unknown format, got %d", rr.Code)
+		}
+		if rr := get("missing", ""); rr.Code != http.StatusNotFound {
+			t.Fatalf("expected 404 for unknown session, got %d", rr.Code)
+		}
+	})
+
+	t.Run("HandleSessionMetrics", func(t *testing.T) {
+		reqBody, _ := json.Marshal(ExecuteRequest{Prompt: "measure me", Executor: executor.ExecutorClaudeCode})
+		rrExec := httptest.NewRecorder()
+		handler.HandleExecute(rrExec, httptest.NewRequest(http.MethodPost, "/execute", bytes.NewBuffer(reqBody)))
+		var execResp executor.ExecuteResponse
+		if err := json.Unmarshal(rrExec.Body.Bytes(), &execResp); err != nil {
+			t.Fatalf("decode execute response: %v", err)
+		}
+		_ = client.WaitContext(context.Background(), execResp.SessionID)
+
+		get := func(sessionID string) *httptest.ResponseRecorder {
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/sessions/"+sessionID+"/metrics", nil), map[string]string{"session_id": sessionID})
+			rr := httptest.NewRecorder()
+			handler.HandleSessionMetrics(rr, req)
+			return rr
+		}
+
+		rr := get(execResp.SessionID)
+		var metrics executor.SessionMetrics
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &metrics); err != nil || metrics.Events != 2 || metrics.Categories["done"] != 1 {
+			t.Fatalf("unexpected metrics payload (%v): %s", err, rr.Body.String())
+		}
+		if rr := get("missing"); rr.Code != http.StatusNotFound {
+			t.Fatalf("expected 404 for unknown session, got %d", rr.Code)
+		}
+	})
+
+	t.Run("HandleSessionTools", func(t *testing.T) {
+		reqBody, _ := json.Marshal(ExecuteRequest{Prompt: "no tools", Executor: executor.ExecutorClaudeCode})
+		rrExec := httptest.NewRecorder()
+		handler.HandleExecute(rrExec, httptest.NewRequest(http.MethodPost, "/execute", bytes.NewBuffer(reqBody)))
+		var execResp executor.ExecuteResponse
+		if err := json.Unmarshal(rrExec.Body.Bytes(), &execResp); err != nil {
+			t.Fatalf("decode execute response: %v", err)
+		}
+		_ = client.WaitContext(context.Background(), execResp.SessionID)
+
+		get := func(sessionID string) *httptest.ResponseRecorder {
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/sessions/"+sessionID+"/tools", nil), map[string]string{"session_id": sessionID})
+			rr := httptest.NewRecorder()
+			handler.HandleSessionTools(rr, req)
+			return rr
+		}
+
+		rr := get(execResp.SessionID)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if body := strings.TrimSpace(rr.Body.String()); body != `{"tools":[]}` {
+			t.Fatalf("expected an empty tool list, got %s", body)
+		}
+		if rr := get("missing"); rr.Code != http.StatusNotFound {
+			t.Fatalf("expected 404 for unknown session, got %d", rr.Code)
+		}
+	})
+
+	t.Run("HandleExecutorModels", func(t *testing.T) {
+		registry.Register("no_models", executor.FactoryFunc(func() (executor.Executor, error) {
+			return &mockExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}, nil
+		}))
+		get := func(name string) *httptest.ResponseRecorder {
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/executors/"+name+"/models", nil), map[string]string{"name": name})
+			rr := httptest.NewRecorder()
+			handler.HandleExecutorModels(rr, req)
+			return rr
+		}
+
+		rr := get(string(executor.ExecutorClaudeCode))
+		var resp struct {
+			Models []string `json:"models"`
+		}
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || !reflect.DeepEqual(resp.Models, executor.DefaultModels[executor.ExecutorClaudeCode]) {
+			t.Fatalf("expected the default Claude models (%v): %s", err, rr.Body.String())
+		}
+		if rr := get("no_models"); rr.Code != http.StatusNotImplemented {
+			t.Fatalf("expected 501 for an executor without models, got %d", rr.Code)
+		}
+		if rr := get("missing"); rr.Code != http.StatusNotFound {
+			t.Fatalf("expected 404 for an unknown executor, got %d", rr.Code)
+		}
+	})
+
+	t.Run("HandleDeleteSession", func(t *testing.T) {
+		reqBody, _ := json.Marshal(ExecuteRequest{Prompt: "delete me", Executor: executor.ExecutorClaudeCode})
+		rrExec := httptest.NewRecorder()
+		handler.HandleExecute(rrExec, httptest.NewRequest(http.MethodPost, "/execute", bytes.NewBuffer(reqBody)))
+		var execResp executor.ExecuteResponse
+		if err := json.Unmarshal(rrExec.Body.Bytes(), &execResp); err != nil {
+			t.Fatalf("decode execute response: %v", err)
+		}
+
+		for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api/sessions/"+execResp.SessionID, nil), map[string]string{"session_id": execResp.SessionID})
+			rr := httptest.NewRecorder()
+			handler.HandleDeleteSession(rr, req)
+			if rr.Code != want {
+				t.Fatalf("expected %d, got %d: %s", want, rr.Code, rr.Body.String())
+			}
+		}
+	})
+
+	t.Run("HandleSessions_KindFilter", func(t *testing.T) {
+		for _, kind := range []string{"review", "bugfix"} {
+			reqBody, _ := json.Marshal(ExecuteRequest{
+				Prompt:   "kind " + kind,
+				Executor: executor.ExecutorClaudeCode,
+				Kind:     kind,
+			})
+			rrExec := httptest.NewRecorder()
+			handler.HandleExecute(rrExec, httptest.NewRequest(http.MethodPost, "/execute", bytes.NewBuffer(reqBody)))
+			if rrExec.Code != http.StatusOK {
+				t.Fatalf("expected execute 200, got %d", rrExec.Code)
+			}
+		}
+
+		rr := httptest.NewRecorder()
+		handler.HandleSessions(rr, httptest.NewRequest(http.MethodGet, "/api/sessions?kind=review", nil))
+		var body struct {
+			Sessions []executor.Session `json:"sessions"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode sessions: %v", err)
+		}
+		if len(body.Sessions) != 1 || body.Sessions[0].Kind != "review" || body.Sessions[0].Title != "kind review" {
+			t.Fatalf("expected only the review session, got %+v", body.Sessions)
+		}
+	})
+
+	t.Run("HandleSessions_LabelFilter", func(t *testing.T) {
+		for _, labels := range []map[string]string{
+			{"user": "alice", "project": "web"},
+			{"user": "alice", "project": "api"},
+			{"user": "bob", "project": "web"},
+		} {
+			reqBody, _ := json.Marshal(ExecuteRequest{
+				Prompt:   "labels " + labels["user"] + " " + labels["project"],
+				Executor: executor.ExecutorClaudeCode,
+				Labels:   labels,
+			})
+			rrExec := httptest.NewRecorder()
+			handler.HandleExecute(rrExec, httptest.NewRequest(http.MethodPost, "/execute", bytes.NewBuffer(reqBody)))
+			if rrExec.Code != http.StatusOK {
+				t.Fatalf("expected execute 200, got %d", rrExec.Code)
+			}
+		}
+
+		list := func(query string) []executor.Session {
+			rr := httptest.NewRecorder()
+			handler.HandleSessions(rr, httptest.NewRequest(http.MethodGet, "/api/sessions?"+query, nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected 200 for %q, got %d: %s", query, rr.Code, rr.Body.String())
+			}
+			var body struct {
+				Sessions []executor.Session `json:"sessions"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode sessions: %v", err)
+			}
+			return body.Sessions
+		}
+
+		if sessions := list("label=user=alice"); len(sessions) != 2 || sessions[0].Labels["user"] != "alice" || sessions[1].Labels["user"] != "alice" {
+			t.Fatalf("expected alice's two sessions, got %+v", sessions)
+		}
+		if sessions := list("label=user=alice&label=project=web"); len(sessions) != 1 || sessions[0].Title != "labels alice web" {
+			t.Fatalf("expected only alice's web session, got %+v", sessions)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.HandleSessions(rr, httptest.NewRequest(http.MethodGet, "/api/sessions?label=user", nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for a label without value, got %d", rr.Code)
+		}
+	})
+
 	t.Run("HandleControl", func(t *testing.T) {
 		sessionID := "test-session-control"
 		capture := &mockExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
@@ -408,6 +993,327 @@ func TestHandlers(t *testing.T) {
 	})
 }
 
+func TestHandlersAllowedExecutors(t *testing.T) {
+	registry := executor.NewRegistry()
+	for _, name := range []executor.ExecutorType{executor.ExecutorClaudeCode, executor.ExecutorCodex} {
+		registry.Register(string(name), executor.FactoryFunc(func() (executor.Executor, error) {
+			return &mockExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}, nil
+		}))
+	}
+	client := sdk.NewWithOptions(sdk.ClientOptions{
+		Registry:         registry,
+		EventStore:       store.NewMemoryEventStore(),
+		AllowedExecutors: []executor.ExecutorType{executor.ExecutorClaudeCode},
+	})
+	handler := NewHandler(client)
+
+	rr := httptest.NewRecorder()
+	handler.HandleExecutors(rr, httptest.NewRequest(http.MethodGet, "/executors", nil))
+	var listing struct {
+		Executors []sdk.ExecutorInfo `json:"executors"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &listing)
+	if len(listing.Executors) != 1 || listing.Executors[0].Name != string(executor.ExecutorClaudeCode) {
+		t.Fatalf("expected only the allowed executor to be listed, got %+v", listing.Executors)
+	}
+
+	reqBody, _ := json.Marshal(ExecuteRequest{Prompt: "hello", Executor: executor.ExecutorCodex})
+	rr = httptest.NewRecorder()
+	handler.HandleExecute(rr, httptest.NewRequest(http.MethodPost, "/execute", bytes.NewBuffer(reqBody)))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a disallowed executor, got %d", rr.Code)
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/executors/codex/models", nil), map[string]string{"name": string(executor.ExecutorCodex)})
+	rr = httptest.NewRecorder()
+	handler.HandleExecutorModels(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a disallowed executor's models, got %d", rr.Code)
+	}
+}
+
+func TestHandleExecuteStopsWaitingOnDisconnect(t *testing.T) {
+	registry := executor.NewRegistryWithLimit(1, executor.LimitBlock)
+	var created atomic.Int32
+	registry.Register(string(executor.ExecutorClaudeCode), executor.FactoryFunc(func() (executor.Executor, error) {
+		created.Add(1)
+		// No room for done, so the first session keeps its slot.
+		return &mockExecutor{logs: make(chan executor.Log), done: make(chan struct{})}, nil
+	}))
+	client := sdk.NewWithOptions(sdk.ClientOptions{Registry: registry, EventStore: store.NewMemoryEventStore()})
+	handler := NewHandler(client)
+
+	reqBody, _ := json.Marshal(ExecuteRequest{Prompt: "hello", Executor: executor.ExecutorClaudeCode})
+	rr := httptest.NewRecorder()
+	handler.HandleExecute(rr, httptest.NewRequest(http.MethodPost, "/execute", bytes.NewBuffer(reqBody)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the first session to start, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	returned := make(chan struct{})
+	go func() {
+		defer close(returned)
+		req := httptest.NewRequest(http.MethodPost, "/execute", bytes.NewBuffer(reqBody)).WithContext(ctx)
+		handler.HandleExecute(httptest.NewRecorder(), req)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-returned:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the queued request to give up once its client disconnected")
+	}
+	if n := created.Load(); n != 1 {
+		t.Fatalf("expected no second executor, got %d", n)
+	}
+}
+
+func TestHandleExecuteStopsWaitingForWorkingDir(t *testing.T) {
+	registry := executor.NewRegistry()
+	var created atomic.Int32
+	registry.Register(string(executor.ExecutorClaudeCode), executor.FactoryFunc(func() (executor.Executor, error) {
+		created.Add(1)
+		return &mockExecutor{logs: make(chan executor.Log), done: make(chan struct{})}, nil
+	}))
+	client := sdk.NewWithOptions(sdk.ClientOptions{
+		Registry:                 registry,
+		EventStore:               store.NewMemoryEventStore(),
+		MaxSessionsPerWorkingDir: 1,
+		WorkingDirLimitMode:      executor.LimitBlock,
+	})
+	handler := NewHandler(client)
+
+	reqBody, _ := json.Marshal(ExecuteRequest{Prompt: "hello", Executor: executor.ExecutorClaudeCode, WorkingDir: t.TempDir()})
+	rr := httptest.NewRecorder()
+	handler.HandleExecute(rr, httptest.NewRequest(http.MethodPost, "/execute", bytes.NewBuffer(reqBody)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the first session to start, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	returned := make(chan struct{})
+	go func() {
+		defer close(returned)
+		req := httptest.NewRequest(http.MethodPost, "/execute", bytes.NewBuffer(reqBody)).WithContext(ctx)
+		handler.HandleExecute(httptest.NewRecorder(), req)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-returned:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the request queued on the working directory to give up once its client disconnected")
+	}
+	if n := created.Load(); n != 1 {
+		t.Fatalf("expected no second executor, got %d", n)
+	}
+}
+
+func TestHandlePollEvents(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &mockExecutor{logs: make(chan executor.Log), done: make(chan struct{})}
+	registry.Register(string(executor.ExecutorClaudeCode), executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	client := sdk.NewWithOptions(sdk.ClientOptions{Registry: registry, EventStore: store.NewMemoryEventStore()})
+	handler := NewHandler(client)
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: executor.ExecutorClaudeCode})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	defer func() { exec.logs <- executor.Log{Type: "done", Content: "done"} }()
+
+	type pollResponse struct {
+		Events  []executor.Event `json:"events"`
+		NextSeq uint64           `json:"next_seq"`
+	}
+	poll := func(query string) (*httptest.ResponseRecorder, pollResponse, time.Duration) {
+		req := httptest.NewRequest(http.MethodGet, "/sessions/"+resp.SessionID+"/events/poll?"+query, nil)
+		req = mux.SetURLVars(req, map[string]string{"session_id": resp.SessionID})
+		rr := httptest.NewRecorder()
+		start := time.Now()
+		handler.HandlePollEvents(rr, req)
+		var body pollResponse
+		_ = json.Unmarshal(rr.Body.Bytes(), &body)
+		return rr, body, time.Since(start)
+	}
+
+	t.Run("Immediate", func(t *testing.T) {
+		_, body, elapsed := poll("after_seq=0&wait=5s")
+		if len(body.Events) != 1 || body.NextSeq != 1 || elapsed > time.Second {
+			t.Fatalf("expected the stored event right away, got %+v after %v", body, elapsed)
+		}
+	})
+
+	t.Run("WaitThenTimeout", func(t *testing.T) {
+		rr, body, elapsed := poll("after_seq=1&wait=100ms")
+		if len(body.Events) != 0 || body.NextSeq != 1 || elapsed < 100*time.Millisecond {
+			t.Fatalf("expected an empty poll after the wait, got %+v after %v", body, elapsed)
+		}
+		if !strings.Contains(rr.Body.String(), `"events":[]`) {
+			t.Fatalf("expected an empty events list, got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("WaitThenEvent", func(t *testing.T) {
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			exec.logs <- executor.Log{Type: "stdout", Content: "new output"}
+		}()
+		_, body, elapsed := poll("after_seq=1&wait=5s")
+		if len(body.Events) != 1 || body.Events[0].Seq != 2 || body.NextSeq != 2 || elapsed > time.Second {
+			t.Fatalf("expected the new event once stored, got %+v after %v", body, elapsed)
+		}
+	})
+
+	t.Run("InvalidWait", func(t *testing.T) {
+		if rr, _, _ := poll("wait=soon"); rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for an invalid wait, got %d", rr.Code)
+		}
+	})
+}
+
+func TestStreamEndsOnServerShutdown(t *testing.T) {
+	registry := executor.NewRegistry()
+	registry.Register(string(executor.ExecutorClaudeCode), executor.FactoryFunc(func() (executor.Executor, error) {
+		return &closingLogsExecutor{mockExecutor: mockExecutor{logs: make(chan executor.Log), done: make(chan struct{})}}, nil
+	}))
+	client := sdk.NewWithOptions(sdk.ClientOptions{Registry: registry, EventStore: store.NewMemoryEventStore()})
+	handler := NewHandler(client)
+	srv := httptest.NewServer(NewRouter(handler))
+	defer srv.Close()
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: executor.ExecutorClaudeCode})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	stream, err := http.Get(srv.URL + "/api/execute/" + resp.SessionID + "/stream?return_all=true")
+	if err != nil {
+		t.Fatalf("connect stream failed: %v", err)
+	}
+	defer stream.Body.Close()
+	reader := bufio.NewReader(stream.Body)
+	if line, err := reader.ReadString('\n'); err != nil || line != "event: progress\n" {
+		t.Fatalf("expected the session_started event first, got %q (err=%v)", line, err)
+	}
+	rest := make(chan string, 1)
+	go func() {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			t.Errorf("stream ended with an error: %v", err)
+		}
+		rest <- string(data)
+	}()
+
+	// The same sequence as cmd/server on SIGTERM.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	serverDone := make(chan error, 1)
+	go func() { serverDone <- srv.Config.Shutdown(ctx) }()
+	sessionsCtx, cancelSessions := context.WithTimeout(ctx, 50*time.Millisecond)
+	client.ShutdownContext(sessionsCtx)
+	cancelSessions()
+	handler.CloseStreams()
+
+	if err := <-serverDone; err != nil {
+		t.Fatalf("server shutdown failed: %v", err)
+	}
+	select {
+	case body := <-rest:
+		if !strings.Contains(body, "\n\nevent: done\ndata: ") || !strings.HasSuffix(body, `"type":"done","content":{}}`+"\n\n") {
+			t.Fatalf("expected the stream to end with a done event, got %q", body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the stream to terminate")
+	}
+}
+
+// closingLogsExecutor ends its log channel when closed, without a done log,
+// like a process killed by shutdown.
+type closingLogsExecutor struct {
+	mockExecutor
+	closeOnce sync.Once
+}
+
+func (m *closingLogsExecutor) Close() error {
+	m.closeOnce.Do(func() { close(m.logs) })
+	return nil
+}
+
+type subjectKey struct{}
+
+func TestHandlersAuthorizer(t *testing.T) {
+	registry := executor.NewRegistry()
+	client := sdk.NewWithOptions(sdk.ClientOptions{Registry: registry, EventStore: store.NewMemoryEventStore()})
+	registry.Register(string(executor.ExecutorClaudeCode), executor.FactoryFunc(func() (executor.Executor, error) {
+		return &mockExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}, nil
+	}))
+	handler := NewHandlerWithOptions(client, HandlerOptions{
+		Authorizer: LabelAuthorizer(client, "owner", func(ctx context.Context) string {
+			subject, _ := ctx.Value(subjectKey{}).(string)
+			return subject
+		}),
+	})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{
+		Prompt:   "hello",
+		Executor: executor.ExecutorClaudeCode,
+		Labels:   map[string]string{"owner": "alice"},
+	})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	_ = client.WaitContext(context.Background(), resp.SessionID)
+
+	request := func(subject, path string) *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		req = req.WithContext(context.WithValue(req.Context(), subjectKey{}, subject))
+		return mux.SetURLVars(req, map[string]string{"session_id": resp.SessionID})
+	}
+
+	for _, tc := range []struct {
+		name   string
+		handle http.HandlerFunc
+	}{
+		{"events", handler.HandleEvents},
+		{"stream", handler.HandleStream},
+	} {
+		rr := httptest.NewRecorder()
+		tc.handle(rr, request("alice", "/"+tc.name+"/"+resp.SessionID))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected owner to get 200, got %d", tc.name, rr.Code)
+		}
+
+		rr = httptest.NewRecorder()
+		tc.handle(rr, request("bob", "/"+tc.name+"/"+resp.SessionID))
+		if rr.Code != http.StatusForbidden {
+			t.Fatalf("%s: expected non-owner to get 403, got %d", tc.name, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handler.HandleSessions(rr, request("bob", "/sessions"))
+	if strings.Contains(rr.Body.String(), resp.SessionID) {
+		t.Fatalf("expected session to be hidden from non-owner: %s", rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	handler.HandleSessions(rr, request("alice", "/sessions"))
+	if !strings.Contains(rr.Body.String(), resp.SessionID) {
+		t.Fatalf("expected session to be listed for owner: %s", rr.Body.String())
+	}
+}
+
+// flushCountingRecorder counts the flushes of a streaming response.
+type flushCountingRecorder struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (r *flushCountingRecorder) Flush() {
+	r.flushes++
+	r.ResponseRecorder.Flush()
+}
+
 type mockExecutor struct {
 	logs        chan executor.Log
 	done        chan struct{}
@@ -435,6 +1341,25 @@ func (m *mockExecutor) Logs() <-chan executor.Log { return m.logs }
 func (m *mockExecutor) Done() <-chan struct{}     { return m.done }
 func (m *mockExecutor) Close() error              { return nil }
 
+type mockResumeExecutor struct {
+	mockExecutor
+}
+
+func (m *mockResumeExecutor) CaptureResume(entry executor.Log, prior executor.ResumeState) executor.ResumeState {
+	if entry.Type == "done" {
+		prior.SessionID = "vendor-1"
+	}
+	return prior
+}
+
+type mockValidatingExecutor struct {
+	mockExecutor
+}
+
+func (m *mockValidatingExecutor) ValidateOptions(opts executor.Options) error {
+	return executor.ValidateChoice("validating_executor", "sandbox", opts.Sandbox, []string{"read-only"})
+}
+
 type mockErrorExecutor struct {
 	mockExecutor
 }
@@ -1,9 +1,16 @@
 package httpapi
 
 import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/mylxsw/asteria/log"
 )
 
@@ -40,6 +47,85 @@ func RecoveryMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// AccessTokenQueryParam carries the bearer token for streaming requests from
+// clients that cannot set headers (EventSource, browser WebSockets).
+const AccessTokenQueryParam = "access_token"
+
+// RequireBearerToken rejects requests whose "Authorization: Bearer" token is
+// not one of tokens with 401. SSE and WebSocket requests may pass the token in
+// the access_token query parameter instead. Empty tokens are ignored.
+func RequireBearerToken(tokens ...string) mux.MiddlewareFunc {
+	var allowed [][]byte
+	for _, token := range nonEmptyTokens(tokens) {
+		allowed = append(allowed, []byte(token))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := requestToken(r)
+			if !validBearerToken(token, allowed) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="executor"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), verifiedTokenKey{}, token)))
+		})
+	}
+}
+
+func nonEmptyTokens(tokens []string) []string {
+	var out []string
+	for _, token := range tokens {
+		if token = strings.TrimSpace(token); token != "" {
+			out = append(out, token)
+		}
+	}
+	return out
+}
+
+// verifiedTokenKey holds the bearer token RequireBearerToken accepted.
+type verifiedTokenKey struct{}
+
+// verifiedToken returns the request's bearer token when RequireBearerToken
+// accepted it, and "" otherwise.
+func verifiedToken(r *http.Request) string {
+	token, _ := r.Context().Value(verifiedTokenKey{}).(string)
+	return token
+}
+
+// requestToken returns the bearer token from the Authorization header, or
+// from the query string for streaming requests.
+func requestToken(r *http.Request) string {
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	if isStreamingRequest(r) {
+		return r.URL.Query().Get(AccessTokenQueryParam)
+	}
+	return ""
+}
+
+func isStreamingRequest(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream") ||
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+func validBearerToken(token string, allowed [][]byte) bool {
+	if token == "" {
+		return false
+	}
+	valid := false
+	for _, candidate := range allowed {
+		if subtle.ConstantTimeCompare([]byte(token), candidate) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
@@ -55,3 +141,12 @@ func (rw *responseWriter) Flush() {
 		f.Flush()
 	}
 }
+
+// Hijack lets WebSocket upgrades work through the logging wrapper.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking not supported")
+	}
+	return h.Hijack()
+}
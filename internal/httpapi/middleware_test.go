@@ -47,3 +47,69 @@ func TestRouter(t *testing.T) {
 	rr := &responseWriter{ResponseWriter: httptest.NewRecorder(), statusCode: http.StatusOK}
 	rr.Flush()
 }
+
+func TestRequireBearerToken(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mw := RequireBearerToken("secret", "other")(next)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		header map[string]string
+		want   int
+	}{
+		{name: "HeaderValid", method: http.MethodPost, target: "/api/execute", header: map[string]string{"Authorization": "Bearer secret"}, want: http.StatusOK},
+		{name: "HeaderSecondToken", method: http.MethodPost, target: "/api/execute", header: map[string]string{"Authorization": "bearer other"}, want: http.StatusOK},
+		{name: "HeaderInvalid", method: http.MethodPost, target: "/api/execute", header: map[string]string{"Authorization": "Bearer wrong"}, want: http.StatusUnauthorized},
+		{name: "HeaderWrongScheme", method: http.MethodPost, target: "/api/execute", header: map[string]string{"Authorization": "Basic secret"}, want: http.StatusUnauthorized},
+		{name: "Missing", method: http.MethodPost, target: "/api/execute", want: http.StatusUnauthorized},
+		{name: "QueryValidSSE", method: http.MethodGet, target: "/api/execute/s1/stream?access_token=secret", header: map[string]string{"Accept": "text/event-stream"}, want: http.StatusOK},
+		{name: "QueryValidWebSocket", method: http.MethodGet, target: "/api/execute/s1/ws?access_token=secret", header: map[string]string{"Upgrade": "websocket"}, want: http.StatusOK},
+		{name: "QueryInvalidSSE", method: http.MethodGet, target: "/api/execute/s1/stream?access_token=wrong", header: map[string]string{"Accept": "text/event-stream"}, want: http.StatusUnauthorized},
+		{name: "QueryMissingSSE", method: http.MethodGet, target: "/api/execute/s1/stream", header: map[string]string{"Accept": "text/event-stream"}, want: http.StatusUnauthorized},
+		{name: "QueryIgnoredForNonStreaming", method: http.MethodGet, target: "/api/sessions?access_token=secret", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+			mw.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Fatalf("expected %d, got %d", tt.want, rr.Code)
+			}
+			if tt.want == http.StatusUnauthorized && rr.Header().Get("WWW-Authenticate") == "" {
+				t.Fatal("expected WWW-Authenticate header on 401")
+			}
+		})
+	}
+
+	t.Run("RouterWiring", func(t *testing.T) {
+		router := NewRouter(NewHandlerWithOptions(sdk.New(), HandlerOptions{BearerTokens: []string{"secret"}}))
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/executors", nil))
+		if rr.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401 without token, got %d", rr.Code)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/api/executors", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200 with token, got %d", rr.Code)
+		}
+
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected /health to stay open, got %d", rr.Code)
+		}
+	})
+}
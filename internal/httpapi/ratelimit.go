@@ -0,0 +1,142 @@
+package httpapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// rateLimitIdleTTL is how long an untouched bucket is kept before cleanup
+// drops it; a bucket idle this long has refilled anyway.
+var rateLimitIdleTTL = 10 * time.Minute
+
+// RateLimitKeyFunc extracts the identity a request is rate limited by.
+// Returning "" exempts the request from limiting.
+type RateLimitKeyFunc func(r *http.Request) string
+
+// RequestIdentity keys requests by their bearer token once RequireBearerToken
+// has accepted it, and by remote IP otherwise, so that unverified tokens
+// cannot be rotated to dodge the limit. Tokens are hashed so the limiter never
+// retains them.
+func RequestIdentity(r *http.Request) string {
+	if token := verifiedToken(r); token != "" {
+		sum := sha256.Sum256([]byte(token))
+		return "token:" + hex.EncodeToString(sum[:8])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// RateLimiter is a per-identity token bucket allowing perMinute requests per
+// minute with bursts up to perMinute. It is safe for concurrent use.
+type RateLimiter struct {
+	perMinute int
+	key       RateLimitKeyFunc
+	now       func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*rateBucket
+	lastSweep time.Time
+}
+
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a limiter keyed by key, or by RequestIdentity when
+// key is nil.
+func NewRateLimiter(perMinute int, key RateLimitKeyFunc) *RateLimiter {
+	if key == nil {
+		key = RequestIdentity
+	}
+	return &RateLimiter{
+		perMinute: perMinute,
+		key:       key,
+		now:       time.Now,
+		buckets:   make(map[string]*rateBucket),
+	}
+}
+
+// RateLimit returns middleware limiting each RequestIdentity to perMinute
+// requests per minute. perMinute <= 0 disables limiting.
+func RateLimit(perMinute int) mux.MiddlewareFunc {
+	return NewRateLimiter(perMinute, nil).Middleware
+}
+
+// Middleware rejects requests over the limit with 429 and a Retry-After
+// header giving the seconds until the next request is allowed.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	if l.perMinute <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := l.key(r)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if ok, retryAfter := l.Allow(key); !ok {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Allow takes a token from key's bucket. When none is left it returns false
+// and how long until one is available.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	now := l.now()
+	capacity := float64(l.perMinute)
+	perSecond := capacity / 60
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &rateBucket{tokens: capacity, last: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.last).Seconds()*perSecond)
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := (1 - bucket.tokens) / perSecond
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+// sweep drops buckets idle for rateLimitIdleTTL, at most once per TTL.
+// Callers must hold l.mu.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitIdleTTL {
+		return
+	}
+	l.lastSweep = now
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.last) >= rateLimitIdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
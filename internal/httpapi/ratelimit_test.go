@@ -0,0 +1,170 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/supremeagent/executor/pkg/sdk"
+)
+
+func TestRateLimiter(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	t.Run("RejectsWithRetryAfter", func(t *testing.T) {
+		limiter := NewRateLimiter(2, nil)
+		now := time.Unix(1000, 0)
+		limiter.now = func() time.Time { return now }
+		mw := limiter.Middleware(next)
+
+		send := func(remote string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/api/execute", nil)
+			req.RemoteAddr = remote
+			rr := httptest.NewRecorder()
+			mw.ServeHTTP(rr, req)
+			return rr
+		}
+
+		for i := 0; i < 2; i++ {
+			if rr := send("10.0.0.1:1234"); rr.Code != http.StatusOK {
+				t.Fatalf("request %d: expected 200, got %d", i, rr.Code)
+			}
+		}
+		rr := send("10.0.0.1:5678")
+		if rr.Code != http.StatusTooManyRequests {
+			t.Fatalf("expected 429, got %d", rr.Code)
+		}
+		if got := rr.Header().Get("Retry-After"); got != "30" {
+			t.Fatalf("expected Retry-After 30, got %q", got)
+		}
+
+		if rr := send("10.0.0.2:1234"); rr.Code != http.StatusOK {
+			t.Fatalf("expected other IP to have its own bucket, got %d", rr.Code)
+		}
+
+		now = now.Add(30 * time.Second)
+		if rr := send("10.0.0.1:1234"); rr.Code != http.StatusOK {
+			t.Fatalf("expected refilled token after 30s, got %d", rr.Code)
+		}
+	})
+
+	t.Run("KeysOnBearerToken", func(t *testing.T) {
+		mw := RequireBearerToken("a", "b")(NewRateLimiter(1, nil).Middleware(next))
+		send := func(token string) int {
+			req := httptest.NewRequest(http.MethodPost, "/api/execute", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rr := httptest.NewRecorder()
+			mw.ServeHTTP(rr, req)
+			return rr.Code
+		}
+		if send("a") != http.StatusOK || send("b") != http.StatusOK {
+			t.Fatal("expected distinct tokens to be limited separately")
+		}
+		if code := send("a"); code != http.StatusTooManyRequests {
+			t.Fatalf("expected 429 for repeated token, got %d", code)
+		}
+	})
+
+	t.Run("UnverifiedTokensKeyOnIP", func(t *testing.T) {
+		mw := NewRateLimiter(1, nil).Middleware(next)
+		send := func(token string) int {
+			req := httptest.NewRequest(http.MethodPost, "/api/execute", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rr := httptest.NewRecorder()
+			mw.ServeHTTP(rr, req)
+			return rr.Code
+		}
+		if send("random-1") != http.StatusOK {
+			t.Fatal("expected the first request to pass")
+		}
+		if code := send("random-2"); code != http.StatusTooManyRequests {
+			t.Fatalf("expected a new unverified token not to get a new bucket, got %d", code)
+		}
+	})
+
+	t.Run("CustomKey", func(t *testing.T) {
+		mw := NewRateLimiter(1, func(r *http.Request) string {
+			return r.Header.Get("X-Subject")
+		}).Middleware(next)
+		send := func(subject string) int {
+			req := httptest.NewRequest(http.MethodPost, "/api/execute", nil)
+			req.Header.Set("X-Subject", subject)
+			rr := httptest.NewRecorder()
+			mw.ServeHTTP(rr, req)
+			return rr.Code
+		}
+		if send("alice") != http.StatusOK || send("alice") != http.StatusTooManyRequests {
+			t.Fatal("expected custom key to be limited")
+		}
+		if send("") != http.StatusOK || send("") != http.StatusOK {
+			t.Fatal("expected empty key to be exempt")
+		}
+	})
+
+	t.Run("CleansUpIdleBuckets", func(t *testing.T) {
+		limiter := NewRateLimiter(5, nil)
+		now := time.Unix(1000, 0)
+		limiter.now = func() time.Time { return now }
+
+		limiter.Allow("a")
+		limiter.Allow("b")
+		now = now.Add(rateLimitIdleTTL)
+		limiter.Allow("c")
+
+		limiter.mu.Lock()
+		defer limiter.mu.Unlock()
+		if len(limiter.buckets) != 1 || limiter.buckets["c"] == nil {
+			t.Fatalf("expected only the active bucket to remain, got %v", limiter.buckets)
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		limiter := NewRateLimiter(50, nil)
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		allowed := 0
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if ok, _ := limiter.Allow("shared"); ok {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if allowed < 50 || allowed > 51 {
+			t.Fatalf("expected about 50 allowed requests, got %d", allowed)
+		}
+	})
+
+	t.Run("RouterAppliesToExecuteOnly", func(t *testing.T) {
+		router := NewRouter(NewHandlerWithOptions(sdk.New(), HandlerOptions{ExecuteRateLimit: 1}))
+		execute := func() int {
+			req := httptest.NewRequest(http.MethodPost, "/api/execute", strings.NewReader("{}"))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			return rr.Code
+		}
+		if code := execute(); code == http.StatusTooManyRequests {
+			t.Fatal("first execute should not be rate limited")
+		}
+		if code := execute(); code != http.StatusTooManyRequests {
+			t.Fatalf("expected second execute to be limited, got %d", code)
+		}
+		for i := 0; i < 3; i++ {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/executors", nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected other routes to be unlimited, got %d", rr.Code)
+			}
+		}
+	})
+}
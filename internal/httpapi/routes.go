@@ -12,14 +12,28 @@ func NewRouter(handler *Handler) *mux.Router {
 	router.Use(LoggingMiddleware)
 	router.Use(RecoveryMiddleware)
 
-	router.HandleFunc("/api/execute", handler.HandleExecute).Methods(http.MethodPost)
-	router.HandleFunc("/api/execute/{session_id}/continue", handler.HandleContinue).Methods(http.MethodPost)
-	router.HandleFunc("/api/execute/{session_id}/interrupt", handler.HandleInterrupt).Methods(http.MethodPost)
-	router.HandleFunc("/api/execute/{session_id}/control", handler.HandleControl).Methods(http.MethodPost)
-	router.HandleFunc("/api/execute/{session_id}/stream", handler.HandleStream).Methods(http.MethodGet)
-	router.HandleFunc("/api/execute/{session_id}/events", handler.HandleEvents).Methods(http.MethodGet)
-	router.HandleFunc("/api/sessions", handler.HandleSessions).Methods(http.MethodGet)
-	router.HandleFunc("/api/executors", handler.HandleExecutors).Methods(http.MethodGet)
+	api := router.PathPrefix("/api").Subrouter()
+	if len(handler.bearerTokens) > 0 {
+		api.Use(RequireBearerToken(handler.bearerTokens...))
+	}
+
+	api.Handle("/execute", handler.executeLimit.Middleware(http.HandlerFunc(handler.HandleExecute))).Methods(http.MethodPost)
+	api.HandleFunc("/execute/{session_id}/continue", handler.HandleContinue).Methods(http.MethodPost)
+	api.HandleFunc("/execute/{session_id}/interrupt", handler.HandleInterrupt).Methods(http.MethodPost)
+	api.HandleFunc("/execute/{session_id}/control", handler.HandleControl).Methods(http.MethodPost)
+	api.HandleFunc("/execute/{session_id}/stream", handler.HandleStream).Methods(http.MethodGet)
+	api.HandleFunc("/execute/{session_id}/ws", handler.HandleWebSocket).Methods(http.MethodGet)
+	api.HandleFunc("/execute/{session_id}/events", handler.HandleEvents).Methods(http.MethodGet)
+	api.HandleFunc("/execute/{session_id}/export", handler.HandleExport).Methods(http.MethodGet)
+	api.HandleFunc("/sessions", handler.HandleSessions).Methods(http.MethodGet)
+	api.HandleFunc("/sessions/{session_id}", handler.HandleSession).Methods(http.MethodGet)
+	api.HandleFunc("/sessions/{session_id}", handler.HandleDeleteSession).Methods(http.MethodDelete)
+	api.HandleFunc("/sessions/{session_id}/transcript", handler.HandleTranscript).Methods(http.MethodGet)
+	api.HandleFunc("/sessions/{session_id}/metrics", handler.HandleSessionMetrics).Methods(http.MethodGet)
+	api.HandleFunc("/sessions/{session_id}/tools", handler.HandleSessionTools).Methods(http.MethodGet)
+	api.HandleFunc("/sessions/{session_id}/events/poll", handler.HandlePollEvents).Methods(http.MethodGet)
+	api.HandleFunc("/executors", handler.HandleExecutors).Methods(http.MethodGet)
+	api.HandleFunc("/executors/{name}/models", handler.HandleExecutorModels).Methods(http.MethodGet)
 
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -0,0 +1,50 @@
+package httpapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+// sseWriter buffers Server-Sent Event frames so each frame's header and data
+// reach the connection in one write, and bursts of frames can share a flush.
+type sseWriter struct {
+	buf     *bufio.Writer
+	flusher http.Flusher
+}
+
+func newSSEWriter(w io.Writer, flusher http.Flusher) *sseWriter {
+	return &sseWriter{buf: bufio.NewWriterSize(w, 32*1024), flusher: flusher}
+}
+
+// WriteEvent appends one "event: <type>\ndata: <json>\n\n" frame to the buffer.
+// Events with an EventID start the frame with an "id: <event id>" line.
+func (s *sseWriter) WriteEvent(evt executor.Event) error {
+	data, _ := json.Marshal(evt)
+	if evt.EventID != "" && !strings.ContainsAny(evt.EventID, "\r\n") {
+		_, _ = s.buf.WriteString("id: ")
+		_, _ = s.buf.WriteString(evt.EventID)
+		_, _ = s.buf.WriteString("\n")
+	}
+	_, _ = s.buf.WriteString("event: ")
+	_, _ = s.buf.WriteString(evt.Type)
+	_, _ = s.buf.WriteString("\ndata: ")
+	_, _ = s.buf.Write(data)
+	_, err := s.buf.WriteString("\n\n")
+	return err
+}
+
+// Flush writes buffered frames to the connection and flushes it.
+func (s *sseWriter) Flush() error {
+	if err := s.buf.Flush(); err != nil {
+		return err
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+	return nil
+}
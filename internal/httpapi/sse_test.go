@@ -0,0 +1,83 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+func sseTestEvents() []executor.Event {
+	return []executor.Event{
+		{SessionID: "s1", Seq: 1, Type: "progress", Content: executor.UnifiedContent{Summary: "Thinking"}},
+		{SessionID: "s1", Seq: 2, Type: "tool", Content: map[string]any{"tool_name": "Read", "path": "a\nb"}},
+		{SessionID: "s1", Seq: 3, Type: "done", Content: "done"},
+	}
+}
+
+func TestSSEWriterMatchesUnbufferedOutput(t *testing.T) {
+	var want bytes.Buffer
+	for _, evt := range sseTestEvents() {
+		data, _ := json.Marshal(evt)
+		_, _ = fmt.Fprintf(&want, "event: %s\ndata: %s\n\n", evt.Type, data)
+	}
+
+	rec := httptest.NewRecorder()
+	sse := newSSEWriter(rec, rec)
+	for _, evt := range sseTestEvents() {
+		if err := sse.WriteEvent(evt); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+	if rec.Body.Len() != 0 {
+		t.Fatal("expected frames to stay buffered until Flush")
+	}
+	if err := sse.Flush(); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), want.Bytes()) || !rec.Flushed {
+		t.Fatalf("expected byte-identical flushed output\nwant: %q\ngot:  %q", want.String(), rec.Body.String())
+	}
+}
+
+func TestSSEWriterEventID(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sse := newSSEWriter(rec, rec)
+	_ = sse.WriteEvent(executor.Event{SessionID: "s1", Seq: 1, Type: "progress", Content: "a", EventID: "01J9ZQ"})
+	_ = sse.WriteEvent(executor.Event{SessionID: "s1", Seq: 2, Type: "progress", Content: "b"})
+	_ = sse.Flush()
+
+	frames := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n\n"), "\n\n")
+	if len(frames) != 2 || !strings.HasPrefix(frames[0], "id: 01J9ZQ\nevent: progress\n") {
+		t.Fatalf("expected the first frame to carry its event id, got %q", rec.Body.String())
+	}
+	if strings.Contains(frames[1], "id: ") {
+		t.Fatalf("expected no id line for an event without EventID, got %q", frames[1])
+	}
+}
+
+func BenchmarkSSEWriter(b *testing.B) {
+	events := sseTestEvents()
+	b.Run("fprintf", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, evt := range events {
+				data, _ := json.Marshal(evt)
+				_, _ = fmt.Fprintf(io.Discard, "event: %s\ndata: %s\n\n", evt.Type, data)
+			}
+		}
+	})
+	b.Run("buffered", func(b *testing.B) {
+		sse := newSSEWriter(io.Discard, nil)
+		for i := 0; i < b.N; i++ {
+			for _, evt := range events {
+				_ = sse.WriteEvent(evt)
+			}
+			_ = sse.Flush()
+		}
+	})
+}
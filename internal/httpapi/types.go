@@ -2,6 +2,7 @@ package httpapi
 
 import (
 	"github.com/supremeagent/executor/pkg/executor"
+	"github.com/supremeagent/executor/pkg/sdk"
 )
 
 type ExecuteRequest = executor.ExecuteRequest
@@ -10,3 +11,11 @@ type ContinueRequest = executor.ContinueRequest
 type ControlResponse = executor.ControlResponse
 type Session = executor.Session
 type LogEvent = executor.Event
+
+// SessionResponse is the body of GET /api/sessions/{session_id}: the session
+// summary, with the current cost estimate, plus, once the executor reports
+// it, the vendor's session id.
+type SessionResponse struct {
+	Session
+	Upstream *sdk.UpstreamSession `json:"upstream,omitempty"`
+}
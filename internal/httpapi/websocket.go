@@ -0,0 +1,204 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/mylxsw/asteria/log"
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+const (
+	wsWriteWait      = 10 * time.Second
+	wsMaxMessageSize = 1 << 20
+)
+
+// Keepalive timing; variables so tests can shorten them.
+var (
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// WebSocket command types accepted from clients.
+const (
+	WebSocketCommandContinue  = "continue"
+	WebSocketCommandInterrupt = "interrupt"
+	WebSocketCommandControl   = "control"
+)
+
+// WebSocketCommand is an inbound frame on the session WebSocket. For
+// "control" commands the request_id/decision/reason fields are used.
+type WebSocketCommand struct {
+	Type       string `json:"type"`
+	Message    string `json:"message,omitempty"`
+	WorkingDir string `json:"working_dir,omitempty"`
+	executor.ControlResponse
+}
+
+// WebSocketAck is sent in reply to every inbound command.
+type WebSocketAck struct {
+	Type    string `json:"type"`
+	Command string `json:"command"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+type wsFrame struct {
+	cmd WebSocketCommand
+	err error
+}
+
+// HandleWebSocket streams session events as JSON text frames and accepts
+// continue/interrupt/control commands on the same connection. It honours the
+// same return_all, debug and view query parameters as HandleStream.
+func (h *Handler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["session_id"]
+	if !h.authorize(w, r, sessionID) {
+		return
+	}
+	debugEnabled, _ := strconv.ParseBool(r.URL.Query().Get("debug"))
+	returnAll, _ := strconv.ParseBool(r.URL.Query().Get("return_all"))
+	view, ok := h.streamView(w, r)
+	if !ok {
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Warningf("HandleWebSocket: upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	frames := make(chan wsFrame)
+	readDone := make(chan struct{})
+	go readWebSocketFrames(conn, frames, readDone, stop)
+
+	events, unsubscribe := h.client.Subscribe(sessionID, executor.SubscribeOptions{
+		ReturnAll:    returnAll,
+		IncludeDebug: debugEnabled,
+		View:         view,
+	})
+	defer func() { unsubscribe() }()
+
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	var lastSeq uint64
+	for {
+		select {
+		case evt, ok := <-events:
+			if !ok {
+				// The run finished; keep the socket open for further commands.
+				events = nil
+				continue
+			}
+			if evt.Seq > lastSeq {
+				lastSeq = evt.Seq
+			}
+			if err := writeWebSocketJSON(conn, evt); err != nil {
+				return
+			}
+		case frame := <-frames:
+			ack := h.runWebSocketCommand(r.Context(), sessionID, frame)
+			if ack.Command == WebSocketCommandContinue && ack.Error == "" && events == nil {
+				unsubscribe()
+				events, unsubscribe = h.client.Subscribe(sessionID, executor.SubscribeOptions{
+					ReturnAll:    true,
+					IncludeDebug: debugEnabled,
+					AfterSeq:     lastSeq,
+					View:         view,
+				})
+			}
+			if err := writeWebSocketJSON(conn, ack); err != nil {
+				return
+			}
+		case <-ticker.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-readDone:
+			return
+		case <-h.streamsClosed:
+			_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(wsWriteWait))
+			return
+		}
+	}
+}
+
+func (h *Handler) runWebSocketCommand(ctx context.Context, sessionID string, frame wsFrame) WebSocketAck {
+	ack := WebSocketAck{Type: "ack", Command: frame.cmd.Type, Status: "ok"}
+
+	err := frame.err
+	if err == nil {
+		switch frame.cmd.Type {
+		case WebSocketCommandContinue:
+			err = h.client.ContinueTaskWithOptions(ctx, sessionID, executor.ContinueRequest{
+				Message:    frame.cmd.Message,
+				WorkingDir: frame.cmd.WorkingDir,
+			})
+		case WebSocketCommandInterrupt:
+			err = h.client.PauseTask(sessionID)
+		case WebSocketCommandControl:
+			if err = validateControlResponse(frame.cmd.ControlResponse); err == nil {
+				err = h.client.RespondControl(ctx, sessionID, frame.cmd.ControlResponse)
+			}
+		default:
+			err = fmt.Errorf("unknown command type %q", frame.cmd.Type)
+		}
+	}
+
+	if err != nil {
+		ack.Status = "error"
+		ack.Error = err.Error()
+	}
+	return ack
+}
+
+// readWebSocketFrames decodes inbound commands until the connection fails or
+// stop is closed. It also maintains the read deadline used for keepalive.
+func readWebSocketFrames(conn *websocket.Conn, frames chan<- wsFrame, done, stop chan struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var frame wsFrame
+		if err := json.Unmarshal(data, &frame.cmd); err != nil {
+			frame.err = fmt.Errorf("invalid command frame: %w", err)
+		}
+		select {
+		case frames <- frame:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func writeWebSocketJSON(conn *websocket.Conn, v any) error {
+	_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return conn.WriteJSON(v)
+}
@@ -0,0 +1,143 @@
+package httpapi
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/supremeagent/executor/pkg/executor"
+	"github.com/supremeagent/executor/pkg/sdk"
+	"github.com/supremeagent/executor/pkg/store"
+	"github.com/supremeagent/executor/pkg/streaming"
+)
+
+func TestHandleWebSocket(t *testing.T) {
+	oldPing := wsPingPeriod
+	wsPingPeriod = 20 * time.Millisecond
+	defer func() { wsPingPeriod = oldPing }()
+
+	registry := executor.NewRegistry()
+	client := sdk.NewWithOptions(sdk.ClientOptions{
+		Registry:      registry,
+		StreamManager: streaming.NewManager(),
+		EventStore:    store.NewMemoryEventStore(),
+	})
+	exec := &wsExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register("ws_executor", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+
+	server := httptest.NewServer(NewRouter(NewHandler(client)))
+	defer server.Close()
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: "ws_executor"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/execute/" + resp.SessionID + "/ws?return_all=true"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	var pings int32
+	conn.SetPingHandler(func(data string) error {
+		atomic.AddInt32(&pings, 1)
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+
+	exec.logs <- executor.Log{Type: "stdout", Content: "hello from executor"}
+	if frame := readWebSocketFrame(t, conn, func(m map[string]any) bool { return m["type"] == "stdout" }); frame["seq"] == nil {
+		t.Fatalf("expected stored event with seq, got %v", frame)
+	}
+
+	_ = conn.WriteJSON(map[string]any{"type": "control", "request_id": "req-1", "decision": "approve"})
+	ack := readWebSocketFrame(t, conn, isAck)
+	if ack["command"] != "control" || ack["status"] != "ok" || exec.control().RequestID != "req-1" {
+		t.Fatalf("expected control to be delivered, got ack %v", ack)
+	}
+
+	_ = conn.WriteJSON(map[string]any{"type": "control", "request_id": "req-2", "decision": "maybe"})
+	if ack := readWebSocketFrame(t, conn, isAck); ack["status"] != "error" {
+		t.Fatalf("expected invalid decision to be rejected, got %v", ack)
+	}
+
+	_ = conn.WriteMessage(websocket.TextMessage, []byte("not json"))
+	if ack := readWebSocketFrame(t, conn, isAck); ack["status"] != "error" {
+		t.Fatalf("expected malformed frame to be rejected, got %v", ack)
+	}
+
+	_ = conn.WriteJSON(map[string]any{"type": "interrupt"})
+	if ack := readWebSocketFrame(t, conn, isAck); ack["command"] != "interrupt" || ack["status"] != "ok" {
+		t.Fatalf("unexpected interrupt ack: %v", ack)
+	}
+	if !exec.interrupted.Load() {
+		t.Fatal("expected executor to be interrupted")
+	}
+
+	if atomic.LoadInt32(&pings) == 0 {
+		time.Sleep(50 * time.Millisecond)
+		_ = conn.WriteJSON(map[string]any{"type": "unknown"})
+		readWebSocketFrame(t, conn, isAck)
+	}
+	if atomic.LoadInt32(&pings) == 0 {
+		t.Fatal("expected keepalive pings from server")
+	}
+	_ = exec.Close()
+}
+
+func isAck(m map[string]any) bool { return m["type"] == "ack" }
+
+func readWebSocketFrame(t *testing.T, conn *websocket.Conn, match func(map[string]any) bool) map[string]any {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var frame map[string]any
+		if err := conn.ReadJSON(&frame); err != nil {
+			t.Fatalf("read frame failed: %v", err)
+		}
+		if match(frame) {
+			return frame
+		}
+	}
+}
+
+type wsExecutor struct {
+	logs        chan executor.Log
+	mu          sync.Mutex
+	lastControl executor.ControlResponse
+	interrupted atomic.Bool
+	closeOnce   sync.Once
+}
+
+func (m *wsExecutor) Start(ctx context.Context, prompt string, opts executor.Options) error {
+	return nil
+}
+func (m *wsExecutor) Interrupt() error {
+	m.interrupted.Store(true)
+	return nil
+}
+func (m *wsExecutor) SendMessage(ctx context.Context, message string) error { return nil }
+func (m *wsExecutor) RespondControl(ctx context.Context, response executor.ControlResponse) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastControl = response
+	return nil
+}
+func (m *wsExecutor) control() executor.ControlResponse {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastControl
+}
+func (m *wsExecutor) Wait() error               { return nil }
+func (m *wsExecutor) Logs() <-chan executor.Log { return m.logs }
+func (m *wsExecutor) Done() <-chan struct{}     { return nil }
+func (m *wsExecutor) Close() error {
+	m.closeOnce.Do(func() { close(m.logs) })
+	return nil
+}
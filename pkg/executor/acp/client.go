@@ -1,9 +1,9 @@
 package acp
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -31,6 +31,9 @@ type Client struct {
 	cmd     *exec.Cmd
 	ptyFile *os.File
 	stdin   io.WriteCloser
+	exit    executor.ProcessExit
+	// install notices npx failing to fetch the package.
+	install executor.InstallWatch
 
 	logsChan  chan executor.Log
 	doneChan  chan struct{}
@@ -44,6 +47,8 @@ type Client struct {
 
 	// autoApprove, when true, immediately approves every incoming permission request.
 	autoApprove bool
+	// approvalPolicy answers the remaining permission requests it matches.
+	approvalPolicy executor.ApprovalPolicy
 
 	// commandRun is substituted during tests to avoid spawning real processes.
 	commandRun func(name string, arg ...string) *exec.Cmd
@@ -83,6 +88,8 @@ func (c *Client) Start(_ context.Context, prompt string, opts executor.Options)
 		return fmt.Errorf("acp: no command args provided")
 	}
 
+	c.approvalPolicy = opts.ApprovalPolicy
+
 	program := c.args[0]
 	rest := c.args[1:]
 
@@ -98,7 +105,7 @@ func (c *Client) Start(_ context.Context, prompt string, opts executor.Options)
 
 	ptmx, err := pty.Start(cmd)
 	if err != nil {
-		return fmt.Errorf("acp: start process with pty: %w", err)
+		return fmt.Errorf("acp: start process with pty: %w", executor.WrapStartError(err))
 	}
 
 	c.cmd = cmd
@@ -107,7 +114,7 @@ func (c *Client) Start(_ context.Context, prompt string, opts executor.Options)
 
 	c.sendLog(executor.Log{
 		Type:    "command",
-		Content: fmt.Sprintf("%s %s", program, strings.Join(rest, " ")),
+		Content: fmt.Sprintf("%s %s", program, strings.Join(opts.RedactArgs(rest), " ")),
 	})
 
 	// Stream stdout ACP events from the PTY.
@@ -133,18 +140,16 @@ func (c *Client) Start(_ context.Context, prompt string, opts executor.Options)
 	return nil
 }
 
-// readLoop reads ACP event lines from r until EOF and translates them to executor.Log entries.
+// readLoop reads ACP event lines from r until EOF and translates them to
+// executor.Log entries, then reaps the process and reports its exit code
+// before done.
 func (c *Client) readLoop(r io.Reader) {
 	defer c.Close()
-	defer c.sendLog(executor.Log{Type: "done", Content: "ACP execution finished"})
-
-	sc := bufio.NewScanner(r)
-	sc.Buffer(make([]byte, 1024*1024), 1024*1024)
 
-	for sc.Scan() {
-		line := strings.TrimSpace(sc.Text())
+	_ = executor.ScanLines(r, c.sendLog, func(line string) bool {
+		line = strings.TrimSpace(line)
 		if line == "" {
-			continue
+			return true
 		}
 
 		raw := []byte(line)
@@ -152,11 +157,18 @@ func (c *Client) readLoop(r io.Reader) {
 		if !ok {
 			// Emit non-ACP lines verbatim (startup messages, etc.).
 			c.sendLog(executor.Log{Type: "stdout", Content: line})
-			continue
+			return true
 		}
 
 		c.dispatchEvent(evt, raw)
+		return true
+	})
+
+	if c.cmd == nil {
+		c.sendLog(executor.Log{Type: "done", Content: "ACP execution finished"})
+		return
 	}
+	executor.ReportExit(c.sendLog, c.install.Wrap(c.exit.Wait(c.cmd)), "ACP execution finished")
 }
 
 // dispatchEvent converts an ACP event to an executor.Log and sends it.
@@ -193,6 +205,8 @@ func (c *Client) dispatchEvent(evt Event, raw []byte) {
 
 			if c.autoApprove {
 				_ = c.approveToolCall(perm.ToolCallID, true, "")
+			} else if response, ok := c.approvalPolicy.Decide(perm.ToolCallID, perm.ToolCall.toolName()); ok {
+				_ = c.RespondControl(context.Background(), response)
 			}
 		}
 
@@ -219,13 +233,7 @@ func (c *Client) approveToolCall(toolCallID string, allow bool, reason string) e
 	if err != nil {
 		return err
 	}
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.closed || c.stdin == nil {
-		return executor.ErrExecutorClosed
-	}
-	_, err = fmt.Fprintf(c.stdin, "%s\n", data)
-	return err
+	return c.writeLine(data)
 }
 
 // Interrupt sends SIGINT to the subprocess.
@@ -238,17 +246,30 @@ func (c *Client) Interrupt() error {
 
 // SendMessage sends a follow-up message to the running session via stdin.
 func (c *Client) SendMessage(_ context.Context, message string) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.closed || c.stdin == nil {
-		return executor.ErrExecutorClosed
-	}
 	payload := map[string]any{
 		"type":    "user_message",
 		"content": message,
 	}
 	data, _ := json.Marshal(payload)
+	return c.writeLine(data)
+}
+
+// writeLine writes data and a newline to stdin. A write that fails because
+// the tool has exited closes the client, so the session ends instead of
+// waiting on a dead process, and returns an error matching
+// executor.ErrExecutorClosed.
+func (c *Client) writeLine(data []byte) error {
+	c.mu.Lock()
+	if c.closed || c.stdin == nil {
+		c.mu.Unlock()
+		return executor.ErrExecutorClosed
+	}
 	_, err := fmt.Fprintf(c.stdin, "%s\n", data)
+	c.mu.Unlock()
+
+	if err = executor.WrapWriteError(err); errors.Is(err, executor.ErrExecutorClosed) {
+		_ = c.Close()
+	}
 	return err
 }
 
@@ -269,12 +290,28 @@ func (c *Client) RespondControl(_ context.Context, response executor.ControlResp
 	return c.approveToolCall(response.RequestID, allow, response.Reason)
 }
 
+// Capabilities reports the optional operations this executor supports.
+func (c *Client) Capabilities() executor.Capabilities {
+	return executor.Capabilities{Interactive: true, Control: true}
+}
+
 // Wait blocks until the executor finishes.
 func (c *Client) Wait() error {
 	<-c.doneChan
 	return nil
 }
 
+// WaitContext waits for the execution to complete or for ctx to be done.
+// It returns ctx.Err() if the context ends the wait first.
+func (c *Client) WaitContext(ctx context.Context) error {
+	select {
+	case <-c.doneChan:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Logs returns the channel of streaming log entries.
 func (c *Client) Logs() <-chan executor.Log {
 	return c.logsChan
@@ -296,7 +333,7 @@ func (c *Client) Close() error {
 
 		if c.cmd != nil && c.cmd.Process != nil {
 			_ = c.cmd.Process.Kill()
-			_ = c.cmd.Wait()
+			_ = c.exit.Wait(c.cmd)
 		}
 		if c.ptyFile != nil {
 			_ = c.ptyFile.Close()
@@ -311,5 +348,6 @@ func (c *Client) sendLog(entry executor.Log) {
 	if c.closed {
 		return
 	}
+	c.install.Observe(entry)
 	c.logsChan <- entry
 }
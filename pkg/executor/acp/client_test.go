@@ -1,8 +1,12 @@
 package acp
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
 	"testing"
@@ -174,6 +178,28 @@ func TestClient_SendMessage_Closed(t *testing.T) {
 	}
 }
 
+func TestClient_SendMessage_ChildExited(t *testing.T) {
+	c := NewClientWithArgs(nil, []string{"x"})
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	// Closing the read end stands in for the child process going away.
+	_ = r.Close()
+	c.stdin = w
+
+	err = c.SendMessage(context.Background(), "hello")
+	if !errors.Is(err, executor.ErrExecutorClosed) {
+		t.Fatalf("expected ErrExecutorClosed, got %v", err)
+	}
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected client to be closed after a broken write")
+	}
+}
+
 func TestClient_CloseTwice(t *testing.T) {
 	c := NewClientWithArgs(nil, []string{"x"})
 	// Should not panic.
@@ -201,6 +227,35 @@ func TestClient_ApprovalAutoApprove(t *testing.T) {
 	// (Auto-approve writes back to stdin which may fail since process exits, but the log must be present.)
 }
 
+func TestClient_ApprovalPolicy(t *testing.T) {
+	c := NewClientWithArgs(nil, []string{"x"})
+	stdin := &bytes.Buffer{}
+	c.stdin = nopWriteCloser{Buffer: stdin}
+	c.approvalPolicy = executor.ApprovalPolicy{AllowedTools: []string{"read"}, Default: executor.ApprovalDefaultDeny}
+
+	input := `{"RequestPermission":{"tool_call_id":"t1","tool_call":{"title":"Read","kind":"read"}}}` + "\n" +
+		`{"RequestPermission":{"tool_call_id":"t2","tool_call":{"kind":"execute"}}}` + "\n"
+	c.readLoop(strings.NewReader(input))
+
+	decisions := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(stdin.String()), "\n") {
+		var resp map[string]any
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatalf("invalid response line %q: %v", line, err)
+		}
+		decisions[resp["tool_call_id"].(string)] = resp["decision"].(string)
+	}
+	if decisions["t1"] != "allow" || decisions["t2"] != "deny" {
+		t.Fatalf("expected allowlisted tool approved and unlisted tool denied, got %v", decisions)
+	}
+}
+
+type nopWriteCloser struct {
+	*bytes.Buffer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
 func TestClient_InterruptBeforeStart(t *testing.T) {
 	c := NewClientWithArgs(nil, []string{"x"})
 	// Should not panic or error.
@@ -42,6 +42,11 @@ func EventTransformer(input executor.TransformInput) executor.Event {
 		content.Phase = "failed"
 		content.Summary = "Execution failed"
 		eventType = "error"
+		if input.Log.Type == "error" {
+			content.MarkAuthFailure()
+		} else {
+			content.MarkCLIAuthFailure()
+		}
 
 	case "command":
 		content.Category = "lifecycle"
@@ -67,17 +72,24 @@ func EventTransformer(input executor.TransformInput) executor.Event {
 			if reqID, ok := obj["request_id"].(string); ok {
 				content.RequestID = reqID
 			}
-			if tc, ok := obj["tool_call"].(map[string]any); ok {
+			if tc, ok := parseJSONObject(obj["tool_call"]); ok {
 				if title, ok := tc["title"].(string); ok && title != "" {
 					content.ToolName = title
 				}
 				if kind, ok := tc["kind"].(string); ok && content.ToolName == "" {
 					content.ToolName = strings.ToLower(kind)
 				}
+				if rawInput, ok := parseJSONObject(tc["raw_input"]); ok {
+					content.Scope = executor.ApprovalScopeFromInput(rawInput)
+					content.Target = content.Scope.Target()
+				}
 			}
 			if content.ToolName != "" {
 				content.Summary = fmt.Sprintf("Waiting for approval: %s", content.ToolName)
 			}
+			if prompt, ok := executor.InteractivePromptText(obj); ok {
+				content.Summary = fmt.Sprintf("Waiting for input: %s", prompt)
+			}
 		}
 
 	// ACP event types
@@ -92,20 +104,38 @@ func EventTransformer(input executor.TransformInput) executor.Event {
 		}
 
 	case string(EventTypeThought):
-		content.Category = "progress"
+		content.Category = "thought"
 		content.Action = "thinking"
-		content.Summary = "Thinking deeply"
-		eventType = "progress"
+		content.Summary = "Thinking"
+		eventType = "thought"
+		if obj, ok := parseJSONObject(input.Log.Content); ok {
+			if inner, ok := obj[string(EventTypeThought)].(map[string]any); ok {
+				obj = inner
+			}
+			if txt := extractTextFromACPContent(obj); txt != "" {
+				content.Text = txt
+			}
+		}
 
 	case string(EventTypeToolCall), string(EventTypeToolUpdate):
 		applyACPToolMapping(&content, input.Log.Content)
 		eventType = "tool"
 
 	case string(EventTypePlan):
-		content.Category = "progress"
-		content.Action = "thinking"
+		content.Category = "plan"
+		content.Action = "planning"
 		content.Summary = "Making a plan"
-		eventType = "progress"
+		eventType = "plan"
+		if steps := parseACPPlan(input.Log.Content); len(steps) > 0 {
+			content.PlanSteps = steps
+			completed := 0
+			for _, step := range steps {
+				if step.Status == executor.PlanStepCompleted {
+					completed++
+				}
+			}
+			content.Summary = fmt.Sprintf("Making a plan (%d/%d steps completed)", completed, len(steps))
+		}
 
 	default:
 		content.Category = "progress"
@@ -124,6 +154,46 @@ func EventTransformer(input executor.TransformInput) executor.Event {
 	}
 }
 
+// parseACPPlan reads the entries of a Plan payload, wrapped as
+// {"Plan":{"entries":[...]}} or bare.
+func parseACPPlan(raw any) []executor.PlanStep {
+	obj, ok := parseJSONObject(raw)
+	if !ok {
+		return nil
+	}
+	if inner, ok := obj[string(EventTypePlan)].(map[string]any); ok {
+		obj = inner
+	}
+	entries, _ := obj["entries"].([]any)
+
+	steps := make([]executor.PlanStep, 0, len(entries))
+	for _, item := range entries {
+		entry, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		step := executor.PlanStep{}
+		step.Content, _ = entry["content"].(string)
+		step.Priority, _ = entry["priority"].(string)
+		status, _ := entry["status"].(string)
+		step.Status = mapACPPlanStatus(status)
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// mapACPPlanStatus normalizes an ACP plan entry status to the PlanStep* values.
+func mapACPPlanStatus(status string) string {
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case "completed", "complete", "done":
+		return executor.PlanStepCompleted
+	case "in_progress", "in-progress", "active", "running":
+		return executor.PlanStepInProgress
+	default:
+		return executor.PlanStepPending
+	}
+}
+
 // applyACPToolMapping reads a ToolCall or ToolUpdate payload and fills content fields.
 func applyACPToolMapping(content *executor.UnifiedContent, raw any) {
 	content.Category = "tool"
@@ -143,6 +213,7 @@ func applyACPToolMapping(content *executor.UnifiedContent, raw any) {
 	if title, ok := tc["title"].(string); ok && title != "" {
 		content.ToolName = title
 	}
+	content.ToolCallID, _ = tc["tool_call_id"].(string)
 	if status, ok := tc["status"].(string); ok {
 		content.Status = status
 		if status == string(ToolStatusCompleted) {
@@ -245,6 +316,13 @@ func parseJSONObject(v any) (map[string]any, bool) {
 		if err := json.Unmarshal([]byte(val), &out); err == nil {
 			return out, true
 		}
+	case ToolCall:
+		// Permission requests carry the typed ToolCall until they are stored.
+		data, err := json.Marshal(val)
+		if err != nil {
+			return nil, false
+		}
+		return parseJSONObject(json.RawMessage(data))
 	}
 	return nil, false
 }
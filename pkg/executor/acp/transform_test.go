@@ -165,19 +165,52 @@ func TestEventTransformer_Message(t *testing.T) {
 
 func TestEventTransformer_Thought(t *testing.T) {
 	evt := EventTransformer(makeInput(string(EventTypeThought), json.RawMessage(`{"Thought":{"Text":{"text":"thinking..."}}}`)))
-	if evt.Type != "progress" {
-		t.Errorf("expected type 'progress', got %q", evt.Type)
+	if evt.Type != "thought" {
+		t.Errorf("expected type 'thought', got %q", evt.Type)
 	}
 	uc, _ := evt.Content.(executor.UnifiedContent)
-	if uc.Action != "thinking" {
-		t.Errorf("expected action 'thinking', got %q", uc.Action)
+	if uc.Action != "thinking" || uc.Category != "thought" {
+		t.Errorf("expected thought category with action 'thinking', got %q/%q", uc.Category, uc.Action)
+	}
+	if uc.Text != "thinking..." {
+		t.Errorf("expected thought text, got %q", uc.Text)
 	}
 }
 
 func TestEventTransformer_Plan(t *testing.T) {
 	evt := EventTransformer(makeInput(string(EventTypePlan), json.RawMessage(`{"Plan":{"entries":[]}}`)))
-	if evt.Type != "progress" {
-		t.Errorf("expected type 'progress', got %q", evt.Type)
+	if evt.Type != "plan" {
+		t.Errorf("expected type 'plan', got %q", evt.Type)
+	}
+}
+
+func TestEventTransformer_PlanSteps(t *testing.T) {
+	raw := json.RawMessage(`{"Plan":{"entries":[` +
+		`{"content":"Read the code","status":"completed","priority":"high"},` +
+		`{"content":"Write the fix","status":"in_progress","priority":"medium"},` +
+		`{"content":"Run tests","status":"pending"}]}}`)
+	evt := EventTransformer(makeInput(string(EventTypePlan), raw))
+	uc, _ := evt.Content.(executor.UnifiedContent)
+	want := []executor.PlanStep{
+		{Content: "Read the code", Status: executor.PlanStepCompleted, Priority: "high"},
+		{Content: "Write the fix", Status: executor.PlanStepInProgress, Priority: "medium"},
+		{Content: "Run tests", Status: executor.PlanStepPending},
+	}
+	if len(uc.PlanSteps) != len(want) {
+		t.Fatalf("expected %d plan steps, got %+v", len(want), uc.PlanSteps)
+	}
+	for i := range want {
+		if uc.PlanSteps[i] != want[i] {
+			t.Errorf("step %d: expected %+v, got %+v", i, want[i], uc.PlanSteps[i])
+		}
+	}
+	if uc.Summary != "Making a plan (1/3 steps completed)" {
+		t.Errorf("unexpected summary %q", uc.Summary)
+	}
+
+	empty := EventTransformer(makeInput(string(EventTypePlan), json.RawMessage(`{"Plan":{"entries":[]}}`)))
+	if uc, _ := empty.Content.(executor.UnifiedContent); uc.PlanSteps != nil || uc.Summary != "Making a plan" {
+		t.Errorf("expected empty plan to keep the generic event, got %+v", uc)
 	}
 }
 
@@ -275,3 +308,31 @@ func TestDefaultSummary(t *testing.T) {
 		})
 	}
 }
+
+func TestEventTransformer_ControlRequestScope(t *testing.T) {
+	evt := EventTransformer(makeInput("control_request", map[string]any{
+		"request_id": "req-2",
+		"tool_call": ToolCall{
+			ID:       "req-2",
+			Kind:     ToolKindExecute,
+			Title:    "run_shell_command",
+			RawInput: json.RawMessage(`{"command":"npm test","directory":"web"}`),
+		},
+	}))
+	uc, _ := evt.Content.(executor.UnifiedContent)
+	if uc.ToolName != "run_shell_command" {
+		t.Errorf("expected ToolName from typed tool call, got %q", uc.ToolName)
+	}
+	if uc.Scope == nil || uc.Scope.Command != "npm test" || uc.Target != "npm test" {
+		t.Fatalf("expected command scope, got %+v", uc.Scope)
+	}
+
+	evt = EventTransformer(makeInput("control_request", map[string]any{
+		"request_id": "req-3",
+		"tool_call":  map[string]any{"title": "web_fetch", "raw_input": map[string]any{"url": "https://example.com"}},
+	}))
+	uc, _ = evt.Content.(executor.UnifiedContent)
+	if uc.Scope == nil || uc.Scope.URL != "https://example.com" {
+		t.Fatalf("expected url scope, got %+v", uc.Scope)
+	}
+}
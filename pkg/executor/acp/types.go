@@ -6,7 +6,10 @@
 // and the session/approval handshake that is common across all three.
 package acp
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"strings"
+)
 
 // EventType identifies the kind of an ACP event line.
 type EventType string
@@ -80,6 +83,15 @@ type ToolCall struct {
 	RawOutput json.RawMessage `json:"raw_output,omitempty"`
 }
 
+// toolName is the title of the tool call, or its lower-cased kind when it has
+// no title, as reported in the tool_name of approval events.
+func (t ToolCall) toolName() string {
+	if t.Title != "" {
+		return t.Title
+	}
+	return strings.ToLower(string(t.Kind))
+}
+
 // PlanEntry is one step in the agent's plan.
 type PlanEntry struct {
 	Content  string `json:"content"`
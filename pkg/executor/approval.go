@@ -0,0 +1,79 @@
+package executor
+
+import (
+	"path"
+	"strings"
+)
+
+// ApprovalDefault decides approval requests for tools not in
+// ApprovalPolicy.AllowedTools.
+type ApprovalDefault string
+
+const (
+	// ApprovalDefaultSurface emits the request for a caller to answer. It is
+	// the behaviour when ApprovalDefault is empty.
+	ApprovalDefaultSurface ApprovalDefault = "surface"
+	// ApprovalDefaultApprove approves the request immediately.
+	ApprovalDefaultApprove ApprovalDefault = "approve"
+	// ApprovalDefaultDeny denies the request immediately.
+	ApprovalDefaultDeny ApprovalDefault = "deny"
+)
+
+// Validate rejects values other than the ApprovalDefault constants and "".
+func (d ApprovalDefault) Validate() error {
+	switch d {
+	case "", ApprovalDefaultSurface, ApprovalDefaultApprove, ApprovalDefaultDeny:
+		return nil
+	}
+	return &OptionError{
+		Field:   "approval_default",
+		Value:   string(d),
+		Allowed: []string{string(ApprovalDefaultSurface), string(ApprovalDefaultApprove), string(ApprovalDefaultDeny)},
+	}
+}
+
+// ApprovalPolicy answers approval requests without a caller. Executors that
+// support it consult Decide for every control request they receive while not
+// already running in an approve-everything mode.
+type ApprovalPolicy struct {
+	// AllowedTools lists tool names approved automatically. Entries are
+	// matched case-insensitively and may use path.Match patterns such as
+	// "mcp__github__*".
+	AllowedTools []string
+	// Default decides requests for every other tool.
+	Default ApprovalDefault
+}
+
+// Decide returns the response to send for approval request requestID about
+// toolName, or false when the request should be surfaced to the caller.
+func (p ApprovalPolicy) Decide(requestID, toolName string) (ControlResponse, bool) {
+	response := ControlResponse{RequestID: requestID}
+	switch {
+	case toolName != "" && p.allows(toolName):
+		response.Decision = ControlDecisionApprove
+		response.Reason = "tool is allowlisted"
+	case p.Default == ApprovalDefaultApprove:
+		response.Decision = ControlDecisionApprove
+		response.Reason = "approved by default policy"
+	case p.Default == ApprovalDefaultDeny:
+		response.Decision = ControlDecisionDeny
+		response.Reason = "tool is not allowlisted"
+	default:
+		return ControlResponse{}, false
+	}
+	return response, true
+}
+
+func (p ApprovalPolicy) allows(toolName string) bool {
+	name := strings.ToLower(toolName)
+	for _, pattern := range p.AllowedTools {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == name {
+			return true
+		}
+		if ok, err := path.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
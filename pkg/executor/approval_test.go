@@ -0,0 +1,44 @@
+package executor
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestApprovalPolicy_Decide(t *testing.T) {
+	policy := ApprovalPolicy{AllowedTools: []string{"Read", "mcp__github__*"}, Default: ApprovalDefaultDeny}
+
+	tests := []struct {
+		tool     string
+		decision ControlDecision
+	}{
+		{"read", ControlDecisionApprove},
+		{"mcp__github__create_issue", ControlDecisionApprove},
+		{"Bash", ControlDecisionDeny},
+		{"", ControlDecisionDeny},
+	}
+	for _, tt := range tests {
+		resp, ok := policy.Decide("req-1", tt.tool)
+		if !ok || resp.Decision != tt.decision || resp.RequestID != "req-1" {
+			t.Fatalf("Decide(%q) = %+v, %v; want %s", tt.tool, resp, ok, tt.decision)
+		}
+	}
+
+	if _, ok := (ApprovalPolicy{AllowedTools: []string{"Read"}}).Decide("req-2", "Bash"); ok {
+		t.Fatal("expected unlisted tool to be surfaced under the default policy")
+	}
+	if resp, ok := (ApprovalPolicy{Default: ApprovalDefaultApprove}).Decide("req-3", "Bash"); !ok || resp.Decision != ControlDecisionApprove {
+		t.Fatalf("expected approve default to approve, got %+v, %v", resp, ok)
+	}
+}
+
+func TestApprovalDefault_Validate(t *testing.T) {
+	for _, d := range []ApprovalDefault{"", ApprovalDefaultSurface, ApprovalDefaultApprove, ApprovalDefaultDeny} {
+		if err := d.Validate(); err != nil {
+			t.Fatalf("Validate(%q) = %v", d, err)
+		}
+	}
+	if err := ApprovalDefault("maybe").Validate(); !errors.Is(err, ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package executor
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ErrorKindAuth marks error events caused by missing, invalid or expired
+// credentials, so that a UI can ask for them instead of offering a retry.
+const ErrorKindAuth = "auth"
+
+var authFailurePattern = regexp.MustCompile(`(?i)invalid[ _-]?(?:x-)?api[ _-]?key|incorrect api key|authentication_error|` +
+	`authentication failed|(?:api error|unexpected status):? 401\b|not logged in|not authenticated|no authentication information|` +
+	`please run /login|(?:run|use) \x60?(?:codex|copilot|claude|gh auth) login|(?:missing|no) api key|` +
+	`api key (?:is )?(?:not set|missing|required)|(?:openai|anthropic)_api_key (?:is )?(?:not set|missing)|oauth token (?:has )?expired`)
+
+// DetectAuthFailure reports whether text describes an authentication
+// failure, such as an invalid API key or a CLI that is not logged in.
+func DetectAuthFailure(text string) bool {
+	return authFailurePattern.MatchString(text)
+}
+
+// MarkAuthFailure turns content into an auth error when its text describes
+// one, and reports whether it did. Transformers try MarkRateLimit first, since
+// a 429 body may mention the account's credentials.
+func (c *UnifiedContent) MarkAuthFailure() bool {
+	if !DetectAuthFailure(c.Text) {
+		return false
+	}
+	c.Category = "error"
+	c.Action = "failed"
+	c.Phase = "failed"
+	c.ErrorKind = ErrorKindAuth
+	c.Summary = "Authentication failed; check the executor's credentials"
+	return true
+}
+
+// MarkCLIAuthFailure is MarkAuthFailure for a plain line of process output.
+// Stderr also carries whatever MCP servers and tools print, so only lines
+// the CLI reports as its own error ("Error: ...") are considered.
+func (c *UnifiedContent) MarkCLIAuthFailure() bool {
+	if !strings.HasPrefix(strings.TrimSpace(c.Text), "Error:") {
+		return false
+	}
+	return c.MarkAuthFailure()
+}
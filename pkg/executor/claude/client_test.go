@@ -3,6 +3,7 @@ package claude
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -39,6 +40,94 @@ func TestClaudeClient(t *testing.T) {
 	}
 }
 
+func TestClaudeClient_StartNotInstalled(t *testing.T) {
+	client := NewClient()
+	client.commandRun = func(name string, arg ...string) *exec.Cmd {
+		return exec.Command("/nonexistent/npx")
+	}
+
+	err := client.Start(context.Background(), "hello", executor.Options{WorkingDir: "."})
+	if !errors.Is(err, executor.ErrExecutorNotInstalled) {
+		t.Fatalf("expected ErrExecutorNotInstalled, got %v", err)
+	}
+}
+
+func TestClaudeClient_InstallFailure(t *testing.T) {
+	client := NewClient()
+	client.commandRun = func(name string, arg ...string) *exec.Cmd {
+		return exec.Command("sh", "-c", "echo 'npm error code E404'; exit 1")
+	}
+	if err := client.Start(context.Background(), "hello", executor.Options{WorkingDir: "."}); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+
+	for log := range client.Logs() {
+		if log.Type == "done" {
+			if !errors.Is(log.Err, executor.ErrExecutorNotInstalled) {
+				t.Fatalf("expected the done log to carry ErrExecutorNotInstalled, got %v", log.Err)
+			}
+			return
+		}
+	}
+	t.Fatal("expected a done log")
+}
+
+func TestBuildArgs(t *testing.T) {
+	args := buildArgs("hello", executor.Options{})
+	joined := strings.Join(args, " ")
+	if strings.Contains(joined, "--allowedTools") || strings.Contains(joined, "--disallowedTools") {
+		t.Fatalf("expected no tool flags by default, got %q", joined)
+	}
+
+	args = buildArgs("hello", executor.Options{
+		AllowedTools:    []string{"Read", "Grep"},
+		DisallowedTools: []string{"Edit", "Write", "Bash"},
+	})
+	joined = strings.Join(args, " ")
+	if !strings.Contains(joined, "--allowedTools Read,Grep") {
+		t.Fatalf("expected comma-joined --allowedTools, got %q", joined)
+	}
+	if !strings.Contains(joined, "--disallowedTools Edit,Write,Bash") {
+		t.Fatalf("expected comma-joined --disallowedTools, got %q", joined)
+	}
+}
+
+func TestClaudeClient_StartLauncher(t *testing.T) {
+	cases := []struct {
+		name string
+		opts executor.Options
+		want string
+	}{
+		{"Launcher", executor.Options{Launcher: "pnpm dlx"}, "pnpm dlx @anthropic-ai/claude-code@latest --print hello"},
+		{"CommandOverride", executor.Options{Launcher: "bunx", Model: "opus", CommandOverride: []string{"/opt/claude", "-p", "{prompt}"}}, "/opt/claude -p hello"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			client := NewClient()
+			client.commandRun = func(name string, arg ...string) *exec.Cmd {
+				got = append([]string{name}, arg...)
+				return mockCommand(name, arg...)
+			}
+			if err := client.Start(context.Background(), "hello", tc.opts); err != nil {
+				t.Fatalf("failed to start: %v", err)
+			}
+			defer client.Close()
+
+			if joined := strings.Join(got, " "); !strings.HasPrefix(joined, tc.want) {
+				t.Fatalf("expected command to start with %q, got %q", tc.want, joined)
+			}
+			if tc.opts.CommandOverride != nil && len(got) != len(tc.opts.CommandOverride) {
+				t.Fatalf("expected the override to replace all flags, got %q", got)
+			}
+			log := <-client.Logs()
+			if log.Type != "command" || !strings.HasPrefix(log.Content.(string), tc.want) {
+				t.Fatalf("expected the command log to show the launched command, got %+v", log)
+			}
+		})
+	}
+}
+
 func TestClaudeClient_More(t *testing.T) {
 	client := NewClient()
 	client.commandRun = mockCommand
@@ -105,6 +194,28 @@ func TestClaudeClient_More(t *testing.T) {
 	})
 }
 
+func TestClaudeClient_WaitContextSilentProcess(t *testing.T) {
+	client := NewClient()
+	client.commandRun = func(name string, arg ...string) *exec.Cmd {
+		return exec.Command("sleep", "30")
+	}
+	if err := client.Start(context.Background(), "hello", executor.Options{}); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := client.WaitContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	client.Close()
+	if err := client.WaitContext(context.Background()); err != nil {
+		t.Fatalf("expected wait to return after close, got %v", err)
+	}
+}
+
 func TestClaudeClient_buildControlPayload(t *testing.T) {
 	c := NewClient()
 
@@ -180,6 +291,30 @@ func TestClaudeClient_trackControlRequest(t *testing.T) {
 	c.trackControlRequest(map[string]any{"type": "control_request"})
 }
 
+func TestClaudeClient_ReassemblesSplitJSONLine(t *testing.T) {
+	client := NewClient()
+	client.commandRun = mockCommand
+	// Start replaces cmd.Env, so the helper switches are passed as options.
+	env := map[string]string{"GO_WANT_HELPER_PROCESS": "1", "HELPER_SPLIT_LINE": "1"}
+	if err := client.Start(context.Background(), "hello", executor.Options{WorkingDir: ".", Env: env}); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+
+	var assistant map[string]any
+	for log := range client.Logs() {
+		if log.Type == "stdout" {
+			if obj, ok := log.Content.(map[string]any); ok && obj["type"] == "assistant" {
+				assistant = obj
+			} else {
+				t.Fatalf("expected no unparsed fragments, got %#v", log.Content)
+			}
+		}
+	}
+	if assistant == nil || extractClaudeText(assistant) != "split across reads" {
+		t.Fatalf("expected reassembled assistant event, got %#v", assistant)
+	}
+}
+
 func TestParseJSONFromLine(t *testing.T) {
 	if _, ok := parseJSONFromLine("not-json"); ok {
 		t.Fatalf("expected parse failure")
@@ -204,6 +339,14 @@ func TestHelperProcess(t *testing.T) {
 	}
 	defer os.Exit(0)
 
+	if os.Getenv("HELPER_SPLIT_LINE") == "1" {
+		// One assistant event broken across two lines, as a PTY may deliver it.
+		fmt.Println(`{"type":"assistant","message":{"content":[{"type":"text","text":"spl`)
+		fmt.Println(`it across reads"}]}}`)
+		fmt.Println(`{"type": "result", "result": "ok", "is_error": false}`)
+		return
+	}
+
 	// Simulate Claude JSON output
 	fmt.Println(`{"type": "stdout", "content": "thinking..."}`)
 	fmt.Println(`{"type": "result", "result": "Hello! I am Claude.", "is_error": false}`)
@@ -3,6 +3,7 @@ package claude
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/supremeagent/executor/pkg/executor"
@@ -26,12 +27,18 @@ func EventTransformer(input executor.TransformInput) executor.Event {
 		content.Phase = "completed"
 		content.Summary = "Execution completed"
 		eventType = "done"
+		if obj, ok := parseJSONObject(input.Log.Content); ok {
+			content.Usage = claudeUsage(obj)
+		}
 	case "stderr", "error":
 		content.Category = "error"
 		content.Action = "failed"
 		content.Phase = "failed"
 		content.Summary = "Execution failed"
 		eventType = "error"
+		if !content.MarkRateLimit() {
+			content.MarkAuthFailure()
+		}
 	case "control_request":
 		content.Category = "approval"
 		content.Action = "approval_required"
@@ -41,11 +48,18 @@ func EventTransformer(input executor.TransformInput) executor.Event {
 		if obj, ok := parseJSONObject(input.Log.Content); ok {
 			if request, ok := obj["request"].(map[string]any); ok {
 				content.ToolName, _ = request["tool_name"].(string)
+				if input, ok := request["input"].(map[string]any); ok {
+					content.Scope = executor.ApprovalScopeFromInput(input)
+					content.Target = content.Scope.Target()
+				}
 			}
 			content.RequestID, _ = obj["request_id"].(string)
 			if content.ToolName != "" {
 				content.Summary = fmt.Sprintf("Waiting for approval: %s", content.ToolName)
 			}
+			if prompt, ok := executor.InteractivePromptText(obj); ok {
+				content.Summary = fmt.Sprintf("Waiting for input: %s", prompt)
+			}
 		}
 	case "result":
 		content.Category = "message"
@@ -62,6 +76,11 @@ func EventTransformer(input executor.TransformInput) executor.Event {
 		if obj, ok := parseJSONObject(input.Log.Content); ok {
 			applyClaudeObjectMapping(&content, obj)
 			eventType = eventTypeForCategory(content.Category)
+			// Claude reports API failures such as 429s, and a missing or
+			// invalid API key, as assistant text.
+			if content.Category == "message" && isAPIFailureText(content.Text) && (content.MarkRateLimit() || content.MarkAuthFailure()) {
+				eventType = "error"
+			}
 		}
 	}
 
@@ -88,21 +107,41 @@ func applyClaudeObjectMapping(content *executor.UnifiedContent, obj map[string]a
 		content.Category = "tool"
 		content.Phase = "started"
 		content.ToolName = extractClaudeToolName(obj)
+		content.ToolCallID, _ = obj["id"].(string)
 		content.Target = extractClaudeTarget(obj)
 		mapToolAction(content)
+		// TodoWrite sends the whole todo list each time, which is the plan.
+		if steps, ok := extractClaudeTodos(obj); ok {
+			applyClaudePlan(content, steps)
+		}
 	case "tool_result":
 		content.Category = "tool"
 		content.Phase = "completed"
 		content.ToolName = extractClaudeToolName(obj)
+		content.ToolCallID, _ = obj["tool_use_id"].(string)
 		content.Target = extractClaudeTarget(obj)
 		mapToolAction(content)
+		content.Files = extractClaudeFileChanges(obj)
 		if content.Summary != "" {
 			content.Summary = strings.Replace(content.Summary, "Starting", "Completed", 1)
 		}
+		// The result of a TodoWrite stays a plan event so that it pairs
+		// with its tool_use.
+		if strings.EqualFold(content.ToolName, "TodoWrite") {
+			steps, _ := extractClaudeTodos(obj)
+			applyClaudePlan(content, steps)
+		}
 	case "assistant", "message":
 		content.Category = "message"
 		content.Action = "responding"
 		content.Summary = "Generating reply"
+		// Messages carrying only thinking blocks go to the thought stream.
+		if thinking := extractClaudeThinking(obj); thinking != "" && extractClaudeText(obj) == "" {
+			content.Category = "thought"
+			content.Action = "thinking"
+			content.Summary = "Thinking"
+			content.Text = thinking
+		}
 	case "system":
 		content.Category = "progress"
 		if subtype == "init" {
@@ -130,6 +169,44 @@ func applyClaudeObjectMapping(content *executor.UnifiedContent, obj map[string]a
 	}
 }
 
+// claudeUsage returns the token usage of a result object: one entry per
+// model from modelUsage, or the model-less totals from usage.
+func claudeUsage(result map[string]any) []executor.TokenUsage {
+	if byModel, ok := result["modelUsage"].(map[string]any); ok && len(byModel) > 0 {
+		models := make([]string, 0, len(byModel))
+		for model := range byModel {
+			models = append(models, model)
+		}
+		sort.Strings(models)
+		usage := make([]executor.TokenUsage, 0, len(models))
+		for _, model := range models {
+			counts, _ := byModel[model].(map[string]any)
+			usage = append(usage, executor.TokenUsage{
+				Model:             model,
+				InputTokens:       executor.TokenCount(counts["inputTokens"]) + executor.TokenCount(counts["cacheCreationInputTokens"]),
+				CachedInputTokens: executor.TokenCount(counts["cacheReadInputTokens"]),
+				OutputTokens:      executor.TokenCount(counts["outputTokens"]),
+			})
+		}
+		return usage
+	}
+	counts, ok := result["usage"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	return []executor.TokenUsage{{
+		InputTokens:       executor.TokenCount(counts["input_tokens"]) + executor.TokenCount(counts["cache_creation_input_tokens"]),
+		CachedInputTokens: executor.TokenCount(counts["cache_read_input_tokens"]),
+		OutputTokens:      executor.TokenCount(counts["output_tokens"]),
+	}}
+}
+
+// isAPIFailureText reports whether an assistant message is a failure notice
+// written by Claude Code itself rather than by the model.
+func isAPIFailureText(text string) bool {
+	return strings.HasPrefix(text, "API Error") || strings.HasPrefix(text, "Invalid API key")
+}
+
 func mapToolAction(content *executor.UnifiedContent) {
 	name := strings.ToLower(content.ToolName)
 	target := content.Target
@@ -199,6 +276,60 @@ func extractClaudeToolName(obj map[string]any) string {
 	return ""
 }
 
+// extractClaudeTodos reads the todo list of a TodoWrite tool_use as plan
+// steps.
+func extractClaudeTodos(obj map[string]any) ([]executor.PlanStep, bool) {
+	if !strings.EqualFold(extractClaudeToolName(obj), "TodoWrite") {
+		return nil, false
+	}
+	input, _ := obj["input"].(map[string]any)
+	todos, ok := input["todos"].([]any)
+	if !ok {
+		return nil, false
+	}
+	steps := make([]executor.PlanStep, 0, len(todos))
+	for _, item := range todos {
+		todo, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		step := executor.PlanStep{Status: executor.PlanStepPending}
+		step.Content, _ = todo["content"].(string)
+		step.Priority, _ = todo["priority"].(string)
+		switch todo["status"] {
+		case executor.PlanStepInProgress:
+			step.Status = executor.PlanStepInProgress
+		case executor.PlanStepCompleted:
+			step.Status = executor.PlanStepCompleted
+		}
+		steps = append(steps, step)
+	}
+	return steps, true
+}
+
+// applyClaudePlan turns a TodoWrite tool_use or tool_result content into a
+// plan update. It keeps the tool name and call id, so the SDK still counts
+// the tool_use as a tool call.
+func applyClaudePlan(content *executor.UnifiedContent, steps []executor.PlanStep) {
+	completed := 0
+	for _, step := range steps {
+		if step.Status == executor.PlanStepCompleted {
+			completed++
+		}
+	}
+	content.Category = "plan"
+	content.Action = "planning"
+	content.PlanSteps = steps
+	if content.Phase == "completed" {
+		content.Summary = "Updated the plan"
+		if len(steps) > 0 {
+			content.Summary = fmt.Sprintf("Updated the plan (%d/%d steps completed)", completed, len(steps))
+		}
+		return
+	}
+	content.Summary = fmt.Sprintf("Updating the plan (%d/%d steps completed)", completed, len(steps))
+}
+
 func extractClaudeTarget(obj map[string]any) string {
 	if input, ok := obj["input"].(map[string]any); ok {
 		if path, ok := input["file_path"].(string); ok && path != "" {
@@ -217,6 +348,64 @@ func extractClaudeTarget(obj map[string]any) string {
 	return ""
 }
 
+// extractClaudeFileChanges derives file changes from an edit/write tool_result.
+func extractClaudeFileChanges(obj map[string]any) []executor.FileChange {
+	input, _ := obj["input"].(map[string]any)
+	if input == nil {
+		return nil
+	}
+	path, _ := input["file_path"].(string)
+	if path == "" {
+		path, _ = input["notebook_path"].(string)
+	}
+	if path == "" {
+		return nil
+	}
+
+	change := executor.FileChange{Path: path}
+	name := strings.ToLower(extractClaudeToolName(obj))
+	switch {
+	case strings.Contains(name, "multiedit"):
+		change.Op = executor.FileOpModify
+		edits, _ := input["edits"].([]any)
+		for _, item := range edits {
+			if edit, ok := item.(map[string]any); ok {
+				change.Deletions += countLines(edit["old_string"])
+				change.Additions += countLines(edit["new_string"])
+			}
+		}
+	case strings.Contains(name, "edit"):
+		change.Op = executor.FileOpModify
+		change.Deletions = countLines(input["old_string"])
+		change.Additions = countLines(input["new_string"])
+	case strings.Contains(name, "write"):
+		change.Op = executor.FileOpCreate
+		change.Additions = countLines(input["content"])
+	default:
+		return nil
+	}
+
+	// Claude reports whether a write created or updated the file.
+	if result, ok := obj["tool_use_result"].(map[string]any); ok {
+		switch result["type"] {
+		case "create":
+			change.Op = executor.FileOpCreate
+		case "update":
+			change.Op = executor.FileOpModify
+		}
+	}
+
+	return []executor.FileChange{change}
+}
+
+func countLines(v any) int {
+	text, _ := v.(string)
+	if text == "" {
+		return 0
+	}
+	return strings.Count(strings.TrimSuffix(text, "\n"), "\n") + 1
+}
+
 func extractClaudeText(obj map[string]any) string {
 	if result, ok := obj["result"].(string); ok && result != "" {
 		return result
@@ -239,10 +428,34 @@ func extractClaudeText(obj map[string]any) string {
 	return ""
 }
 
+// extractClaudeThinking joins the text of the message's thinking blocks.
+func extractClaudeThinking(obj map[string]any) string {
+	msg, ok := obj["message"].(map[string]any)
+	if !ok {
+		return ""
+	}
+	blocks, _ := msg["content"].([]any)
+	var parts []string
+	for _, item := range blocks {
+		block, ok := item.(map[string]any)
+		if !ok || block["type"] != "thinking" {
+			continue
+		}
+		if text, ok := block["thinking"].(string); ok && text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
 func eventTypeForCategory(category string) string {
 	switch category {
 	case "tool":
 		return "tool"
+	case "thought":
+		return "thought"
+	case "plan":
+		return "plan"
 	case "progress", "lifecycle":
 		return "progress"
 	case "done":
@@ -2,7 +2,10 @@ package claude
 
 import (
 	"encoding/json"
+	"fmt"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/supremeagent/executor/pkg/executor"
 )
@@ -35,11 +38,11 @@ func TestEventTransformer_ControlToolAndDone(t *testing.T) {
 		Executor:  "claude_code",
 		Log: executor.Log{
 			Type:    "stdout",
-			Content: `{"type":"tool_use","tool_name":"Read","input":{"file_path":"/tmp/a.go"}}`,
+			Content: `{"type":"tool_use","id":"toolu_1","tool_name":"Read","input":{"file_path":"/tmp/a.go"}}`,
 		},
 	})
 	toolContent := toolEvt.Content.(executor.UnifiedContent)
-	if toolEvt.Type != "tool" || toolContent.Action != "reading" || toolContent.Target != "/tmp/a.go" {
+	if toolEvt.Type != "tool" || toolContent.Action != "reading" || toolContent.Target != "/tmp/a.go" || toolContent.ToolCallID != "toolu_1" {
 		t.Fatalf("unexpected tool event, got type=%s content=%+v", toolEvt.Type, toolContent)
 	}
 
@@ -240,3 +243,268 @@ func TestMapToolAction_Branches(t *testing.T) {
 		})
 	}
 }
+
+func TestEventTransformer_ToolResultFileChanges(t *testing.T) {
+	evt := EventTransformer(executor.TransformInput{
+		SessionID: "s1",
+		Executor:  "claude_code",
+		Log: executor.Log{
+			Type:    "stdout",
+			Content: `{"type":"tool_result","tool_name":"Edit","input":{"file_path":"main.go","old_string":"a\nb","new_string":"a\nb\nc"}}`,
+		},
+	})
+	content := evt.Content.(executor.UnifiedContent)
+	if len(content.Files) != 1 {
+		t.Fatalf("expected one file change, got %+v", content.Files)
+	}
+	if got := content.Files[0]; got.Path != "main.go" || got.Op != executor.FileOpModify || got.Additions != 3 || got.Deletions != 2 {
+		t.Fatalf("unexpected file change: %+v", got)
+	}
+
+	evt = EventTransformer(executor.TransformInput{
+		Executor: "claude_code",
+		Log: executor.Log{
+			Type:    "stdout",
+			Content: `{"type":"tool_result","tool_name":"Write","input":{"file_path":"new.go","content":"package x\n"},"tool_use_result":{"type":"create"}}`,
+		},
+	})
+	content = evt.Content.(executor.UnifiedContent)
+	if len(content.Files) != 1 || content.Files[0].Op != executor.FileOpCreate || content.Files[0].Additions != 1 {
+		t.Fatalf("unexpected write file change: %+v", content.Files)
+	}
+
+	evt = EventTransformer(executor.TransformInput{
+		Executor: "claude_code",
+		Log:      executor.Log{Type: "stdout", Content: `{"type":"tool_result","tool_name":"Read","input":{"file_path":"main.go"}}`},
+	})
+	if files := evt.Content.(executor.UnifiedContent).Files; files != nil {
+		t.Fatalf("expected no file changes for read tool, got %+v", files)
+	}
+}
+
+func TestEventTransformer_ControlRequestScope(t *testing.T) {
+	evt := EventTransformer(executor.TransformInput{
+		SessionID: "s1",
+		Executor:  "claude_code",
+		Log: executor.Log{
+			Type:    "control_request",
+			Content: `{"type":"control_request","request_id":"req-2","request":{"subtype":"can_use_tool","tool_name":"Bash","input":{"command":"rm -rf build","description":"clean"}}}`,
+		},
+	})
+	content := evt.Content.(executor.UnifiedContent)
+	if content.Scope == nil || content.Scope.Command != "rm -rf build" || content.Target != "rm -rf build" {
+		t.Fatalf("expected command scope, got %+v", content.Scope)
+	}
+
+	evt = EventTransformer(executor.TransformInput{
+		SessionID: "s1",
+		Executor:  "claude_code",
+		Log: executor.Log{
+			Type: "control_request",
+			Content: map[string]any{
+				"request_id": "req-3",
+				"request":    map[string]any{"tool_name": "Write", "input": map[string]any{"file_path": "/repo/main.go"}},
+			},
+		},
+	})
+	content = evt.Content.(executor.UnifiedContent)
+	if content.Scope == nil || len(content.Scope.Paths) != 1 || content.Scope.Paths[0] != "/repo/main.go" {
+		t.Fatalf("expected path scope, got %+v", content.Scope)
+	}
+}
+
+func TestEventTransformer_RateLimit(t *testing.T) {
+	evt := EventTransformer(executor.TransformInput{
+		SessionID: "s1",
+		Executor:  "claude_code",
+		Log: executor.Log{
+			Type:    "error",
+			Content: `API Error: 429 {"type":"error","error":{"type":"rate_limit_error","message":"This request would exceed the rate limit for your organization. Please try again in 30 seconds."}}`,
+		},
+	})
+	if evt.Type != "error" {
+		t.Fatalf("expected error event, got %s", evt.Type)
+	}
+	content := evt.Content.(executor.UnifiedContent)
+	if content.ErrorKind != executor.ErrorKindRateLimit || content.RetryAfter != 30 {
+		t.Fatalf("expected rate_limit retry 30, got %q/%d", content.ErrorKind, content.RetryAfter)
+	}
+
+	evt = EventTransformer(executor.TransformInput{
+		SessionID: "s1",
+		Executor:  "claude_code",
+		Log: executor.Log{
+			Type: "stdout",
+			Content: map[string]any{
+				"type": "assistant",
+				"message": map[string]any{"content": []any{
+					map[string]any{"type": "text", "text": `API Error: 429 {"type":"error","error":{"type":"rate_limit_error","message":"Number of requests has exceeded your rate limit"}}`},
+				}},
+			},
+		},
+	})
+	content = evt.Content.(executor.UnifiedContent)
+	if evt.Type != "error" || content.ErrorKind != executor.ErrorKindRateLimit || content.RetryAfter != 0 {
+		t.Fatalf("expected assistant API error to be a rate limit, got %s %+v", evt.Type, content)
+	}
+
+	resetAt := time.Now().Add(time.Hour).Unix()
+	evt = EventTransformer(executor.TransformInput{
+		SessionID: "s1",
+		Executor:  "claude_code",
+		Log:       executor.Log{Type: "error", Content: fmt.Sprintf("Claude AI usage limit reached|%d", resetAt)},
+	})
+	content = evt.Content.(executor.UnifiedContent)
+	if content.ErrorKind != executor.ErrorKindRateLimit || content.RetryAfter < 3590 || content.RetryAfter > 3600 {
+		t.Fatalf("expected usage limit retry of about an hour, got %q/%d", content.ErrorKind, content.RetryAfter)
+	}
+
+	evt = EventTransformer(executor.TransformInput{
+		SessionID: "s1",
+		Executor:  "claude_code",
+		Log:       executor.Log{Type: "error", Content: "API Error: 500 internal server error"},
+	})
+	if content := evt.Content.(executor.UnifiedContent); content.ErrorKind != "" || content.Summary != "Execution failed" {
+		t.Fatalf("expected other errors to stay unclassified, got %+v", content)
+	}
+}
+
+func TestEventTransformer_Thinking(t *testing.T) {
+	evt := EventTransformer(executor.TransformInput{
+		SessionID: "s1",
+		Executor:  "claude_code",
+		Log: executor.Log{Type: "stdout", Content: map[string]any{
+			"type": "assistant",
+			"message": map[string]any{"content": []any{
+				map[string]any{"type": "thinking", "thinking": "The user wants a test.", "signature": "sig"},
+			}},
+		}},
+	})
+	content := evt.Content.(executor.UnifiedContent)
+	if evt.Type != "thought" || content.Category != "thought" || content.Text != "The user wants a test." {
+		t.Fatalf("expected thinking block as a thought event, got %s %+v", evt.Type, content)
+	}
+
+	evt = EventTransformer(executor.TransformInput{
+		SessionID: "s1",
+		Executor:  "claude_code",
+		Log: executor.Log{Type: "stdout", Content: map[string]any{
+			"type": "assistant",
+			"message": map[string]any{"content": []any{
+				map[string]any{"type": "text", "text": "Here you go."},
+			}},
+		}},
+	})
+	if evt.Type != "message" {
+		t.Fatalf("expected text blocks to stay messages, got %s", evt.Type)
+	}
+}
+
+func TestEventTransformer_AuthFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		log  executor.Log
+	}{
+		{
+			name: "AssistantNotice",
+			log: executor.Log{Type: "stdout", Content: map[string]any{
+				"type": "assistant",
+				"message": map[string]any{"content": []any{
+					map[string]any{"type": "text", "text": "Invalid API key · Please run /login"},
+				}},
+			}},
+		},
+		{
+			name: "ErrorResult",
+			log:  executor.Log{Type: "error", Content: `API Error: 401 {"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evt := EventTransformer(executor.TransformInput{SessionID: "s1", Executor: "claude_code", Log: tt.log})
+			content := evt.Content.(executor.UnifiedContent)
+			if evt.Type != "error" || content.ErrorKind != executor.ErrorKindAuth {
+				t.Fatalf("expected auth error, got %s %+v", evt.Type, content)
+			}
+		})
+	}
+
+	evt := EventTransformer(executor.TransformInput{SessionID: "s1", Executor: "claude_code", Log: executor.Log{
+		Type: "stdout",
+		Content: map[string]any{
+			"type": "assistant",
+			"message": map[string]any{"content": []any{
+				map[string]any{"type": "text", "text": "The handler now returns 401 Unauthorized for invalid API keys."},
+			}},
+		},
+	}})
+	if content := evt.Content.(executor.UnifiedContent); evt.Type != "message" || content.ErrorKind != "" {
+		t.Fatalf("expected model text about auth to stay a message, got %s %+v", evt.Type, content)
+	}
+}
+
+func TestEventTransformer_TodoWritePlan(t *testing.T) {
+	evt := EventTransformer(executor.TransformInput{
+		SessionID: "s1",
+		Executor:  "claude_code",
+		Log: executor.Log{
+			Type: "stdout",
+			Content: `{"type":"tool_use","id":"toolu_9","tool_name":"TodoWrite","input":{"todos":[` +
+				`{"content":"Read the code","status":"completed","activeForm":"Reading the code"},` +
+				`{"content":"Write the fix","status":"in_progress","activeForm":"Writing the fix"},` +
+				`{"content":"Run tests","status":"pending","activeForm":"Running tests"}]}}`,
+		},
+	})
+	content := evt.Content.(executor.UnifiedContent)
+	want := []executor.PlanStep{
+		{Content: "Read the code", Status: executor.PlanStepCompleted},
+		{Content: "Write the fix", Status: executor.PlanStepInProgress},
+		{Content: "Run tests", Status: executor.PlanStepPending},
+	}
+	if evt.Type != "plan" || !reflect.DeepEqual(content.PlanSteps, want) {
+		t.Fatalf("expected a plan event with the todo list, got type=%s steps=%+v", evt.Type, content.PlanSteps)
+	}
+	if content.Summary != "Updating the plan (1/3 steps completed)" || content.ToolCallID != "toolu_9" || content.Phase != "started" {
+		t.Fatalf("unexpected plan content %+v", content)
+	}
+
+	evt = EventTransformer(executor.TransformInput{
+		SessionID: "s1",
+		Executor:  "claude_code",
+		Log: executor.Log{
+			Type:    "stdout",
+			Content: `{"type":"tool_result","tool_use_id":"toolu_9","tool_name":"TodoWrite","content":"Todos have been modified successfully"}`,
+		},
+	})
+	content = evt.Content.(executor.UnifiedContent)
+	if evt.Type != "plan" || content.ToolCallID != "toolu_9" || content.Phase != "completed" || content.PlanSteps != nil {
+		t.Fatalf("expected the TodoWrite result to close the plan call, got type=%s %+v", evt.Type, content)
+	}
+}
+
+func TestEventTransformer_TokenUsage(t *testing.T) {
+	result := map[string]any{
+		"type":   "result",
+		"result": "ok",
+		"usage":  map[string]any{"input_tokens": float64(10), "output_tokens": float64(20)},
+		"modelUsage": map[string]any{
+			"claude-sonnet-4-5-20250929": map[string]any{"inputTokens": float64(100), "cacheCreationInputTokens": float64(50), "cacheReadInputTokens": float64(400), "outputTokens": float64(80)},
+			"claude-3-5-haiku-20241022":  map[string]any{"inputTokens": float64(30), "outputTokens": float64(5)},
+		},
+	}
+	evt := EventTransformer(executor.TransformInput{SessionID: "s1", Executor: "claude_code", Log: executor.Log{Type: "done", Content: result}})
+	want := []executor.TokenUsage{
+		{Model: "claude-3-5-haiku-20241022", InputTokens: 30, OutputTokens: 5},
+		{Model: "claude-sonnet-4-5-20250929", InputTokens: 150, CachedInputTokens: 400, OutputTokens: 80},
+	}
+	if got := evt.Content.(executor.UnifiedContent).Usage; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected per-model usage %+v, got %+v", want, got)
+	}
+
+	delete(result, "modelUsage")
+	evt = EventTransformer(executor.TransformInput{SessionID: "s1", Executor: "claude_code", Log: executor.Log{Type: "done", Content: result}})
+	want = []executor.TokenUsage{{InputTokens: 10, OutputTokens: 20}}
+	if got := evt.Content.(executor.UnifiedContent).Usage; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected total usage %+v, got %+v", want, got)
+	}
+}
@@ -1,7 +1,6 @@
 package codex
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -20,6 +19,11 @@ type Client struct {
 	cmd    *exec.Cmd
 	stdin  io.WriteCloser
 	stdout io.ReadCloser
+	exit   executor.ProcessExit
+	// install notices npx failing to fetch the package.
+	install executor.InstallWatch
+	// stderrDone is closed once stderr reaches EOF.
+	stderrDone chan struct{}
 
 	logsChan  chan executor.Log
 	doneChan  chan struct{}
@@ -28,25 +32,48 @@ type Client struct {
 	mu        sync.Mutex
 
 	conversationID string
+	// turn holds the conversation settings repeated on every sendUserTurn.
+	turn           SendUserTurnParams
 	autoApprove    bool
+	approvalPolicy executor.ApprovalPolicy
+	traceRPC       bool
 
-	pendingMu sync.Mutex
-	pending   map[int64]chan JSONRPCMessage
-	control   map[string]RequestID
+	pendingMu  sync.Mutex
+	pending    map[int64]pendingRequest
+	maxPending int
+	control    map[string]RequestID
+
+	requestTimeout time.Duration
 
 	commandRun func(name string, arg ...string) *exec.Cmd
 	idCounter  int64
 }
 
+// DefaultMaxPendingRequests caps outstanding JSON-RPC requests awaiting a
+// response; the oldest is evicted when the cap is reached.
+const DefaultMaxPendingRequests = 256
+
+// DefaultRequestTimeout is how long a JSON-RPC request waits for a response
+// when Options.CodexRequestTimeout is not set.
+const DefaultRequestTimeout = 60 * time.Second
+
+type pendingRequest struct {
+	ch     chan JSONRPCMessage
+	sentAt time.Time
+}
+
 // NewClient creates a new Codex client
 func NewClient() *Client {
 	return &Client{
 		logsChan:   make(chan executor.Log, 100),
 		doneChan:   make(chan struct{}),
-		pending:    make(map[int64]chan JSONRPCMessage),
+		pending:    make(map[int64]pendingRequest),
+		maxPending: DefaultMaxPendingRequests,
 		control:    make(map[string]RequestID),
 		commandRun: exec.Command,
 		idCounter:  1,
+
+		requestTimeout: DefaultRequestTimeout,
 	}
 }
 
@@ -60,10 +87,21 @@ func (c *Client) nextID() RequestID {
 
 // Start starts the Codex executor with the given prompt
 func (c *Client) Start(ctx context.Context, prompt string, opts executor.Options) error {
+	if opts.CodexMaxPendingRequests > 0 {
+		c.pendingMu.Lock()
+		c.maxPending = opts.CodexMaxPendingRequests
+		c.pendingMu.Unlock()
+	}
+
+	c.traceRPC = opts.CodexTraceRPC
+	if opts.CodexRequestTimeout > 0 {
+		c.requestTimeout = opts.CodexRequestTimeout
+	}
+
 	// Build command for Codex app-server
-	args := []string{"-y", "@openai/codex@0.104.0", "app-server", "--listen", "stdio://"}
+	argv := opts.LaunchCommand(prompt, []string{"@openai/codex@0.104.0", "app-server", "--listen", "stdio://"})
 
-	cmd := c.commandRun("npx", args...)
+	cmd := c.commandRun(argv[0], argv[1:]...)
 	cmd.Dir = opts.WorkingDir
 	cmd.Env = executor.BuildCommandEnv(opts.Env)
 
@@ -88,59 +126,61 @@ func (c *Client) Start(ctx context.Context, prompt string, opts executor.Options
 	c.stdout = stdout
 
 	// Log the command being executed
-	c.sendLog(executor.Log{Type: "init", Content: fmt.Sprintf("npx %s", strings.Join(args, " "))})
+	c.sendLog(executor.Log{Type: "init", Content: strings.Join(opts.RedactArgs(argv), " ")})
 
 	// Start the process
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start codex: %w", err)
+		return fmt.Errorf("failed to start codex: %w", executor.WrapStartError(err))
 	}
 
-	// Handle stderr in background
+	// Handle stderr in background; npm/node noise is downgraded to debug.
+	c.stderrDone = make(chan struct{})
 	go func() {
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			line := scanner.Text()
-			c.sendLog(executor.Log{Type: "error", Content: line})
-		}
+		defer close(c.stderrDone)
+		_ = executor.ScanLines(stderr, c.sendLog, func(line string) bool {
+			c.sendLog(executor.Log{Type: opts.ClassifyStderr(line), Content: line})
+			return true
+		})
 	}()
 
 	// Determine auto-approve setting.
 	// Only explicit "never" should auto-approve; empty value defaults to unless-trusted.
 	c.autoApprove = strings.EqualFold(strings.TrimSpace(opts.AskForApproval), "never")
+	c.approvalPolicy = opts.ApprovalPolicy
 
 	// Start reading responses in background
 	go c.readLoop(ctx, stdout)
 
 	// Run initialization and starting in a goroutine
 	go func() {
-		sendError := func(msg string) {
-			c.sendLog(executor.Log{Type: "error", Content: msg})
+		sendError := func(err error) {
+			c.sendLog(executor.Log{Type: "error", Content: err.Error(), Err: err})
 			c.Close()
 		}
 
 		// Initialize the connection
 		if err := c.initialize(); err != nil {
-			sendError(fmt.Sprintf("failed to initialize: %v", err))
+			sendError(fmt.Errorf("failed to initialize: %w", err))
 			return
 		}
 
 		// Start a new conversation
 		conversationID, err := c.startOrResumeConversation(opts)
 		if err != nil {
-			sendError(fmt.Sprintf("failed to create conversation: %v", err))
+			sendError(fmt.Errorf("failed to create conversation: %w", err))
 			return
 		}
 		c.conversationID = conversationID
 
 		// Add conversation listener
 		if err := c.addListener(conversationID); err != nil {
-			sendError(fmt.Sprintf("failed to add listener: %v", err))
+			sendError(fmt.Errorf("failed to add listener: %w", err))
 			return
 		}
 
 		// Send the user message
 		if err := c.sendUserMessage(conversationID, prompt); err != nil {
-			sendError(fmt.Sprintf("failed to send message: %v", err))
+			sendError(fmt.Errorf("failed to send message: %w", err))
 			return
 		}
 	}()
@@ -184,22 +224,13 @@ func (c *Client) initialize() error {
 }
 
 func (c *Client) newConversation(opts executor.Options) (string, error) {
-	sandbox := opts.Sandbox
-	if sandbox == "" {
-		sandbox = "workspace-write"
-	}
-
-	askForApproval := opts.AskForApproval
-	if askForApproval == "" {
-		askForApproval = "unless-trusted"
-	}
-
+	sandbox, askForApproval := conversationPolicies(opts)
 	params := NewConversationParams{
 		Model:                opts.Model,
 		Sandbox:              sandbox,
 		AskForApproval:       askForApproval,
 		ModelReasoningEffort: opts.ModelReasoningEffort,
-		WorkingDirectory:     opts.WorkingDir,
+		WorkingDirectory:     conversationWorkingDir(opts),
 	}
 
 	req := JSONRPCMessage{
@@ -219,9 +250,51 @@ func (c *Client) newConversation(opts executor.Options) (string, error) {
 		return "", fmt.Errorf("failed to parse newConversation result: %w", err)
 	}
 
+	c.rememberTurnContext(opts, result.Model)
 	return result.ConversationID, nil
 }
 
+// conversationPolicies returns the sandbox mode and approval policy for a
+// new conversation, applying Codex's defaults for empty options.
+func conversationPolicies(opts executor.Options) (sandbox, askForApproval string) {
+	sandbox = opts.Sandbox
+	if sandbox == "" {
+		sandbox = "workspace-write"
+	}
+	askForApproval = opts.AskForApproval
+	if askForApproval == "" {
+		askForApproval = "unless-trusted"
+	}
+	return sandbox, askForApproval
+}
+
+// rememberTurnContext records the conversation settings SendTurn repeats.
+// model is the model the app-server reported, if any.
+func (c *Client) rememberTurnContext(opts executor.Options, model string) {
+	sandbox, askForApproval := conversationPolicies(opts)
+	if model == "" {
+		model = opts.Model
+	}
+	c.mu.Lock()
+	c.turn = SendUserTurnParams{
+		Cwd:            conversationWorkingDir(opts),
+		ApprovalPolicy: askForApproval,
+		SandboxPolicy:  SandboxPolicy{Mode: sandbox},
+		Model:          model,
+		Summary:        "auto",
+	}
+	c.mu.Unlock()
+}
+
+// conversationWorkingDir returns the workingDirectory sent to Codex, which
+// may differ from the process cwd when CodexWorkingDirectory is set.
+func conversationWorkingDir(opts executor.Options) string {
+	if opts.CodexWorkingDirectory != "" {
+		return opts.CodexWorkingDirectory
+	}
+	return opts.WorkingDir
+}
+
 func (c *Client) resumeConversation(opts executor.Options) (string, error) {
 	params := ResumeConversationParams{
 		Path: opts.ResumePath,
@@ -230,7 +303,7 @@ func (c *Client) resumeConversation(opts executor.Options) (string, error) {
 			Sandbox:              opts.Sandbox,
 			AskForApproval:       opts.AskForApproval,
 			ModelReasoningEffort: opts.ModelReasoningEffort,
-			WorkingDirectory:     opts.WorkingDir,
+			WorkingDirectory:     conversationWorkingDir(opts),
 		},
 	}
 	if opts.ResumeSessionID != "" {
@@ -254,6 +327,7 @@ func (c *Client) resumeConversation(opts executor.Options) (string, error) {
 		return "", fmt.Errorf("failed to parse resumeConversation result: %w", err)
 	}
 
+	c.rememberTurnContext(opts, result.Model)
 	return result.ConversationID, nil
 }
 
@@ -318,6 +392,31 @@ func (c *Client) SendMessage(ctx context.Context, message string) error {
 	return c.sendUserMessage(c.conversationID, message)
 }
 
+// SendTurn sends message like SendMessage, applying turn's overrides to this
+// turn only. Without overrides it is the same as SendMessage.
+func (c *Client) SendTurn(ctx context.Context, message string, turn executor.TurnOptions) error {
+	if turn.ModelReasoningEffort == "" {
+		return c.SendMessage(ctx, message)
+	}
+
+	c.mu.Lock()
+	params := c.turn
+	c.mu.Unlock()
+	params.ConversationID = c.conversationID
+	params.Items = []InputItem{{Type: "text", Data: InputItemData{Text: message}}}
+	params.Effort = turn.ModelReasoningEffort
+
+	req := JSONRPCMessage{
+		JSONRPC: "2.0",
+		ID:      ptrToRequestID(c.nextID()),
+		Method:  "sendUserTurn",
+		Params:  mustJSON(params),
+	}
+
+	_, err := c.sendRequest(req)
+	return err
+}
+
 func (c *Client) RespondControl(ctx context.Context, response executor.ControlResponse) error {
 	c.pendingMu.Lock()
 	reqID, ok := c.control[response.RequestID]
@@ -350,12 +449,82 @@ func (c *Client) RespondControl(ctx context.Context, response executor.ControlRe
 	return nil
 }
 
+// Sandbox modes and approval policies accepted by the Codex app-server.
+var (
+	SandboxModes     = []string{"read-only", "workspace-write", "danger-full-access"}
+	ApprovalPolicies = []string{"never", "on-request", "on-failure", "unless-trusted"}
+)
+
+// ValidateOptions rejects sandbox and approval values Codex does not accept.
+func (c *Client) ValidateOptions(opts executor.Options) error {
+	if err := executor.ValidateChoice(string(executor.ExecutorCodex), "sandbox", opts.Sandbox, SandboxModes); err != nil {
+		return err
+	}
+	return executor.ValidateChoice(string(executor.ExecutorCodex), "ask_for_approval", opts.AskForApproval, ApprovalPolicies)
+}
+
+// CaptureResume records the conversation ID and rollout path returned by
+// newConversation/resumeConversation and carried by conversation events.
+func (c *Client) CaptureResume(entry executor.Log, prior executor.ResumeState) executor.ResumeState {
+	obj, ok := executor.DecodeJSONObject(entry.Content)
+	if !ok {
+		return prior
+	}
+	if result, ok := obj["result"].(map[string]any); ok {
+		if conv, ok := result["conversationId"].(string); ok && conv != "" {
+			prior.SessionID = conv
+		}
+		if rollout, ok := result["rolloutPath"].(string); ok && rollout != "" {
+			prior.Path = rollout
+		}
+	}
+	if conv, ok := obj["conversationId"].(string); ok && conv != "" {
+		prior.SessionID = conv
+	}
+	return prior
+}
+
+// CaptureVersion reads the CLI version from the userAgent returned by
+// initialize, e.g. "codex_cli_rs/0.46.0 (Mac OS 15.0.0; arm64)".
+func (c *Client) CaptureVersion(entry executor.Log) string {
+	obj, ok := executor.DecodeJSONObject(entry.Content)
+	if !ok {
+		return ""
+	}
+	result, _ := obj["result"].(map[string]any)
+	userAgent, _ := result["userAgent"].(string)
+	if userAgent == "" {
+		return ""
+	}
+	product, _, _ := strings.Cut(userAgent, " ")
+	if _, version, ok := strings.Cut(product, "/"); ok {
+		return version
+	}
+	return product
+}
+
+// Capabilities reports the optional operations this executor supports.
+func (c *Client) Capabilities() executor.Capabilities {
+	return executor.Capabilities{Resume: true, Interactive: true, Control: true}
+}
+
 // Wait waits for the execution to complete
 func (c *Client) Wait() error {
 	<-c.doneChan
 	return nil
 }
 
+// WaitContext waits for the execution to complete or for ctx to be done.
+// It returns ctx.Err() if the context ends the wait first.
+func (c *Client) WaitContext(ctx context.Context) error {
+	select {
+	case <-c.doneChan:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Logs returns a channel for streaming logs
 func (c *Client) Logs() <-chan executor.Log {
 	return c.logsChan
@@ -377,7 +546,7 @@ func (c *Client) Close() error {
 
 		if c.cmd != nil && c.cmd.Process != nil {
 			c.cmd.Process.Kill()
-			c.cmd.Wait()
+			_ = c.exit.Wait(c.cmd)
 		}
 
 		if c.stdin != nil {
@@ -385,8 +554,8 @@ func (c *Client) Close() error {
 		}
 
 		c.pendingMu.Lock()
-		for _, ch := range c.pending {
-			close(ch)
+		for _, p := range c.pending {
+			close(p.ch)
 		}
 		c.pending = nil
 		c.control = nil
@@ -402,6 +571,7 @@ func (c *Client) sendLog(log executor.Log) {
 	if c.closed {
 		return
 	}
+	c.install.Observe(log)
 	c.logsChan <- log
 }
 
@@ -411,6 +581,8 @@ func (c *Client) sendJSON(v any) error {
 		return fmt.Errorf("failed to marshal JSON: %w", err)
 	}
 
+	c.trace("outbound", data)
+
 	c.pendingMu.Lock()
 	if c.stdin == nil {
 		c.pendingMu.Unlock()
@@ -444,9 +616,17 @@ func (c *Client) sendRequest(req JSONRPCMessage) (JSONRPCMessage, error) {
 		c.pendingMu.Unlock()
 		return JSONRPCMessage{}, fmt.Errorf("client closed")
 	}
-	c.pending[id] = ch
+	evicted, evictedOK := c.evictOldestPendingLocked()
+	c.pending[id] = pendingRequest{ch: ch, sentAt: time.Now()}
 	c.pendingMu.Unlock()
 
+	if evictedOK {
+		c.sendLog(executor.Log{
+			Type:    "warning",
+			Content: fmt.Sprintf("codex: %d pending requests reached, evicted unanswered request %d", c.maxPending, evicted),
+		})
+	}
+
 	defer func() {
 		c.pendingMu.Lock()
 		if c.pending != nil {
@@ -459,92 +639,171 @@ func (c *Client) sendRequest(req JSONRPCMessage) (JSONRPCMessage, error) {
 		return JSONRPCMessage{}, err
 	}
 
+	timer := time.NewTimer(c.requestTimeout)
+	defer timer.Stop()
+
 	select {
 	case resp, ok := <-ch:
 		if !ok {
 			return JSONRPCMessage{}, fmt.Errorf("channel closed")
 		}
 		if resp.Error != nil {
-			return JSONRPCMessage{}, fmt.Errorf("RPC error: %s (code: %d)", resp.Error.Message, resp.Error.Code)
+			return JSONRPCMessage{}, &executor.RPCError{Code: resp.Error.Code, Message: resp.Error.Message}
 		}
 		return resp, nil
-	case <-time.After(60 * time.Second):
-		return JSONRPCMessage{}, fmt.Errorf("timeout waiting for response")
+	case <-timer.C:
+		// An app-server that stops answering is treated as dead: close, which
+		// also fails every other pending request. The caller reports the
+		// timeout, so it is logged once.
+		c.Close()
+		return JSONRPCMessage{}, fmt.Errorf("%w waiting for %s response after %s", executor.ErrTimeout, req.Method, c.requestTimeout)
+	}
+}
+
+// evictOldestPendingLocked drops the oldest pending request when the map is
+// at capacity, closing its channel so the waiting caller fails fast.
+// c.pendingMu must be held.
+func (c *Client) evictOldestPendingLocked() (int64, bool) {
+	if c.maxPending <= 0 || len(c.pending) < c.maxPending {
+		return 0, false
+	}
+
+	var oldestID int64
+	var oldest time.Time
+	found := false
+	for id, p := range c.pending {
+		if !found || p.sentAt.Before(oldest) || (p.sentAt.Equal(oldest) && id < oldestID) {
+			oldestID, oldest, found = id, p.sentAt, true
+		}
+	}
+	close(c.pending[oldestID].ch)
+	delete(c.pending, oldestID)
+	return oldestID, true
+}
+
+// trace emits a raw JSON-RPC message as a debug log when tracing is enabled.
+func (c *Client) trace(direction string, data []byte) {
+	if !c.traceRPC {
+		return
+	}
+
+	var msg JSONRPCMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return
 	}
+	if msg.Method == "sendUserMessage" {
+		var params SendUserMessageParams
+		if err := json.Unmarshal(msg.Params, &params); err == nil {
+			for i := range params.Items {
+				if text := params.Items[i].Data.Text; text != "" {
+					params.Items[i].Data.Text = fmt.Sprintf("[redacted %d chars]", len(text))
+				}
+			}
+			msg.Params = mustJSON(params)
+			data, _ = json.Marshal(msg)
+		}
+	}
+
+	c.sendLog(executor.Log{
+		Type: "debug",
+		Content: map[string]any{
+			"direction": direction,
+			"method":    msg.Method,
+			"message":   json.RawMessage(data),
+		},
+	})
 }
 
 func ctxDone(ctx context.Context) <-chan struct{} {
 	return ctx.Done()
 }
 
+// readLoop handles app-server output until the task completes or stdout
+// closes. An app-server that exits on its own is reaped and its exit code
+// reported before done.
 func (c *Client) readLoop(_ context.Context, stdout io.Reader) {
 	defer c.Close()
-	defer c.sendLog(executor.Log{Type: "done", Content: "Codex execution finished"})
 
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
-			continue
-		}
+	completed := false
+	_ = executor.ScanLines(stdout, c.sendLog, func(line string) bool {
+		completed = c.handleLine(line)
+		return !completed
+	})
+	if completed || c.cmd == nil {
+		c.sendLog(executor.Log{Type: "done", Content: "Codex execution finished"})
+		return
+	}
+	executor.AwaitDrain(c.stderrDone)
+	executor.ReportExit(c.sendLog, c.install.Wrap(c.exit.Wait(c.cmd)), "Codex execution finished")
+}
+
+// handleLine processes one stdout line and reports whether the task is
+// complete.
+func (c *Client) handleLine(line string) bool {
+	if line == "" {
+		return false
+	}
+
+	// Try to parse as JSON-RPC message
+	var msg JSONRPCMessage
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		c.sendLog(executor.Log{Type: "error", Content: line})
+		return false
+	}
+	c.trace("inbound", []byte(line))
 
-		// Try to parse as JSON-RPC message
-		var msg JSONRPCMessage
-		if err := json.Unmarshal([]byte(line), &msg); err != nil {
-			c.sendLog(executor.Log{Type: "error", Content: line})
-			continue
+	// If it's a response, send to pending request.
+	if msg.Method == "" && msg.ID != nil && msg.ID.Number != nil {
+		id := *msg.ID.Number
+		c.pendingMu.Lock()
+		if p, ok := c.pending[id]; ok {
+			p.ch <- msg
 		}
+		c.pendingMu.Unlock()
+	}
 
-		// If it's a response, send to pending request.
-		if msg.Method == "" && msg.ID != nil && msg.ID.Number != nil {
-			id := *msg.ID.Number
+	// Always log the message
+	if msg.Method == "" {
+		c.sendLog(executor.Log{Type: "output", Content: line})
+	} else {
+		// JSON-RPC requests with method+id are interactive control requests.
+		if msg.ID != nil && isControlMethod(msg.Method, msg.Params) {
+			requestID := requestIDToString(*msg.ID)
 			c.pendingMu.Lock()
-			if ch, ok := c.pending[id]; ok {
-				ch <- msg
+			if c.control != nil {
+				c.control[requestID] = *msg.ID
 			}
 			c.pendingMu.Unlock()
-		}
 
-		// Always log the message
-		if msg.Method == "" {
-			c.sendLog(executor.Log{Type: "output", Content: line})
-		} else {
-			// JSON-RPC requests with method+id are interactive control requests.
-			if msg.ID != nil && isControlMethod(msg.Method, msg.Params) {
-				requestID := requestIDToString(*msg.ID)
-				c.pendingMu.Lock()
-				if c.control != nil {
-					c.control[requestID] = *msg.ID
-				}
-				c.pendingMu.Unlock()
-
-				c.sendLog(executor.Log{
-					Type: "control_request",
-					Content: map[string]any{
-						"request_id": requestID,
-						"method":     msg.Method,
-						"params":     json.RawMessage(msg.Params),
-					},
-				})
+			c.sendLog(executor.Log{
+				Type: "control_request",
+				Content: map[string]any{
+					"request_id": requestID,
+					"method":     msg.Method,
+					"params":     json.RawMessage(msg.Params),
+				},
+			})
 
-				if c.autoApprove {
-					_ = c.RespondControl(context.Background(), executor.ControlResponse{
-						RequestID: requestID,
-						Decision:  executor.ControlDecisionApprove,
-						Reason:    "auto approved",
-					})
-				}
+			if c.autoApprove {
+				_ = c.RespondControl(context.Background(), executor.ControlResponse{
+					RequestID: requestID,
+					Decision:  executor.ControlDecisionApprove,
+					Reason:    "auto approved",
+				})
+			} else if response, ok := c.approvalPolicy.Decide(requestID, controlToolName(msg.Method, msg.Params)); ok {
+				_ = c.RespondControl(context.Background(), response)
 			}
+		}
 
-			// Check for events
-			c.sendLog(executor.Log{Type: msg.Method, Content: msg.Params})
+		// Check for events
+		c.sendLog(executor.Log{Type: msg.Method, Content: msg.Params})
 
-			// Check for task completion
-			if msg.Method == "codex/event/task_complete" {
-				return
-			}
+		// Check for task completion
+		if msg.Method == "codex/event/task_complete" {
+			return true
 		}
 	}
+	return false
 }
 
 // Factory creates Codex executor instances
@@ -578,6 +837,12 @@ func requestIDToString(id RequestID) string {
 	return ""
 }
 
+// controlToolName names the tool an approval request is for, matching the
+// tool_name of the transformed approval event.
+func controlToolName(method string, params json.RawMessage) string {
+	return detectToolFromControl(map[string]any{"method": method, "params": params})
+}
+
 func isControlMethod(method string, params json.RawMessage) bool {
 	if strings.Contains(strings.ToLower(method), "approval") {
 		return true
@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -143,6 +145,57 @@ func TestCodexClient_ReadLoopControlRequest(t *testing.T) {
 	}
 }
 
+func TestCodexClient_ReadLoopApprovalPolicy(t *testing.T) {
+	client := NewClient()
+	buf := &bytes.Buffer{}
+	client.stdin = nopWriteCloser{Buffer: buf}
+	client.approvalPolicy = executor.ApprovalPolicy{AllowedTools: []string{"bash"}, Default: executor.ApprovalDefaultDeny}
+	input := `{"jsonrpc":"2.0","id":11,"method":"execCommandApproval","params":{"call_id":"call-1","command":["ls"]}}` + "\n" +
+		`{"jsonrpc":"2.0","id":12,"method":"applyPatchApproval","params":{"call_id":"call-2"}}` + "\n" +
+		`{"jsonrpc":"2.0","method":"codex/event/task_complete","params":{}}` + "\n"
+
+	client.readLoop(context.Background(), strings.NewReader(input))
+	for range client.Logs() {
+	}
+
+	decisions := map[float64]string{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var msg map[string]any
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			t.Fatalf("invalid response line %q: %v", line, err)
+		}
+		result, _ := msg["result"].(map[string]any)
+		decisions[msg["id"].(float64)], _ = result["decision"].(string)
+	}
+	if decisions[11] != "approved" || decisions[12] != "denied" {
+		t.Fatalf("expected allowlisted exec approved and unlisted patch denied, got %v (%s)", decisions, buf.String())
+	}
+}
+
+func TestCodexClient_ReadLoopLongLine(t *testing.T) {
+	client := NewClient()
+	big := `{"jsonrpc":"2.0","method":"codex/event/exec_command_output_delta","params":{"chunk":"` +
+		strings.Repeat("x", executor.LargeLineBytes) + `"}}`
+	input := big + "\n" + `{"jsonrpc":"2.0","method":"codex/event/task_complete","params":{}}` + "\n"
+
+	client.readLoop(context.Background(), strings.NewReader(input))
+
+	var sawDebug, sawBig, sawDone bool
+	for evt := range client.Logs() {
+		switch evt.Type {
+		case "debug":
+			sawDebug = true
+		case "codex/event/exec_command_output_delta":
+			sawBig = len(evt.Content.(json.RawMessage)) > executor.LargeLineBytes
+		case "done":
+			sawDone = true
+		}
+	}
+	if !sawDebug || !sawBig || !sawDone {
+		t.Fatalf("expected debug log, full long event and done, got debug=%v event=%v done=%v", sawDebug, sawBig, sawDone)
+	}
+}
+
 func TestCodexHelpers(t *testing.T) {
 	idn := int64(8)
 	if got := requestIDToString(RequestID{Number: &idn}); got != "8" {
@@ -176,6 +229,26 @@ func TestCodexHelpers(t *testing.T) {
 	}
 }
 
+func TestCodexClient_ValidateOptions(t *testing.T) {
+	client := NewClient()
+	if err := client.ValidateOptions(executor.Options{Sandbox: "read-only", AskForApproval: "on-request"}); err != nil {
+		t.Fatalf("expected valid options, got %v", err)
+	}
+	if err := client.ValidateOptions(executor.Options{}); err != nil {
+		t.Fatalf("expected empty options to use defaults, got %v", err)
+	}
+
+	err := client.ValidateOptions(executor.Options{Sandbox: "full"})
+	var optErr *executor.OptionError
+	if !errors.Is(err, executor.ErrInvalidOption) || !errors.As(err, &optErr) || optErr.Field != "sandbox" || optErr.Value != "full" {
+		t.Fatalf("expected sandbox option error, got %v", err)
+	}
+	err = client.ValidateOptions(executor.Options{AskForApproval: "always"})
+	if !errors.As(err, &optErr) || optErr.Field != "ask_for_approval" {
+		t.Fatalf("expected approval option error, got %v", err)
+	}
+}
+
 func TestCodexClient_RPCMethods(t *testing.T) {
 	t.Run("initialize", func(t *testing.T) {
 		client := NewClient()
@@ -199,6 +272,57 @@ func TestCodexClient_RPCMethods(t *testing.T) {
 		}
 	})
 
+	t.Run("newConversation_CodexWorkingDirectory", func(t *testing.T) {
+		client := NewClient()
+		out := &bytes.Buffer{}
+		client.stdin = nopWriteCloser{Buffer: out}
+		respondPendingOnce(client, 2, JSONRPCMessage{JSONRPC: "2.0", ID: &RequestID{Number: int64Ptr(2)}, Result: mustJSON(map[string]any{"conversationId": "conv-new"})})
+		if _, err := client.newConversation(executor.Options{WorkingDir: "/jail", CodexWorkingDirectory: "/mnt/project"}); err != nil {
+			t.Fatalf("newConversation failed: %v", err)
+		}
+
+		var msg JSONRPCMessage
+		if err := json.Unmarshal(out.Bytes(), &msg); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		var params NewConversationParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			t.Fatalf("failed to decode params: %v", err)
+		}
+		if params.WorkingDirectory != "/mnt/project" {
+			t.Fatalf("expected override working directory, got %q", params.WorkingDirectory)
+		}
+	})
+
+	t.Run("newConversation_SandboxAndEffort", func(t *testing.T) {
+		params := func(opts executor.Options) NewConversationParams {
+			client := NewClient()
+			out := &bytes.Buffer{}
+			client.stdin = nopWriteCloser{Buffer: out}
+			respondPendingOnce(client, 2, JSONRPCMessage{JSONRPC: "2.0", ID: &RequestID{Number: int64Ptr(2)}, Result: mustJSON(map[string]any{"conversationId": "conv-new"})})
+			if _, err := client.newConversation(opts); err != nil {
+				t.Fatalf("newConversation failed: %v", err)
+			}
+			var msg JSONRPCMessage
+			if err := json.Unmarshal(out.Bytes(), &msg); err != nil {
+				t.Fatalf("failed to decode request: %v", err)
+			}
+			var params NewConversationParams
+			if err := json.Unmarshal(msg.Params, &params); err != nil {
+				t.Fatalf("failed to decode params: %v", err)
+			}
+			return params
+		}
+
+		got := params(executor.Options{WorkingDir: "/repo", Sandbox: "read-only", ModelReasoningEffort: "high"})
+		if got.Sandbox != "read-only" || got.ModelReasoningEffort != "high" {
+			t.Fatalf("expected the requested sandbox and effort, got %+v", got)
+		}
+		if got := params(executor.Options{WorkingDir: "/repo"}); got.Sandbox != "workspace-write" || got.ModelReasoningEffort != "" {
+			t.Fatalf("expected the default sandbox and no effort, got %+v", got)
+		}
+	})
+
 	t.Run("resumeConversation", func(t *testing.T) {
 		client := NewClient()
 		client.stdin = nopWriteCloser{Buffer: &bytes.Buffer{}}
@@ -230,6 +354,50 @@ func TestCodexClient_RPCMethods(t *testing.T) {
 		}
 	})
 
+	t.Run("sendUserTurn", func(t *testing.T) {
+		client := NewClient()
+		out := &bytes.Buffer{}
+		client.stdin = nopWriteCloser{Buffer: out}
+		respondPendingOnce(client, 2, JSONRPCMessage{JSONRPC: "2.0", ID: &RequestID{Number: int64Ptr(2)}, Result: mustJSON(map[string]any{"conversationId": "conv-1", "model": "gpt-5-codex"})})
+		id, err := client.newConversation(executor.Options{WorkingDir: "/repo", Sandbox: "read-only"})
+		if err != nil {
+			t.Fatalf("newConversation failed: %v", err)
+		}
+		client.conversationID = id
+
+		out.Reset()
+		respondPendingOnce(client, 3, JSONRPCMessage{JSONRPC: "2.0", ID: &RequestID{Number: int64Ptr(3)}, Result: mustJSON(map[string]any{})})
+		if err := client.SendTurn(context.Background(), "think harder", executor.TurnOptions{ModelReasoningEffort: "high"}); err != nil {
+			t.Fatalf("SendTurn failed: %v", err)
+		}
+		var msg JSONRPCMessage
+		if err := json.Unmarshal(out.Bytes(), &msg); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		var params SendUserTurnParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			t.Fatalf("failed to decode params: %v", err)
+		}
+		if msg.Method != "sendUserTurn" || params.Effort != "high" || params.ConversationID != "conv-1" {
+			t.Fatalf("expected a sendUserTurn with the effort override, got %s %+v", msg.Method, params)
+		}
+		if params.Model != "gpt-5-codex" || params.Cwd != "/repo" || params.SandboxPolicy.Mode != "read-only" || params.ApprovalPolicy != "unless-trusted" {
+			t.Fatalf("expected the conversation settings to be repeated, got %+v", params)
+		}
+		if len(params.Items) != 1 || params.Items[0].Data.Text != "think harder" {
+			t.Fatalf("unexpected items: %+v", params.Items)
+		}
+
+		out.Reset()
+		respondPendingOnce(client, 4, JSONRPCMessage{JSONRPC: "2.0", ID: &RequestID{Number: int64Ptr(4)}, Result: mustJSON(map[string]any{})})
+		if err := client.SendTurn(context.Background(), "carry on", executor.TurnOptions{}); err != nil {
+			t.Fatalf("SendTurn failed: %v", err)
+		}
+		if err := json.Unmarshal(out.Bytes(), &msg); err != nil || msg.Method != "sendUserMessage" {
+			t.Fatalf("expected a plain sendUserMessage without overrides, got %q (%v)", msg.Method, err)
+		}
+	})
+
 	t.Run("addListener", func(t *testing.T) {
 		client := NewClient()
 		client.stdin = nopWriteCloser{Buffer: &bytes.Buffer{}}
@@ -255,10 +423,10 @@ func respondPendingOnce(c *Client, id int64, resp JSONRPCMessage) {
 	go func() {
 		for i := 0; i < 200; i++ {
 			c.pendingMu.Lock()
-			ch, ok := c.pending[id]
+			p, ok := c.pending[id]
 			c.pendingMu.Unlock()
 			if ok {
-				ch <- resp
+				p.ch <- resp
 				return
 			}
 			time.Sleep(1 * time.Millisecond)
@@ -268,6 +436,112 @@ func respondPendingOnce(c *Client, id int64, resp JSONRPCMessage) {
 
 func int64Ptr(v int64) *int64 { return &v }
 
+func TestCodexClient_PendingRequestsBounded(t *testing.T) {
+	client := NewClient()
+	client.stdin = nopWriteCloser{Buffer: &bytes.Buffer{}}
+	client.maxPending = 8
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.sendRequest(JSONRPCMessage{JSONRPC: "2.0", ID: ptrRequestID(client.nextID()), Method: "noop"})
+			errs <- err
+		}()
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(errs) < 42 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 42 evicted requests to fail fast, got %d", len(errs))
+		}
+		client.pendingMu.Lock()
+		if n := len(client.pending); n > 8 {
+			client.pendingMu.Unlock()
+			t.Fatalf("pending map exceeded cap: %d", n)
+		}
+		client.pendingMu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+
+	warnings := 0
+	for len(client.logsChan) > 0 {
+		if log := <-client.logsChan; log.Type == "warning" {
+			warnings++
+		}
+	}
+	if warnings != 42 {
+		t.Fatalf("expected a warning per eviction, got %d", warnings)
+	}
+
+	client.Close()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err == nil {
+			t.Fatal("expected unanswered requests to fail")
+		}
+	}
+}
+
+func ptrRequestID(id RequestID) *RequestID { return &id }
+
+func TestCodexClient_TraceRPC(t *testing.T) {
+	run := func(trace bool) []executor.Log {
+		client := NewClient()
+		client.stdin = nopWriteCloser{Buffer: &bytes.Buffer{}}
+		client.traceRPC = trace
+
+		respondPendingOnce(client, 2, JSONRPCMessage{JSONRPC: "2.0", ID: &RequestID{Number: int64Ptr(2)}, Result: mustJSON(map[string]any{})})
+		if err := client.initialize(); err != nil {
+			t.Fatalf("initialize failed: %v", err)
+		}
+		respondPendingOnce(client, 3, JSONRPCMessage{JSONRPC: "2.0", ID: &RequestID{Number: int64Ptr(3)}, Result: mustJSON(map[string]any{"conversationId": "conv-1"})})
+		if _, err := client.newConversation(executor.Options{}); err != nil {
+			t.Fatalf("newConversation failed: %v", err)
+		}
+		respondPendingOnce(client, 4, JSONRPCMessage{JSONRPC: "2.0", ID: &RequestID{Number: int64Ptr(4)}, Result: mustJSON(map[string]any{})})
+		if err := client.sendUserMessage("conv-1", "top secret prompt"); err != nil {
+			t.Fatalf("sendUserMessage failed: %v", err)
+		}
+		client.readLoop(context.Background(), strings.NewReader(`{"jsonrpc":"2.0","id":9,"result":{}}`+"\n"))
+
+		var logs []executor.Log
+		for log := range client.logsChan {
+			if log.Type == "debug" {
+				logs = append(logs, log)
+			}
+		}
+		return logs
+	}
+
+	if logs := run(false); len(logs) != 0 {
+		t.Fatalf("expected no trace events when disabled, got %d", len(logs))
+	}
+
+	logs := run(true)
+	seen := map[string]bool{}
+	for _, log := range logs {
+		content := log.Content.(map[string]any)
+		seen[content["direction"].(string)+":"+content["method"].(string)] = true
+		if strings.Contains(string(content["message"].(json.RawMessage)), "top secret prompt") {
+			t.Fatalf("expected user message text to be redacted: %s", content["message"])
+		}
+	}
+	for _, key := range []string{"outbound:initialize", "outbound:initialized", "outbound:newConversation", "outbound:sendUserMessage", "inbound:"} {
+		if !seen[key] {
+			t.Fatalf("expected trace event %s, got %v", key, seen)
+		}
+	}
+
+	evt := EventTransformer(executor.TransformInput{Executor: "codex", Log: logs[0]})
+	if evt.Type != "debug" {
+		t.Fatalf("expected trace logs to stay debug events, got %s", evt.Type)
+	}
+}
+
 func TestCodexClient_RespondControl(t *testing.T) {
 	client := NewClient()
 	buf := &bytes.Buffer{}
@@ -334,3 +608,54 @@ type nopWriteCloser struct {
 }
 
 func (n nopWriteCloser) Close() error { return nil }
+
+func TestCodexClient_SendRequestRPCError(t *testing.T) {
+	client := NewClient()
+	client.stdin = nopWriteCloser{Buffer: &bytes.Buffer{}}
+	defer client.Close()
+
+	id := client.nextID()
+	respondPendingOnce(client, *id.Number, JSONRPCMessage{JSONRPC: "2.0", ID: ptrRequestID(id), Error: &JSONRPCError{Code: -32601, Message: "method not found"}})
+	_, err := client.sendRequest(JSONRPCMessage{JSONRPC: "2.0", ID: ptrRequestID(id), Method: "noop"})
+
+	var rpcErr *executor.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32601 {
+		t.Fatalf("expected RPCError with code -32601, got %v", err)
+	}
+	if kind, code := executor.ErrorDetails(fmt.Errorf("failed to initialize: %w", err)); kind != executor.ErrorKindRPC || code != "-32601" {
+		t.Fatalf("expected rpc/-32601, got %s/%s", kind, code)
+	}
+}
+
+func TestCodexClient_SendRequestTimeout(t *testing.T) {
+	client := NewClient()
+	client.stdin = nopWriteCloser{Buffer: &bytes.Buffer{}}
+	client.requestTimeout = 20 * time.Millisecond
+
+	_, err := client.sendRequest(JSONRPCMessage{JSONRPC: "2.0", ID: ptrRequestID(client.nextID()), Method: "newConversation"})
+	if !errors.Is(err, executor.ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+
+	select {
+	case <-client.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected client to close after a request timeout")
+	}
+	client.pendingMu.Lock()
+	pending := client.pending
+	client.pendingMu.Unlock()
+	if pending != nil {
+		t.Fatalf("expected pending requests to be released, got %v", pending)
+	}
+
+	// The caller reports the timeout; sendRequest itself logs nothing.
+	for log := range client.Logs() {
+		if log.Type == "error" {
+			t.Fatalf("expected no error log from sendRequest, got %+v", log)
+		}
+	}
+	if kind, _ := executor.ErrorDetails(fmt.Errorf("failed to initialize: %w", err)); kind != executor.ErrorKindTimeout {
+		t.Fatalf("expected timeout error kind, got %q", kind)
+	}
+}
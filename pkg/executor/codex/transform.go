@@ -3,6 +3,7 @@ package codex
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/supremeagent/executor/pkg/executor"
@@ -32,6 +33,15 @@ func EventTransformer(input executor.TransformInput) executor.Event {
 		content.Phase = "failed"
 		content.Summary = "Execution failed"
 		eventType = "error"
+		// Errors the client raised itself carry Err; anything else is a
+		// line of output that may come from a tool rather than codex.
+		if !content.MarkRateLimit() {
+			if input.Log.Err != nil {
+				content.MarkAuthFailure()
+			} else {
+				content.MarkCLIAuthFailure()
+			}
+		}
 	case "control_request":
 		content.Category = "approval"
 		content.Action = "approval_required"
@@ -41,10 +51,28 @@ func EventTransformer(input executor.TransformInput) executor.Event {
 		if obj, ok := parseJSONObject(input.Log.Content); ok {
 			content.RequestID, _ = obj["request_id"].(string)
 			content.ToolName = detectToolFromControl(obj)
+			content.Scope = codexApprovalScope(obj)
+			content.Target = content.Scope.Target()
 			if content.ToolName != "" {
 				content.Summary = fmt.Sprintf("Waiting for approval: %s", content.ToolName)
 			}
 		}
+	case "debug":
+		content.Category = "debug"
+		content.Action = "tracing"
+		if obj, ok := parseJSONObject(input.Log.Content); ok {
+			direction, _ := obj["direction"].(string)
+			method, _ := obj["method"].(string)
+			content.Summary = strings.TrimSpace(fmt.Sprintf("JSON-RPC %s %s", direction, method))
+		} else {
+			content.Summary = content.Text
+		}
+		eventType = "debug"
+	case "warning":
+		content.Category = "lifecycle"
+		content.Action = "warning"
+		content.Summary = content.Text
+		eventType = "progress"
 	case "init":
 		content.Category = "lifecycle"
 		content.Action = "starting"
@@ -58,6 +86,9 @@ func EventTransformer(input executor.TransformInput) executor.Event {
 			content.Phase = "delta"
 			eventType = "progress"
 			applyCodexEventMapping(&content, input.Log.Type, input.Log.Content)
+			if content.Category == "error" || content.Category == "thought" {
+				eventType = content.Category
+			}
 		}
 	}
 
@@ -97,6 +128,20 @@ func applyCodexEventMapping(content *executor.UnifiedContent, logType string, ra
 	}
 
 	switch {
+	case msgType == "error" || msgType == "stream_error":
+		if obj, ok := parseJSONObject(raw); ok {
+			if message := nestedString(obj, "msg", "message"); message != "" {
+				content.Text = message
+			}
+		}
+		if !content.MarkRateLimit() && !content.MarkAuthFailure() {
+			content.Summary = fmt.Sprintf("Processing: %s", msgType)
+		}
+	case msgType == "token_count":
+		content.Summary = "Token usage"
+		if obj, ok := parseJSONObject(raw); ok {
+			content.Usage = codexUsage(obj)
+		}
 	case strings.Contains(msgType, "task_complete"):
 		content.Category = "done"
 		content.Action = "completed"
@@ -114,6 +159,13 @@ func applyCodexEventMapping(content *executor.UnifiedContent, logType string, ra
 		if content.Status != "" {
 			content.Summary = fmt.Sprintf("Tool %s status: %s", content.Target, content.Status)
 		}
+	case strings.HasPrefix(msgType, "agent_reasoning"):
+		content.Category = "thought"
+		content.Action = "thinking"
+		content.Summary = "Thinking"
+		if obj, ok := parseJSONObject(raw); ok {
+			content.Text = fallback(nestedString(obj, "msg", "text"), nestedString(obj, "msg", "delta"))
+		}
 	case strings.Contains(msgType, "search"):
 		content.Action = "searching"
 		content.Summary = "Searching"
@@ -141,6 +193,23 @@ func applyCodexEventMapping(content *executor.UnifiedContent, logType string, ra
 	}
 }
 
+// codexUsage returns the usage of the turn a token_count event reports.
+// Codex counts cached input tokens as part of input_tokens.
+func codexUsage(obj map[string]any) []executor.TokenUsage {
+	msg, _ := obj["msg"].(map[string]any)
+	info, _ := msg["info"].(map[string]any)
+	last, ok := info["last_token_usage"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	cached := executor.TokenCount(last["cached_input_tokens"])
+	return []executor.TokenUsage{{
+		InputTokens:       executor.TokenCount(last["input_tokens"]) - cached,
+		CachedInputTokens: cached,
+		OutputTokens:      executor.TokenCount(last["output_tokens"]),
+	}}
+}
+
 func parseJSONObject(v any) (map[string]any, bool) {
 	switch val := v.(type) {
 	case map[string]any:
@@ -160,7 +229,7 @@ func parseJSONObject(v any) (map[string]any, bool) {
 }
 
 func detectToolFromControl(obj map[string]any) string {
-	params, _ := obj["params"].(map[string]any)
+	params, _ := parseJSONObject(obj["params"])
 	method, _ := obj["method"].(string)
 	if strings.Contains(strings.ToLower(method), "patch") {
 		return "edit"
@@ -176,6 +245,36 @@ func detectToolFromControl(obj map[string]any) string {
 	return ""
 }
 
+// codexApprovalScope reads the command and cwd of exec approvals and the
+// changed files and grant root of patch approvals.
+func codexApprovalScope(obj map[string]any) *executor.ApprovalScope {
+	params, ok := parseJSONObject(obj["params"])
+	if !ok {
+		return nil
+	}
+	scope := executor.ApprovalScopeFromInput(params)
+	if scope == nil {
+		scope = &executor.ApprovalScope{}
+	}
+
+	if changes, ok := params["fileChanges"].(map[string]any); ok {
+		paths := make([]string, 0, len(changes))
+		for path := range changes {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		scope.Paths = append(scope.Paths, paths...)
+	}
+	if root, ok := params["grantRoot"].(string); ok && root != "" {
+		scope.Paths = append(scope.Paths, root)
+	}
+
+	if scope.Command == "" && scope.Cwd == "" && len(scope.Paths) == 0 && scope.URL == "" {
+		return nil
+	}
+	return scope
+}
+
 func nestedString(src map[string]any, path ...string) string {
 	var cur any = src
 	for _, p := range path {
@@ -2,6 +2,8 @@ package codex
 
 import (
 	"encoding/json"
+	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/supremeagent/executor/pkg/executor"
@@ -153,3 +155,169 @@ func TestHelpers(t *testing.T) {
 		t.Fatal("defaultSummary should not be empty")
 	}
 }
+
+func TestEventTransformer_ControlRequestScope(t *testing.T) {
+	evt := EventTransformer(executor.TransformInput{
+		SessionID: "s1",
+		Executor:  "codex",
+		Log: executor.Log{
+			Type: "control_request",
+			Content: map[string]any{
+				"request_id": "6",
+				"method":     "execCommandApproval",
+				"params":     json.RawMessage(`{"conversationId":"c1","callId":"call-1","command":["git","push"],"cwd":"/repo","reason":"needs network"}`),
+			},
+		},
+	})
+	content := evt.Content.(executor.UnifiedContent)
+	if content.Scope == nil || content.Scope.Command != "git push" || content.Scope.Cwd != "/repo" || content.Target != "git push" {
+		t.Fatalf("expected exec scope, got %+v", content.Scope)
+	}
+
+	evt = EventTransformer(executor.TransformInput{
+		SessionID: "s1",
+		Executor:  "codex",
+		Log: executor.Log{
+			Type: "control_request",
+			Content: map[string]any{
+				"request_id": "7",
+				"method":     "applyPatchApproval",
+				"params":     json.RawMessage(`{"callId":"call-2","fileChanges":{"b.go":{"update":{}},"a.go":{"add":{}}},"grantRoot":"/repo"}`),
+			},
+		},
+	})
+	content = evt.Content.(executor.UnifiedContent)
+	if content.Scope == nil || fmt.Sprint(content.Scope.Paths) != "[a.go b.go /repo]" || content.Target != "a.go" {
+		t.Fatalf("expected patch scope, got %+v", content.Scope)
+	}
+}
+
+func TestEventTransformer_RateLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		log        executor.Log
+		retryAfter int
+	}{
+		{
+			name:       "StderrTryAgain",
+			log:        executor.Log{Type: "error", Content: "stream error: 429 Too Many Requests: Rate limit reached for gpt-5 on tokens per min. Please try again in 1.5s."},
+			retryAfter: 2,
+		},
+		{
+			name: "StreamErrorEvent",
+			log: executor.Log{Type: "codex/event/stream_error", Content: map[string]any{
+				"msg": map[string]any{"type": "stream_error", "message": "exceeded retry limit, last status: 429 Too Many Requests; retrying 1/5 in 200ms"},
+			}},
+			retryAfter: 1,
+		},
+		{
+			name: "ErrorEventWithoutHint",
+			log: executor.Log{Type: "codex/event/error", Content: map[string]any{
+				"msg": map[string]any{"type": "error", "message": "You've hit your usage limit. Rate limit exceeded."},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evt := EventTransformer(executor.TransformInput{SessionID: "s1", Executor: "codex", Log: tt.log})
+			if evt.Type != "error" {
+				t.Fatalf("expected error event, got %s", evt.Type)
+			}
+			content := evt.Content.(executor.UnifiedContent)
+			if content.ErrorKind != executor.ErrorKindRateLimit || content.RetryAfter != tt.retryAfter {
+				t.Fatalf("expected rate_limit with retry %d, got %q/%d", tt.retryAfter, content.ErrorKind, content.RetryAfter)
+			}
+		})
+	}
+
+	evt := EventTransformer(executor.TransformInput{SessionID: "s1", Executor: "codex", Log: executor.Log{
+		Type:    "codex/event/error",
+		Content: map[string]any{"msg": map[string]any{"type": "error", "message": "sandbox denied"}},
+	}})
+	if content := evt.Content.(executor.UnifiedContent); evt.Type != "progress" || content.ErrorKind != "" {
+		t.Fatalf("expected other errors to stay unclassified, got %s %+v", evt.Type, content)
+	}
+}
+
+func TestEventTransformer_Reasoning(t *testing.T) {
+	tests := []struct {
+		logType string
+		msg     map[string]any
+		text    string
+	}{
+		{"codex/event/agent_reasoning", map[string]any{"type": "agent_reasoning", "text": "**Inspecting files**"}, "**Inspecting files**"},
+		{"codex/event/agent_reasoning_delta", map[string]any{"type": "agent_reasoning_delta", "delta": "Inspect"}, "Inspect"},
+	}
+	for _, tt := range tests {
+		evt := EventTransformer(executor.TransformInput{SessionID: "s1", Executor: "codex", Log: executor.Log{
+			Type:    tt.logType,
+			Content: map[string]any{"msg": tt.msg},
+		}})
+		content := evt.Content.(executor.UnifiedContent)
+		if evt.Type != "thought" || content.Category != "thought" || content.Text != tt.text {
+			t.Fatalf("%s: expected thought event with text %q, got %s %+v", tt.logType, tt.text, evt.Type, content)
+		}
+	}
+}
+
+func TestEventTransformer_AuthFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		log  executor.Log
+	}{
+		{
+			name: "NotLoggedIn",
+			log:  executor.Log{Type: "stderr", Content: "Error: Not logged in. Run `codex login` or set OPENAI_API_KEY."},
+		},
+		{
+			name: "ErrorEvent",
+			log: executor.Log{Type: "codex/event/error", Content: map[string]any{
+				"msg": map[string]any{"type": "error", "message": "unexpected status 401 Unauthorized: Incorrect API key provided: sk-abc***"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evt := EventTransformer(executor.TransformInput{SessionID: "s1", Executor: "codex", Log: tt.log})
+			content := evt.Content.(executor.UnifiedContent)
+			if evt.Type != "error" || content.ErrorKind != executor.ErrorKindAuth {
+				t.Fatalf("expected auth error, got %s %+v", evt.Type, content)
+			}
+		})
+	}
+
+	// MCP servers and tools write to the same stderr.
+	for _, line := range []string{
+		"2025-06-01T10:00:00Z ERROR rmcp: tracker server returned 401 Unauthorized",
+		"gh: token has expired, not logged in",
+	} {
+		evt := EventTransformer(executor.TransformInput{SessionID: "s1", Executor: "codex", Log: executor.Log{Type: "error", Content: line}})
+		if content := evt.Content.(executor.UnifiedContent); content.ErrorKind != "" {
+			t.Fatalf("expected %q to stay unclassified, got %+v", line, content)
+		}
+	}
+}
+
+func TestEventTransformer_TokenUsage(t *testing.T) {
+	evt := EventTransformer(executor.TransformInput{SessionID: "s1", Executor: "codex", Log: executor.Log{
+		Type: "codex/event/token_count",
+		Content: map[string]any{"msg": map[string]any{"type": "token_count", "info": map[string]any{
+			"total_token_usage": map[string]any{"input_tokens": float64(5000), "cached_input_tokens": float64(3000), "output_tokens": float64(900)},
+			"last_token_usage":  map[string]any{"input_tokens": float64(1200), "cached_input_tokens": float64(1000), "output_tokens": float64(300)},
+		}}},
+	}})
+	content := evt.Content.(executor.UnifiedContent)
+	want := []executor.TokenUsage{{InputTokens: 200, CachedInputTokens: 1000, OutputTokens: 300}}
+	if evt.Type != "progress" || !reflect.DeepEqual(content.Usage, want) {
+		t.Fatalf("expected last turn usage %+v, got %s %+v", want, evt.Type, content.Usage)
+	}
+
+	evt = EventTransformer(executor.TransformInput{SessionID: "s1", Executor: "codex", Log: executor.Log{
+		Type:    "codex/event/token_count",
+		Content: map[string]any{"msg": map[string]any{"type": "token_count", "info": nil}},
+	}})
+	if usage := evt.Content.(executor.UnifiedContent).Usage; usage != nil {
+		t.Fatalf("expected no usage without info, got %+v", usage)
+	}
+}
@@ -101,6 +101,7 @@ type NewConversationParams struct {
 // NewConversationResult represents new conversation result
 type NewConversationResult struct {
 	ConversationID string `json:"conversationId"`
+	Model          string `json:"model,omitempty"`
 	RolloutPath    string `json:"rolloutPath,omitempty"`
 }
 
@@ -115,6 +116,7 @@ type ResumeConversationParams struct {
 // ResumeConversationResult represents resume conversation result
 type ResumeConversationResult struct {
 	ConversationID string `json:"conversationId"`
+	Model          string `json:"model,omitempty"`
 	RolloutPath    string `json:"rolloutPath,omitempty"`
 }
 
@@ -124,6 +126,25 @@ type SendUserMessageParams struct {
 	Items          []InputItem `json:"items"`
 }
 
+// SendUserTurnParams represents send user turn parameters. Unlike
+// sendUserMessage, a turn carries its own context, so settings such as the
+// reasoning effort can change for one turn.
+type SendUserTurnParams struct {
+	ConversationID string        `json:"conversationId"`
+	Items          []InputItem   `json:"items"`
+	Cwd            string        `json:"cwd"`
+	ApprovalPolicy string        `json:"approvalPolicy"`
+	SandboxPolicy  SandboxPolicy `json:"sandboxPolicy"`
+	Model          string        `json:"model"`
+	Effort         string        `json:"effort,omitempty"`
+	Summary        string        `json:"summary"`
+}
+
+// SandboxPolicy represents the sandbox of a user turn
+type SandboxPolicy struct {
+	Mode string `json:"mode"`
+}
+
 // InputItem represents an input item
 type InputItem struct {
 	Type string        `json:"type"`
@@ -1,11 +1,12 @@
 package copilot
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"os"
 	"os/exec"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
 
@@ -13,6 +14,9 @@ import (
 	"github.com/supremeagent/executor/pkg/executor"
 )
 
+// packageArgs are the launcher arguments that run the Copilot CLI.
+var packageArgs = []string{"--package", "@github/copilot@latest", "copilot"}
+
 // Client implements the Executor interface for Copilot CLI
 type Client struct {
 	cmd        *exec.Cmd
@@ -23,6 +27,10 @@ type Client struct {
 	closed     bool
 	mu         sync.Mutex
 	commandRun func(name string, arg ...string) *exec.Cmd
+	// install notices npx failing to fetch the package.
+	install executor.InstallWatch
+
+	prompts *executor.PromptDetector
 }
 
 // NewClient creates a new Copilot Code client
@@ -36,9 +44,8 @@ func NewClient() *Client {
 
 // Start starts the Copilot Code executor with the given prompt
 func (c *Client) Start(ctx context.Context, prompt string, opts executor.Options) error {
-	args := []string{"-y", "--package", "@github/copilot@latest", "copilot"}
-
-	args = append(args, "-p", prompt)
+	c.prompts = executor.NewPromptDetector(opts)
+	args := append(slices.Clone(packageArgs), "-p", prompt)
 
 	if opts.Model != "" {
 		args = append(args, "--model", opts.Model)
@@ -50,7 +57,8 @@ func (c *Client) Start(ctx context.Context, prompt string, opts executor.Options
 		args = append(args, "--allow-all-tools")
 	}
 
-	cmd := c.commandRun("npx", args...)
+	argv := opts.LaunchCommand(prompt, args)
+	cmd := c.commandRun(argv[0], argv[1:]...)
 	cmd.Dir = opts.WorkingDir
 	cmd.Env = executor.BuildCommandEnv(opts.Env, map[string]string{
 		"NPM_CONFIG_LOGLEVEL": "error",
@@ -60,11 +68,11 @@ func (c *Client) Start(ctx context.Context, prompt string, opts executor.Options
 		"NO_COLOR":            "1", // strip ansi code
 	})
 
-	c.sendLog(executor.Log{Type: "command", Content: fmt.Sprintf("npx %s", strings.Join(args, " "))})
+	c.sendLog(executor.Log{Type: "command", Content: strings.Join(opts.RedactArgs(argv), " ")})
 
 	ptmx, err := pty.Start(cmd)
 	if err != nil {
-		return fmt.Errorf("failed to start command with pty: %w", err)
+		return fmt.Errorf("failed to start command with pty: %w", executor.WrapStartError(err))
 	}
 
 	c.cmd = cmd
@@ -73,21 +81,15 @@ func (c *Client) Start(ctx context.Context, prompt string, opts executor.Options
 	go func() {
 		defer c.Close()
 		defer ptmx.Close()
-		defer c.sendLog(executor.Log{Type: "done", Content: "Copilot execution finished"})
-
-		scanner := bufio.NewScanner(ptmx)
-		scanner.Buffer(make([]byte, 1024*1024), 1024*1024) // 1MB buffer
-		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
-			if line == "" {
-				continue
+
+		_ = executor.ScanLinesPartial(ptmx, c.sendLog, func(line string) bool {
+			if line = strings.TrimSpace(line); line != "" {
+				c.handleText(line)
 			}
-			c.sendLog(executor.Log{Type: "stdout", Content: line})
-		}
+			return true
+		}, c.handlePrompt)
 
-		if err := cmd.Wait(); err != nil {
-			c.sendLog(executor.Log{Type: "error", Content: err.Error()})
-		}
+		executor.ReportExit(c.sendLog, c.install.Wrap(cmd.Wait()), "Copilot execution finished")
 	}()
 
 	return nil
@@ -104,15 +106,74 @@ func (c *Client) SendMessage(ctx context.Context, message string) error {
 	return fmt.Errorf("copilot does not support interactive sending in stream mode")
 }
 
+// RespondControl answers an interactive prompt detected in Copilot's output.
+// Copilot has no other control requests in stream mode.
 func (c *Client) RespondControl(ctx context.Context, response executor.ControlResponse) error {
+	if answer, ok := c.prompts.Answer(response); ok {
+		return c.writeInput(answer)
+	}
 	return fmt.Errorf("copilot does not support interactive control in stream mode")
 }
 
+var (
+	// modelChoicesPattern matches the choices listed for --model in
+	// Copilot's help output, once line wrapping is removed.
+	modelChoicesPattern = regexp.MustCompile(`--model <[^>]*>[^(]*\(choices: ([^)]*)\)`)
+	quotedPattern       = regexp.MustCompile(`"([^"]+)"`)
+)
+
+// Models lists the model choices that Copilot's --help output gives for
+// --model, running the CLI through opts.Launcher. A CommandOverride runs a
+// whole session, so no help command can be derived from it and the list is
+// unavailable.
+func (c *Client) Models(ctx context.Context, opts executor.Options) ([]string, error) {
+	if len(opts.CommandOverride) > 0 {
+		return nil, fmt.Errorf("copilot models with a command override: %w", executor.ErrModelsUnavailable)
+	}
+	argv := opts.LaunchCommand("", append(slices.Clone(packageArgs), "--help"))
+	cmd := c.commandRun(argv[0], argv[1:]...)
+	cmd.Env = executor.BuildCommandEnv(map[string]string{
+		"NPM_CONFIG_LOGLEVEL": "error",
+		"NO_COLOR":            "1",
+	})
+	output, err := executor.CommandOutput(ctx, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("copilot --help: %w", err)
+	}
+
+	help := strings.Join(strings.Fields(string(output)), " ")
+	match := modelChoicesPattern.FindStringSubmatch(help)
+	if match == nil {
+		return nil, fmt.Errorf("copilot --help lists no model choices: %w", executor.ErrModelsUnavailable)
+	}
+	var models []string
+	for _, choice := range quotedPattern.FindAllStringSubmatch(match[1], -1) {
+		models = append(models, choice[1])
+	}
+	return models, nil
+}
+
+// Capabilities reports the optional operations this executor supports.
+func (c *Client) Capabilities() executor.Capabilities {
+	return executor.Capabilities{}
+}
+
 func (c *Client) Wait() error {
 	<-c.doneChan
 	return nil
 }
 
+// WaitContext waits for the execution to complete or for ctx to be done.
+// It returns ctx.Err() if the context ends the wait first.
+func (c *Client) WaitContext(ctx context.Context) error {
+	select {
+	case <-c.doneChan:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (c *Client) Logs() <-chan executor.Log {
 	return c.logsChan
 }
@@ -145,9 +206,42 @@ func (c *Client) sendLog(entry executor.Log) {
 	if c.closed {
 		return
 	}
+	c.install.Observe(entry)
 	c.logsChan <- entry
 }
 
+// writeInput writes text, such as the answer to an interactive prompt, to
+// the terminal as typed.
+func (c *Client) writeInput(text string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || c.ptyFile == nil {
+		return executor.ErrExecutorClosed
+	}
+	_, err := c.ptyFile.Write([]byte(text))
+	return err
+}
+
+// handleText reports an output line, as an error when it is an
+// authentication failure.
+func (c *Client) handleText(line string) {
+	// Copilot prints start-up failures such as a missing login as plain
+	// output; report those as errors so the transformer can classify them.
+	if strings.HasPrefix(line, "Error:") && executor.DetectAuthFailure(line) {
+		c.sendLog(executor.Log{Type: "error", Content: line})
+		return
+	}
+	c.sendLog(executor.Log{Type: "stdout", Content: line})
+}
+
+// handlePrompt reports the unterminated output line Copilot is waiting on as
+// a control request when it is an interactive prompt.
+func (c *Client) handlePrompt(line string) {
+	if req, ok := c.prompts.Detect(strings.TrimSpace(line)); ok {
+		c.sendLog(executor.Log{Type: "control_request", Content: req})
+	}
+}
+
 type Factory struct{}
 
 func NewFactory() *Factory {
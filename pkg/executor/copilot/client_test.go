@@ -2,7 +2,9 @@ package copilot
 
 import (
 	"context"
+	"errors"
 	"os/exec"
+	"strings"
 	"testing"
 	"time"
 
@@ -94,3 +96,141 @@ func TestClient_Wait_AfterStart(t *testing.T) {
 
 // Compile-time interface check.
 var _ executor.Executor = (*Client)(nil)
+
+func TestClient_InteractivePromptBecomesControlRequest(t *testing.T) {
+	c := NewClient()
+	c.commandRun = fakeCmd(`echo "Reply with (y/n)"; printf 'Proceed? (y/n) '; read answer; echo "answer=$answer"`)
+
+	if err := c.Start(context.Background(), "do something", executor.Options{WorkingDir: t.TempDir()}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	answered := false
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case log, ok := <-c.Logs():
+			if !ok {
+				t.Fatal("logs closed before the prompt was answered")
+			}
+			if log.Type == "control_request" {
+				req := log.Content.(map[string]any)
+				if prompt, ok := executor.InteractivePromptText(req); !ok || prompt != "Proceed? (y/n)" {
+					t.Fatalf("unexpected control request: %+v", req)
+				}
+				response := executor.ControlResponse{RequestID: req["request_id"].(string), Decision: executor.ControlDecisionApprove}
+				if err := c.RespondControl(context.Background(), response); err != nil {
+					t.Fatalf("RespondControl: %v", err)
+				}
+				answered = true
+			}
+			if log.Type == "stdout" && log.Content == "answer=y" {
+				if !answered {
+					t.Fatal("expected the prompt to be reported before the answer")
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for the answered prompt")
+		}
+	}
+}
+
+func TestClient_Models(t *testing.T) {
+	c := NewClient()
+	c.commandRun = fakeCmd(`cat <<'EOF'
+Options:
+  --model <model>      Set the AI model to use (choices: "claude-sonnet-4.5",
+                       "gpt-5", "gpt-5-mini")
+  --allow-all-tools    Allow all tools to run without confirmation
+EOF`)
+
+	var launched []string
+	run := c.commandRun
+	c.commandRun = func(name string, args ...string) *exec.Cmd {
+		launched = append([]string{name}, args...)
+		return run(name, args...)
+	}
+	models, err := c.Models(context.Background(), executor.Options{Launcher: "pnpm dlx"})
+	if err != nil {
+		t.Fatalf("Models: %v", err)
+	}
+	want := []string{"claude-sonnet-4.5", "gpt-5", "gpt-5-mini"}
+	if strings.Join(models, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v, got %v", want, models)
+	}
+	if len(launched) < 2 || launched[0] != "pnpm" || launched[1] != "dlx" || launched[len(launched)-1] != "--help" {
+		t.Fatalf("expected the help command to use the launcher, got %q", launched)
+	}
+
+	c.commandRun = fakeCmd(`echo "Usage: copilot [options]"`)
+	if _, err := c.Models(context.Background(), executor.Options{}); !errors.Is(err, executor.ErrModelsUnavailable) {
+		t.Fatalf("expected ErrModelsUnavailable without model choices, got %v", err)
+	}
+	if _, err := c.Models(context.Background(), executor.Options{CommandOverride: []string{"copilot", "-p", "{prompt}"}}); !errors.Is(err, executor.ErrModelsUnavailable) {
+		t.Fatalf("expected ErrModelsUnavailable with a command override, got %v", err)
+	}
+}
+
+func TestClient_AuthFailureBecomesAuthError(t *testing.T) {
+	c := NewClient()
+	c.commandRun = fakeCmd(`echo "Error: No authentication information found. Please run copilot and use /login, or set GH_TOKEN."`)
+	if err := c.Start(context.Background(), "do something", executor.Options{WorkingDir: t.TempDir()}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case entry, ok := <-c.Logs():
+			if !ok {
+				t.Fatal("expected an error log for the auth failure")
+			}
+			if entry.Type != "error" {
+				continue
+			}
+			evt := EventTransformer(executor.TransformInput{SessionID: "s1", Executor: "copilot", Log: entry})
+			if content := evt.Content.(executor.UnifiedContent); evt.Type != "error" || content.ErrorKind != executor.ErrorKindAuth {
+				t.Fatalf("expected auth error event, got %s %+v", evt.Type, content)
+			}
+			return
+		case <-timeout:
+			t.Fatal("timed out waiting for the auth error")
+		}
+	}
+}
+
+func TestClient_NonZeroExitReportsCode(t *testing.T) {
+	c := NewClient()
+	c.commandRun = fakeCmd(`echo "working"; exit 3`)
+	if err := c.Start(context.Background(), "do something", executor.Options{WorkingDir: t.TempDir()}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	var types []string
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case entry, ok := <-c.Logs():
+			if !ok {
+				t.Fatalf("logs closed before done, got %v", types)
+			}
+			types = append(types, entry.Type)
+			if entry.Type != "done" {
+				continue
+			}
+			if len(types) < 2 || types[len(types)-2] != "error" {
+				t.Fatalf("expected an error log before done, got %v", types)
+			}
+			evt := EventTransformer(executor.TransformInput{SessionID: "s1", Executor: "copilot", Log: entry})
+			content := evt.Content.(executor.UnifiedContent)
+			content.ApplyError(entry.Err)
+			if content.ExitCode != 3 {
+				t.Fatalf("expected exit code 3 on done, got %+v", content)
+			}
+			return
+		case <-timeout:
+			t.Fatal("timed out waiting for done")
+		}
+	}
+}
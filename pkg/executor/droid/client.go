@@ -6,13 +6,14 @@
 //	           [--model M] [--reasoning-effort E]
 //
 // The user prompt is passed via stdin (written then closed). Droid streams
-// newline-delimited JSON events on stdout until it exits.
+// newline-delimited JSON events on stdout until it exits. With
+// Options.DroidInteractive, stdin instead carries stream-json user messages and
+// stays open so SendMessage can add further turns.
 //
 // Session resumption is supported via the --session-id flag.
 package droid
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -30,6 +31,9 @@ type Client struct {
 	cmd    *exec.Cmd
 	stdin  io.WriteCloser
 	stdout io.ReadCloser
+	exit   executor.ProcessExit
+	// stderrDone is closed once stderr reaches EOF.
+	stderrDone chan struct{}
 
 	logsChan  chan executor.Log
 	doneChan  chan struct{}
@@ -37,6 +41,11 @@ type Client struct {
 	mu        sync.Mutex
 	closed    bool
 
+	// interactive keeps stdin open for stream-json user messages.
+	interactive bool
+	writeMu     sync.Mutex
+	promptSent  chan struct{}
+
 	// commandRun is substituted during tests to avoid spawning real processes.
 	commandRun func(name string, arg ...string) *exec.Cmd
 }
@@ -50,19 +59,50 @@ func NewClient(commandRun func(string, ...string) *exec.Cmd) *Client {
 	return &Client{
 		logsChan:   make(chan executor.Log, 200),
 		doneChan:   make(chan struct{}),
+		promptSent: make(chan struct{}),
 		commandRun: commandRun,
 	}
 }
 
 // Start builds the Droid CLI argument vector, spawns the process, pipes the
 // prompt into stdin, and begins streaming events from stdout.
+// Unknown autonomy or reasoning-effort values are rejected before anything is
+// spawned.
 func (c *Client) Start(_ context.Context, prompt string, opts executor.Options) error {
+	opts, err := normalizeOptions(opts)
+	if err != nil {
+		return err
+	}
 	args := buildArgs(opts)
-	return c.launch(prompt, opts.WorkingDir, opts.Env, args)
+	return c.launch(prompt, opts, args)
+}
+
+// ValidateOptions rejects autonomy and reasoning-effort values Droid does not
+// accept.
+func (c *Client) ValidateOptions(opts executor.Options) error {
+	_, err := normalizeOptions(opts)
+	return err
+}
+
+// normalizeOptions returns opts with DroidAutonomy and DroidReasoningEffort
+// trimmed and lowercased, or the *executor.OptionError for the first value
+// Droid does not accept.
+func normalizeOptions(opts executor.Options) (executor.Options, error) {
+	autonomy, err := NormalizeAutonomy(opts.DroidAutonomy)
+	if err != nil {
+		return opts, err
+	}
+	effort, err := NormalizeReasoningEffort(opts.DroidReasoningEffort)
+	if err != nil {
+		return opts, err
+	}
+	opts.DroidAutonomy = string(autonomy)
+	opts.DroidReasoningEffort = string(effort)
+	return opts, nil
 }
 
 // launch is the shared implementation used for both initial start and test injection.
-func (c *Client) launch(prompt, workingDir string, env map[string]string, args []string) error {
+func (c *Client) launch(prompt string, opts executor.Options, args []string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("droid: no command args provided")
 	}
@@ -71,8 +111,8 @@ func (c *Client) launch(prompt, workingDir string, env map[string]string, args [
 	rest := args[1:]
 
 	cmd := c.commandRun(program, rest...)
-	cmd.Dir = workingDir
-	cmd.Env = executor.BuildCommandEnv(env, map[string]string{
+	cmd.Dir = opts.WorkingDir
+	cmd.Env = executor.BuildCommandEnv(opts.Env, map[string]string{
 		"NPM_CONFIG_LOGLEVEL": "error",
 	})
 
@@ -94,29 +134,51 @@ func (c *Client) launch(prompt, workingDir string, env map[string]string, args [
 	c.cmd = cmd
 	c.stdin = stdin
 	c.stdout = stdout
+	c.interactive = opts.DroidInteractive
 
 	c.sendLog(executor.Log{
 		Type:    "command",
-		Content: fmt.Sprintf("%s %s", program, strings.Join(rest, " ")),
+		Content: fmt.Sprintf("%s %s", program, strings.Join(opts.RedactArgs(rest), " ")),
 	})
 
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("droid: start process: %w", err)
+		return fmt.Errorf("droid: start process: %w", executor.WrapStartError(err))
 	}
 
-	// Drain stderr in background; forward lines as error logs.
+	// Drain stderr in background; forward lines as stderr logs, or debug logs
+	// for npm/node noise.
+	c.stderrDone = make(chan struct{})
 	go func() {
-		sc := bufio.NewScanner(stderr)
-		for sc.Scan() {
-			if line := strings.TrimSpace(sc.Text()); line != "" {
-				c.sendLog(executor.Log{Type: "stderr", Content: line})
+		defer close(c.stderrDone)
+		_ = executor.ScanLines(stderr, c.sendLog, func(line string) bool {
+			if line = strings.TrimSpace(line); line != "" {
+				logType := "stderr"
+				if opts.ClassifyStderr(line) == "debug" {
+					logType = "debug"
+				}
+				c.sendLog(executor.Log{Type: logType, Content: line})
 			}
-		}
+			return true
+		})
 	}()
 
 	// Stream stdout droid events in background.
 	go c.readLoop(stdout)
 
+	if c.interactive {
+		// Send the prompt as the first user message and keep stdin open.
+		go func() {
+			defer close(c.promptSent)
+			if err := c.writeUserMessage(prompt); err != nil {
+				c.sendLog(executor.Log{
+					Type:    "error",
+					Content: fmt.Sprintf("droid: write prompt: %v", err),
+				})
+			}
+		}()
+		return nil
+	}
+
 	// Write the prompt to stdin and close it so Droid knows the input is complete.
 	go func() {
 		defer stdin.Close()
@@ -131,6 +193,26 @@ func (c *Client) launch(prompt, workingDir string, env map[string]string, args [
 	return nil
 }
 
+// writeUserMessage writes one stream-json user message line to stdin.
+func (c *Client) writeUserMessage(text string) error {
+	data, err := json.Marshal(NewUserMessage(text))
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed || c.stdin == nil {
+		return executor.ErrExecutorClosed
+	}
+	_, err = c.stdin.Write(append(data, '\n'))
+	return err
+}
+
 // buildArgs constructs the Droid CLI argument list from executor Options.
 func buildArgs(opts executor.Options) []string {
 	args := []string{"droid", "exec", "--output-format", "stream-json"}
@@ -163,34 +245,42 @@ func buildArgs(opts executor.Options) []string {
 	if opts.ResumeSessionID != "" {
 		args = append(args, "--session-id", opts.ResumeSessionID)
 	}
+	if opts.DroidInteractive {
+		args = append(args, "--input-format", "stream-json")
+	}
 
 	args = append(args, opts.ExtraArgs...)
 	return args
 }
 
-// readLoop reads stream-json lines from r until EOF.
+// readLoop reads stream-json lines from r until EOF, then reaps the process
+// and reports its exit code before done.
 func (c *Client) readLoop(r io.Reader) {
 	defer c.Close()
-	defer c.sendLog(executor.Log{Type: "done", Content: "Droid execution finished"})
-
-	sc := bufio.NewScanner(r)
-	sc.Buffer(make([]byte, 1024*1024), 1024*1024)
 
-	for sc.Scan() {
-		line := strings.TrimSpace(sc.Text())
+	_ = executor.ScanLines(r, c.sendLog, func(line string) bool {
+		line = strings.TrimSpace(line)
 		if line == "" {
-			continue
+			return true
 		}
 
 		var evt DroidEvent
 		if err := json.Unmarshal([]byte(line), &evt); err != nil {
 			// Forward unparsed lines verbatim.
 			c.sendLog(executor.Log{Type: "stdout", Content: line})
-			continue
+			return true
 		}
 
 		c.dispatchEvent(evt)
+		return true
+	})
+
+	if c.cmd == nil {
+		c.sendLog(executor.Log{Type: "done", Content: "Droid execution finished"})
+		return
 	}
+	executor.AwaitDrain(c.stderrDone)
+	executor.ReportExit(c.sendLog, c.exit.Wait(c.cmd), "Droid execution finished")
 }
 
 // dispatchEvent converts a parsed DroidEvent to an executor.Log.
@@ -221,9 +311,19 @@ func (c *Client) Interrupt() error {
 	return nil
 }
 
-// SendMessage is not supported by Droid (single-shot execution); it returns an error.
-func (c *Client) SendMessage(_ context.Context, _ string) error {
-	return fmt.Errorf("droid: SendMessage not supported; start a new session instead")
+// SendMessage writes a follow-up user message. It is only supported when the
+// session was started with Options.DroidInteractive; otherwise Droid runs
+// single-shot and an error is returned.
+func (c *Client) SendMessage(ctx context.Context, message string) error {
+	if !c.interactive {
+		return fmt.Errorf("droid: SendMessage not supported; start a new session instead")
+	}
+	select {
+	case <-c.promptSent:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return c.writeUserMessage(message)
 }
 
 // RespondControl is not supported by Droid in ACP mode; returns an error.
@@ -231,12 +331,75 @@ func (c *Client) RespondControl(_ context.Context, _ executor.ControlResponse) e
 	return fmt.Errorf("droid: RespondControl not supported")
 }
 
+// CaptureResume records the session_id reported by system and message events,
+// which --session-id accepts to resume the session.
+func (c *Client) CaptureResume(entry executor.Log, prior executor.ResumeState) executor.ResumeState {
+	if entry.Type != "droid_system" && entry.Type != "droid_message" {
+		return prior
+	}
+	switch evt := entry.Content.(type) {
+	case DroidEvent:
+		if evt.SessionID != "" {
+			prior.SessionID = evt.SessionID
+		}
+	case *DroidEvent:
+		if evt != nil && evt.SessionID != "" {
+			prior.SessionID = evt.SessionID
+		}
+	default:
+		if obj, ok := executor.DecodeJSONObject(entry.Content); ok {
+			if sid, ok := obj["session_id"].(string); ok && sid != "" {
+				prior.SessionID = sid
+			}
+		}
+	}
+	return prior
+}
+
+// CaptureVersion reads the CLI version from the system event.
+func (c *Client) CaptureVersion(entry executor.Log) string {
+	if entry.Type != "droid_system" {
+		return ""
+	}
+	switch evt := entry.Content.(type) {
+	case DroidEvent:
+		return evt.Version
+	case *DroidEvent:
+		if evt != nil {
+			return evt.Version
+		}
+	default:
+		if obj, ok := executor.DecodeJSONObject(entry.Content); ok {
+			version, _ := obj["version"].(string)
+			return version
+		}
+	}
+	return ""
+}
+
+// Capabilities reports the optional operations this executor supports.
+// SendMessage also works when started with Options.DroidInteractive.
+func (c *Client) Capabilities() executor.Capabilities {
+	return executor.Capabilities{Resume: true}
+}
+
 // Wait blocks until the executor finishes.
 func (c *Client) Wait() error {
 	<-c.doneChan
 	return nil
 }
 
+// WaitContext waits for the execution to complete or for ctx to be done.
+// It returns ctx.Err() if the context ends the wait first.
+func (c *Client) WaitContext(ctx context.Context) error {
+	select {
+	case <-c.doneChan:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Logs returns the channel of streaming log entries.
 func (c *Client) Logs() <-chan executor.Log {
 	return c.logsChan
@@ -258,7 +421,7 @@ func (c *Client) Close() error {
 
 		if c.cmd != nil && c.cmd.Process != nil {
 			_ = c.cmd.Process.Kill()
-			_ = c.cmd.Wait()
+			_ = c.exit.Wait(c.cmd)
 		}
 		if c.stdin != nil {
 			_ = c.stdin.Close()
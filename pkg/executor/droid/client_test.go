@@ -2,7 +2,11 @@ package droid
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -17,6 +21,14 @@ func fakeCmd(script string) func(string, ...string) *exec.Cmd {
 	}
 }
 
+func TestStart_NotInstalled(t *testing.T) {
+	c := NewClient(func(string, ...string) *exec.Cmd { return exec.Command("/nonexistent/droid") })
+	err := c.Start(context.Background(), "hi", executor.Options{})
+	if !errors.Is(err, executor.ErrExecutorNotInstalled) {
+		t.Fatalf("expected ErrExecutorNotInstalled, got %v", err)
+	}
+}
+
 func TestBuildArgs_DefaultYolo(t *testing.T) {
 	opts := executor.Options{Yolo: true}
 	args := buildArgs(opts)
@@ -243,6 +255,72 @@ func TestClient_SendMessage_ReturnsError(t *testing.T) {
 	}
 }
 
+func TestClient_SendMessage_NonInteractiveClosesStdin(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "stdin")
+	c := NewClient(fakeCmd("cat > " + input))
+
+	opts := executor.Options{WorkingDir: t.TempDir(), DroidAutonomy: string(AutonomyNormal)}
+	if err := c.Start(context.Background(), "hello droid", opts); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := c.SendMessage(context.Background(), "again"); err == nil {
+		t.Error("expected SendMessage to fail without DroidInteractive")
+	}
+
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected process to exit once stdin is closed")
+	}
+	data, _ := os.ReadFile(input)
+	if string(data) != "hello droid" {
+		t.Errorf("expected raw prompt on stdin, got %q", data)
+	}
+}
+
+func TestClient_SendMessage_Interactive(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "stdin")
+	script := `while IFS= read -r line; do printf '%s\n' "$line" >> ` + input + `; printf '{"type":"message","role":"assistant","text":"ack"}\n'; done`
+	c := NewClient(fakeCmd(script))
+	defer c.Close()
+
+	opts := executor.Options{WorkingDir: t.TempDir(), DroidAutonomy: string(AutonomyNormal), DroidInteractive: true}
+	if !containsFlag(buildArgs(opts), "--input-format") {
+		t.Fatalf("expected --input-format for interactive mode, got: %v", buildArgs(opts))
+	}
+	if err := c.Start(context.Background(), "first", opts); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := c.SendMessage(context.Background(), "second"); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	acks := 0
+	timeout := time.After(5 * time.Second)
+	for acks < 2 {
+		select {
+		case log := <-c.Logs():
+			if log.Type == "droid_message" {
+				acks++
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for replies, got %d", acks)
+		}
+	}
+
+	data, _ := os.ReadFile(input)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected two stdin lines, got %q", data)
+	}
+	for i, want := range []string{"first", "second"} {
+		var msg UserMessage
+		if err := json.Unmarshal([]byte(lines[i]), &msg); err != nil || msg.Role != "user" || msg.Text != want {
+			t.Errorf("unexpected user message %q (err=%v)", lines[i], err)
+		}
+	}
+}
+
 func TestClient_RespondControl_ReturnsError(t *testing.T) {
 	c := NewClient(nil)
 	err := c.RespondControl(context.Background(), executor.ControlResponse{})
@@ -308,3 +386,87 @@ func logTypes(logs []executor.Log) []string {
 	}
 	return types
 }
+
+func TestClient_StderrClassification(t *testing.T) {
+	script := `printf 'npm notice New version available\n' >&2 && printf 'fatal: bad credentials\n' >&2 && sleep 0.2`
+	c := NewClient(fakeCmd(script))
+
+	opts := executor.Options{WorkingDir: t.TempDir(), DroidAutonomy: string(AutonomyNormal)}
+	if err := c.Start(context.Background(), "test", opts); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	got := map[string]string{}
+	for log := range c.Logs() {
+		if line, ok := log.Content.(string); ok {
+			got[line] = log.Type
+		}
+	}
+	if got["npm notice New version available"] != "debug" {
+		t.Errorf("expected npm notice to be debug, got %v", got)
+	}
+	if got["fatal: bad credentials"] != "stderr" {
+		t.Errorf("expected genuine stderr to stay stderr, got %v", got)
+	}
+}
+
+func TestNormalizeOptions(t *testing.T) {
+	cases := []struct {
+		name      string
+		opts      executor.Options
+		autonomy  string
+		effort    string
+		wantField string
+	}{
+		{name: "empty", opts: executor.Options{}},
+		{name: "canonical", opts: executor.Options{DroidAutonomy: "medium", DroidReasoningEffort: "dynamic"}, autonomy: "medium", effort: "dynamic"},
+		{name: "mixed case and spaces", opts: executor.Options{DroidAutonomy: " High ", DroidReasoningEffort: "OFF\n"}, autonomy: "high", effort: "off"},
+		{name: "skip permissions", opts: executor.Options{DroidAutonomy: "Skip-Permissions-Unsafe"}, autonomy: "skip-permissions-unsafe"},
+		{name: "unknown autonomy", opts: executor.Options{DroidAutonomy: "full"}, wantField: "droid_autonomy"},
+		{name: "unknown effort", opts: executor.Options{DroidReasoningEffort: "hgih"}, wantField: "droid_reasoning_effort"},
+		{name: "effort is not an autonomy", opts: executor.Options{DroidAutonomy: "dynamic"}, wantField: "droid_autonomy"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := normalizeOptions(tc.opts)
+			if tc.wantField != "" {
+				var optErr *executor.OptionError
+				if !errors.Is(err, executor.ErrInvalidOption) || !errors.As(err, &optErr) || optErr.Field != tc.wantField {
+					t.Fatalf("expected %s option error, got %v", tc.wantField, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.DroidAutonomy != tc.autonomy || got.DroidReasoningEffort != tc.effort {
+				t.Fatalf("expected %q/%q, got %q/%q", tc.autonomy, tc.effort, got.DroidAutonomy, got.DroidReasoningEffort)
+			}
+		})
+	}
+}
+
+func TestStart_RejectsInvalidReasoningEffort(t *testing.T) {
+	c := NewClient(func(string, ...string) *exec.Cmd {
+		t.Fatalf("droid must not be spawned for invalid options")
+		return nil
+	})
+	err := c.Start(context.Background(), "hi", executor.Options{DroidReasoningEffort: "maximum"})
+	if !errors.Is(err, executor.ErrInvalidOption) || !strings.Contains(err.Error(), `"maximum"`) {
+		t.Fatalf("expected invalid option error naming the value, got %v", err)
+	}
+	if err := c.ValidateOptions(executor.Options{DroidAutonomy: "Low"}); err != nil {
+		t.Fatalf("expected Low to be accepted, got %v", err)
+	}
+}
+
+func TestBuildArgs_NormalizedReasoningEffort(t *testing.T) {
+	opts, err := normalizeOptions(executor.Options{DroidAutonomy: "LOW", DroidReasoningEffort: " Medium "})
+	if err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	args := strings.Join(buildArgs(opts), " ")
+	if !strings.Contains(args, "--auto low") || !strings.Contains(args, "--reasoning-effort medium") {
+		t.Fatalf("expected normalized flags, got: %s", args)
+	}
+}
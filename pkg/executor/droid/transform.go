@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/supremeagent/executor/pkg/executor"
 )
@@ -36,6 +37,12 @@ func EventTransformer(input executor.TransformInput) executor.Event {
 		content.Summary = "Execution failed"
 		eventType = "error"
 
+	case "debug":
+		content.Category = "debug"
+		content.Action = "tracing"
+		content.Summary = content.Text
+		eventType = "debug"
+
 	case "command":
 		content.Category = "lifecycle"
 		content.Action = "starting"
@@ -81,6 +88,7 @@ func EventTransformer(input executor.TransformInput) executor.Event {
 		content.Phase = "started"
 		if evt, ok := parseDroidEvent(input.Log.Content); ok {
 			content.ToolName = evt.ToolName
+			content.ToolCallID = evt.ID
 			applyDroidToolMapping(&content, evt.ToolName)
 		}
 
@@ -90,12 +98,14 @@ func EventTransformer(input executor.TransformInput) executor.Event {
 		content.Phase = "completed"
 		if evt, ok := parseDroidEvent(input.Log.Content); ok {
 			content.ToolName = evt.ToolName
+			content.ToolCallID = evt.ID
 			applyDroidToolMapping(&content, evt.ToolName)
 			if evt.IsError {
 				content.Phase = "failed"
 				content.Status = "failed"
 			} else {
 				content.Status = "success"
+				content.Files = droidFileChanges(evt)
 			}
 		}
 
@@ -120,10 +130,17 @@ func EventTransformer(input executor.TransformInput) executor.Event {
 		content.Summary = defaultSummary(content)
 	}
 
-	return executor.Event{
+	evt := executor.Event{
 		Type:    eventType,
 		Content: content,
 	}
+	if strings.HasPrefix(input.Log.Type, "droid_") {
+		if parsed, ok := parseDroidEvent(input.Log.Content); ok && parsed.Timestamp > 0 {
+			// Droid timestamps are Unix milliseconds.
+			evt.Timestamp = time.UnixMilli(int64(parsed.Timestamp))
+		}
+	}
+	return evt
 }
 
 // applyDroidToolMapping maps a Droid tool name to human-readable action fields.
@@ -165,6 +182,101 @@ func applyDroidToolMapping(content *executor.UnifiedContent, toolName string) {
 	}
 }
 
+// droidFileChanges derives file changes from the parameters of an
+// Edit/MultiEdit/Create/ApplyPatch tool result.
+func droidFileChanges(evt DroidEvent) []executor.FileChange {
+	if len(evt.Parameters) == 0 {
+		return nil
+	}
+	var params map[string]any
+	if err := json.Unmarshal(evt.Parameters, &params); err != nil {
+		return nil
+	}
+
+	name := strings.ToLower(evt.ToolName)
+	if name == "applypatch" {
+		patch, _ := params["input"].(string)
+		if patch == "" {
+			patch, _ = params["patch"].(string)
+		}
+		return parsePatchFileChanges(patch)
+	}
+
+	path, _ := params["file_path"].(string)
+	if path == "" {
+		path, _ = params["path"].(string)
+	}
+	if path == "" {
+		return nil
+	}
+
+	change := executor.FileChange{Path: path}
+	switch name {
+	case "edit":
+		change.Op = executor.FileOpModify
+		change.Deletions = countLines(firstString(params, "old_string", "old_str"))
+		change.Additions = countLines(firstString(params, "new_string", "new_str"))
+	case "multiedit":
+		change.Op = executor.FileOpModify
+		edits, _ := params["edits"].([]any)
+		for _, item := range edits {
+			if edit, ok := item.(map[string]any); ok {
+				change.Deletions += countLines(firstString(edit, "old_string", "old_str"))
+				change.Additions += countLines(firstString(edit, "new_string", "new_str"))
+			}
+		}
+	case "create":
+		change.Op = executor.FileOpCreate
+		change.Additions = countLines(firstString(params, "content"))
+	default:
+		return nil
+	}
+	return []executor.FileChange{change}
+}
+
+// parsePatchFileChanges reads an apply_patch style patch
+// ("*** Update File: path" sections) and counts +/- lines per file.
+func parsePatchFileChanges(patch string) []executor.FileChange {
+	var changes []executor.FileChange
+	current := -1
+	for _, line := range strings.Split(patch, "\n") {
+		switch {
+		case strings.HasPrefix(line, "*** Add File: "):
+			changes = append(changes, executor.FileChange{Path: strings.TrimSpace(strings.TrimPrefix(line, "*** Add File: ")), Op: executor.FileOpCreate})
+			current = len(changes) - 1
+		case strings.HasPrefix(line, "*** Update File: "):
+			changes = append(changes, executor.FileChange{Path: strings.TrimSpace(strings.TrimPrefix(line, "*** Update File: ")), Op: executor.FileOpModify})
+			current = len(changes) - 1
+		case strings.HasPrefix(line, "*** Delete File: "):
+			changes = append(changes, executor.FileChange{Path: strings.TrimSpace(strings.TrimPrefix(line, "*** Delete File: ")), Op: executor.FileOpDelete})
+			current = -1
+		case strings.HasPrefix(line, "***"):
+			current = -1
+		case current >= 0 && strings.HasPrefix(line, "+"):
+			changes[current].Additions++
+		case current >= 0 && strings.HasPrefix(line, "-"):
+			changes[current].Deletions++
+		}
+	}
+	return changes
+}
+
+func firstString(obj map[string]any, keys ...string) string {
+	for _, key := range keys {
+		if v, ok := obj[key].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func countLines(text string) int {
+	if text == "" {
+		return 0
+	}
+	return strings.Count(strings.TrimSuffix(text, "\n"), "\n") + 1
+}
+
 // parseDroidEvent extracts a DroidEvent from a Log content value.
 func parseDroidEvent(raw any) (DroidEvent, bool) {
 	switch v := raw.(type) {
@@ -1,6 +1,7 @@
 package droid
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/supremeagent/executor/pkg/executor"
@@ -260,3 +261,42 @@ func TestParseDroidEvent_Unsupported(t *testing.T) {
 		t.Error("expected parseDroidEvent to fail for unsupported type")
 	}
 }
+
+func TestEventTransformer_DroidToolResultFileChanges(t *testing.T) {
+	dEvt := DroidEvent{
+		Type:       EventTypeToolResult,
+		ToolName:   "Edit",
+		Parameters: json.RawMessage(`{"file_path":"src/app.ts","old_str":"x","new_str":"y\nz"}`),
+	}
+	uc, _ := EventTransformer(makeInput("droid_tool_result", dEvt)).Content.(executor.UnifiedContent)
+	if len(uc.Files) != 1 || uc.Files[0].Path != "src/app.ts" || uc.Files[0].Op != executor.FileOpModify {
+		t.Fatalf("unexpected edit file changes: %+v", uc.Files)
+	}
+	if uc.Files[0].Additions != 2 || uc.Files[0].Deletions != 1 {
+		t.Fatalf("unexpected edit line counts: %+v", uc.Files[0])
+	}
+
+	patch := "*** Begin Patch\n*** Add File: new.txt\n+hello\n*** Update File: old.txt\n@@\n-a\n+b\n+c\n*** Delete File: gone.txt\n*** End Patch"
+	params, _ := json.Marshal(map[string]string{"input": patch})
+	dEvt = DroidEvent{Type: EventTypeToolResult, ToolName: "ApplyPatch", Parameters: params}
+	uc, _ = EventTransformer(makeInput("droid_tool_result", dEvt)).Content.(executor.UnifiedContent)
+	want := []executor.FileChange{
+		{Path: "new.txt", Op: executor.FileOpCreate, Additions: 1},
+		{Path: "old.txt", Op: executor.FileOpModify, Additions: 2, Deletions: 1},
+		{Path: "gone.txt", Op: executor.FileOpDelete},
+	}
+	if len(uc.Files) != len(want) {
+		t.Fatalf("unexpected patch file changes: %+v", uc.Files)
+	}
+	for i := range want {
+		if uc.Files[i] != want[i] {
+			t.Fatalf("file change %d: got %+v, want %+v", i, uc.Files[i], want[i])
+		}
+	}
+
+	dEvt = DroidEvent{Type: EventTypeToolResult, ToolName: "Read", Parameters: json.RawMessage(`{"file_path":"a"}`)}
+	uc, _ = EventTransformer(makeInput("droid_tool_result", dEvt)).Content.(executor.UnifiedContent)
+	if uc.Files != nil {
+		t.Fatalf("expected no file changes for read tool, got %+v", uc.Files)
+	}
+}
@@ -1,6 +1,13 @@
 // Package droid provides type definitions for the Droid executor stream-json protocol.
 package droid
 
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
 // Autonomy represents the permission level for Droid's file and system operations.
 type Autonomy string
 
@@ -29,6 +36,47 @@ const (
 	ReasoningEffortHigh    ReasoningEffort = "high"
 )
 
+// Autonomies and ReasoningEfforts are the values Droid accepts for
+// Options.DroidAutonomy and Options.DroidReasoningEffort.
+var (
+	Autonomies = []string{
+		string(AutonomyNormal), string(AutonomyLow), string(AutonomyMedium),
+		string(AutonomyHigh), string(AutonomySkipPermissionsUnsafe),
+	}
+	ReasoningEfforts = []string{
+		string(ReasoningEffortNone), string(ReasoningEffortDynamic), string(ReasoningEffortOff),
+		string(ReasoningEffortLow), string(ReasoningEffortMedium), string(ReasoningEffortHigh),
+	}
+)
+
+// NormalizeAutonomy trims and lowercases value. It returns an
+// *executor.OptionError when the result is not one of Autonomies; an empty
+// value is kept so the default applies.
+func NormalizeAutonomy(value string) (Autonomy, error) {
+	normalized := strings.ToLower(strings.TrimSpace(value))
+	if err := executor.ValidateChoice(string(executor.ExecutorDroid), "droid_autonomy", normalized, Autonomies); err != nil {
+		return "", withValue(err, value)
+	}
+	return Autonomy(normalized), nil
+}
+
+// NormalizeReasoningEffort is NormalizeAutonomy for ReasoningEfforts.
+func NormalizeReasoningEffort(value string) (ReasoningEffort, error) {
+	normalized := strings.ToLower(strings.TrimSpace(value))
+	if err := executor.ValidateChoice(string(executor.ExecutorDroid), "droid_reasoning_effort", normalized, ReasoningEfforts); err != nil {
+		return "", withValue(err, value)
+	}
+	return ReasoningEffort(normalized), nil
+}
+
+// withValue reports the caller's original spelling in an option error.
+func withValue(err error, value string) error {
+	if optErr, ok := err.(*executor.OptionError); ok {
+		optErr.Value = value
+	}
+	return err
+}
+
 // EventType is the value of the "type" field in Droid stream-json output.
 type EventType string
 
@@ -50,6 +98,7 @@ type DroidEvent struct {
 	SessionID string   `json:"session_id,omitempty"`
 	Model     string   `json:"model,omitempty"`
 	Tools     []string `json:"tools,omitempty"`
+	Version   string   `json:"version,omitempty"`
 
 	// Message fields
 	Role      string `json:"role,omitempty"`
@@ -58,9 +107,10 @@ type DroidEvent struct {
 	Timestamp uint64 `json:"timestamp,omitempty"`
 
 	// ToolCall fields
-	MessageID string `json:"messageId,omitempty"`
-	ToolID    string `json:"toolId,omitempty"`
-	ToolName  string `json:"toolName,omitempty"`
+	MessageID  string          `json:"messageId,omitempty"`
+	ToolID     string          `json:"toolId,omitempty"`
+	ToolName   string          `json:"toolName,omitempty"`
+	Parameters json.RawMessage `json:"parameters,omitempty"`
 
 	// ToolResult fields
 	IsError bool `json:"isError,omitempty"`
@@ -74,3 +124,15 @@ type DroidEvent struct {
 	Message string `json:"message,omitempty"`
 	Source  string `json:"source,omitempty"`
 }
+
+// UserMessage is one stream-json input line sent to Droid in interactive mode.
+type UserMessage struct {
+	Type EventType `json:"type"`
+	Role string    `json:"role"`
+	Text string    `json:"text"`
+}
+
+// NewUserMessage builds a user message input line.
+func NewUserMessage(text string) UserMessage {
+	return UserMessage{Type: EventTypeMessage, Role: "user", Text: text}
+}
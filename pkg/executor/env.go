@@ -46,3 +46,93 @@ func BuildCommandEnv(overrides ...map[string]string) []string {
 
 	return result
 }
+
+// DefaultRedactKeys are the substrings that mark an environment variable or
+// CLI flag as secret. Matching is case-insensitive.
+var DefaultRedactKeys = []string{"KEY", "TOKEN", "SECRET", "PASSWORD"}
+
+const redactedValue = "***"
+
+// RedactEnv masks the values of KEY=VALUE entries whose key matches
+// DefaultRedactKeys.
+func RedactEnv(env []string) []string {
+	return RedactEnvKeys(env, DefaultRedactKeys)
+}
+
+// RedactEnvKeys is like RedactEnv but matches keys against denylist.
+func RedactEnvKeys(env []string, denylist []string) []string {
+	result := make([]string, len(env))
+	for i, kv := range env {
+		key, _, ok := strings.Cut(kv, "=")
+		if ok && isSecretKey(key, denylist) {
+			kv = key + "=" + redactedValue
+		}
+		result[i] = kv
+	}
+	return result
+}
+
+// RedactArgs masks secret values in command-line arguments: the value of
+// "--api-key=v" or "--api-key v" style flags and of "API_KEY=v" assignments
+// whose name matches DefaultRedactKeys.
+func RedactArgs(args []string) []string {
+	return RedactArgsKeys(args, DefaultRedactKeys)
+}
+
+// RedactArgsKeys is like RedactArgs but matches names against denylist.
+func RedactArgsKeys(args []string, denylist []string) []string {
+	result := make([]string, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		result[i] = arg
+
+		name, _, hasValue := strings.Cut(arg, "=")
+		isFlag := strings.HasPrefix(name, "-")
+		if !isFlag && (!hasValue || strings.ContainsAny(name, " \t")) {
+			continue
+		}
+		if !isSecretKey(strings.TrimLeft(name, "-"), denylist) {
+			continue
+		}
+
+		if hasValue {
+			result[i] = name + "=" + redactedValue
+		} else if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			i++
+			result[i] = redactedValue
+		}
+	}
+	return result
+}
+
+// RedactArgs masks secret arguments using o.RedactKeys, or DefaultRedactKeys
+// when it is empty.
+func (o Options) RedactArgs(args []string) []string {
+	return RedactArgsKeys(args, o.redactKeys())
+}
+
+// RedactEnv masks secret environment values using o.RedactKeys, or
+// DefaultRedactKeys when it is empty.
+func (o Options) RedactEnv(env []string) []string {
+	return RedactEnvKeys(env, o.redactKeys())
+}
+
+func (o Options) redactKeys() []string {
+	if len(o.RedactKeys) > 0 {
+		return o.RedactKeys
+	}
+	return DefaultRedactKeys
+}
+
+func isSecretKey(key string, denylist []string) bool {
+	key = strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+	if key == "" {
+		return false
+	}
+	for _, pattern := range denylist {
+		if pattern != "" && strings.Contains(key, strings.ToUpper(pattern)) {
+			return true
+		}
+	}
+	return false
+}
@@ -36,3 +36,34 @@ func TestBuildCommandEnv(t *testing.T) {
 		t.Fatal("expected empty key to be ignored")
 	}
 }
+
+func TestRedactEnv(t *testing.T) {
+	got := RedactEnv([]string{"OPENAI_API_KEY=sk-123", "GH_TOKEN=abc", "db_password=pw", "PATH=/usr/bin", "BROKEN"})
+	want := []string{"OPENAI_API_KEY=***", "GH_TOKEN=***", "db_password=***", "PATH=/usr/bin", "BROKEN"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("unexpected redacted env: %v", got)
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	args := []string{"-y", "tool", "--api-key", "sk-123", "--token=abc", "CLIENT_SECRET=xyz", "--model", "gpt", "fix the api_key=1 bug"}
+	got := strings.Join(RedactArgs(args), " ")
+	want := "-y tool --api-key *** --token=*** CLIENT_SECRET=*** --model gpt fix the api_key=1 bug"
+	if got != want {
+		t.Fatalf("unexpected redacted args:\n got %s\nwant %s", got, want)
+	}
+	if args[3] != "sk-123" {
+		t.Fatal("expected input args to be left untouched")
+	}
+}
+
+func TestOptionsRedactKeysOverride(t *testing.T) {
+	opts := Options{RedactKeys: []string{"model"}}
+	got := strings.Join(opts.RedactArgs([]string{"--model", "gpt", "--api-key", "sk"}), " ")
+	if got != "--model *** --api-key sk" {
+		t.Fatalf("expected custom denylist to replace defaults, got %s", got)
+	}
+	if env := opts.RedactEnv([]string{"MODEL=x"}); env[0] != "MODEL=***" {
+		t.Fatalf("unexpected redacted env: %v", env)
+	}
+}
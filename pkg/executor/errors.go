@@ -1,9 +1,149 @@
 package executor
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
 
 var (
 	ErrUnknownExecutorType = errors.New("unknown executor type")
 	ErrSessionNotFound     = errors.New("session not found")
 	ErrExecutorClosed      = errors.New("executor closed")
+	ErrTooManySessions     = errors.New("too many concurrent sessions")
+	ErrInvalidOption       = errors.New("invalid option")
+	// ErrExecutorNotInstalled means the agent CLI (or npx used to fetch it)
+	// could not be found, as opposed to a CLI that started and then failed.
+	ErrExecutorNotInstalled = errors.New("executor not installed")
+	// ErrTimeout means an executor gave up waiting for its subprocess, e.g.
+	// for a JSON-RPC response.
+	ErrTimeout = errors.New("timeout")
+)
+
+// Error kinds reported in UnifiedContent.ErrorKind.
+const (
+	// ErrorKindProcessExit means the agent process exited unsuccessfully;
+	// ErrorCode holds the exit code.
+	ErrorKindProcessExit = "process_exit"
+	// ErrorKindTimeout means a request to the agent timed out.
+	ErrorKindTimeout = "timeout"
+	// ErrorKindRPC means the agent answered a request with an error;
+	// ErrorCode holds the JSON-RPC error code.
+	ErrorKindRPC = "rpc"
 )
+
+// OptionError reports an option value an executor does not accept. It
+// matches ErrInvalidOption with errors.Is.
+type OptionError struct {
+	Executor string
+	Field    string
+	Value    string
+	Allowed  []string
+}
+
+func (e *OptionError) Error() string {
+	msg := fmt.Sprintf("%s: %s %q", ErrInvalidOption, e.Field, e.Value)
+	if e.Executor != "" {
+		msg += " for " + e.Executor
+	}
+	if len(e.Allowed) > 0 {
+		msg += " (allowed: " + strings.Join(e.Allowed, ", ") + ")"
+	}
+	return msg
+}
+
+func (e *OptionError) Unwrap() error { return ErrInvalidOption }
+
+// RPCError is a JSON-RPC error response from an agent process.
+type RPCError struct {
+	Code    int
+	Message string
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("RPC error: %s (code: %d)", e.Message, e.Code)
+}
+
+// ErrorDetails classifies err into an error kind and code for
+// UnifiedContent. It returns empty strings for errors it does not recognise.
+func ErrorDetails(err error) (kind, code string) {
+	var rpcErr *RPCError
+	var exitErr *exec.ExitError
+	var timeoutErr interface{ Timeout() bool }
+	switch {
+	case err == nil:
+		return "", ""
+	case errors.Is(err, ErrExecutorNotInstalled):
+		return ErrorKindNotInstalled, ""
+	case errors.As(err, &rpcErr):
+		return ErrorKindRPC, strconv.Itoa(rpcErr.Code)
+	case errors.As(err, &exitErr):
+		return ErrorKindProcessExit, strconv.Itoa(exitErr.ExitCode())
+	case errors.Is(err, ErrTimeout), errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &timeoutErr) && timeoutErr.Timeout():
+		return ErrorKindTimeout, ""
+	}
+	return "", ""
+}
+
+// ApplyError sets ExitCode when err is a process exit error, and ErrorKind
+// and ErrorCode unless a transformer already classified the event. Done
+// events only get ExitCode, since the end of a run is not itself an error.
+func (c *UnifiedContent) ApplyError(err error) {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		c.ExitCode = exitErr.ExitCode()
+	}
+	if c.ErrorKind != "" || c.Category == "done" {
+		return
+	}
+	c.ErrorKind, c.ErrorCode = ErrorDetails(err)
+}
+
+// ValidateChoice returns an *OptionError when value is set and not one of
+// allowed. Empty values are accepted so executors can apply defaults.
+func ValidateChoice(executorName, field, value string, allowed []string) error {
+	if value == "" || slices.Contains(allowed, value) {
+		return nil
+	}
+	return &OptionError{Executor: executorName, Field: field, Value: value, Allowed: allowed}
+}
+
+// WrapStartError marks process start errors caused by a missing binary with
+// ErrExecutorNotInstalled, keeping the original error in the chain: a failed
+// PATH lookup, or a program path that does not exist. Other errors, such as a
+// missing working directory, are returned unchanged.
+func WrapStartError(err error) error {
+	var pathErr *fs.PathError
+	if errors.Is(err, exec.ErrNotFound) ||
+		(errors.As(err, &pathErr) && pathErr.Op == "fork/exec" && errors.Is(pathErr.Err, fs.ErrNotExist)) {
+		return fmt.Errorf("%w: %w", ErrExecutorNotInstalled, err)
+	}
+	return err
+}
+
+// IsBrokenPipe reports whether err is a write to a PTY or pipe whose
+// subprocess end is gone: EIO (PTY with no child), EPIPE, or a closed file.
+func IsBrokenPipe(err error) bool {
+	return errors.Is(err, syscall.EIO) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, fs.ErrClosed) ||
+		errors.Is(err, io.ErrClosedPipe)
+}
+
+// WrapWriteError marks stdin/PTY write errors caused by a dead subprocess with
+// ErrExecutorClosed, keeping the original error in the chain. Other errors are
+// returned unchanged.
+func WrapWriteError(err error) error {
+	if IsBrokenPipe(err) {
+		return fmt.Errorf("%w: %w", ErrExecutorClosed, err)
+	}
+	return err
+}
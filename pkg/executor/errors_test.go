@@ -0,0 +1,93 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"testing"
+)
+
+func TestErrorDetails(t *testing.T) {
+	exitErr := exec.Command("sh", "-c", "exit 3").Run()
+	if exitErr == nil {
+		t.Fatal("expected command to fail")
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		wantKind string
+		wantCode string
+	}{
+		{"Nil", nil, "", ""},
+		{"ProcessExit", exitErr, ErrorKindProcessExit, "3"},
+		{"RPC", fmt.Errorf("failed to add listener: %w", &RPCError{Code: -32603, Message: "internal error"}), ErrorKindRPC, "-32603"},
+		{"Timeout", fmt.Errorf("%w waiting for response", ErrTimeout), ErrorKindTimeout, ""},
+		{"DeadlineExceeded", context.DeadlineExceeded, ErrorKindTimeout, ""},
+		{"NotInstalled", fmt.Errorf("%w: %w", ErrExecutorNotInstalled, exitErr), ErrorKindNotInstalled, ""},
+		{"Unknown", errors.New("boom"), "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, code := ErrorDetails(tt.err)
+			if kind != tt.wantKind || code != tt.wantCode {
+				t.Fatalf("ErrorDetails(%v) = %q/%q, want %q/%q", tt.err, kind, code, tt.wantKind, tt.wantCode)
+			}
+		})
+	}
+
+	content := UnifiedContent{ErrorKind: ErrorKindRateLimit}
+	content.ApplyError(exitErr)
+	if content.ErrorKind != ErrorKindRateLimit || content.ErrorCode != "" {
+		t.Fatalf("expected existing kind to be kept, got %+v", content)
+	}
+}
+
+func TestWrapStartError(t *testing.T) {
+	missingBinary := exec.Command("executor-test-missing-binary").Start()
+	missingPath := exec.Command("/nonexistent/npx").Start()
+	missingDirCmd := exec.Command("sh", "-c", "true")
+	missingDirCmd.Dir = "/nonexistent/workdir"
+	missingDir := missingDirCmd.Start()
+
+	for name, err := range map[string]error{"MissingBinary": missingBinary, "MissingPath": missingPath} {
+		if !errors.Is(WrapStartError(err), ErrExecutorNotInstalled) {
+			t.Fatalf("%s: expected ErrExecutorNotInstalled, got %v", name, WrapStartError(err))
+		}
+	}
+	if missingDir == nil || errors.Is(WrapStartError(missingDir), ErrExecutorNotInstalled) {
+		t.Fatalf("expected a missing working directory not to count as not installed, got %v", WrapStartError(missingDir))
+	}
+}
+
+func TestReportExit(t *testing.T) {
+	exitErr := exec.Command("sh", "-c", "exit 3").Run()
+	if got := ExitCode(exitErr); got != 3 {
+		t.Fatalf("ExitCode = %d, want 3", got)
+	}
+	if got := ExitCode(nil); got != 0 {
+		t.Fatalf("ExitCode(nil) = %d, want 0", got)
+	}
+
+	var logs []Log
+	ReportExit(func(l Log) { logs = append(logs, l) }, exitErr, "finished")
+	if len(logs) != 2 || logs[0].Type != "error" || logs[1].Type != "done" {
+		t.Fatalf("expected error then done, got %+v", logs)
+	}
+	if logs[0].Err != exitErr || logs[1].Err != exitErr {
+		t.Fatalf("expected both logs to carry the exit error, got %+v", logs)
+	}
+
+	done := UnifiedContent{Category: "done"}
+	done.ApplyError(logs[1].Err)
+	if done.ExitCode != 3 || done.ErrorKind != "" {
+		t.Fatalf("expected done to get only the exit code, got %+v", done)
+	}
+
+	logs = nil
+	ReportExit(func(l Log) { logs = append(logs, l) }, nil, "finished")
+	if len(logs) != 1 || logs[0].Type != "done" || logs[0].Err != nil {
+		t.Fatalf("expected a single clean done on success, got %+v", logs)
+	}
+}
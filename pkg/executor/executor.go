@@ -2,7 +2,9 @@ package executor
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
 )
 
 // Executor defines the interface for AI executor implementations
@@ -32,6 +34,105 @@ type Executor interface {
 	Close() error
 }
 
+// Capabilities describes which optional operations an executor supports.
+type Capabilities struct {
+	// Resume reports that a finished session can be resumed with a new prompt.
+	Resume bool `json:"resume"`
+	// Interactive reports that SendMessage works while the executor is running.
+	Interactive bool `json:"interactive"`
+	// Control reports that RespondControl answers approval requests.
+	Control bool `json:"control"`
+}
+
+// CapabilityReporter is implemented by executors (or their factories) that
+// advertise Capabilities. Executors without it report no capabilities.
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// ResumeState is the executor-specific data needed to resume a finished
+// session. The SDK stores it opaquely and passes it back as
+// Options.ResumeSessionID and Options.ResumePath.
+type ResumeState struct {
+	SessionID string `json:"session_id,omitempty"`
+	Path      string `json:"path,omitempty"`
+}
+
+// ResumeCapturer is implemented by executors that can extract ResumeState
+// from their own logs. CaptureResume is called for every log in order and
+// returns the updated state (prior when nothing changed); it must not block.
+type ResumeCapturer interface {
+	CaptureResume(log Log, prior ResumeState) ResumeState
+}
+
+// TurnOptions are overrides that apply to a single continued turn.
+type TurnOptions struct {
+	// ModelReasoningEffort overrides the reasoning effort for this turn only.
+	ModelReasoningEffort string
+}
+
+// TurnSender is implemented by executors that accept TurnOptions for a
+// message sent to a live session. Executors without it only get SendMessage.
+type TurnSender interface {
+	SendTurn(ctx context.Context, message string, turn TurnOptions) error
+}
+
+// VersionCapturer is implemented by executors whose output reports the agent
+// CLI version. CaptureVersion returns the version carried by log, or "" when
+// log has none; it must not block.
+type VersionCapturer interface {
+	CaptureVersion(log Log) string
+}
+
+// OptionValidator is implemented by executors that reject unsupported option
+// values before Start, returning an error matching ErrInvalidOption.
+type OptionValidator interface {
+	ValidateOptions(opts Options) error
+}
+
+// Shutdowner is implemented by executors that need to finalize (flush state,
+// notify a server) before Close. Shutdown is called right before Close when a
+// session ends or the registry shuts down; like Close, it must tolerate being
+// called more than once.
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// DefaultShutdownTimeout bounds Shutdown when the caller has no deadline.
+const DefaultShutdownTimeout = 5 * time.Second
+
+// ShutdownAndClose gives exec a chance to finalize via Shutdowner, bounded by
+// ctx, and then closes it. The Shutdown error is returned when Close succeeds.
+func ShutdownAndClose(ctx context.Context, exec Executor) error {
+	var shutdownErr error
+	if s, ok := exec.(Shutdowner); ok {
+		shutdownErr = s.Shutdown(ctx)
+	}
+	if err := exec.Close(); err != nil {
+		return err
+	}
+	return shutdownErr
+}
+
+// ContextWaiter is implemented by executors whose Wait can be bounded by a context.
+type ContextWaiter interface {
+	WaitContext(ctx context.Context) error
+}
+
+// WaitContext waits for exec to finish or for ctx to be done, whichever comes
+// first. It uses exec's own WaitContext when available and falls back to Done.
+func WaitContext(ctx context.Context, exec Executor) error {
+	if waiter, ok := exec.(ContextWaiter); ok {
+		return waiter.WaitContext(ctx)
+	}
+	select {
+	case <-exec.Done():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Factory creates executor instances
 type Factory interface {
 	Create() (Executor, error)
@@ -58,11 +159,36 @@ type Options struct {
 	// Claude Code specific
 	Approvals                  bool
 	DangerouslySkipPermissions bool
+	// AllowedTools and DisallowedTools are passed comma-joined as
+	// --allowedTools and --disallowedTools, e.g. DisallowedTools
+	// {"Edit", "Write", "Bash"} for a read-only run. AllowedTools comes from
+	// ExecuteRequest.ClaudeAllowedTools, not from the ApprovalPolicy allowlist.
+	AllowedTools    []string
+	DisallowedTools []string
 
 	// Codex specific
 	Sandbox              string
 	AskForApproval       string
 	ModelReasoningEffort string
+	// CodexMaxPendingRequests caps unanswered JSON-RPC requests (default
+	// codex.DefaultMaxPendingRequests); the oldest is evicted beyond it.
+	CodexMaxPendingRequests int
+	// CodexRequestTimeout bounds how long a JSON-RPC request waits for its
+	// response (default codex.DefaultRequestTimeout). A timeout closes the
+	// executor and is reported once: as an "error" log during startup, or as
+	// the error of the call that timed out.
+	CodexRequestTimeout time.Duration
+	// CodexWorkingDirectory, when set, is sent as the conversation's
+	// workingDirectory instead of WorkingDir. WorkingDir stays the process cwd,
+	// so a jailed app-server can operate on a differently mounted path.
+	CodexWorkingDirectory string
+	// CodexTraceRPC emits every JSON-RPC message sent to or received from the
+	// Codex app-server as a "debug" log. User message text is redacted.
+	CodexTraceRPC bool
+
+	// ApprovalPolicy answers approval requests that would otherwise be
+	// surfaced. Used by Claude Code, Codex, Qwen and ACP-based executors.
+	ApprovalPolicy ApprovalPolicy
 
 	// Shared: skip all permission/approval prompts and run autonomously.
 	// Used by Gemini (--yolo), Qwen (--yolo), Droid (--skip-permissions-unsafe).
@@ -75,24 +201,76 @@ type Options struct {
 	// Droid specific: reasoning effort (none, dynamic, off, low, medium, high).
 	DroidReasoningEffort string
 
+	// Droid specific: keep stdin open and exchange stream-json user messages so
+	// SendMessage can continue the conversation. When false the prompt is
+	// written as plain text and stdin is closed.
+	DroidInteractive bool
+
 	// Copilot specific: allow all tools without prompting.
 	CopilotAllowAllTools bool
 
 	// Gemini / Qwen / Copilot: extra CLI args forwarded verbatim to the subprocess.
 	ExtraArgs []string
+
+	// Launcher runs the npm package of Claude Code, Codex, Qwen, Gemini and
+	// Copilot in place of DefaultLauncher, e.g. "pnpm dlx", "bunx" or the
+	// path of a vendored npx.
+	Launcher string
+	// CommandOverride replaces the whole command of those executors, program
+	// first, bypassing Launcher and the flags built from the other options.
+	// PromptPlaceholder is replaced with the prompt; Codex and Gemini still
+	// send the prompt over their protocol.
+	CommandOverride []string
+
+	// RedactKeys overrides DefaultRedactKeys when masking secrets in logged
+	// commands. Entries are case-insensitive substrings of env or flag names.
+	RedactKeys []string
+
+	// BenignStderrPatterns extends DefaultBenignStderrPatterns with stderr
+	// line prefixes reported as "debug" instead of "error" logs.
+	BenignStderrPatterns []string
+
+	// MaxToolCalls, when > 0, caps the tool calls of a run. The SDK counts
+	// them and stops a run that makes more, marking its session failed.
+	MaxToolCalls int
+
+	// InteractivePromptPatterns extends DefaultInteractivePromptPatterns with
+	// output fragments that mark a line as a prompt waiting for a terminal
+	// answer. Claude, Qwen and Copilot report such lines as control requests.
+	InteractivePromptPatterns []string
 }
 
 // Log represents a log entry from the executor
 type Log struct {
 	Type    string // "stdout", "stderr", "tool_use", "error", "done"
 	Content any
+	// Err is the error behind an "error" log, or the process exit error on
+	// the "done" log of a failed run (see ReportExit). It is not stored; the
+	// SDK uses it to fill in UnifiedContent.ErrorKind and ExitCode.
+	Err error
 }
 
+// LimitMode controls what CreateSession does when a registry's concurrent
+// session limit is reached.
+type LimitMode int
+
+const (
+	// LimitReject makes CreateSession fail with ErrTooManySessions.
+	LimitReject LimitMode = iota
+	// LimitBlock makes CreateSession wait until a session is removed or the
+	// context is done.
+	LimitBlock
+)
+
 // Registry manages executor instances
 type Registry struct {
 	factories map[string]Factory
 	sessions  map[string]Executor
 	mu        sync.RWMutex
+
+	// slots holds one token per registered session when a limit is set.
+	slots     chan struct{}
+	limitMode LimitMode
 }
 
 // NewRegistry creates a new executor registry
@@ -103,6 +281,19 @@ func NewRegistry() *Registry {
 	}
 }
 
+// NewRegistryWithLimit creates a registry that allows at most n concurrent
+// sessions; n <= 0 means unlimited. A session counts from CreateSession until
+// RemoveSession (or ShutdownAll), and mode decides whether further sessions
+// are rejected or wait for a free slot.
+func NewRegistryWithLimit(n int, mode LimitMode) *Registry {
+	r := NewRegistry()
+	if n > 0 {
+		r.slots = make(chan struct{}, n)
+		r.limitMode = mode
+	}
+	return r
+}
+
 // Register registers an executor factory
 func (r *Registry) Register(name string, factory Factory) {
 	r.mu.Lock()
@@ -110,8 +301,28 @@ func (r *Registry) Register(name string, factory Factory) {
 	r.factories[name] = factory
 }
 
+// Clone returns a new registry with the same factories and no sessions.
+// Registering on the clone does not affect the original.
+func (r *Registry) Clone() *Registry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	clone := NewRegistryWithLimit(cap(r.slots), r.limitMode)
+	for name, factory := range r.factories {
+		clone.factories[name] = factory
+	}
+	return clone
+}
+
 // CreateSession creates a new executor session
 func (r *Registry) CreateSession(id, executorType string, opts Options) (Executor, error) {
+	return r.CreateSessionContext(context.Background(), id, executorType, opts)
+}
+
+// CreateSessionContext creates a new executor session. When the registry's
+// session limit is reached it returns ErrTooManySessions, or in LimitBlock
+// mode waits for a free slot until ctx is done and then returns ctx.Err().
+func (r *Registry) CreateSessionContext(ctx context.Context, id, executorType string, opts Options) (Executor, error) {
 	r.mu.RLock()
 	factory, ok := r.factories[executorType]
 	r.mu.RUnlock()
@@ -119,18 +330,52 @@ func (r *Registry) CreateSession(id, executorType string, opts Options) (Executo
 		return nil, ErrUnknownExecutorType
 	}
 
+	if err := r.acquireSlot(ctx); err != nil {
+		return nil, err
+	}
 	exec, err := factory.Create()
 	if err != nil {
+		r.releaseSlot()
 		return nil, err
 	}
 
 	r.mu.Lock()
+	if _, replaced := r.sessions[id]; replaced {
+		// The replaced session's slot carries over to the new executor.
+		r.releaseSlot()
+	}
 	r.sessions[id] = exec
 	r.mu.Unlock()
 
 	return exec, nil
 }
 
+func (r *Registry) acquireSlot(ctx context.Context) error {
+	if r.slots == nil {
+		return nil
+	}
+	if r.limitMode == LimitBlock {
+		select {
+		case r.slots <- struct{}{}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	select {
+	case r.slots <- struct{}{}:
+		return nil
+	default:
+		return ErrTooManySessions
+	}
+}
+
+func (r *Registry) releaseSlot() {
+	if r.slots != nil {
+		<-r.slots
+	}
+}
+
 // GetSession gets an executor session by ID
 func (r *Registry) GetSession(id string) (Executor, bool) {
 	r.mu.RLock()
@@ -139,11 +384,26 @@ func (r *Registry) GetSession(id string) (Executor, bool) {
 	return exec, ok
 }
 
+// SessionIDs returns the IDs of all active sessions, sorted.
+func (r *Registry) SessionIDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]string, 0, len(r.sessions))
+	for id := range r.sessions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
 // RemoveSession removes a session from the registry
 func (r *Registry) RemoveSession(id string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	delete(r.sessions, id)
+	if _, ok := r.sessions[id]; ok {
+		delete(r.sessions, id)
+		r.releaseSlot()
+	}
 }
 
 // ShutdownAll shuts down all active sessions
@@ -153,21 +413,54 @@ func (r *Registry) ShutdownAll() {
 	for id, ex := range r.sessions {
 		sessions = append(sessions, ex)
 		delete(r.sessions, id)
+		r.releaseSlot()
 	}
 	r.mu.Unlock()
 
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout)
+	defer cancel()
 	for _, ex := range sessions {
-		_ = ex.Close()
+		_ = ShutdownAndClose(ctx, ex)
 	}
 }
 
-// Executors returns a list of names for all registered executors.
-func (r *Registry) Executors() []string {
+// List returns the names of all registered executors, sorted.
+func (r *Registry) List() []string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	names := make([]string, 0, len(r.factories))
 	for name := range r.factories {
 		names = append(names, name)
 	}
+	sort.Strings(names)
 	return names
 }
+
+// Executors returns a list of names for all registered executors.
+func (r *Registry) Executors() []string {
+	return r.List()
+}
+
+// Capabilities reports the capabilities of a registered executor. The factory
+// is asked first; otherwise a throwaway instance is created and inspected.
+func (r *Registry) Capabilities(name string) (Capabilities, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[name]
+	r.mu.RUnlock()
+	if !ok {
+		return Capabilities{}, ErrUnknownExecutorType
+	}
+
+	if reporter, ok := factory.(CapabilityReporter); ok {
+		return reporter.Capabilities(), nil
+	}
+	exec, err := factory.Create()
+	if err != nil {
+		return Capabilities{}, err
+	}
+	defer exec.Close()
+	if reporter, ok := exec.(CapabilityReporter); ok {
+		return reporter.Capabilities(), nil
+	}
+	return Capabilities{}, nil
+}
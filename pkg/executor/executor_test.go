@@ -2,7 +2,10 @@ package executor
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"testing"
+	"time"
 )
 
 type MockExecutor struct {
@@ -62,3 +65,170 @@ func TestRegistry(t *testing.T) {
 		t.Errorf("expected ErrUnknownExecutorType, got %v", err)
 	}
 }
+
+func TestRegistryClone(t *testing.T) {
+	r := NewRegistry()
+	r.Register("mock", FactoryFunc(func() (Executor, error) {
+		return &MockExecutor{logs: make(chan Log), done: make(chan struct{})}, nil
+	}))
+	if _, err := r.CreateSession("base-session", "mock", Options{}); err != nil {
+		t.Fatalf("create session failed: %v", err)
+	}
+
+	clone := r.Clone()
+	if _, ok := clone.GetSession("base-session"); ok {
+		t.Fatal("clone should not share sessions")
+	}
+	if _, err := clone.CreateSession("clone-session", "mock", Options{}); err != nil {
+		t.Fatalf("clone should share factories: %v", err)
+	}
+	if _, ok := r.GetSession("clone-session"); ok {
+		t.Fatal("sessions created on clone should not appear in original")
+	}
+
+	clone.Register("clone-only", FactoryFunc(func() (Executor, error) {
+		return &MockExecutor{}, nil
+	}))
+	if _, err := r.CreateSession("s", "clone-only", Options{}); err != ErrUnknownExecutorType {
+		t.Fatalf("registering on clone should not affect original, got %v", err)
+	}
+}
+
+type capableExecutor struct{ MockExecutor }
+
+func (c *capableExecutor) Capabilities() Capabilities {
+	return Capabilities{Resume: true, Control: true}
+}
+
+func TestRegistryListAndCapabilities(t *testing.T) {
+	r := NewRegistry()
+	r.Register("plain", FactoryFunc(func() (Executor, error) { return &MockExecutor{}, nil }))
+	r.Register("capable", FactoryFunc(func() (Executor, error) { return &capableExecutor{}, nil }))
+
+	names := r.List()
+	if len(names) != 2 || names[0] != "capable" || names[1] != "plain" {
+		t.Fatalf("expected sorted names, got %v", names)
+	}
+
+	caps, err := r.Capabilities("capable")
+	if err != nil || !caps.Resume || !caps.Control || caps.Interactive {
+		t.Fatalf("unexpected capabilities: %+v, %v", caps, err)
+	}
+	if caps, err := r.Capabilities("plain"); err != nil || caps != (Capabilities{}) {
+		t.Fatalf("expected default capabilities, got %+v, %v", caps, err)
+	}
+	if _, err := r.Capabilities("missing"); err != ErrUnknownExecutorType {
+		t.Fatalf("expected ErrUnknownExecutorType, got %v", err)
+	}
+}
+
+func TestRegistryLimitReject(t *testing.T) {
+	r := NewRegistryWithLimit(2, LimitReject)
+	r.Register("mock", FactoryFunc(func() (Executor, error) { return &MockExecutor{}, nil }))
+
+	for _, id := range []string{"s1", "s2"} {
+		if _, err := r.CreateSession(id, "mock", Options{}); err != nil {
+			t.Fatalf("create %s failed: %v", id, err)
+		}
+	}
+	if _, err := r.CreateSession("s3", "mock", Options{}); !errors.Is(err, ErrTooManySessions) {
+		t.Fatalf("expected ErrTooManySessions, got %v", err)
+	}
+
+	r.RemoveSession("s1")
+	r.RemoveSession("s1")
+	if _, err := r.CreateSession("s3", "mock", Options{}); err != nil {
+		t.Fatalf("expected slot to be freed by RemoveSession: %v", err)
+	}
+	if _, err := r.CreateSession("s4", "mock", Options{}); !errors.Is(err, ErrTooManySessions) {
+		t.Fatalf("removing a missing session must not free a slot, got %v", err)
+	}
+
+	r.ShutdownAll()
+	if _, err := r.CreateSession("s5", "mock", Options{}); err != nil {
+		t.Fatalf("expected ShutdownAll to free slots: %v", err)
+	}
+}
+
+func TestRegistryLimitBlock(t *testing.T) {
+	r := NewRegistryWithLimit(1, LimitBlock)
+	r.Register("mock", FactoryFunc(func() (Executor, error) { return &MockExecutor{}, nil }))
+	if _, err := r.CreateSession("s1", "mock", Options{}); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := r.CreateSessionContext(ctx, "s2", "mock", Options{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected blocked create to end with the context, got %v", err)
+	}
+
+	created := make(chan error, 1)
+	go func() {
+		_, err := r.CreateSessionContext(context.Background(), "s2", "mock", Options{})
+		created <- err
+	}()
+	select {
+	case err := <-created:
+		t.Fatalf("expected create to block while full, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	r.RemoveSession("s1")
+	select {
+	case err := <-created:
+		if err != nil {
+			t.Fatalf("expected blocked create to succeed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked create did not resume after RemoveSession")
+	}
+}
+
+type shutdownExecutor struct {
+	MockExecutor
+	calls []string
+}
+
+func (m *shutdownExecutor) Shutdown(ctx context.Context) error {
+	m.calls = append(m.calls, "shutdown")
+	return nil
+}
+
+func (m *shutdownExecutor) Close() error {
+	m.calls = append(m.calls, "close")
+	return nil
+}
+
+func TestRegistryShutdownAllCallsShutdowner(t *testing.T) {
+	r := NewRegistry()
+	exec := &shutdownExecutor{}
+	r.Register("finalizing", FactoryFunc(func() (Executor, error) { return exec, nil }))
+	if _, err := r.CreateSession("s1", "finalizing", Options{}); err != nil {
+		t.Fatalf("create session failed: %v", err)
+	}
+
+	r.ShutdownAll()
+
+	if len(exec.calls) != 2 || exec.calls[0] != "shutdown" || exec.calls[1] != "close" {
+		t.Fatalf("expected Shutdown before Close, got %v", exec.calls)
+	}
+}
+
+func TestDecodeJSONObjectHelpers(t *testing.T) {
+	obj, ok := DecodeJSONObject(map[string]any{"k": "v"})
+	if !ok || obj["k"] != "v" {
+		t.Fatalf("decode map failed: %#v", obj)
+	}
+
+	raw := json.RawMessage(`{"n":1}`)
+	obj, ok = DecodeJSONObject(raw)
+	if !ok || obj["n"].(float64) != 1 {
+		t.Fatalf("decode raw failed: %#v", obj)
+	}
+
+	obj, ok = DecodeJSONObjectFromLine("prefix {\"a\":\"b\"}")
+	if !ok || obj["a"] != "b" {
+		t.Fatalf("decode from line failed: %#v", obj)
+	}
+}
@@ -0,0 +1,65 @@
+package executor
+
+import (
+	"errors"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// DefaultDrainTimeout bounds how long AwaitDrain waits for a stderr reader.
+const DefaultDrainTimeout = time.Second
+
+// ExitCode returns the exit code of a process whose Wait returned err: 0 for
+// nil, the process's code for an *exec.ExitError (-1 when a signal killed
+// it), and -1 for other errors.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// ReportExit sends the logs that end a run whose process exited with waitErr,
+// the result of cmd.Wait: an "error" log when the process failed, then the
+// "done" log with doneContent. Both carry waitErr, from which the SDK fills in
+// UnifiedContent.ExitCode.
+func ReportExit(send func(Log), waitErr error, doneContent any) {
+	if waitErr != nil {
+		send(Log{Type: "error", Content: waitErr.Error(), Err: waitErr})
+	}
+	send(Log{Type: "done", Content: doneContent, Err: waitErr})
+}
+
+// ProcessExit reaps an agent process once and shares the result, so that an
+// output reader reporting the exit code and Close, which kills the process,
+// can both wait for it. The zero value is ready to use.
+type ProcessExit struct {
+	once sync.Once
+	err  error
+}
+
+// Wait waits for cmd to exit, or returns the result of an earlier Wait.
+func (p *ProcessExit) Wait(cmd *exec.Cmd) error {
+	p.once.Do(func() { p.err = cmd.Wait() })
+	return p.err
+}
+
+// AwaitDrain waits up to DefaultDrainTimeout for drained, which a stderr
+// reader closes at EOF, so that its last lines (often the reason a process
+// failed) are reported before the exit. A nil channel returns at once.
+func AwaitDrain(drained <-chan struct{}) {
+	if drained == nil {
+		return
+	}
+	timer := time.NewTimer(DefaultDrainTimeout)
+	defer timer.Stop()
+	select {
+	case <-drained:
+	case <-timer.C:
+	}
+}
@@ -1,7 +1,7 @@
 // Package gemini implements executor.Executor for Google Gemini CLI.
 //
 // Gemini CLI speaks the Agent Client Protocol (ACP) over stdin/stdout using
-// line-delimited JSON. The binary is invoked via npx:
+// line-delimited JSON. The binary is invoked via npx (see Options.Launcher):
 //
 //	npx -y @google/gemini-cli@latest --experimental-acp [--yolo] [--model M]
 //
@@ -37,16 +37,16 @@ func NewClient(commandRun func(string, ...string) *exec.Cmd) *Client {
 
 // Start builds the Gemini CLI argument vector and launches the process.
 func (c *Client) Start(ctx context.Context, prompt string, opts executor.Options) error {
-	args := buildArgs(opts)
+	args := buildArgs(prompt, opts)
 	inner := acp.NewClientWithArgs(c.commandRun, args)
 	inner.SetAutoApprove(opts.Yolo)
 	c.inner = inner
 	return inner.Start(ctx, prompt, opts)
 }
 
-// buildArgs constructs the npx argument list for Gemini CLI.
-func buildArgs(opts executor.Options) []string {
-	args := []string{"npx", "-y", npmPackage}
+// buildArgs constructs the command that runs Gemini CLI, launcher first.
+func buildArgs(prompt string, opts executor.Options) []string {
+	args := []string{npmPackage}
 
 	if opts.Model != "" {
 		args = append(args, "--model", opts.Model)
@@ -59,7 +59,7 @@ func buildArgs(opts executor.Options) []string {
 	// Required flag to enable ACP mode.
 	args = append(args, "--experimental-acp")
 	args = append(args, opts.ExtraArgs...)
-	return args
+	return opts.LaunchCommand(prompt, args)
 }
 
 func (c *Client) Interrupt() error {
@@ -83,6 +83,11 @@ func (c *Client) RespondControl(ctx context.Context, response executor.ControlRe
 	return executor.ErrExecutorClosed
 }
 
+// Capabilities reports the optional operations this executor supports.
+func (c *Client) Capabilities() executor.Capabilities {
+	return executor.Capabilities{Interactive: true, Control: true}
+}
+
 func (c *Client) Wait() error {
 	if c.inner != nil {
 		return c.inner.Wait()
@@ -90,6 +95,13 @@ func (c *Client) Wait() error {
 	return nil
 }
 
+func (c *Client) WaitContext(ctx context.Context) error {
+	if c.inner != nil {
+		return c.inner.WaitContext(ctx)
+	}
+	return nil
+}
+
 func (c *Client) Logs() <-chan executor.Log {
 	if c.inner != nil {
 		return c.inner.Logs()
@@ -17,7 +17,7 @@ func fakeCmd(script string) func(string, ...string) *exec.Cmd {
 }
 
 func TestBuildArgs_NoOptions(t *testing.T) {
-	args := buildArgs(executor.Options{})
+	args := buildArgs("", executor.Options{})
 	if len(args) < 3 {
 		t.Fatalf("expected at least 3 args, got %d: %v", len(args), args)
 	}
@@ -33,7 +33,7 @@ func TestBuildArgs_NoOptions(t *testing.T) {
 }
 
 func TestBuildArgs_WithYolo(t *testing.T) {
-	args := buildArgs(executor.Options{Yolo: true})
+	args := buildArgs("", executor.Options{Yolo: true})
 	if !containsFlag(args, "--yolo") {
 		t.Errorf("expected --yolo flag, got: %v", args)
 	}
@@ -46,7 +46,7 @@ func TestBuildArgs_WithYolo(t *testing.T) {
 }
 
 func TestBuildArgs_WithModel(t *testing.T) {
-	args := buildArgs(executor.Options{Model: "gemini-2.0-flash"})
+	args := buildArgs("", executor.Options{Model: "gemini-2.0-flash"})
 	if !containsFlag(args, "--model") {
 		t.Errorf("expected --model flag, got: %v", args)
 	}
@@ -56,14 +56,14 @@ func TestBuildArgs_WithModel(t *testing.T) {
 }
 
 func TestBuildArgs_ExtraArgs(t *testing.T) {
-	args := buildArgs(executor.Options{ExtraArgs: []string{"--verbose"}})
+	args := buildArgs("", executor.Options{ExtraArgs: []string{"--verbose"}})
 	if !containsFlag(args, "--verbose") {
 		t.Errorf("expected --verbose in extra args, got: %v", args)
 	}
 }
 
 func TestBuildArgs_NpmPackage(t *testing.T) {
-	args := buildArgs(executor.Options{})
+	args := buildArgs("", executor.Options{})
 	found := false
 	for _, a := range args {
 		if a == npmPackage {
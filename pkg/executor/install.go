@@ -0,0 +1,45 @@
+package executor
+
+import (
+	"fmt"
+	"regexp"
+	"sync/atomic"
+)
+
+// ErrorKindNotInstalled marks the failed exit of a run whose launcher could
+// not fetch the executor's package, e.g. an unknown version or no registry
+// access. The error behind it matches ErrExecutorNotInstalled.
+const ErrorKindNotInstalled = "not_installed"
+
+var installFailurePattern = regexp.MustCompile(`(?i)\bnpm (?:err!|error) (?:code (?:E404|ETARGET|ENOVERSIONS|ENOTFOUND|EAI_AGAIN|ECONNREFUSED|ECONNRESET|ETIMEDOUT|EINTEGRITY|E401|E403)\b|404\b)|` +
+	`could not determine executable to run|\bERR_PNPM_(?:FETCH_\d+|NO_MATCHING_VERSION|META_FETCH_FAIL)\b`)
+
+// DetectInstallFailure reports whether text is npm or pnpm reporting that a
+// package could not be fetched.
+func DetectInstallFailure(text string) bool {
+	return installFailurePattern.MatchString(text)
+}
+
+// InstallWatch notices a launcher reporting that the executor's package
+// could not be fetched, which happens after the process has started, so that
+// the run's failed exit can be reported as ErrExecutorNotInstalled. The zero
+// value is ready to use.
+type InstallWatch struct {
+	failed atomic.Bool
+}
+
+// Observe checks the text of a plain output log.
+func (w *InstallWatch) Observe(entry Log) {
+	if text, ok := entry.Content.(string); ok && DetectInstallFailure(text) {
+		w.failed.Store(true)
+	}
+}
+
+// Wrap marks waitErr, the result of cmd.Wait, with ErrExecutorNotInstalled
+// when the process failed after an install failure was observed.
+func (w *InstallWatch) Wrap(waitErr error) error {
+	if waitErr == nil || !w.failed.Load() {
+		return waitErr
+	}
+	return fmt.Errorf("%w: %w", ErrExecutorNotInstalled, waitErr)
+}
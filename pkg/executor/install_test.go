@@ -0,0 +1,43 @@
+package executor
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestDetectInstallFailure(t *testing.T) {
+	for _, text := range []string{
+		"npm error code E404",
+		"npm ERR! code ETARGET",
+		"npm ERR! 404 Not Found - GET https://registry.npmjs.org/@scope%2fcli - Not found",
+		"npm error could not determine executable to run",
+		" ERR_PNPM_FETCH_404  GET https://registry.npmjs.org/@scope%2Fcli: Not Found - 404",
+	} {
+		if !DetectInstallFailure(text) {
+			t.Errorf("expected %q to be an install failure", text)
+		}
+	}
+	for _, text := range []string{"npm warn deprecated glob@7.2.3", "npm notice New major version", "Error: file not found (404)"} {
+		if DetectInstallFailure(text) {
+			t.Errorf("expected %q not to be an install failure", text)
+		}
+	}
+}
+
+func TestInstallWatch(t *testing.T) {
+	exitErr := exec.Command("sh", "-c", "exit 1").Run()
+
+	var w InstallWatch
+	w.Observe(Log{Type: "stdout", Content: "starting"})
+	if err := w.Wrap(exitErr); errors.Is(err, ErrExecutorNotInstalled) {
+		t.Fatalf("expected a plain failure before an install failure is seen, got %v", err)
+	}
+	w.Observe(Log{Type: "error", Content: "npm error code E404"})
+	if err := w.Wrap(exitErr); !errors.Is(err, ErrExecutorNotInstalled) || ExitCode(err) != 1 {
+		t.Fatalf("expected ErrExecutorNotInstalled keeping the exit code, got %v", err)
+	}
+	if err := w.Wrap(nil); err != nil {
+		t.Fatalf("expected a clean exit to stay clean, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// DefaultMaxJSONObjectBytes is how much a JSONLineAssembler buffers while
+// waiting for the rest of a split JSON object when MaxBytes is not set.
+const DefaultMaxJSONObjectBytes = 8 << 20
+
+// JSONLineAssembler rejoins JSON objects that a PTY split across several
+// lines. Feed it every line read from the executor; it holds back a line that
+// starts an object but does not complete it, appends the following lines and
+// releases the joined line once it parses. Fragments that never complete
+// (because MaxBytes is exceeded or a later line cannot continue them) are
+// released unchanged, so no output is lost.
+type JSONLineAssembler struct {
+	// MaxBytes caps the buffered fragment. Defaults to
+	// DefaultMaxJSONObjectBytes when <= 0.
+	MaxBytes int
+
+	buf []byte
+}
+
+// Add feeds one line (without its newline) and returns the lines that are
+// ready to be processed, in order. It returns nil while an object is
+// incomplete.
+func (a *JSONLineAssembler) Add(line string) []string {
+	if len(a.buf) == 0 {
+		if !strings.HasPrefix(line, "{") || !incompleteJSON([]byte(line)) {
+			return []string{line}
+		}
+		a.buf = append(a.buf, line...)
+		return nil
+	}
+
+	joined := append(a.buf[:len(a.buf):len(a.buf)], line...)
+	if json.Valid(joined) {
+		a.buf = nil
+		return []string{string(joined)}
+	}
+	if len(joined) <= a.maxBytes() && incompleteJSON(joined) {
+		a.buf = joined
+		return nil
+	}
+
+	// line does not continue the fragment: release both separately.
+	fragment := string(a.buf)
+	a.buf = nil
+	return append([]string{fragment}, a.Add(line)...)
+}
+
+// Flush returns a buffered incomplete fragment, if any, and resets the
+// assembler. Call it once the stream ends.
+func (a *JSONLineAssembler) Flush() (string, bool) {
+	if len(a.buf) == 0 {
+		return "", false
+	}
+	fragment := string(a.buf)
+	a.buf = nil
+	return fragment, true
+}
+
+func (a *JSONLineAssembler) maxBytes() int {
+	if a.MaxBytes > 0 {
+		return a.MaxBytes
+	}
+	return DefaultMaxJSONObjectBytes
+}
+
+// incompleteJSON reports whether data is the valid beginning of a JSON value
+// that ends too early.
+func incompleteJSON(data []byte) bool {
+	var v json.RawMessage
+	err := json.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJSONLineAssembler(t *testing.T) {
+	t.Run("ReassemblesSplitObject", func(t *testing.T) {
+		var a JSONLineAssembler
+		if got := a.Add(`{"type":"assistant","message":{"content":"hel`); got != nil {
+			t.Fatalf("expected first fragment to be held, got %q", got)
+		}
+		got := a.Add(`lo world"}}`)
+		if len(got) != 1 {
+			t.Fatalf("expected one reassembled line, got %q", got)
+		}
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(got[0]), &obj); err != nil {
+			t.Fatalf("reassembled line is not JSON: %v", err)
+		}
+		if obj["message"].(map[string]any)["content"] != "hello world" {
+			t.Fatalf("unexpected reassembled object: %v", obj)
+		}
+	})
+
+	t.Run("PassesThroughCompleteAndPlainLines", func(t *testing.T) {
+		var a JSONLineAssembler
+		for _, line := range []string{`{"type":"result"}`, "plain output", "", "{ not json"} {
+			if got := a.Add(line); !reflect.DeepEqual(got, []string{line}) {
+				t.Fatalf("expected %q to pass through, got %q", line, got)
+			}
+		}
+	})
+
+	t.Run("ReleasesFragmentThatCannotContinue", func(t *testing.T) {
+		var a JSONLineAssembler
+		a.Add(`{"type":"assistant","text":"trunc`)
+		a.Add(`ated",`)
+		got := a.Add(`{"type":"result"}`)
+		want := []string{`{"type":"assistant","text":"truncated",`, `{"type":"result"}`}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("expected fragment then next object, got %q", got)
+		}
+	})
+
+	t.Run("MaxBytes", func(t *testing.T) {
+		a := JSONLineAssembler{MaxBytes: 16}
+		a.Add(`{"text":"0123`)
+		got := a.Add(`456789abcdef`)
+		if !reflect.DeepEqual(got, []string{`{"text":"0123`, `456789abcdef`}) {
+			t.Fatalf("expected oversized fragment to be released, got %q", got)
+		}
+	})
+
+	t.Run("Flush", func(t *testing.T) {
+		var a JSONLineAssembler
+		a.Add(`{"type":"partial`)
+		if fragment, ok := a.Flush(); !ok || fragment != `{"type":"partial` {
+			t.Fatalf("expected buffered fragment, got %q %v", fragment, ok)
+		}
+		if _, ok := a.Flush(); ok {
+			t.Fatal("expected empty assembler after flush")
+		}
+	})
+}
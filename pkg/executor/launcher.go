@@ -0,0 +1,38 @@
+package executor
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// DefaultLauncher runs the npm packages of Claude Code, Codex, Qwen, Gemini
+// and Copilot when Options.Launcher is empty.
+const DefaultLauncher = "npx"
+
+// PromptPlaceholder is replaced with the prompt in Options.CommandOverride.
+const PromptPlaceholder = "{prompt}"
+
+// LaunchCommand returns the argument vector that runs an npm package
+// executor, program first. CommandOverride wins when set, with
+// PromptPlaceholder expanded in each element. Otherwise the Launcher, split
+// on spaces (e.g. "pnpm dlx"), or DefaultLauncher is followed by pkgArgs;
+// npx also gets -y so that it installs the package without asking.
+func (o Options) LaunchCommand(prompt string, pkgArgs []string) []string {
+	if len(o.CommandOverride) > 0 {
+		argv := make([]string, len(o.CommandOverride))
+		for i, arg := range o.CommandOverride {
+			argv[i] = strings.ReplaceAll(arg, PromptPlaceholder, prompt)
+		}
+		return argv
+	}
+
+	argv := strings.Fields(o.Launcher)
+	if len(argv) == 0 {
+		argv = []string{DefaultLauncher}
+	}
+	if filepath.Base(argv[0]) == "npx" && !slices.Contains(argv[1:], "-y") && !slices.Contains(argv[1:], "--yes") {
+		argv = append(argv, "-y")
+	}
+	return append(argv, pkgArgs...)
+}
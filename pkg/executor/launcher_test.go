@@ -0,0 +1,35 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLaunchCommand(t *testing.T) {
+	pkgArgs := []string{"@scope/cli@latest", "--print", "fix it"}
+	cases := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{"Default", Options{}, "npx -y @scope/cli@latest --print fix it"},
+		{"Pnpm", Options{Launcher: "pnpm dlx"}, "pnpm dlx @scope/cli@latest --print fix it"},
+		{"Bunx", Options{Launcher: "  bunx "}, "bunx @scope/cli@latest --print fix it"},
+		{"VendoredNpx", Options{Launcher: "/opt/node/bin/npx"}, "/opt/node/bin/npx -y @scope/cli@latest --print fix it"},
+		{"NpxWithYes", Options{Launcher: "npx --yes"}, "npx --yes @scope/cli@latest --print fix it"},
+		{"Override", Options{Launcher: "bunx", CommandOverride: []string{"/usr/local/bin/cli", "--prompt={prompt}", "{prompt}"}}, "/usr/local/bin/cli --prompt=fix it fix it"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := strings.Join(tc.opts.LaunchCommand("fix it", pkgArgs), " "); got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+
+	override := []string{"cli", "{prompt}"}
+	Options{CommandOverride: override}.LaunchCommand("fix it", nil)
+	if override[1] != "{prompt}" {
+		t.Fatal("expected CommandOverride to be left untouched")
+	}
+}
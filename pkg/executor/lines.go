@@ -0,0 +1,121 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// LargeLineBytes is the soft line length above which ScanLines reports a
+// debug log. Longer lines are still read in full.
+const LargeLineBytes = 1 << 20
+
+// PartialLineDelay is how long ScanLinesPartial waits for the rest of a line
+// before reporting the part it has.
+var PartialLineDelay = 500 * time.Millisecond
+
+// ScanLines reads r line by line and calls fn with each line, without its
+// trailing "\n" or "\r\n", until r is exhausted or fn returns false. Unlike
+// bufio.Scanner it has no maximum line length, so one huge tool result does
+// not end the stream early. When send is set, lines over LargeLineBytes are
+// reported to it as debug logs. It returns nil at EOF and the read error
+// otherwise; a final line without a newline is still passed to fn.
+func ScanLines(r io.Reader, send func(Log), fn func(line string) bool) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if !fn(trimLine(line, send)) {
+				return nil
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// ScanLinesPartial is ScanLines for a terminal. A CLI that waits for an
+// answer prints its prompt without a newline, so when output stops in the
+// middle of a line for PartialLineDelay, that unterminated line is passed to
+// partial. It is reported once; fn still receives the whole line once it is
+// finished.
+func ScanLinesPartial(r io.Reader, send func(Log), fn func(line string) bool, partial func(line string)) error {
+	type chunk struct {
+		data []byte
+		err  error
+	}
+	chunks := make(chan chunk)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			buf := make([]byte, 32*1024)
+			n, err := r.Read(buf)
+			select {
+			case chunks <- chunk{data: buf[:n], err: err}:
+			case <-stop:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var pending []byte
+	var idle <-chan time.Time
+	reported := false
+	for {
+		select {
+		case c := <-chunks:
+			pending = append(pending, c.data...)
+			for {
+				i := bytes.IndexByte(pending, '\n')
+				if i < 0 {
+					break
+				}
+				line := string(pending[:i+1])
+				pending = pending[i+1:]
+				idle = nil
+				reported = false
+				if !fn(trimLine(line, send)) {
+					return nil
+				}
+			}
+			if c.err != nil {
+				if len(pending) > 0 && !fn(trimLine(string(pending), send)) {
+					return nil
+				}
+				if errors.Is(c.err, io.EOF) {
+					return nil
+				}
+				return c.err
+			}
+			if len(pending) > 0 && len(c.data) > 0 && !reported {
+				idle = time.After(PartialLineDelay)
+			}
+		case <-idle:
+			idle = nil
+			reported = true
+			partial(string(pending))
+		}
+	}
+}
+
+// trimLine drops the line ending of a line read by ScanLines, reporting it to
+// send when it is over LargeLineBytes.
+func trimLine(line string, send func(Log)) string {
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	if len(line) > LargeLineBytes && send != nil {
+		send(Log{Type: "debug", Content: fmt.Sprintf("read a %d byte output line (soft limit %d bytes)", len(line), LargeLineBytes)})
+	}
+	return line
+}
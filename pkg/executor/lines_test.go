@@ -0,0 +1,123 @@
+package executor
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanLines(t *testing.T) {
+	t.Run("SplitsLines", func(t *testing.T) {
+		var lines []string
+		err := ScanLines(strings.NewReader("one\r\ntwo\n\nthree"), nil, func(line string) bool {
+			lines = append(lines, line)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := []string{"one", "two", "", "three"}; !reflect.DeepEqual(lines, want) {
+			t.Fatalf("expected %q, got %q", want, lines)
+		}
+	})
+
+	t.Run("ReadsLinesOverLimit", func(t *testing.T) {
+		huge := strings.Repeat("x", LargeLineBytes+10)
+		var lines []string
+		var logs []Log
+		err := ScanLines(strings.NewReader(huge+"\nafter\n"), func(l Log) { logs = append(logs, l) }, func(line string) bool {
+			lines = append(lines, line)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(lines) != 2 || lines[0] != huge || lines[1] != "after" {
+			t.Fatalf("expected the huge line and the next one, got %d lines", len(lines))
+		}
+		if len(logs) != 1 || logs[0].Type != "debug" {
+			t.Fatalf("expected one debug log for the huge line, got %+v", logs)
+		}
+	})
+
+	t.Run("StopsWhenFnReturnsFalse", func(t *testing.T) {
+		var lines []string
+		_ = ScanLines(strings.NewReader("a\nb\nc\n"), nil, func(line string) bool {
+			lines = append(lines, line)
+			return line != "b"
+		})
+		if want := []string{"a", "b"}; !reflect.DeepEqual(lines, want) {
+			t.Fatalf("expected %q, got %q", want, lines)
+		}
+	})
+
+	t.Run("ReturnsReadError", func(t *testing.T) {
+		readErr := errors.New("boom")
+		err := ScanLines(&failingReader{data: "partial", err: readErr}, nil, func(line string) bool {
+			if line != "partial" {
+				t.Fatalf("unexpected line %q", line)
+			}
+			return true
+		})
+		if !errors.Is(err, readErr) {
+			t.Fatalf("expected read error, got %v", err)
+		}
+	})
+}
+
+func TestScanLinesPartial(t *testing.T) {
+	r, w := io.Pipe()
+	lines := make(chan string, 10)
+	partials := make(chan string, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- ScanLinesPartial(r, nil, func(line string) bool {
+			lines <- line
+			return true
+		}, func(line string) {
+			partials <- line
+		})
+	}()
+
+	_, _ = io.WriteString(w, "Reply with (y/n)\nProceed? (y/n) ")
+	if line := <-lines; line != "Reply with (y/n)" {
+		t.Fatalf("unexpected line %q", line)
+	}
+	select {
+	case partial := <-partials:
+		if partial != "Proceed? (y/n) " {
+			t.Fatalf("unexpected partial line %q", partial)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the unterminated line")
+	}
+
+	_, _ = io.WriteString(w, "y\n")
+	_ = w.Close()
+	if line := <-lines; line != "Proceed? (y/n) y" {
+		t.Fatalf("expected the finished line, got %q", line)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(partials) != 0 {
+		t.Fatalf("expected one partial line, got another %q", <-partials)
+	}
+}
+
+type failingReader struct {
+	data string
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
@@ -0,0 +1,92 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+)
+
+// ErrModelsUnavailable is returned by Registry.Models for executors that
+// neither list their models nor have an entry in DefaultModels.
+var ErrModelsUnavailable = errors.New("model list unavailable")
+
+// ModelLister is implemented by executors (or their factories) that can ask
+// their CLI which values Options.Model accepts. opts carries the launch
+// settings sessions use, such as Launcher and CommandOverride.
+type ModelLister interface {
+	Models(ctx context.Context, opts Options) ([]string, error)
+}
+
+// DefaultModels are curated model names for executors whose CLI has no way
+// to list them, keyed by executor name.
+var DefaultModels = map[ExecutorType][]string{
+	ExecutorClaudeCode: {"sonnet", "opus", "haiku"},
+	ExecutorCodex:      {"gpt-5-codex", "gpt-5"},
+	ExecutorGemini:     {"gemini-2.5-pro", "gemini-2.5-flash", "gemini-2.5-flash-lite"},
+	ExecutorQwen:       {"qwen3-coder-plus", "qwen3-coder-flash"},
+	ExecutorCopilot:    {"claude-sonnet-4.5", "claude-sonnet-4", "gpt-5"},
+}
+
+// Models lists the model names a registered executor accepts, launching its
+// CLI as opts would. The factory is asked first, then a throwaway instance.
+// Executors without ModelLister, or whose listing fails, get their
+// DefaultModels entry when they have one.
+func (r *Registry) Models(ctx context.Context, name string, opts Options) ([]string, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownExecutorType
+	}
+
+	models, err := listModels(ctx, factory, opts)
+	if err == nil {
+		return models, nil
+	}
+	if defaults, ok := DefaultModels[ExecutorType(name)]; ok {
+		return slices.Clone(defaults), nil
+	}
+	return nil, err
+}
+
+func listModels(ctx context.Context, factory Factory, opts Options) ([]string, error) {
+	if lister, ok := factory.(ModelLister); ok {
+		return lister.Models(ctx, opts)
+	}
+	exec, err := factory.Create()
+	if err != nil {
+		return nil, err
+	}
+	defer exec.Close()
+	if lister, ok := exec.(ModelLister); ok {
+		return lister.Models(ctx, opts)
+	}
+	return nil, ErrModelsUnavailable
+}
+
+// CommandOutput runs cmd and returns its stdout, killing it when ctx is done
+// first. It is meant for short helper commands such as a CLI's model list.
+func CommandOutput(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, WrapStartError(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+		}
+		return stdout.Bytes(), nil
+	case <-ctx.Done():
+		_ = cmd.Process.Kill()
+		<-done
+		return nil, ctx.Err()
+	}
+}
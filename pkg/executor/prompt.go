@@ -0,0 +1,118 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultInteractivePromptPatterns are the endings of prompts printed by CLIs
+// that wait for an answer on the terminal. Matching is case-insensitive and
+// ignores trailing spaces and punctuation such as ':' or '...'.
+var DefaultInteractivePromptPatterns = []string{
+	"(y/n)",
+	"[y/n]",
+	"(yes/no)",
+	"[yes/no]",
+	"ok to proceed? (y)",
+	"press y to continue",
+	"press enter to continue",
+}
+
+// InteractivePromptSubtype is the request.subtype of the control_request logs
+// reported for detected interactive prompts.
+const InteractivePromptSubtype = "interactive_prompt"
+
+// promptTrailer is what may follow a prompt pattern at the end of a line.
+const promptTrailer = " \t:.?!>"
+
+// MatchInteractivePrompt reports whether line ends with one of patterns, the
+// way a prompt waiting for an answer does.
+func MatchInteractivePrompt(line string, patterns []string) bool {
+	normalized := strings.ToLower(strings.TrimRight(line, promptTrailer))
+	for _, pattern := range patterns {
+		if pattern = strings.ToLower(strings.TrimRight(strings.TrimSpace(pattern), promptTrailer)); pattern != "" && strings.HasSuffix(normalized, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// InteractivePromptText returns the prompt of a control_request log content
+// produced by PromptDetector, or false for other control requests.
+func InteractivePromptText(obj map[string]any) (string, bool) {
+	request, ok := obj["request"].(map[string]any)
+	if !ok || request["subtype"] != InteractivePromptSubtype {
+		return "", false
+	}
+	prompt, _ := request["prompt"].(string)
+	return prompt, true
+}
+
+// PromptDetector turns interactive prompts in an executor's plain-text output
+// into control requests, so that they can be answered with RespondControl
+// instead of hanging a non-interactive run. Executors only pass it the
+// unterminated line reported by ScanLinesPartial, so finished lines such as
+// model text ending in "(y/n)" are never prompts. It is safe for concurrent
+// use; a nil detector detects nothing.
+type PromptDetector struct {
+	patterns []string
+
+	mu      sync.Mutex
+	next    int
+	pending map[string]string
+}
+
+// NewPromptDetector returns a detector for DefaultInteractivePromptPatterns
+// plus o.InteractivePromptPatterns.
+func NewPromptDetector(o Options) *PromptDetector {
+	patterns := make([]string, 0, len(DefaultInteractivePromptPatterns)+len(o.InteractivePromptPatterns))
+	patterns = append(patterns, DefaultInteractivePromptPatterns...)
+	return &PromptDetector{
+		patterns: append(patterns, o.InteractivePromptPatterns...),
+		pending:  make(map[string]string),
+	}
+}
+
+// Detect returns the control_request log content for line when it is an
+// interactive prompt. The request stays pending until Answer consumes it.
+// Prompts name no tool, so executors surface them instead of consulting their
+// ApprovalPolicy.
+func (d *PromptDetector) Detect(line string) (map[string]any, bool) {
+	if d == nil || !MatchInteractivePrompt(line, d.patterns) {
+		return nil, false
+	}
+	prompt := strings.TrimSpace(line)
+	d.mu.Lock()
+	d.next++
+	requestID := fmt.Sprintf("prompt-%d", d.next)
+	d.pending[requestID] = prompt
+	d.mu.Unlock()
+	return map[string]any{
+		"type":       "control_request",
+		"request_id": requestID,
+		"request": map[string]any{
+			"subtype": InteractivePromptSubtype,
+			"prompt":  prompt,
+		},
+	}, true
+}
+
+// Answer returns the terminal input answering a detected prompt: "y" to
+// approve and "n" to deny, followed by a newline. It returns false when
+// response.RequestID is not a pending prompt.
+func (d *PromptDetector) Answer(response ControlResponse) (string, bool) {
+	if d == nil {
+		return "", false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.pending[response.RequestID]; !ok {
+		return "", false
+	}
+	delete(d.pending, response.RequestID)
+	if response.Decision == ControlDecisionDeny {
+		return "n\n", true
+	}
+	return "y\n", true
+}
@@ -0,0 +1,41 @@
+package executor
+
+import "testing"
+
+func TestPromptDetector(t *testing.T) {
+	d := NewPromptDetector(Options{InteractivePromptPatterns: []string{"Overwrite file?"}})
+
+	req, ok := d.Detect("Proceed? (y/n)")
+	if !ok {
+		t.Fatal("expected a y/n prompt to be detected")
+	}
+	prompt, ok := InteractivePromptText(req)
+	if !ok || prompt != "Proceed? (y/n)" || req["type"] != "control_request" {
+		t.Fatalf("unexpected control request: %+v", req)
+	}
+	if _, ok := d.Detect("overwrite FILE? "); !ok {
+		t.Fatal("expected an extra pattern to be detected")
+	}
+	if _, ok := d.Detect("Reading the repository"); ok {
+		t.Fatal("expected ordinary output not to be a prompt")
+	}
+	if _, ok := d.Detect("Continue? [Y/n]: "); !ok {
+		t.Fatal("expected a prompt with a trailing colon to be detected")
+	}
+	if _, ok := d.Detect("Answer (y/n) questions with a single letter."); ok {
+		t.Fatal("expected a pattern in the middle of a line not to be a prompt")
+	}
+
+	requestID := req["request_id"].(string)
+	if answer, ok := d.Answer(ControlResponse{RequestID: requestID, Decision: ControlDecisionDeny}); !ok || answer != "n\n" {
+		t.Fatalf("expected a deny to answer n, got %q %v", answer, ok)
+	}
+	if _, ok := d.Answer(ControlResponse{RequestID: requestID, Decision: ControlDecisionApprove}); ok {
+		t.Fatal("expected a prompt to be answered only once")
+	}
+
+	var none *PromptDetector
+	if _, ok := none.Detect("Proceed? (y/n)"); ok {
+		t.Fatal("expected a nil detector to detect nothing")
+	}
+}
@@ -1,9 +1,9 @@
 package qwen
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -25,6 +25,11 @@ type Client struct {
 	mu         sync.Mutex
 	controls   map[string]ControlRequestType
 	commandRun func(name string, arg ...string) *exec.Cmd
+	// install notices npx failing to fetch the package.
+	install executor.InstallWatch
+
+	approvalPolicy executor.ApprovalPolicy
+	prompts        *executor.PromptDetector
 }
 
 // NewClient creates a new Qwen Code client
@@ -39,7 +44,9 @@ func NewClient() *Client {
 
 // Start starts the Qwen Code executor with the given prompt
 func (c *Client) Start(ctx context.Context, prompt string, opts executor.Options) error {
-	args := []string{"-y", "--package", "@qwen-code/qwen-code@latest", "qwen", prompt, "--output-format", "stream-json"}
+	c.approvalPolicy = opts.ApprovalPolicy
+	c.prompts = executor.NewPromptDetector(opts)
+	args := []string{"--package", "@qwen-code/qwen-code@latest", "qwen", prompt, "--output-format", "stream-json"}
 
 	if opts.Model != "" {
 		args = append(args, "--model", opts.Model)
@@ -56,19 +63,21 @@ func (c *Client) Start(ctx context.Context, prompt string, opts executor.Options
 		args = append(args, "--permission-prompt-tool", "stdio", "--input-format", "stream-json")
 	}
 
+	argv := opts.LaunchCommand(prompt, args)
+
 	// Create command
-	cmd := c.commandRun("npx", args...)
+	cmd := c.commandRun(argv[0], argv[1:]...)
 	cmd.Dir = opts.WorkingDir
 	// Unset QWEN env if needed (not strictly required, but analogous to Claude)
 	cmd.Env = executor.BuildCommandEnv(opts.Env, map[string]string{})
 
 	// Log the command being executed (mask the prompt in logs for brevity)
-	c.sendLog(executor.Log{Type: "command", Content: fmt.Sprintf("npx %s", strings.Join(args, " "))})
+	c.sendLog(executor.Log{Type: "command", Content: strings.Join(opts.RedactArgs(argv), " ")})
 
 	// Use PTY to get unbuffered output from Node.js
 	ptmx, err := pty.Start(cmd)
 	if err != nil {
-		return fmt.Errorf("failed to start command with pty: %w", err)
+		return fmt.Errorf("failed to start command with pty: %w", executor.WrapStartError(err))
 	}
 
 	c.cmd = cmd
@@ -79,46 +88,33 @@ func (c *Client) Start(ctx context.Context, prompt string, opts executor.Options
 		defer c.Close()
 		defer ptmx.Close()
 
-		defer c.sendLog(executor.Log{Type: "done", Content: "Qwen execution finished"})
-
-		scanner := bufio.NewScanner(ptmx)
-		scanner.Buffer(make([]byte, 1024*1024), 1024*1024) // 1MB buffer for large JSON lines
-		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
-			if line == "" {
-				continue
-			}
-
-			obj, ok := parseJSONFromLine(line)
-			if !ok {
-				c.sendLog(executor.Log{Type: "stdout", Content: line})
-				continue
-			}
-
-			typeName, _ := obj["type"].(string)
-			switch typeName {
-			case "control_request":
-				c.trackControlRequest(obj)
-				c.sendLog(executor.Log{Type: "control_request", Content: obj})
-			case "result":
-				result, _ := obj["result"].(string)
-				isError, _ := obj["is_error"].(bool)
-				if isError {
-					c.sendLog(executor.Log{Type: "error", Content: result})
-				} else {
-					c.sendLog(executor.Log{Type: "result", Content: result})
+		// The PTY can split one JSON event across several lines.
+		var assembler executor.JSONLineAssembler
+		stopped := false
+		_ = executor.ScanLinesPartial(ptmx, c.sendLog, func(raw string) bool {
+			for _, line := range assembler.Add(strings.TrimSpace(raw)) {
+				if c.handleLine(line) {
+					stopped = true
+					return false
 				}
-				c.sendLog(executor.Log{Type: "done", Content: obj})
-				return
-			default:
-				c.sendLog(executor.Log{Type: "stdout", Content: obj})
+			}
+			return true
+		}, c.handlePrompt)
+		if !stopped {
+			if fragment, ok := assembler.Flush(); ok {
+				stopped = c.handleLine(fragment)
 			}
 		}
-
-		if err := cmd.Wait(); err != nil {
-			c.sendLog(executor.Log{Type: "error", Content: err.Error()})
+		if stopped {
+			// The result already ended the run. With stream-json input the
+			// process keeps waiting for more, so stop it instead of waiting
+			// for an exit code, and reap it.
+			c.Close()
+			_ = cmd.Wait()
+			return
 		}
 
+		executor.ReportExit(c.sendLog, c.install.Wrap(cmd.Wait()), "Qwen execution finished")
 	}()
 
 	return nil
@@ -139,6 +135,9 @@ func (c *Client) SendMessage(ctx context.Context, message string) error {
 }
 
 func (c *Client) RespondControl(ctx context.Context, response executor.ControlResponse) error {
+	if answer, ok := c.prompts.Answer(response); ok {
+		return c.writeInput(answer)
+	}
 	raw, err := c.buildControlPayload(response)
 	if err != nil {
 		return err
@@ -147,12 +146,28 @@ func (c *Client) RespondControl(ctx context.Context, response executor.ControlRe
 	return c.writeJSONLine(msg)
 }
 
+// Capabilities reports the optional operations this executor supports.
+func (c *Client) Capabilities() executor.Capabilities {
+	return executor.Capabilities{Control: true}
+}
+
 // Wait waits for the execution to complete
 func (c *Client) Wait() error {
 	<-c.doneChan
 	return nil
 }
 
+// WaitContext waits for the execution to complete or for ctx to be done.
+// It returns ctx.Err() if the context ends the wait first.
+func (c *Client) WaitContext(ctx context.Context) error {
+	select {
+	case <-c.doneChan:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Logs returns a channel for streaming logs
 func (c *Client) Logs() <-chan executor.Log {
 	return c.logsChan
@@ -190,42 +205,56 @@ func (c *Client) sendLog(entry executor.Log) {
 		return
 	}
 
+	c.install.Observe(entry)
 	c.logsChan <- entry
 }
 
+// writeJSONLine writes v to the PTY. A write that fails because Qwen has
+// exited closes the client, so the session ends instead of waiting on a dead
+// process, and returns an error matching executor.ErrExecutorClosed.
 func (c *Client) writeJSONLine(v any) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.closed || c.ptyFile == nil {
-		return executor.ErrExecutorClosed
-	}
 	data, err := json.Marshal(v)
 	if err != nil {
 		return err
 	}
-	if _, err := c.ptyFile.Write(append(data, '\n')); err != nil {
-		return err
+	return c.writeInput(string(data) + "\n")
+}
+
+// writeInput writes text to the PTY as typed, with the same error handling
+// as writeJSONLine.
+func (c *Client) writeInput(text string) error {
+	c.mu.Lock()
+	if c.closed || c.ptyFile == nil {
+		c.mu.Unlock()
+		return executor.ErrExecutorClosed
 	}
-	return nil
+	_, err := c.ptyFile.Write([]byte(text))
+	c.mu.Unlock()
+
+	if err = executor.WrapWriteError(err); errors.Is(err, executor.ErrExecutorClosed) {
+		_ = c.Close()
+	}
+	return err
 }
 
-func (c *Client) trackControlRequest(obj map[string]any) {
+func (c *Client) trackControlRequest(obj map[string]any) (ControlRequest, bool) {
 	data, err := json.Marshal(obj)
 	if err != nil {
-		return
+		return ControlRequest{}, false
 	}
 	var req ControlRequest
 	if err := json.Unmarshal(data, &req); err != nil {
-		return
+		return ControlRequest{}, false
 	}
 	if req.RequestID == "" {
-		return
+		return ControlRequest{}, false
 	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.controls != nil {
 		c.controls[req.RequestID] = req.Request
 	}
+	return req, true
 }
 
 func (c *Client) buildControlPayload(response executor.ControlResponse) (json.RawMessage, error) {
@@ -289,6 +318,60 @@ func (c *Client) buildControlPayload(response executor.ControlResponse) (json.Ra
 	}
 }
 
+// handleLine turns one output line into logs. It returns true once the
+// final result has been sent.
+func (c *Client) handleLine(line string) bool {
+	if line == "" {
+		return false
+	}
+
+	obj, ok := parseJSONFromLine(line)
+	if !ok {
+		c.sendLog(executor.Log{Type: "stdout", Content: line})
+		return false
+	}
+
+	typeName, _ := obj["type"].(string)
+	switch typeName {
+	case "control_request":
+		req, tracked := c.trackControlRequest(obj)
+		c.sendLog(executor.Log{Type: "control_request", Content: obj})
+		// Only tool permission requests are approvals; hook callbacks answer
+		// hooks the caller configured and are always surfaced.
+		if tracked && req.Request.Subtype == "can_use_tool" {
+			if response, ok := c.approvalPolicy.Decide(req.RequestID, req.Request.ToolName); ok {
+				_ = c.RespondControl(context.Background(), response)
+			}
+		}
+	case "result":
+		result, _ := obj["result"].(string)
+		isError, _ := obj["is_error"].(bool)
+		if isError {
+			c.sendLog(executor.Log{Type: "error", Content: result})
+		} else {
+			c.sendLog(executor.Log{Type: "result", Content: result})
+		}
+		c.sendLog(executor.Log{Type: "done", Content: obj})
+		return true
+	default:
+		c.sendLog(executor.Log{Type: "stdout", Content: obj})
+	}
+	return false
+}
+
+// handlePrompt reports the unterminated output line the CLI is waiting on as
+// a control request when it is an interactive prompt.
+func (c *Client) handlePrompt(line string) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "{") {
+		// A JSON event still being written.
+		return
+	}
+	if req, ok := c.prompts.Detect(line); ok {
+		c.sendLog(executor.Log{Type: "control_request", Content: req})
+	}
+}
+
 func parseJSONFromLine(line string) (map[string]any, bool) {
 	start := strings.Index(line, "{")
 	if start < 0 {
@@ -3,6 +3,7 @@ package qwen
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -105,6 +106,27 @@ func TestQwenClient_More(t *testing.T) {
 	})
 }
 
+func TestQwenClient_SendMessageChildExited(t *testing.T) {
+	c := NewClient()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Closing the read end stands in for the child process going away.
+	_ = r.Close()
+	c.ptyFile = w
+
+	err = c.SendMessage(context.Background(), "hello")
+	if !errors.Is(err, executor.ErrExecutorClosed) {
+		t.Fatalf("expected ErrExecutorClosed, got %v", err)
+	}
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected client to be closed after a broken write")
+	}
+}
+
 func TestQwenClient_buildControlPayload(t *testing.T) {
 	c := NewClient()
 
@@ -180,6 +202,34 @@ func TestQwenClient_trackControlRequest(t *testing.T) {
 	c.trackControlRequest(map[string]any{"type": "control_request"})
 }
 
+func TestQwenClient_ApprovalPolicy(t *testing.T) {
+	c := NewClient()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	c.ptyFile = w
+	c.approvalPolicy = executor.ApprovalPolicy{AllowedTools: []string{"read_*"}, Default: executor.ApprovalDefaultDeny}
+
+	c.handleLine(`{"type":"control_request","request_id":"req-1","request":{"subtype":"can_use_tool","tool_name":"read_file","input":{"path":"a.go"}}}`)
+	c.handleLine(`{"type":"control_request","request_id":"req-2","request":{"subtype":"can_use_tool","tool_name":"run_shell_command","input":{"command":"rm -rf /"}}}`)
+	c.handleLine(`{"type":"control_request","request_id":"req-3","request":{"subtype":"hook_callback","callback_id":"hook-1","input":{}}}`)
+	_ = w.Close()
+	data, _ := io.ReadAll(r)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected two control responses and a surfaced hook callback, got %q", data)
+	}
+	if !strings.Contains(lines[0], "req-1") || !strings.Contains(lines[0], `"behavior":"allow"`) {
+		t.Fatalf("expected allowlisted tool to be approved, got %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "req-2") || !strings.Contains(lines[1], `"behavior":"deny"`) {
+		t.Fatalf("expected unlisted tool to be denied, got %s", lines[1])
+	}
+}
+
 func TestParseJSONFromLine(t *testing.T) {
 	if _, ok := parseJSONFromLine("not-json"); ok {
 		t.Fatalf("expected parse failure")
@@ -41,11 +41,18 @@ func EventTransformer(input executor.TransformInput) executor.Event {
 		if obj, ok := parseJSONObject(input.Log.Content); ok {
 			if request, ok := obj["request"].(map[string]any); ok {
 				content.ToolName, _ = request["tool_name"].(string)
+				if input, ok := request["input"].(map[string]any); ok {
+					content.Scope = executor.ApprovalScopeFromInput(input)
+					content.Target = content.Scope.Target()
+				}
 			}
 			content.RequestID, _ = obj["request_id"].(string)
 			if content.ToolName != "" {
 				content.Summary = fmt.Sprintf("Waiting for approval: %s", content.ToolName)
 			}
+			if prompt, ok := executor.InteractivePromptText(obj); ok {
+				content.Summary = fmt.Sprintf("Waiting for input: %s", prompt)
+			}
 		}
 	case "result":
 		content.Category = "message"
@@ -88,14 +95,17 @@ func applyClaudeObjectMapping(content *executor.UnifiedContent, obj map[string]a
 		content.Category = "tool"
 		content.Phase = "started"
 		content.ToolName = extractClaudeToolName(obj)
+		content.ToolCallID, _ = obj["id"].(string)
 		content.Target = extractClaudeTarget(obj)
 		mapToolAction(content)
 	case "tool_result":
 		content.Category = "tool"
 		content.Phase = "completed"
 		content.ToolName = extractClaudeToolName(obj)
+		content.ToolCallID, _ = obj["tool_use_id"].(string)
 		content.Target = extractClaudeTarget(obj)
 		mapToolAction(content)
+		content.Files = extractClaudeFileChanges(obj)
 		if content.Summary != "" {
 			content.Summary = strings.Replace(content.Summary, "Starting", "Completed", 1)
 		}
@@ -103,6 +113,13 @@ func applyClaudeObjectMapping(content *executor.UnifiedContent, obj map[string]a
 		content.Category = "message"
 		content.Action = "responding"
 		content.Summary = "Generating reply"
+		// Messages carrying only thinking blocks go to the thought stream.
+		if thinking := extractClaudeThinking(obj); thinking != "" && extractClaudeText(obj) == "" {
+			content.Category = "thought"
+			content.Action = "thinking"
+			content.Summary = "Thinking"
+			content.Text = thinking
+		}
 	case "system":
 		content.Category = "progress"
 		if subtype == "init" {
@@ -217,6 +234,64 @@ func extractClaudeTarget(obj map[string]any) string {
 	return ""
 }
 
+// extractClaudeFileChanges derives file changes from an edit/write tool_result.
+func extractClaudeFileChanges(obj map[string]any) []executor.FileChange {
+	input, _ := obj["input"].(map[string]any)
+	if input == nil {
+		return nil
+	}
+	path, _ := input["file_path"].(string)
+	if path == "" {
+		path, _ = input["notebook_path"].(string)
+	}
+	if path == "" {
+		return nil
+	}
+
+	change := executor.FileChange{Path: path}
+	name := strings.ToLower(extractClaudeToolName(obj))
+	switch {
+	case strings.Contains(name, "multiedit"):
+		change.Op = executor.FileOpModify
+		edits, _ := input["edits"].([]any)
+		for _, item := range edits {
+			if edit, ok := item.(map[string]any); ok {
+				change.Deletions += countLines(edit["old_string"])
+				change.Additions += countLines(edit["new_string"])
+			}
+		}
+	case strings.Contains(name, "edit"):
+		change.Op = executor.FileOpModify
+		change.Deletions = countLines(input["old_string"])
+		change.Additions = countLines(input["new_string"])
+	case strings.Contains(name, "write"):
+		change.Op = executor.FileOpCreate
+		change.Additions = countLines(input["content"])
+	default:
+		return nil
+	}
+
+	// Claude reports whether a write created or updated the file.
+	if result, ok := obj["tool_use_result"].(map[string]any); ok {
+		switch result["type"] {
+		case "create":
+			change.Op = executor.FileOpCreate
+		case "update":
+			change.Op = executor.FileOpModify
+		}
+	}
+
+	return []executor.FileChange{change}
+}
+
+func countLines(v any) int {
+	text, _ := v.(string)
+	if text == "" {
+		return 0
+	}
+	return strings.Count(strings.TrimSuffix(text, "\n"), "\n") + 1
+}
+
 func extractClaudeText(obj map[string]any) string {
 	if result, ok := obj["result"].(string); ok && result != "" {
 		return result
@@ -239,10 +314,32 @@ func extractClaudeText(obj map[string]any) string {
 	return ""
 }
 
+// extractClaudeThinking joins the text of the message's thinking blocks.
+func extractClaudeThinking(obj map[string]any) string {
+	msg, ok := obj["message"].(map[string]any)
+	if !ok {
+		return ""
+	}
+	blocks, _ := msg["content"].([]any)
+	var parts []string
+	for _, item := range blocks {
+		block, ok := item.(map[string]any)
+		if !ok || block["type"] != "thinking" {
+			continue
+		}
+		if text, ok := block["thinking"].(string); ok && text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
 func eventTypeForCategory(category string) string {
 	switch category {
 	case "tool":
 		return "tool"
+	case "thought":
+		return "thought"
 	case "progress", "lifecycle":
 		return "progress"
 	case "done":
@@ -240,3 +240,72 @@ func TestMapToolAction_Branches(t *testing.T) {
 		})
 	}
 }
+
+func TestEventTransformer_ToolResultFileChanges(t *testing.T) {
+	evt := EventTransformer(executor.TransformInput{
+		SessionID: "s1",
+		Executor:  "qwen",
+		Log: executor.Log{
+			Type:    "stdout",
+			Content: `{"type":"tool_result","tool_name":"Edit","input":{"file_path":"main.go","old_string":"a\nb","new_string":"a\nb\nc"}}`,
+		},
+	})
+	content := evt.Content.(executor.UnifiedContent)
+	if len(content.Files) != 1 {
+		t.Fatalf("expected one file change, got %+v", content.Files)
+	}
+	if got := content.Files[0]; got.Path != "main.go" || got.Op != executor.FileOpModify || got.Additions != 3 || got.Deletions != 2 {
+		t.Fatalf("unexpected file change: %+v", got)
+	}
+
+	evt = EventTransformer(executor.TransformInput{
+		Executor: "qwen",
+		Log: executor.Log{
+			Type:    "stdout",
+			Content: `{"type":"tool_result","tool_name":"Write","input":{"file_path":"new.go","content":"package x\n"},"tool_use_result":{"type":"create"}}`,
+		},
+	})
+	content = evt.Content.(executor.UnifiedContent)
+	if len(content.Files) != 1 || content.Files[0].Op != executor.FileOpCreate || content.Files[0].Additions != 1 {
+		t.Fatalf("unexpected write file change: %+v", content.Files)
+	}
+
+	evt = EventTransformer(executor.TransformInput{
+		Executor: "qwen",
+		Log:      executor.Log{Type: "stdout", Content: `{"type":"tool_result","tool_name":"Read","input":{"file_path":"main.go"}}`},
+	})
+	if files := evt.Content.(executor.UnifiedContent).Files; files != nil {
+		t.Fatalf("expected no file changes for read tool, got %+v", files)
+	}
+}
+
+func TestEventTransformer_ControlRequestScope(t *testing.T) {
+	evt := EventTransformer(executor.TransformInput{
+		SessionID: "s1",
+		Executor:  "qwen",
+		Log: executor.Log{
+			Type:    "control_request",
+			Content: `{"type":"control_request","request_id":"req-2","request":{"subtype":"can_use_tool","tool_name":"Bash","input":{"command":"rm -rf build","description":"clean"}}}`,
+		},
+	})
+	content := evt.Content.(executor.UnifiedContent)
+	if content.Scope == nil || content.Scope.Command != "rm -rf build" || content.Target != "rm -rf build" {
+		t.Fatalf("expected command scope, got %+v", content.Scope)
+	}
+
+	evt = EventTransformer(executor.TransformInput{
+		SessionID: "s1",
+		Executor:  "qwen",
+		Log: executor.Log{
+			Type: "control_request",
+			Content: map[string]any{
+				"request_id": "req-3",
+				"request":    map[string]any{"tool_name": "Write", "input": map[string]any{"file_path": "/repo/main.go"}},
+			},
+		},
+	})
+	content = evt.Content.(executor.UnifiedContent)
+	if content.Scope == nil || len(content.Scope.Paths) != 1 || content.Scope.Paths[0] != "/repo/main.go" {
+		t.Fatalf("expected path scope, got %+v", content.Scope)
+	}
+}
@@ -0,0 +1,85 @@
+package executor
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorKindRateLimit marks error events caused by a provider rate limit or
+// usage quota. RetryAfter carries the provider's retry hint when it gave one.
+const ErrorKindRateLimit = "rate_limit"
+
+var (
+	rateLimitPattern = regexp.MustCompile(`(?i)\b429\b|rate[ _-]?limit|too many requests|usage limit reached|quota exceeded|resource_exhausted`)
+	retryInPattern   = regexp.MustCompile(`(?i)(?:try again|retry|retrying)(?:\s+\d+/\d+)?\s+in\s+(\d+(?:\.\d+)?)\s*(ms|milliseconds?|s|secs?|seconds?|m|mins?|minutes?|h|hours?)\b`)
+	retryAfterHeader = regexp.MustCompile(`(?i)retry[-_ ]after"?\s*[:=]?\s*"?(\d+(?:\.\d+)?)\s*(ms|milliseconds?|s|secs?|seconds?|m|mins?|minutes?|h|hours?)?\b`)
+	usageResetAt     = regexp.MustCompile(`(?i)usage limit reached\|(\d{9,})`)
+)
+
+// DetectRateLimit reports whether text describes a provider rate limit and,
+// when it includes a retry hint, how many seconds to wait (0 when unknown).
+func DetectRateLimit(text string) (retryAfter int, ok bool) {
+	if !rateLimitPattern.MatchString(text) {
+		return 0, false
+	}
+	if m := usageResetAt.FindStringSubmatch(text); m != nil {
+		if ts, err := strconv.ParseInt(m[1], 10, 64); err == nil {
+			return ceilSeconds(time.Until(time.Unix(ts, 0)).Seconds()), true
+		}
+	}
+	for _, pattern := range []*regexp.Regexp{retryInPattern, retryAfterHeader} {
+		if m := pattern.FindStringSubmatch(text); m != nil {
+			value, err := strconv.ParseFloat(m[1], 64)
+			if err == nil {
+				return ceilSeconds(value * unitSeconds(m[2])), true
+			}
+		}
+	}
+	return 0, true
+}
+
+// MarkRateLimit turns content into a rate-limit error when its text describes
+// one, and reports whether it did.
+func (c *UnifiedContent) MarkRateLimit() bool {
+	retryAfter, ok := DetectRateLimit(c.Text)
+	if !ok {
+		return false
+	}
+	c.Category = "error"
+	c.Action = "failed"
+	c.Phase = "failed"
+	c.ErrorKind = ErrorKindRateLimit
+	c.RetryAfter = retryAfter
+	c.Summary = "Rate limited by provider"
+	if retryAfter > 0 {
+		c.Summary = fmt.Sprintf("Rate limited by provider, retry in %ds", retryAfter)
+	}
+	return true
+}
+
+func unitSeconds(unit string) float64 {
+	unit = strings.ToLower(unit)
+	switch {
+	case strings.HasPrefix(unit, "ms"), strings.HasPrefix(unit, "milli"):
+		return 0.001
+	case strings.HasPrefix(unit, "h"):
+		return 3600
+	case strings.HasPrefix(unit, "m"):
+		return 60
+	default:
+		return 1
+	}
+}
+
+// ceilSeconds rounds a positive wait up to whole seconds, so sub-second hints
+// still produce a non-zero RetryAfter.
+func ceilSeconds(seconds float64) int {
+	if seconds <= 0 {
+		return 0
+	}
+	return int(math.Ceil(seconds))
+}
@@ -0,0 +1,50 @@
+package executor
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DefaultBenignStderrPatterns are stderr line prefixes written by npm, npx and
+// Node.js that are noise rather than failures. Matching is case-insensitive,
+// after any "(node:PID) [CODE] " prefix is removed.
+var DefaultBenignStderrPatterns = []string{
+	"npm notice",
+	"npm warn",
+	"npx: installed",
+	"downloading",
+	"deprecationwarning",
+	"experimentalwarning",
+	"(use `node --trace-",
+}
+
+var nodePIDPrefix = regexp.MustCompile(`^\(node:\d+\)\s*(\[[A-Z]+\d+\]\s*)?`)
+
+// ClassifyStderr returns the log type for a stderr line: "debug" when it
+// matches DefaultBenignStderrPatterns and "error" otherwise.
+func ClassifyStderr(line string) string {
+	return ClassifyStderrPatterns(line, DefaultBenignStderrPatterns)
+}
+
+// ClassifyStderrPatterns is like ClassifyStderr but matches line against
+// benign instead of the defaults.
+func ClassifyStderrPatterns(line string, benign []string) string {
+	normalized := strings.ToLower(nodePIDPrefix.ReplaceAllString(strings.TrimSpace(line), ""))
+	for _, pattern := range benign {
+		if pattern = strings.ToLower(strings.TrimSpace(pattern)); pattern != "" && strings.HasPrefix(normalized, pattern) {
+			return "debug"
+		}
+	}
+	return "error"
+}
+
+// ClassifyStderr classifies a stderr line using DefaultBenignStderrPatterns
+// plus o.BenignStderrPatterns.
+func (o Options) ClassifyStderr(line string) string {
+	if len(o.BenignStderrPatterns) == 0 {
+		return ClassifyStderr(line)
+	}
+	patterns := make([]string, 0, len(DefaultBenignStderrPatterns)+len(o.BenignStderrPatterns))
+	patterns = append(patterns, DefaultBenignStderrPatterns...)
+	return ClassifyStderrPatterns(line, append(patterns, o.BenignStderrPatterns...))
+}
@@ -0,0 +1,40 @@
+package executor
+
+import "testing"
+
+func TestClassifyStderr(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"npm notice New minor version of npm available!", "debug"},
+		{"npm WARN deprecated inflight@1.0.6: This module is not supported", "debug"},
+		{"npm warn exec The following package was not found and will be installed", "debug"},
+		{"Downloading codex binary...", "debug"},
+		{"(node:4242) [DEP0040] DeprecationWarning: The `punycode` module is deprecated.", "debug"},
+		{"(node:17) ExperimentalWarning: Fetch API is an experimental feature", "debug"},
+		{"(Use `node --trace-deprecation ...` to show where the warning was created)", "debug"},
+		{"Error: ENOENT: no such file or directory", "error"},
+		{"Error downloading model weights", "error"},
+		{"(node:17) UnhandledPromiseRejectionWarning: boom", "error"},
+		{"npm ERR! code E404", "error"},
+	}
+	for _, tt := range tests {
+		if got := ClassifyStderr(tt.line); got != tt.want {
+			t.Errorf("ClassifyStderr(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestOptionsClassifyStderrExtraPatterns(t *testing.T) {
+	opts := Options{BenignStderrPatterns: []string{"Loaded cached credentials"}}
+	if got := opts.ClassifyStderr("loaded cached credentials."); got != "debug" {
+		t.Fatalf("expected extra pattern to be benign, got %q", got)
+	}
+	if got := opts.ClassifyStderr("npm notice update available"); got != "debug" {
+		t.Fatalf("expected defaults to still apply, got %q", got)
+	}
+	if got := opts.ClassifyStderr("fatal: not a git repository"); got != "error" {
+		t.Fatalf("expected genuine error, got %q", got)
+	}
+}
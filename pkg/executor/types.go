@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -21,6 +22,10 @@ const (
 
 // ExecuteRequest defines task startup options.
 type ExecuteRequest struct {
+	// SessionID, when set, is used as the new session's id instead of a
+	// generated one, e.g. to make retries idempotent or to match an external
+	// ticket. Execute fails with sdk.ErrSessionExists when it is taken.
+	SessionID      string            `json:"session_id,omitempty"`
 	Prompt         string            `json:"prompt"`
 	Executor       ExecutorType      `json:"executor"`
 	WorkingDir     string            `json:"working_dir"`
@@ -29,6 +34,32 @@ type ExecuteRequest struct {
 	Sandbox        string            `json:"sandbox,omitempty"`
 	Env            map[string]string `json:"env,omitempty"`
 	AskForApproval string            `json:"ask_for_approval,omitempty"`
+	// AllowedTools lists tools (names or path.Match patterns) whose approval
+	// requests are approved without asking.
+	AllowedTools []string `json:"allowed_tools,omitempty"`
+	// ClaudeAllowedTools lists tools Claude Code may use without asking
+	// (--allowedTools), in the CLI's own rule syntax such as "Bash(git:*)".
+	ClaudeAllowedTools []string `json:"claude_allowed_tools,omitempty"`
+	// DisallowedTools lists tools Claude Code may not use (--disallowedTools).
+	DisallowedTools []string `json:"disallowed_tools,omitempty"`
+	// ApprovalDefault decides approval requests for other tools: surface
+	// (the default), approve or deny.
+	ApprovalDefault ApprovalDefault `json:"approval_default,omitempty"`
+	// ContextFiles lists files (relative to WorkingDir or absolute within it)
+	// whose contents are prepended to the prompt.
+	ContextFiles []string `json:"context_files,omitempty"`
+	// Labels are arbitrary key/value tags copied onto the session.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Kind is the workflow category of the session (e.g. "review", "bugfix"),
+	// a single well-known dimension sessions can be filtered by.
+	Kind string `json:"kind,omitempty"`
+	// MaxToolCalls, when > 0, stops a run that makes more tool calls and
+	// marks the session failed, so a looping agent cannot run unbounded.
+	MaxToolCalls int `json:"max_tool_calls,omitempty"`
+	// ModelReasoningEffort sets the Codex reasoning effort (e.g. "low",
+	// "high") for the session's runs. ContinueRequest can override it for
+	// one turn.
+	ModelReasoningEffort string `json:"model_reasoning_effort,omitempty"`
 }
 
 // ExecuteResponse is returned after a task starts.
@@ -40,6 +71,12 @@ type ExecuteResponse struct {
 // ContinueRequest defines a resume/continue payload.
 type ContinueRequest struct {
 	Message string `json:"message"`
+	// WorkingDir, when set, overrides the session's original working
+	// directory for a resumed run (e.g. after the repository moved).
+	WorkingDir string `json:"working_dir,omitempty"`
+	// ModelReasoningEffort, when set, overrides the Codex reasoning effort
+	// for this turn only (e.g. "high" for one hard follow-up).
+	ModelReasoningEffort string `json:"model_reasoning_effort,omitempty"`
 }
 
 type ControlDecision string
@@ -73,16 +110,81 @@ const (
 	SessionStatusRunning     SessionStatus = "running"
 	SessionStatusDone        SessionStatus = "done"
 	SessionStatusInterrupted SessionStatus = "interrupted"
+	// SessionStatusFailed means the run ended after an error the caller has
+	// to fix before retrying, such as an authentication failure.
+	SessionStatusFailed SessionStatus = "failed"
 )
 
 // Session represents one task session summary.
 type Session struct {
-	SessionID string        `json:"session_id"`
-	Title     string        `json:"title"`
-	Status    SessionStatus `json:"status"`
-	Executor  ExecutorType  `json:"executor"`
-	CreatedAt time.Time     `json:"created_at"`
-	UpdatedAt time.Time     `json:"updated_at"`
+	SessionID string            `json:"session_id"`
+	Title     string            `json:"title"`
+	Status    SessionStatus     `json:"status"`
+	Executor  ExecutorType      `json:"executor"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Kind      string            `json:"kind,omitempty"`
+	// ExecutorVersion is the agent CLI version reported by the executor,
+	// when it reports one.
+	ExecutorVersion string `json:"executor_version,omitempty"`
+	// LastEventType is the type of the most recently stored event.
+	LastEventType string `json:"last_event_type,omitempty"`
+	// EventCount is the session's latest event seq, i.e. the number of events
+	// stored so far. Only filled in by Client.GetSession.
+	EventCount uint64 `json:"event_count,omitempty"`
+	// Result is the final answer of the latest run, combined from its result
+	// events according to the SDK client's ResultMode.
+	Result string `json:"result,omitempty"`
+	// DroppedEvents counts events the event store rejected and the SDK
+	// dropped under its drop_events store failure policy.
+	DroppedEvents int `json:"dropped_events,omitempty"`
+	// Metrics summarizes the run. It is recorded when the session ends and
+	// nil while it is still running.
+	Metrics *SessionMetrics `json:"metrics,omitempty"`
+	// Cost is the estimated price of the tokens the run used. It is recorded
+	// when the session ends, for executors that report token usage.
+	Cost *SessionCost `json:"cost,omitempty"`
+	// Plan is the agent's latest plan. Each plan event carries the full plan
+	// and replaces it.
+	Plan      []PlanStep `json:"plan,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// SessionMetrics summarizes a session's stored events.
+type SessionMetrics struct {
+	// Duration is the wall time from session creation to its last event.
+	Duration time.Duration `json:"duration"`
+	// Events is the number of stored events.
+	Events int `json:"events"`
+	// Categories counts the events by type, e.g. "message" or "tool".
+	Categories map[string]int `json:"categories"`
+	// ToolCalls counts tool invocations, not their progress or results.
+	ToolCalls int `json:"tool_calls"`
+	// Approvals counts approval requests raised by the executor.
+	Approvals int `json:"approvals"`
+}
+
+// SessionCost is the estimated USD cost of a session's token usage.
+type SessionCost struct {
+	USD               float64 `json:"usd"`
+	InputTokens       int64   `json:"input_tokens"`
+	CachedInputTokens int64   `json:"cached_input_tokens"`
+	OutputTokens      int64   `json:"output_tokens"`
+	// Unpriced is set when some usage had no price, because its model is
+	// unknown or missing from the price table. That usage adds no cost.
+	Unpriced bool `json:"unpriced,omitempty"`
+	// UnknownModels lists the models missing from the price table.
+	UnknownModels []string `json:"unknown_models,omitempty"`
+}
+
+// TokenUsage is the number of tokens one model consumed, as reported on an
+// event. InputTokens excludes CachedInputTokens.
+type TokenUsage struct {
+	// Model is the model that used the tokens, when the executor reports it.
+	Model             string `json:"model,omitempty"`
+	InputTokens       int64  `json:"input_tokens"`
+	CachedInputTokens int64  `json:"cached_input_tokens,omitempty"`
+	OutputTokens      int64  `json:"output_tokens"`
 }
 
 // Event represents one streamed task event.
@@ -93,6 +195,16 @@ type Event struct {
 	Timestamp time.Time `json:"timestamp,omitempty"`
 	Type      string    `json:"type"`
 	Content   any       `json:"content"`
+	// EventID is a globally unique id for correlating the event across
+	// sessions, set when the SDK client has an EventIDGenerator. Seq still
+	// orders events within a session.
+	EventID string `json:"event_id,omitempty"`
+	// Normalized is true when Content is a UnifiedContent produced by a
+	// transformer, and false for raw passthrough output.
+	Normalized bool `json:"normalized,omitempty"`
+	// Truncated is set by stores that cap history on the oldest retained
+	// event, meaning earlier events of the session were discarded.
+	Truncated bool `json:"truncated,omitempty"`
 }
 
 // SubscribeOptions configures event subscription behavior.
@@ -101,6 +213,21 @@ type SubscribeOptions struct {
 	IncludeDebug bool
 	AfterSeq     uint64
 	Limit        int
+	// Categories keeps only events whose Type is in the list, for both
+	// history replay and the live tail. Empty means all.
+	Categories []string
+	// View names an output view registered on the SDK client (e.g. "compact")
+	// that reshapes emitted events without changing storage. Empty or
+	// unknown names emit events unchanged.
+	View string
+	// CoalesceProgress, when > 0, holds live "progress" events for up to this
+	// long and emits only the most recent one. Any other event type flushes
+	// the held event and is emitted immediately. History replay is unaffected.
+	CoalesceProgress time.Duration
+	// CoalesceTools drops "tool" events for started calls and emits only the
+	// completed (or failed) event, with content.duration_ms measured from the
+	// start event that has the same content.tool_call_id.
+	CoalesceTools bool
 }
 
 // Hooks allows callers to observe session lifecycle and persistence behavior.
@@ -109,6 +236,14 @@ type Hooks struct {
 	OnEventStored  func(ctx context.Context, evt Event)
 	OnSessionEnd   func(ctx context.Context, sessionID string)
 	OnStoreError   func(ctx context.Context, sessionID string, evt Event, err error)
+	// OnTransformError runs when an event transformer panics on log.
+	// recovered is the value passed to panic. The SDK then falls back to the
+	// built-in transformer, or stores the log untransformed.
+	OnTransformError func(ctx context.Context, sessionID string, log Log, recovered any)
+	// OnRawLog receives every log an executor emits, exactly as emitted and
+	// before it is transformed or stored, e.g. to tee raw output elsewhere.
+	// It runs on the session's log loop, so it should not block.
+	OnRawLog func(ctx context.Context, sessionID, executorName string, log Log)
 }
 
 // EventTransformer transforms executor logs to a unified stream event.
@@ -135,7 +270,116 @@ type UnifiedContent struct {
 	ToolName   string `json:"tool_name,omitempty"`
 	RequestID  string `json:"request_id,omitempty"`
 	Status     string `json:"status,omitempty"`
-	Raw        any    `json:"raw,omitempty"`
+	// ToolCallID identifies a tool call across its started and completed
+	// events, when the executor reports one.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	// DurationMS is how long a tool call took, set on completed tool events
+	// delivered with SubscribeOptions.CoalesceTools.
+	DurationMS int64 `json:"duration_ms,omitempty"`
+	// Files lists files changed by a completed tool call, when known.
+	Files []FileChange `json:"files,omitempty"`
+	// Scope details what an approval request would let the tool touch.
+	Scope *ApprovalScope `json:"scope,omitempty"`
+	// PlanSteps is the agent's full current plan, set on plan events of
+	// executors that report one. The latest plan event is authoritative.
+	PlanSteps []PlanStep `json:"plan_steps,omitempty"`
+	// ErrorKind classifies error events, e.g. ErrorKindRateLimit.
+	ErrorKind string `json:"error_kind,omitempty"`
+	// ErrorCode is the exit code or JSON-RPC code that goes with ErrorKind.
+	ErrorCode string `json:"error_code,omitempty"`
+	// RetryAfter is the provider's suggested wait in seconds before retrying.
+	RetryAfter int `json:"retry_after,omitempty"`
+	// ExitCode is the agent process's nonzero exit code, set on the error
+	// and done events that end a run whose process failed.
+	ExitCode int `json:"exit_code,omitempty"`
+	// Usage is the token usage reported on the event, one entry per model.
+	// Claude Code reports it on done events and Codex on token_count events.
+	Usage []TokenUsage `json:"usage,omitempty"`
+	Raw   any          `json:"raw,omitempty"`
+}
+
+// ApprovalScope describes the command, paths or URL covered by an approval
+// request, so approval prompts can show what is being allowed.
+type ApprovalScope struct {
+	Command string   `json:"command,omitempty"`
+	Cwd     string   `json:"cwd,omitempty"`
+	Paths   []string `json:"paths,omitempty"`
+	URL     string   `json:"url,omitempty"`
+}
+
+// Target returns the most specific scope detail (command, first path, then
+// URL) for use as an event target.
+func (s *ApprovalScope) Target() string {
+	switch {
+	case s == nil:
+		return ""
+	case s.Command != "":
+		return s.Command
+	case len(s.Paths) > 0:
+		return s.Paths[0]
+	default:
+		return s.URL
+	}
+}
+
+// ApprovalScopeFromInput extracts an ApprovalScope from common tool input
+// fields (command, cwd, file_path, notebook_path, path, abs_path and url).
+// It returns nil when none are present.
+func ApprovalScopeFromInput(input map[string]any) *ApprovalScope {
+	var scope ApprovalScope
+	switch cmd := input["command"].(type) {
+	case string:
+		scope.Command = cmd
+	case []any:
+		parts := make([]string, 0, len(cmd))
+		for _, part := range cmd {
+			if text, ok := part.(string); ok {
+				parts = append(parts, text)
+			}
+		}
+		scope.Command = strings.Join(parts, " ")
+	}
+	scope.Cwd, _ = input["cwd"].(string)
+	for _, key := range []string{"file_path", "notebook_path", "path", "abs_path"} {
+		if path, ok := input[key].(string); ok && path != "" {
+			scope.Paths = append(scope.Paths, path)
+		}
+	}
+	scope.URL, _ = input["url"].(string)
+
+	if scope.Command == "" && scope.Cwd == "" && len(scope.Paths) == 0 && scope.URL == "" {
+		return nil
+	}
+	return &scope
+}
+
+// PlanStep is one entry of an agent's plan.
+type PlanStep struct {
+	Content  string `json:"content"`
+	Status   string `json:"status"`
+	Priority string `json:"priority,omitempty"`
+}
+
+// Plan step statuses reported in PlanStep.Status.
+const (
+	PlanStepPending    = "pending"
+	PlanStepInProgress = "in_progress"
+	PlanStepCompleted  = "completed"
+)
+
+// File change operations reported in FileChange.Op.
+const (
+	FileOpCreate = "create"
+	FileOpModify = "modify"
+	FileOpDelete = "delete"
+)
+
+// FileChange describes one file touched by an executor tool call.
+type FileChange struct {
+	Path      string `json:"path"`
+	Op        string `json:"op"`
+	Additions int    `json:"additions,omitempty"`
+	Deletions int    `json:"deletions,omitempty"`
 }
 
 func StringifyContent(v any) string {
@@ -150,3 +394,38 @@ func StringifyContent(v any) string {
 		return fmt.Sprintf("%v", val)
 	}
 }
+
+// DecodeJSONObject returns v as a JSON object when it is a map or JSON text
+// (string, []byte or json.RawMessage) encoding one.
+func DecodeJSONObject(v any) (map[string]any, bool) {
+	switch val := v.(type) {
+	case map[string]any:
+		return val, true
+	case json.RawMessage:
+		var out map[string]any
+		if err := json.Unmarshal(val, &out); err == nil {
+			return out, true
+		}
+	case []byte:
+		var out map[string]any
+		if err := json.Unmarshal(val, &out); err == nil {
+			return out, true
+		}
+	case string:
+		var out map[string]any
+		if err := json.Unmarshal([]byte(val), &out); err == nil {
+			return out, true
+		}
+	}
+	return nil, false
+}
+
+// DecodeJSONObjectFromLine decodes the JSON object starting at the first "{"
+// in line, ignoring any prefix such as a log tag.
+func DecodeJSONObjectFromLine(line string) (map[string]any, bool) {
+	start := strings.Index(line, "{")
+	if start < 0 {
+		return nil, false
+	}
+	return DecodeJSONObject(line[start:])
+}
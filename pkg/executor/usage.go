@@ -0,0 +1,20 @@
+package executor
+
+import "encoding/json"
+
+// TokenCount returns a token count decoded from JSON agent output, or 0
+// when v is not a number.
+func TokenCount(v any) int64 {
+	switch n := v.(type) {
+	case float64:
+		return int64(n)
+	case int:
+		return int64(n)
+	case int64:
+		return n
+	case json.Number:
+		i, _ := n.Int64()
+		return i
+	}
+	return 0
+}
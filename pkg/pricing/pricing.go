@@ -0,0 +1,108 @@
+// Package pricing estimates what agent sessions cost from the token usage
+// their executors report.
+package pricing
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+// Price is what a model charges, in USD per million tokens.
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+	// CachedInput is the rate for input read from the prompt cache. When 0,
+	// cached input is charged at the Input rate.
+	CachedInput float64 `json:"cached_input,omitempty"`
+}
+
+// Cost returns the USD cost of usage at p.
+func (p Price) Cost(usage executor.TokenUsage) float64 {
+	cached := p.CachedInput
+	if cached == 0 {
+		cached = p.Input
+	}
+	return (float64(usage.InputTokens)*p.Input +
+		float64(usage.CachedInputTokens)*cached +
+		float64(usage.OutputTokens)*p.Output) / 1_000_000
+}
+
+// PriceTable maps model names, or name prefixes such as "claude-sonnet-4"
+// that cover dated releases, to their prices.
+type PriceTable map[string]Price
+
+// DefaultPriceTable holds public list prices for the default models of the
+// built-in executors. Prices change; override them with
+// sdk.ClientOptions.PriceTable.
+var DefaultPriceTable = PriceTable{
+	"sonnet":           {Input: 3, Output: 15, CachedInput: 0.3},
+	"opus":             {Input: 15, Output: 75, CachedInput: 1.5},
+	"haiku":            {Input: 1, Output: 5, CachedInput: 0.1},
+	"claude-sonnet-4":  {Input: 3, Output: 15, CachedInput: 0.3},
+	"claude-opus-4":    {Input: 15, Output: 75, CachedInput: 1.5},
+	"claude-haiku-4-5": {Input: 1, Output: 5, CachedInput: 0.1},
+	"claude-3-5-haiku": {Input: 0.8, Output: 4, CachedInput: 0.08},
+	"gpt-5":            {Input: 1.25, Output: 10, CachedInput: 0.125},
+	"gpt-5-mini":       {Input: 0.25, Output: 2, CachedInput: 0.025},
+}
+
+// Merge returns a copy of t with the entries of overrides added or replaced.
+func (t PriceTable) Merge(overrides PriceTable) PriceTable {
+	merged := maps.Clone(t)
+	if merged == nil {
+		merged = make(PriceTable, len(overrides))
+	}
+	maps.Copy(merged, overrides)
+	return merged
+}
+
+// Lookup returns the price of model: the entry with its exact name, or else
+// the one with the longest name that model starts with. Names are compared
+// case-insensitively.
+func (t PriceTable) Lookup(model string) (Price, bool) {
+	model = strings.ToLower(strings.TrimSpace(model))
+	if model == "" {
+		return Price{}, false
+	}
+	var (
+		best  Price
+		found bool
+		width int
+	)
+	for name, price := range t {
+		name = strings.ToLower(name)
+		if name == model {
+			return price, true
+		}
+		if strings.HasPrefix(model, name) && len(name) > width {
+			best, found, width = price, true, len(name)
+		}
+	}
+	return best, found
+}
+
+// Estimate adds usage to cost, priced at t. defaultModel is used for usage
+// that does not name its model. Usage whose model has no price adds its
+// tokens but no cost, and marks cost as unpriced.
+func (t PriceTable) Estimate(cost *executor.SessionCost, usage executor.TokenUsage, defaultModel string) {
+	cost.InputTokens += usage.InputTokens
+	cost.CachedInputTokens += usage.CachedInputTokens
+	cost.OutputTokens += usage.OutputTokens
+
+	model := usage.Model
+	if model == "" {
+		model = defaultModel
+	}
+	price, ok := t.Lookup(model)
+	if !ok {
+		cost.Unpriced = true
+		if model != "" && !slices.Contains(cost.UnknownModels, model) {
+			cost.UnknownModels = append(cost.UnknownModels, model)
+		}
+		return
+	}
+	cost.USD += price.Cost(usage)
+}
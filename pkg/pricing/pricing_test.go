@@ -0,0 +1,61 @@
+package pricing
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+func TestPriceTableLookup(t *testing.T) {
+	table := PriceTable{
+		"gpt-5":      {Input: 1.25, Output: 10},
+		"gpt-5-mini": {Input: 0.25, Output: 2},
+	}
+	tests := []struct {
+		model string
+		want  float64
+		ok    bool
+	}{
+		{"gpt-5", 1.25, true},
+		{"GPT-5-Codex", 1.25, true},
+		{"gpt-5-mini-2025-08-07", 0.25, true},
+		{"o3", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		price, ok := table.Lookup(tt.model)
+		if ok != tt.ok || price.Input != tt.want {
+			t.Fatalf("Lookup(%q) = %+v/%v, want input %v/%v", tt.model, price, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	table := DefaultPriceTable.Merge(PriceTable{"sonnet": {Input: 2, Output: 10}})
+	if table["sonnet"].Input != 2 || DefaultPriceTable["sonnet"].Input != 3 {
+		t.Fatalf("expected Merge to override a copy, got %+v / %+v", table["sonnet"], DefaultPriceTable["sonnet"])
+	}
+
+	var cost executor.SessionCost
+	table.Estimate(&cost, executor.TokenUsage{Model: "claude-sonnet-4-5-20250929", InputTokens: 1_000_000, CachedInputTokens: 1_000_000, OutputTokens: 100_000}, "")
+	table.Estimate(&cost, executor.TokenUsage{InputTokens: 500_000}, "sonnet")
+	table.Estimate(&cost, executor.TokenUsage{Model: "mystery-1", OutputTokens: 10}, "")
+	table.Estimate(&cost, executor.TokenUsage{OutputTokens: 10}, "")
+
+	// 3 + 0.3 + 1.5 for the dated sonnet, 1 for the alias.
+	if math.Abs(cost.USD-5.8) > 1e-9 {
+		t.Fatalf("expected $5.80, got %v", cost.USD)
+	}
+	if cost.InputTokens != 1_500_000 || cost.CachedInputTokens != 1_000_000 || cost.OutputTokens != 100_020 {
+		t.Fatalf("expected all tokens to be counted, got %+v", cost)
+	}
+	if !cost.Unpriced || !reflect.DeepEqual(cost.UnknownModels, []string{"mystery-1"}) {
+		t.Fatalf("expected unpriced usage to be flagged, got %+v", cost)
+	}
+
+	if got := (Price{Input: 4, Output: 8}).Cost(executor.TokenUsage{CachedInputTokens: 1_000_000}); got != 4 {
+		t.Fatalf("expected cached input at the input rate without a cached rate, got %v", got)
+	}
+}
@@ -0,0 +1,40 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+// ErrExecutorNotAllowed is matched by ExecutorNotAllowedError.
+var ErrExecutorNotAllowed = errors.New("executor is not allowed")
+
+// ExecutorNotAllowedError reports a request for an executor outside
+// ClientOptions.AllowedExecutors. It matches ErrExecutorNotAllowed with
+// errors.Is.
+type ExecutorNotAllowedError struct {
+	Executor executor.ExecutorType
+}
+
+func (e *ExecutorNotAllowedError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrExecutorNotAllowed, e.Executor)
+}
+
+func (e *ExecutorNotAllowedError) Unwrap() error { return ErrExecutorNotAllowed }
+
+// executorAllowed reports whether sessions may use executor name. Every
+// executor is allowed when ClientOptions.AllowedExecutors is empty.
+func (c *Client) executorAllowed(name executor.ExecutorType) bool {
+	return len(c.allowedExecutors) == 0 || slices.Contains(c.allowedExecutors, name)
+}
+
+// checkExecutorAllowed returns an *ExecutorNotAllowedError for an executor
+// sessions may not use.
+func (c *Client) checkExecutorAllowed(name executor.ExecutorType) error {
+	if !c.executorAllowed(name) {
+		return &ExecutorNotAllowedError{Executor: name}
+	}
+	return nil
+}
@@ -5,6 +5,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -19,12 +23,27 @@ import (
 	"github.com/supremeagent/executor/pkg/executor/droid"
 	"github.com/supremeagent/executor/pkg/executor/gemini"
 	"github.com/supremeagent/executor/pkg/executor/qwen"
+	"github.com/supremeagent/executor/pkg/pricing"
 	"github.com/supremeagent/executor/pkg/store"
 	"github.com/supremeagent/executor/pkg/streaming"
 )
 
 var ErrPromptRequired = errors.New("prompt is required")
 var ErrResumeUnavailable = errors.New("resume state unavailable for this session")
+var ErrInvalidWorkingDir = errors.New("working directory is not an existing directory")
+var ErrSessionExists = errors.New("session already exists")
+
+// EventTypeDeleted is the terminal event live subscribers receive when the
+// session they follow is deleted. It is not stored.
+const EventTypeDeleted = "deleted"
+
+// NoLogsSourceType is the UnifiedContent.SourceType of the error event that
+// fails a session whose executor returns a nil Logs channel.
+const NoLogsSourceType = "no_logs"
+
+// SessionStartedSourceType is the UnifiedContent.SourceType of the lifecycle
+// event Execute stores as the first event (seq 1) of every session.
+const SessionStartedSourceType = "session_started"
 
 // ClientOptions configures SDK client behavior.
 type ClientOptions struct {
@@ -33,6 +52,120 @@ type ClientOptions struct {
 	EventStore    store.EventStore
 	Hooks         executor.Hooks
 	Transformers  map[string]executor.EventTransformer
+	// TransformerChains adds post-processing stages per executor name. Stages
+	// run in order after the base transformer (the built-in default, or the
+	// replacement from Transformers); each stage receives the previous Event's
+	// Type and Content as TransformInput.Log.
+	TransformerChains map[string][]executor.EventTransformer
+	// MaxContextBytes limits the total size of ExecuteRequest.ContextFiles.
+	// Defaults to DefaultMaxContextBytes when <= 0.
+	MaxContextBytes int64
+	// PathRedactor, when set, rewrites absolute paths in the targets,
+	// summaries, text, file changes, approval scopes and raw payloads of
+	// normalized events before they are stored or streamed. Events without
+	// UnifiedContent, plan steps and Hooks.OnRawLog are not redacted. See
+	// MaskAbsolutePath.
+	PathRedactor PathRedactor
+	// SessionStore persists session summaries, requests and resume state so
+	// they survive a restart. Defaults to store.NopSessionStore.
+	SessionStore store.SessionStore
+	// IgnoreExecutorTimestamps stamps every event with the time it is stored
+	// instead of the upstream time a transformer took from executor output.
+	IgnoreExecutorTimestamps bool
+	// StartRetries is how many times a failed executor start is retried with
+	// a fresh executor instance. 0 disables retries.
+	StartRetries int
+	// StartRetryDelay is the pause between start attempts. Defaults to
+	// DefaultStartRetryDelay when <= 0.
+	StartRetryDelay time.Duration
+	// StartErrorClassifier decides which start errors are retried. Defaults
+	// to DefaultStartErrorClassifier.
+	StartErrorClassifier StartErrorClassifier
+	// Views adds or replaces named views selectable with
+	// SubscribeOptions.View, alongside the built-in "full" and "compact".
+	Views map[string]View
+	// SessionPersistInterval debounces SessionStore writes for summary
+	// updates that do not change a session's status (e.g. UpdatedAt on every
+	// event). Status changes are written immediately. Defaults to
+	// DefaultSessionPersistInterval when <= 0.
+	SessionPersistInterval time.Duration
+	// BenignStderrPatterns extends executor.DefaultBenignStderrPatterns for
+	// every session: matching stderr lines become debug events, not errors.
+	BenignStderrPatterns []string
+	// InteractivePromptPatterns extends
+	// executor.DefaultInteractivePromptPatterns for every session: matching
+	// output lines become approval events answered with Respond.
+	InteractivePromptPatterns []string
+	// MaxSessionsPerWorkingDir limits how many sessions may run at once in the
+	// same resolved WorkingDir, since agents sharing a checkout corrupt each
+	// other's state. 0 means unlimited. A run holds its slot until it ends.
+	MaxSessionsPerWorkingDir int
+	// WorkingDirLimitMode decides whether a run over MaxSessionsPerWorkingDir
+	// fails with ErrWorkingDirBusy (LimitReject) or waits for a slot until its
+	// context is done (LimitBlock).
+	WorkingDirLimitMode executor.LimitMode
+	// RawMode stores and streams every executor log as-is: the event's Type
+	// and Content are the Log's, and no transformer, including Transformers
+	// and TransformerChains, runs. Use it to parse executor output yourself;
+	// Retransform can still normalize the stored events later. The
+	// SDK-generated session-started event stays normalized.
+	RawMode bool
+	// StallThreshold, when > 0, records a non-terminal "progress" event with
+	// content.phase "stalled" once a running session has produced no output
+	// for this long, so UIs can warn that the executor may be stuck. The
+	// warning is repeated after the executor produces output and goes quiet
+	// again. Paused sessions and sessions waiting for an approval response
+	// are not considered stalled.
+	StallThreshold time.Duration
+	// StallTimeout, when > 0, stops a running session that has produced no
+	// output for this long. The session ends as interrupted. 0 waits forever.
+	StallTimeout time.Duration
+	// ResultMode decides how several result events of one run are combined
+	// into Session.Result. Defaults to ResultModeAppend.
+	ResultMode ResultMode
+	// StoreFailurePolicy decides how a session reacts when the EventStore
+	// rejects one of its events. Defaults to StoreFailureDropEvents.
+	StoreFailurePolicy StoreFailurePolicy
+	// ModelsCacheTTL is how long Models reuses an executor's model list
+	// before asking again. Defaults to DefaultModelsCacheTTL when <= 0.
+	ModelsCacheTTL time.Duration
+	// PromptTemplates wraps the prompt (including ContextFiles) passed to
+	// Start for sessions of each executor. Continue messages and the session
+	// title are not templated.
+	PromptTemplates map[executor.ExecutorType]PromptTemplate
+	// IDGenerator returns the id of a new session whose ExecuteRequest has no
+	// SessionID. Defaults to a random UUID.
+	IDGenerator func() string
+	// AllowedExecutors limits the executors sessions may use, even when more
+	// factories are registered. Execute rejects others with an
+	// *ExecutorNotAllowedError and Executors does not list them. Empty
+	// allows every registered executor.
+	AllowedExecutors []executor.ExecutorType
+	// EventIDGenerator returns the Event.EventID stamped on every event
+	// before it is stored, e.g. uuid.NewString or a ULID source. Events have
+	// no EventID while it is nil.
+	EventIDGenerator func() string
+	// ResumeTokenKey signs the tokens of ExportResumeToken and verifies those
+	// passed to ExecuteFromResumeToken. Clients exchanging tokens must share
+	// it; resume tokens are disabled while it is empty.
+	ResumeTokenKey []byte
+	// ResumeTokenTTL is how long a token from ExportResumeToken can be
+	// used. Defaults to DefaultResumeTokenTTL when <= 0.
+	ResumeTokenTTL time.Duration
+	// PriceTable adds or replaces model prices of pricing.DefaultPriceTable
+	// used by SessionCost, e.g. with negotiated rates.
+	PriceTable pricing.PriceTable
+	// WorkingDirRoot, when set, confines sessions to this directory: a
+	// working directory that resolves outside it, through ".." or symlinks,
+	// fails with ErrWorkingDirNotAllowed. Accepted working directories are
+	// passed to executors resolved. Empty allows any directory.
+	WorkingDirRoot string
+	// Launcher sets executor.Options.Launcher for every session, e.g.
+	// "pnpm dlx" or "bunx" where npx is unavailable.
+	Launcher string
+	// CommandOverrides sets executor.Options.CommandOverride for the sessions
+	// of each executor. An override takes precedence over Launcher.
+	CommandOverrides map[executor.ExecutorType][]string
 }
 
 // Client is the SDK entry point for executing and managing tasks.
@@ -42,21 +175,57 @@ type Client struct {
 	store      store.EventStore
 	hooks      executor.Hooks
 	transforms map[string]executor.EventTransformer
+	fallbacks  map[string]executor.EventTransformer
+	chains     map[string][]executor.EventTransformer
+	viewsMu    sync.RWMutex
+	views      map[string]View
+
+	maxContextBytes          int64
+	pathRedactor             PathRedactor
+	ignoreExecutorTimestamps bool
+	startRetries             int
+	startRetryDelay          time.Duration
+	startClassifier          StartErrorClassifier
+	benignStderr             []string
+	interactivePrompts       []string
+	promptTemplates          map[executor.ExecutorType]PromptTemplate
+	newSessionID             func() string
+	newEventID               func() string
+	allowedExecutors         []executor.ExecutorType
+	launcher                 string
+	commandOverrides         map[executor.ExecutorType][]string
+	resumeTokenKey           []byte
+	resumeTokenTTL           time.Duration
+	prices                   pricing.PriceTable
+	workDirs                 *workDirLimiter
+	workDirRoot              string
+	resultMode               ResultMode
+	rawMode                  bool
+	stallThreshold           time.Duration
+	stallTimeout             time.Duration
+	storeFailure             StoreFailurePolicy
+
+	modelsMu  sync.Mutex
+	modelsTTL time.Duration
+	models    map[string]cachedModels
+
+	sessionStore store.SessionStore
+	// persistMu serializes session snapshots and saves so an older snapshot
+	// never overwrites a newer one.
+	persistMu       sync.Mutex
+	persistInterval time.Duration
+	pendingMu       sync.Mutex
+	pendingPersist  map[string]*time.Timer
+	closed          bool
 
 	sessionsMu sync.RWMutex
 	sessions   map[string]executor.Session
 	requests   map[string]executor.ExecuteRequest
-	resumeInfo map[string]sessionResumeInfo
-}
-
-type sessionResumeInfo struct {
-	ClaudeSessionID   string
-	CodexConversation string
-	CodexRolloutPath  string
-}
-
-type storeCloser interface {
-	Close()
+	resumeInfo map[string]executor.ResumeState
+	finished   map[string]chan struct{}
+	// reserved holds the ids of sessions Execute is starting, so that two
+	// requests cannot claim the same id before either is registered.
+	reserved map[string]struct{}
 }
 
 func RegisterAllExecutors(registry *executor.Registry) {
@@ -92,23 +261,145 @@ func NewWithOptions(opts ClientOptions) *Client {
 	if opts.EventStore == nil {
 		opts.EventStore = store.NewMemoryEventStore()
 	}
+	if opts.MaxContextBytes <= 0 {
+		opts.MaxContextBytes = DefaultMaxContextBytes
+	}
+	if opts.SessionStore == nil {
+		opts.SessionStore = store.NopSessionStore{}
+	}
+	if opts.StartRetryDelay <= 0 {
+		opts.StartRetryDelay = DefaultStartRetryDelay
+	}
+	if opts.StartErrorClassifier == nil {
+		opts.StartErrorClassifier = DefaultStartErrorClassifier
+	}
+	if opts.SessionPersistInterval <= 0 {
+		opts.SessionPersistInterval = DefaultSessionPersistInterval
+	}
+	if opts.ModelsCacheTTL <= 0 {
+		opts.ModelsCacheTTL = DefaultModelsCacheTTL
+	}
+	if opts.ResumeTokenTTL <= 0 {
+		opts.ResumeTokenTTL = DefaultResumeTokenTTL
+	}
+	if opts.IDGenerator == nil {
+		opts.IDGenerator = uuid.NewString
+	}
 
 	transforms := defaultEventTransformers()
+	// Replaced built-ins stay as fallbacks for a replacement that panics.
+	fallbacks := make(map[string]executor.EventTransformer)
 	for name, tf := range opts.Transformers {
 		if tf != nil {
+			if builtin, ok := transforms[name]; ok {
+				fallbacks[name] = builtin
+			}
 			transforms[name] = tf
 		}
 	}
 
-	return &Client{
+	chains := make(map[string][]executor.EventTransformer, len(opts.TransformerChains))
+	for name, stages := range opts.TransformerChains {
+		for _, tf := range stages {
+			if tf != nil {
+				chains[name] = append(chains[name], tf)
+			}
+		}
+	}
+
+	views := defaultViews()
+	for name, view := range opts.Views {
+		if view != nil {
+			views[name] = view
+		}
+	}
+
+	client := &Client{
 		registry:   opts.Registry,
 		stream:     opts.StreamManager,
 		store:      opts.EventStore,
 		hooks:      opts.Hooks,
 		transforms: transforms,
+		fallbacks:  fallbacks,
+		chains:     chains,
+		views:      views,
 		sessions:   make(map[string]executor.Session),
 		requests:   make(map[string]executor.ExecuteRequest),
-		resumeInfo: make(map[string]sessionResumeInfo),
+		resumeInfo: make(map[string]executor.ResumeState),
+		finished:   make(map[string]chan struct{}),
+		reserved:   make(map[string]struct{}),
+
+		maxContextBytes:          opts.MaxContextBytes,
+		pathRedactor:             opts.PathRedactor,
+		ignoreExecutorTimestamps: opts.IgnoreExecutorTimestamps,
+		startRetries:             opts.StartRetries,
+		startRetryDelay:          opts.StartRetryDelay,
+		startClassifier:          opts.StartErrorClassifier,
+		benignStderr:             slices.Clone(opts.BenignStderrPatterns),
+		interactivePrompts:       slices.Clone(opts.InteractivePromptPatterns),
+		promptTemplates:          maps.Clone(opts.PromptTemplates),
+		newSessionID:             opts.IDGenerator,
+		newEventID:               opts.EventIDGenerator,
+		allowedExecutors:         slices.Clone(opts.AllowedExecutors),
+		launcher:                 opts.Launcher,
+		commandOverrides:         maps.Clone(opts.CommandOverrides),
+		resumeTokenKey:           slices.Clone(opts.ResumeTokenKey),
+		resumeTokenTTL:           opts.ResumeTokenTTL,
+		prices:                   pricing.DefaultPriceTable.Merge(opts.PriceTable),
+		workDirs:                 newWorkDirLimiter(opts.MaxSessionsPerWorkingDir, opts.WorkingDirLimitMode),
+		workDirRoot:              resolveWorkingDirRoot(opts.WorkingDirRoot),
+		resultMode:               opts.ResultMode,
+		rawMode:                  opts.RawMode,
+		stallThreshold:           opts.StallThreshold,
+		stallTimeout:             opts.StallTimeout,
+		storeFailure:             opts.StoreFailurePolicy,
+		modelsTTL:                opts.ModelsCacheTTL,
+		models:                   make(map[string]cachedModels),
+		sessionStore:             opts.SessionStore,
+		persistInterval:          opts.SessionPersistInterval,
+		pendingPersist:           make(map[string]*time.Timer),
+	}
+	if client.storeFailure == StoreFailureDegradeToMemory {
+		client.store = newOverlayStore(client.store, client.reportStoreError)
+	}
+	client.restoreSessions()
+	return client
+}
+
+// restoreSessions loads persisted sessions. Sessions recorded as running are
+// marked interrupted, since no executor survives a restart.
+func (c *Client) restoreSessions() {
+	records, err := c.sessionStore.Load(context.Background())
+	if err != nil {
+		log.Errorf("load sessions failed: %v", err)
+		return
+	}
+
+	c.sessionsMu.Lock()
+	defer c.sessionsMu.Unlock()
+	for _, record := range records {
+		sessionID := record.Session.SessionID
+		if sessionID == "" {
+			continue
+		}
+		session := record.Session
+		if _, running := c.registry.GetSession(sessionID); !running && session.Status == executor.SessionStatusRunning {
+			session.Status = executor.SessionStatusInterrupted
+		}
+		c.sessions[sessionID] = session
+		c.requests[sessionID] = record.Request
+
+		var resume executor.ResumeState
+		if len(record.Resume) > 0 {
+			if err := json.Unmarshal(record.Resume, &resume); err != nil {
+				log.Warningf("decode resume state failed: session=%s err=%v", sessionID, err)
+			}
+		}
+		c.resumeInfo[sessionID] = resume
+
+		finished := make(chan struct{})
+		close(finished)
+		c.finished[sessionID] = finished
 	}
 }
 
@@ -122,16 +413,56 @@ func (c *Client) RegisterExecutor(name string, factory executor.Factory) {
 	c.registry.Register(name, factory)
 }
 
-// Execute starts a new task.
+// detachedSessionKey marks contexts made by DetachSession.
+type detachedSessionKey struct{}
+
+// DetachSession returns a context for Execute whose cancellation only aborts
+// starting the session: waiting for a registry or working directory slot and
+// starting the executor. Once Execute returns, the session outlives ctx. It
+// suits callers such as HTTP handlers whose context ends with the request.
+func DetachSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, detachedSessionKey{}, true)
+}
+
+func sessionDetached(ctx context.Context) bool {
+	detached, _ := ctx.Value(detachedSessionKey{}).(bool)
+	return detached
+}
+
+// Execute starts a new task. Cancelling ctx after Execute returns interrupts
+// and closes the running executor; pass a context made by DetachSession, or
+// a non-cancellable one (e.g. context.WithoutCancel), to let the session
+// outlive the caller.
 func (c *Client) Execute(ctx context.Context, req executor.ExecuteRequest) (executor.ExecuteResponse, error) {
 	if req.Prompt == "" {
 		return executor.ExecuteResponse{}, ErrPromptRequired
 	}
+	if err := req.ApprovalDefault.Validate(); err != nil {
+		return executor.ExecuteResponse{}, err
+	}
 	if req.Executor == "" {
 		req.Executor = executor.ExecutorClaudeCode
 	}
+	if err := c.checkExecutorAllowed(req.Executor); err != nil {
+		return executor.ExecuteResponse{}, err
+	}
+	workingDir, err := c.confineWorkingDir(req.WorkingDir)
+	if err != nil {
+		return executor.ExecuteResponse{}, err
+	}
+	req.WorkingDir = workingDir
+	prompt, err := c.buildPrompt(req)
+	if err != nil {
+		return executor.ExecuteResponse{}, err
+	}
+	prompt = c.applyPromptTemplate(req.Executor, prompt)
+
+	sessionID, err := c.reserveSessionID(req.SessionID)
+	if err != nil {
+		return executor.ExecuteResponse{}, err
+	}
+	defer c.releaseSessionID(sessionID)
 
-	sessionID := uuid.New().String()
 	opts := executor.Options{
 		WorkingDir:                 req.WorkingDir,
 		Model:                      req.Model,
@@ -141,16 +472,22 @@ func (c *Client) Execute(ctx context.Context, req executor.ExecuteRequest) (exec
 		Sandbox:                    req.Sandbox,
 		Env:                        req.Env,
 		AskForApproval:             req.AskForApproval,
+		ApprovalPolicy:             executor.ApprovalPolicy{AllowedTools: req.AllowedTools, Default: req.ApprovalDefault},
+		AllowedTools:               req.ClaudeAllowedTools,
+		DisallowedTools:            req.DisallowedTools,
+		MaxToolCalls:               req.MaxToolCalls,
+		ModelReasoningEffort:       req.ModelReasoningEffort,
 	}
 
-	exec, err := c.registry.CreateSession(sessionID, string(req.Executor), opts)
+	release, err := c.lockWorkingDir(ctx, req.WorkingDir)
 	if err != nil {
 		return executor.ExecuteResponse{}, err
 	}
-
-	if err := exec.Start(ctx, req.Prompt, opts); err != nil {
-		_ = exec.Close()
-		c.registry.RemoveSession(sessionID)
+	exec, err := c.startSession(ctx, sessionID, string(req.Executor), prompt, opts)
+	if err != nil {
+		if release != nil {
+			release()
+		}
 		return executor.ExecuteResponse{}, err
 	}
 
@@ -164,54 +501,235 @@ func (c *Client) Execute(ctx context.Context, req executor.ExecuteRequest) (exec
 		Title:     truncateTitle(req.Prompt, 36),
 		Status:    executor.SessionStatusRunning,
 		Executor:  req.Executor,
+		Labels:    maps.Clone(req.Labels),
+		Kind:      req.Kind,
 		CreatedAt: now,
 		UpdatedAt: now,
 	})
 	c.setSessionRequest(sessionID, req)
+	c.recordSessionStarted(sessionID, string(req.Executor))
 
-	go c.pipeSessionLogs(sessionID, string(req.Executor), exec)
+	finished := c.runSession(sessionID, string(req.Executor), exec, opts)
+	releaseWhenFinished(finished, release)
+	if ctx.Done() != nil && !sessionDetached(ctx) {
+		go c.closeOnCancel(ctx, sessionID, exec, finished)
+	}
 
 	return executor.ExecuteResponse{SessionID: sessionID, Status: "running"}, nil
 }
 
-func (c *Client) pipeSessionLogs(sessionID, executorName string, exec executor.Executor) {
+// closeOnCancel interrupts and closes exec when ctx is cancelled before the
+// session finishes. It returns as soon as either happens.
+func (c *Client) closeOnCancel(ctx context.Context, sessionID string, exec executor.Executor, finished <-chan struct{}) {
+	select {
+	case <-finished:
+		return
+	case <-ctx.Done():
+	}
+
+	if current, ok := c.registry.GetSession(sessionID); !ok || current != exec {
+		return
+	}
+	_ = exec.Interrupt()
+	_ = exec.Close()
+}
+
+// runSession pipes exec's logs in the background. The returned channel is
+// closed once the executor has finished and all of its events are stored.
+func (c *Client) runSession(sessionID, executorName string, exec executor.Executor, opts executor.Options) <-chan struct{} {
+	finished := make(chan struct{})
+	c.sessionsMu.Lock()
+	c.finished[sessionID] = finished
+	c.sessionsMu.Unlock()
+
+	go func() {
+		defer close(finished)
+		c.pipeSessionLogs(sessionID, executorName, exec, opts)
+	}()
+	return finished
+}
+
+// pipeSessionLogs stores the logs of one run until its first stored done
+// event, which is the run's only terminal event. Executors may report done
+// more than once, e.g. on their result and again when the process exits, so
+// nothing the executor sends after it is stored.
+func (c *Client) pipeSessionLogs(sessionID, executorName string, exec executor.Executor, opts executor.Options) {
 	done := false
+	// failed marks the run failed however it ends, since the SDK itself stops
+	// runs that break a limit. authFailed does the same for an auth error,
+	// after which agents usually exit, until the agent shows it got past the
+	// error by replying or calling a tool.
+	failed := false
+	authFailed := false
+	toolCalls := 0
 	defer func() {
-		if !done {
+		if failed || authFailed {
+			c.updateSessionStatus(sessionID, executor.SessionStatusFailed)
+		} else if !done {
 			c.updateSessionStatus(sessionID, executor.SessionStatusInterrupted)
 		}
-		_ = exec.Close()
+		c.recordSessionMetrics(sessionID)
+		if overlay, ok := c.store.(*overlayStore); ok {
+			overlay.endSession(sessionID)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), executor.DefaultShutdownTimeout)
+		_ = executor.ShutdownAndClose(ctx, exec)
+		cancel()
 		c.registry.RemoveSession(sessionID)
 		if c.hooks.OnSessionEnd != nil {
 			c.hooks.OnSessionEnd(context.Background(), sessionID)
 		}
 	}()
 
-	for logEntry := range exec.Logs() {
-		c.captureResumeState(sessionID, executorName, logEntry)
+	watch := newStallWatch(c.stallThreshold, c.stallTimeout)
+	defer watch.stop()
+
+	logs := exec.Logs()
+	if logs == nil {
+		// Receiving from a nil channel would block forever.
+		c.recordSessionError(sessionID, executorName, NoLogsSourceType, "Executor has no log channel; stopping the session")
+		failed = true
+		return
+	}
+	for {
+		var logEntry executor.Log
+		select {
+		case entry, ok := <-logs:
+			if !ok {
+				return
+			}
+			logEntry = entry
+			watch.activity()
+		case <-watch.C():
+			if c.waitingOnCaller(sessionID) {
+				watch.activity()
+				continue
+			}
+			warn, timedOut := watch.fire()
+			if timedOut {
+				c.recordStalled(sessionID, executorName, watch.quietFor(), true)
+				return
+			}
+			if warn {
+				c.recordStalled(sessionID, executorName, watch.quietFor(), false)
+			}
+			continue
+		}
+
+		if c.hooks.OnRawLog != nil {
+			c.hooks.OnRawLog(context.Background(), sessionID, executorName, logEntry)
+		}
+		c.captureResumeState(sessionID, exec, logEntry)
+		c.captureExecutorVersion(sessionID, exec, logEntry)
 		evt := c.transformEvent(sessionID, executorName, logEntry)
-		storedEvt, err := c.store.Append(context.Background(), evt)
-		if err != nil {
-			if c.hooks.OnStoreError != nil {
-				c.hooks.OnStoreError(context.Background(), sessionID, evt, err)
+		evt = c.redactPaths(sessionID, evt)
+		storedEvt, ok := c.recordEvent(sessionID, evt)
+		if !ok {
+			if c.storeFailure == StoreFailureFailSession {
+				c.publishStoreFailure(sessionID, executorName)
+				failed = true
+				return
 			}
-			log.Errorf("store append failed: session=%s type=%s err=%v", sessionID, logEntry.Type, err)
 			continue
 		}
-		if c.hooks.OnEventStored != nil {
-			c.hooks.OnEventStored(context.Background(), storedEvt)
+		switch storedEvt.Type {
+		case "error":
+			if isAuthFailure(storedEvt) {
+				authFailed = true
+			}
+		case "message", "tool", "plan":
+			authFailed = false
+		}
+		if isToolCall(storedEvt) {
+			toolCalls++
+			if opts.MaxToolCalls > 0 && toolCalls > opts.MaxToolCalls {
+				c.recordToolLimitExceeded(sessionID, executorName, opts.MaxToolCalls)
+				failed = true
+				return
+			}
 		}
-
-		c.touchSession(sessionID, storedEvt)
-		c.stream.AppendLog(sessionID, streaming.LogEntry{Type: storedEvt.Type, Content: storedEvt})
 		if storedEvt.Type == "done" {
 			done = true
-			c.updateSessionStatus(sessionID, executor.SessionStatusDone)
+			if !failed && !authFailed {
+				c.updateSessionStatus(sessionID, executor.SessionStatusDone)
+			}
 			return
 		}
 	}
 }
 
+// isAuthFailure reports whether evt is an error classified as
+// executor.ErrorKindAuth.
+func isAuthFailure(evt executor.Event) bool {
+	content, ok := transcriptContent(evt)
+	return ok && content.ErrorKind == executor.ErrorKindAuth
+}
+
+// recordEvent appends evt to the store, runs the store hooks, updates the
+// session summary and publishes the stored event to live subscribers. It
+// returns false when the store rejects the event, which is counted as dropped
+// unless the StoreFailurePolicy fails the session instead.
+func (c *Client) recordEvent(sessionID string, evt executor.Event) (executor.Event, bool) {
+	if c.newEventID != nil && evt.EventID == "" {
+		evt.EventID = c.newEventID()
+	}
+	storedEvt, err := c.store.Append(context.Background(), evt)
+	if err != nil {
+		c.reportStoreError(evt, err)
+		if c.storeFailure != StoreFailureFailSession {
+			c.countDroppedEvent(sessionID)
+		}
+		return executor.Event{}, false
+	}
+	if c.hooks.OnEventStored != nil {
+		c.hooks.OnEventStored(context.Background(), storedEvt)
+	}
+
+	c.touchSession(sessionID, storedEvt)
+	c.stream.AppendLog(sessionID, streaming.LogEntry{Type: storedEvt.Type, Content: storedEvt})
+	return storedEvt, true
+}
+
+// recordSessionStarted stores the synthetic lifecycle event every session
+// begins with, so subscribers see activity before the executor's first log.
+func (c *Client) recordSessionStarted(sessionID, executorName string) {
+	c.recordEvent(sessionID, executor.Event{
+		SessionID: sessionID,
+		Executor:  executorName,
+		Timestamp: time.Now(),
+		Type:      "progress",
+		Content: executor.UnifiedContent{
+			Source:     executorName,
+			SourceType: SessionStartedSourceType,
+			Category:   "lifecycle",
+			Action:     "starting",
+			Phase:      "started",
+			Summary:    "Session started",
+		},
+		Normalized: true,
+	})
+}
+
+// recordSessionError stores an error event the SDK raises itself when it
+// fails a session, e.g. for exceeding a limit.
+func (c *Client) recordSessionError(sessionID, executorName, sourceType, summary string) {
+	c.recordEvent(sessionID, executor.Event{
+		SessionID: sessionID,
+		Executor:  executorName,
+		Timestamp: time.Now(),
+		Type:      "error",
+		Content: executor.UnifiedContent{
+			Source:     executorName,
+			SourceType: sourceType,
+			Category:   "error",
+			Action:     "failed",
+			Phase:      "failed",
+			Summary:    summary,
+		},
+		Normalized: true,
+	})
+}
+
 // PauseTask interrupts a running task.
 func (c *Client) PauseTask(sessionID string) error {
 	exec, ok := c.registry.GetSession(sessionID)
@@ -226,14 +744,57 @@ func (c *Client) PauseTask(sessionID string) error {
 	return nil
 }
 
+// InterruptByLabel interrupts and closes every running session whose label
+// key equals value, and returns the affected session IDs.
+func (c *Client) InterruptByLabel(ctx context.Context, key, value string) []string {
+	c.sessionsMu.RLock()
+	var matched []string
+	for sessionID, session := range c.sessions {
+		if v, ok := session.Labels[key]; ok && v == value {
+			matched = append(matched, sessionID)
+		}
+	}
+	c.sessionsMu.RUnlock()
+	sort.Strings(matched)
+
+	affected := make([]string, 0, len(matched))
+	for _, sessionID := range matched {
+		if ctx.Err() != nil {
+			break
+		}
+		exec, ok := c.registry.GetSession(sessionID)
+		if !ok {
+			continue
+		}
+		_ = exec.Interrupt()
+		_ = exec.Close()
+		c.updateSessionStatus(sessionID, executor.SessionStatusInterrupted)
+		affected = append(affected, sessionID)
+	}
+	return affected
+}
+
 // ContinueTask continues a paused/running task with a message.
 func (c *Client) ContinueTask(ctx context.Context, sessionID string, message string) error {
+	return c.ContinueTaskWithOptions(ctx, sessionID, executor.ContinueRequest{Message: message})
+}
+
+// ContinueTaskWithOptions is like ContinueTask but also accepts a working
+// directory override. The override only applies when the session has to be
+// resumed; a live session keeps its directory. Once the resumed run starts,
+// the override becomes the session's working directory for later resumes.
+// A ModelReasoningEffort override applies to this turn only: it is sent with
+// the message to a live executor implementing executor.TurnSender, or used
+// for the resumed run, and is not stored on the session. Without one, a
+// resumed run keeps the session's ExecuteRequest.ModelReasoningEffort.
+func (c *Client) ContinueTaskWithOptions(ctx context.Context, sessionID string, continueReq executor.ContinueRequest) error {
+	message := continueReq.Message
 	if message == "" {
 		message = "continue"
 	}
 
 	if exec, ok := c.registry.GetSession(sessionID); ok {
-		if err := exec.SendMessage(ctx, message); err != nil {
+		if err := sendTurn(ctx, exec, message, continueReq); err != nil {
 			return err
 		}
 		c.updateSessionStatus(sessionID, executor.SessionStatusRunning)
@@ -244,6 +805,28 @@ func (c *Client) ContinueTask(ctx context.Context, sessionID string, message str
 	if !ok {
 		return executor.ErrSessionNotFound
 	}
+	if resume == (executor.ResumeState{}) {
+		return ErrResumeUnavailable
+	}
+	if err := c.checkExecutorAllowed(req.Executor); err != nil {
+		return err
+	}
+	if continueReq.WorkingDir != "" {
+		workingDir, err := resolveWorkingDir(continueReq.WorkingDir)
+		if err != nil {
+			return err
+		}
+		req.WorkingDir = workingDir
+	}
+	workingDir, err := c.confineWorkingDir(req.WorkingDir)
+	if err != nil {
+		return err
+	}
+	req.WorkingDir = workingDir
+	effort := continueReq.ModelReasoningEffort
+	if effort == "" {
+		effort = req.ModelReasoningEffort
+	}
 	opts := executor.Options{
 		WorkingDir:                 req.WorkingDir,
 		Model:                      req.Model,
@@ -253,39 +836,59 @@ func (c *Client) ContinueTask(ctx context.Context, sessionID string, message str
 		Sandbox:                    req.Sandbox,
 		Env:                        req.Env,
 		AskForApproval:             req.AskForApproval,
+		ApprovalPolicy:             executor.ApprovalPolicy{AllowedTools: req.AllowedTools, Default: req.ApprovalDefault},
+		AllowedTools:               req.ClaudeAllowedTools,
+		DisallowedTools:            req.DisallowedTools,
+		ResumeSessionID:            resume.SessionID,
+		ResumePath:                 resume.Path,
+		ModelReasoningEffort:       effort,
+		MaxToolCalls:               req.MaxToolCalls,
 	}
 
-	switch req.Executor {
-	case executor.ExecutorClaudeCode:
-		if resume.ClaudeSessionID == "" {
-			return ErrResumeUnavailable
-		}
-		opts.ResumeSessionID = resume.ClaudeSessionID
-	case executor.ExecutorCodex:
-		if resume.CodexConversation == "" && resume.CodexRolloutPath == "" {
-			return ErrResumeUnavailable
-		}
-		opts.ResumeSessionID = resume.CodexConversation
-		opts.ResumePath = resume.CodexRolloutPath
-	default:
-		return fmt.Errorf("resume unsupported for executor %s", req.Executor)
-	}
-
-	exec, err := c.registry.CreateSession(sessionID, string(req.Executor), opts)
+	release, err := c.lockWorkingDir(ctx, req.WorkingDir)
 	if err != nil {
 		return err
 	}
-	if err := exec.Start(ctx, message, opts); err != nil {
-		_ = exec.Close()
-		c.registry.RemoveSession(sessionID)
+	exec, err := c.startSession(ctx, sessionID, string(req.Executor), message, opts)
+	if err != nil {
+		if release != nil {
+			release()
+		}
 		return err
 	}
-	go c.pipeSessionLogs(sessionID, string(req.Executor), exec)
+	releaseWhenFinished(c.runSession(sessionID, string(req.Executor), exec, opts), release)
 
+	if continueReq.WorkingDir != "" {
+		c.setSessionRequest(sessionID, req)
+	}
 	c.updateSessionStatus(sessionID, executor.SessionStatusRunning)
 	return nil
 }
 
+// sendTurn sends message to a live executor, with the per-turn overrides of
+// continueReq when the executor supports them.
+func sendTurn(ctx context.Context, exec executor.Executor, message string, continueReq executor.ContinueRequest) error {
+	turn := executor.TurnOptions{ModelReasoningEffort: continueReq.ModelReasoningEffort}
+	if sender, ok := exec.(executor.TurnSender); ok && turn != (executor.TurnOptions{}) {
+		return sender.SendTurn(ctx, message, turn)
+	}
+	return exec.SendMessage(ctx, message)
+}
+
+// resolveWorkingDir returns dir as an absolute path after checking that it is
+// an existing directory.
+func resolveWorkingDir(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidWorkingDir, dir)
+	}
+	info, err := os.Stat(abs)
+	if err != nil || !info.IsDir() {
+		return "", fmt.Errorf("%w: %s", ErrInvalidWorkingDir, dir)
+	}
+	return abs, nil
+}
+
 // ResumeTask is an alias for ContinueTask.
 func (c *Client) ResumeTask(ctx context.Context, sessionID string, message string) error {
 	return c.ContinueTask(ctx, sessionID, message)
@@ -300,6 +903,24 @@ func (c *Client) RespondControl(ctx context.Context, sessionID string, response
 	return exec.RespondControl(ctx, response)
 }
 
+// WaitContext blocks until the session's current run has finished and its
+// events are stored, or until ctx is done, in which case ctx.Err() is returned.
+func (c *Client) WaitContext(ctx context.Context, sessionID string) error {
+	c.sessionsMu.RLock()
+	finished, ok := c.finished[sessionID]
+	c.sessionsMu.RUnlock()
+	if !ok {
+		return executor.ErrSessionNotFound
+	}
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // SessionRunning reports whether a session is still active.
 func (c *Client) SessionRunning(sessionID string) bool {
 	_, ok := c.registry.GetSession(sessionID)
@@ -311,6 +932,12 @@ func (c *Client) ListEvents(ctx context.Context, sessionID string, afterSeq uint
 	return c.store.List(ctx, sessionID, store.ListOptions{AfterSeq: afterSeq, Limit: limit})
 }
 
+// ListEventsWithOptions reads persisted session events using the full set of
+// store list options (seq range, limit and type filter).
+func (c *Client) ListEventsWithOptions(ctx context.Context, sessionID string, opts store.ListOptions) ([]executor.Event, error) {
+	return c.store.List(ctx, sessionID, opts)
+}
+
 // GetSessionEvents returns stored events for a session.
 func (c *Client) GetSessionEvents(sessionID string) ([]executor.Event, bool) {
 	events, err := c.ListEvents(context.Background(), sessionID, 0, 0)
@@ -323,12 +950,107 @@ func (c *Client) GetSessionEvents(sessionID string) ([]executor.Event, bool) {
 	return events, true
 }
 
+// DeleteSession stops a session if it is running, ends live subscriptions
+// with an EventTypeDeleted event and removes the session's summary, stored
+// events and persisted record. It returns executor.ErrSessionNotFound for
+// unknown sessions, or ctx.Err() if ctx ends while waiting for the run to stop.
+func (c *Client) DeleteSession(ctx context.Context, sessionID string) error {
+	c.sessionsMu.RLock()
+	_, ok := c.sessions[sessionID]
+	finished := c.finished[sessionID]
+	c.sessionsMu.RUnlock()
+	if !ok {
+		return executor.ErrSessionNotFound
+	}
+
+	// Stop the run first so no later event recreates the deleted session.
+	if exec, running := c.registry.GetSession(sessionID); running {
+		_ = exec.Interrupt()
+		_ = exec.Close()
+	}
+	if finished != nil {
+		select {
+		case <-finished:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	c.stream.AppendLog(sessionID, streaming.LogEntry{
+		Type:    EventTypeDeleted,
+		Content: executor.Event{SessionID: sessionID, Type: EventTypeDeleted, Content: map[string]any{}},
+	})
+	c.stream.UnregisterSession(sessionID)
+
+	c.cancelPendingPersist(sessionID)
+	c.sessionsMu.Lock()
+	delete(c.sessions, sessionID)
+	delete(c.requests, sessionID)
+	delete(c.resumeInfo, sessionID)
+	delete(c.finished, sessionID)
+	c.sessionsMu.Unlock()
+
+	var errs []error
+	if deleter, ok := c.store.(store.SessionDeleter); ok {
+		errs = append(errs, deleter.DeleteSession(ctx, sessionID))
+	}
+	errs = append(errs, c.sessionStore.Delete(ctx, sessionID))
+	return errors.Join(errs...)
+}
+
+// GetSession returns the summary of one session, with EventCount taken from
+// the event store's latest seq. ok is false for unknown sessions.
+func (c *Client) GetSession(sessionID string) (executor.Session, bool) {
+	c.sessionsMu.RLock()
+	session, ok := c.sessions[sessionID]
+	c.sessionsMu.RUnlock()
+	if !ok {
+		return executor.Session{}, false
+	}
+
+	if seq, err := c.store.LatestSeq(context.Background(), sessionID); err == nil {
+		session.EventCount = seq
+	}
+	return session, true
+}
+
 // ListSessions returns all known sessions sorted by update time (desc).
-func (c *Client) ListSessions(_ context.Context) []executor.Session {
+func (c *Client) ListSessions(ctx context.Context) []executor.Session {
+	return c.ListSessionsWithOptions(ctx, ListSessionsOptions{})
+}
+
+// ListSessionsOptions filters ListSessionsWithOptions. Zero fields match
+// every session.
+type ListSessionsOptions struct {
+	// Kind keeps only sessions started with this ExecuteRequest.Kind.
+	Kind string
+	// Labels keeps only sessions carrying every one of these labels with
+	// the same value.
+	Labels map[string]string
+}
+
+// Match reports whether session passes the filter.
+func (o ListSessionsOptions) Match(session executor.Session) bool {
+	if o.Kind != "" && session.Kind != o.Kind {
+		return false
+	}
+	for key, value := range o.Labels {
+		if v, ok := session.Labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// ListSessionsWithOptions returns the sessions matching opts, most recently
+// updated first.
+func (c *Client) ListSessionsWithOptions(_ context.Context, opts ListSessionsOptions) []executor.Session {
 	c.sessionsMu.RLock()
 	list := make([]executor.Session, 0, len(c.sessions))
 	for _, session := range c.sessions {
-		list = append(list, session)
+		if opts.Match(session) {
+			list = append(list, session)
+		}
 	}
 	c.sessionsMu.RUnlock()
 
@@ -339,6 +1061,8 @@ func (c *Client) ListSessions(_ context.Context) []executor.Session {
 	return list
 }
 
+// touchSession updates the session summary for a stored event. Status
+// changes are persisted right away; other updates are debounced.
 func (c *Client) touchSession(sessionID string, evt executor.Event) {
 	status := executor.SessionStatusRunning
 	if evt.Type == "done" {
@@ -348,104 +1072,190 @@ func (c *Client) touchSession(sessionID string, evt executor.Event) {
 		evt.Timestamp = time.Now()
 	}
 	c.sessionsMu.Lock()
-	defer c.sessionsMu.Unlock()
-
 	session, ok := c.sessions[sessionID]
 	if !ok {
 		title := sessionID
 		if value, ok := evt.Content.(string); ok && value != "" {
 			title = truncateTitle(value, 36)
 		}
-		c.sessions[sessionID] = executor.Session{
+		session = executor.Session{
 			SessionID: sessionID,
 			Title:     title,
-			Status:    status,
 			Executor:  executor.ExecutorType(evt.Executor),
 			CreatedAt: evt.Timestamp,
-			UpdatedAt: evt.Timestamp,
 		}
-		return
+	}
+	statusChanged := session.Status != status
+	if status == executor.SessionStatusRunning {
+		// A resumed run replaces the metrics of the previous one when it ends.
+		session.Metrics = nil
+		session.Cost = nil
+		if statusChanged {
+			session.Result = ""
+		}
+	}
+	if text, ok := resultText(evt); ok {
+		session.Result = c.resultMode.combine(session.Result, text)
+	}
+	if evt.Type == "plan" {
+		if content, ok := transcriptContent(evt); ok && content.PlanSteps != nil {
+			session.Plan = slices.Clone(content.PlanSteps)
+		}
 	}
 	session.UpdatedAt = evt.Timestamp
+	session.LastEventType = evt.Type
 	if evt.Executor != "" {
 		session.Executor = executor.ExecutorType(evt.Executor)
 	}
 	session.Status = status
 	c.sessions[sessionID] = session
+	c.sessionsMu.Unlock()
+
+	if statusChanged {
+		c.persistSession(sessionID)
+	} else {
+		c.schedulePersist(sessionID)
+	}
 }
 
 func (c *Client) updateSessionStatus(sessionID string, status executor.SessionStatus) {
 	c.sessionsMu.Lock()
-	defer c.sessionsMu.Unlock()
-
 	session, ok := c.sessions[sessionID]
 	if !ok {
+		c.sessionsMu.Unlock()
 		return
 	}
 
 	session.Status = status
 	session.UpdatedAt = time.Now()
 	c.sessions[sessionID] = session
+	c.sessionsMu.Unlock()
+
+	c.persistSession(sessionID)
 }
 
 func (c *Client) upsertSession(session executor.Session) {
 	c.sessionsMu.Lock()
-	defer c.sessionsMu.Unlock()
-
 	c.sessions[session.SessionID] = session
+	c.sessionsMu.Unlock()
+
+	c.persistSession(session.SessionID)
 }
 
 func (c *Client) setSessionRequest(sessionID string, req executor.ExecuteRequest) {
 	c.sessionsMu.Lock()
-	defer c.sessionsMu.Unlock()
 	c.requests[sessionID] = req
+	c.sessionsMu.Unlock()
+
+	c.persistSession(sessionID)
 }
 
-func (c *Client) getSessionRuntime(sessionID string) (executor.ExecuteRequest, sessionResumeInfo, bool) {
+// persistSession saves the current summary, request and resume state of a
+// session to the SessionStore. Failures are logged and otherwise ignored.
+func (c *Client) persistSession(sessionID string) {
+	c.cancelPendingPersist(sessionID)
+
+	c.persistMu.Lock()
+	defer c.persistMu.Unlock()
+
+	c.sessionsMu.RLock()
+	session, ok := c.sessions[sessionID]
+	record := store.SessionRecord{Session: session, Request: c.requests[sessionID]}
+	resume, hasResume := c.resumeInfo[sessionID]
+	c.sessionsMu.RUnlock()
+	if !ok {
+		return
+	}
+
+	if hasResume && resume != (executor.ResumeState{}) {
+		data, err := json.Marshal(resume)
+		if err != nil {
+			log.Errorf("encode resume state failed: session=%s err=%v", sessionID, err)
+			return
+		}
+		record.Resume = data
+	}
+	if err := c.sessionStore.Save(context.Background(), record); err != nil {
+		log.Errorf("save session failed: session=%s err=%v", sessionID, err)
+	}
+}
+
+func (c *Client) getSessionRuntime(sessionID string) (executor.ExecuteRequest, executor.ResumeState, bool) {
 	c.sessionsMu.RLock()
 	defer c.sessionsMu.RUnlock()
 	req, ok := c.requests[sessionID]
 	if !ok {
-		return executor.ExecuteRequest{}, sessionResumeInfo{}, false
+		return executor.ExecuteRequest{}, executor.ResumeState{}, false
 	}
 	return req, c.resumeInfo[sessionID], true
 }
 
-func (c *Client) captureResumeState(sessionID, executorName string, logEntry executor.Log) {
-	c.sessionsMu.Lock()
-	defer c.sessionsMu.Unlock()
+// UpstreamSession identifies a session on the agent vendor's side, so
+// external tooling can correlate it with the vendor's own logs and
+// dashboards.
+type UpstreamSession struct {
+	// ID is the Claude or Droid session id, or the Codex conversation id.
+	ID string `json:"id"`
+	// RolloutPath is the Codex rollout file of the conversation, when known.
+	RolloutPath string `json:"rollout_path,omitempty"`
+}
 
+// ResumeState returns the upstream session id the executor reported for
+// sessionID. ok is false for unknown sessions and for sessions whose
+// executor has not reported an id (yet).
+func (c *Client) ResumeState(sessionID string) (UpstreamSession, bool) {
+	c.sessionsMu.RLock()
 	resume := c.resumeInfo[sessionID]
-	switch executorName {
-	case string(executor.ExecutorClaudeCode):
-		if obj, ok := decodeJSONObject(logEntry.Content); ok {
-			if sid, ok := obj["session_id"].(string); ok && sid != "" {
-				resume.ClaudeSessionID = sid
-			}
-		} else if text := executor.StringifyContent(logEntry.Content); text != "" {
-			if obj, ok := decodeJSONObjectFromLine(text); ok {
-				if sid, ok := obj["session_id"].(string); ok && sid != "" {
-					resume.ClaudeSessionID = sid
-				}
-			}
-		}
-	case string(executor.ExecutorCodex):
-		if obj, ok := decodeJSONObject(logEntry.Content); ok {
-			if result, ok := obj["result"].(map[string]any); ok {
-				if conv, ok := result["conversationId"].(string); ok && conv != "" {
-					resume.CodexConversation = conv
-				}
-				if rollout, ok := result["rolloutPath"].(string); ok && rollout != "" {
-					resume.CodexRolloutPath = rollout
-				}
-			}
-			if conv, ok := obj["conversationId"].(string); ok && conv != "" {
-				resume.CodexConversation = conv
-			}
-		}
+	c.sessionsMu.RUnlock()
+	if resume.SessionID == "" {
+		return UpstreamSession{}, false
+	}
+	return UpstreamSession{ID: resume.SessionID, RolloutPath: resume.Path}, true
+}
+
+// captureResumeState lets executors implementing executor.ResumeCapturer
+// update the session's resume state from logEntry.
+func (c *Client) captureResumeState(sessionID string, exec executor.Executor, logEntry executor.Log) {
+	capturer, ok := exec.(executor.ResumeCapturer)
+	if !ok {
+		return
 	}
 
+	c.sessionsMu.Lock()
+	prior := c.resumeInfo[sessionID]
+	resume := capturer.CaptureResume(logEntry, prior)
 	c.resumeInfo[sessionID] = resume
+	c.sessionsMu.Unlock()
+
+	if resume != prior {
+		c.persistSession(sessionID)
+	}
+}
+
+// captureExecutorVersion records the CLI version from logEntry on the session
+// for executors implementing executor.VersionCapturer.
+func (c *Client) captureExecutorVersion(sessionID string, exec executor.Executor, logEntry executor.Log) {
+	capturer, ok := exec.(executor.VersionCapturer)
+	if !ok {
+		return
+	}
+	version := capturer.CaptureVersion(logEntry)
+	if version == "" {
+		return
+	}
+
+	c.sessionsMu.Lock()
+	session, ok := c.sessions[sessionID]
+	changed := ok && session.ExecutorVersion != version
+	if changed {
+		session.ExecutorVersion = version
+		c.sessions[sessionID] = session
+	}
+	c.sessionsMu.Unlock()
+
+	if changed {
+		c.persistSession(sessionID)
+	}
 }
 
 func truncateTitle(text string, limit int) string {
@@ -462,37 +1272,6 @@ func truncateTitle(text string, limit int) string {
 	return string(runes[:limit])
 }
 
-func decodeJSONObject(v any) (map[string]any, bool) {
-	switch val := v.(type) {
-	case map[string]any:
-		return val, true
-	case json.RawMessage:
-		var out map[string]any
-		if err := json.Unmarshal(val, &out); err == nil {
-			return out, true
-		}
-	case []byte:
-		var out map[string]any
-		if err := json.Unmarshal(val, &out); err == nil {
-			return out, true
-		}
-	case string:
-		var out map[string]any
-		if err := json.Unmarshal([]byte(val), &out); err == nil {
-			return out, true
-		}
-	}
-	return nil, false
-}
-
-func decodeJSONObjectFromLine(line string) (map[string]any, bool) {
-	start := strings.Index(line, "{")
-	if start < 0 {
-		return nil, false
-	}
-	return decodeJSONObject(line[start:])
-}
-
 // Subscribe streams session events via channel.
 func (c *Client) Subscribe(sessionID string, opts executor.SubscribeOptions) (<-chan executor.Event, func()) {
 	out := make(chan executor.Event, 100)
@@ -507,10 +1286,28 @@ func (c *Client) Subscribe(sessionID string, opts executor.SubscribeOptions) (<-
 		barrierSeq, _ := c.store.LatestSeq(context.Background(), sessionID)
 		lastEmittedSeq := opts.AfterSeq
 
+		filter := store.ListOptions{Types: opts.Categories}
+		view, _ := c.view(opts.View)
+		var tools *toolCoalescer
+		if opts.CoalesceTools {
+			tools = newToolCoalescer()
+		}
 		emit := func(evt executor.Event) bool {
 			if evt.Type == "debug" && !opts.IncludeDebug {
 				return true
 			}
+			if !filter.MatchType(evt.Type) {
+				return true
+			}
+			if tools != nil {
+				var keep bool
+				if evt, keep = tools.filter(evt); !keep {
+					return true
+				}
+			}
+			if view != nil {
+				evt = view(evt)
+			}
 			select {
 			case out <- evt:
 				if evt.Seq > lastEmittedSeq {
@@ -527,6 +1324,7 @@ func (c *Client) Subscribe(sessionID string, opts executor.SubscribeOptions) (<-
 				AfterSeq: opts.AfterSeq,
 				UntilSeq: barrierSeq,
 				Limit:    opts.Limit,
+				Types:    opts.Categories,
 			})
 			if err != nil {
 				if c.hooks.OnStoreError != nil {
@@ -548,14 +1346,51 @@ func (c *Client) Subscribe(sessionID string, opts executor.SubscribeOptions) (<-
 			return
 		}
 
+		// With CoalesceProgress, the latest progress event waits in pending
+		// until the window ends or another event type arrives.
+		var pending *executor.Event
+		var window *time.Timer
+		var windowC <-chan time.Time
+		defer func() {
+			if window != nil {
+				window.Stop()
+			}
+		}()
+		flush := func() bool {
+			if windowC != nil && !window.Stop() {
+				select {
+				case <-window.C:
+				default:
+				}
+			}
+			windowC = nil
+			if pending == nil {
+				return true
+			}
+			evt := *pending
+			pending = nil
+			return emit(evt)
+		}
+
 		for {
 			select {
 			case entry, ok := <-newLogs:
 				if !ok {
-					_ = emit(executor.Event{SessionID: sessionID, Type: "done", Content: map[string]any{}})
+					if flush() {
+						_ = emit(executor.Event{SessionID: sessionID, Type: "done", Content: map[string]any{}})
+					}
 					return
 				}
 
+				if entry.Type == streaming.LagEntryType {
+					// The stream dropped events while out was full; replay
+					// them from the store instead of leaving a gap.
+					if !flush() || !c.replayMissed(sessionID, lastEmittedSeq, filter, emit) {
+						return
+					}
+					continue
+				}
+
 				evt, ok := entry.Content.(executor.Event)
 				if !ok {
 					evt = executor.Event{SessionID: sessionID, Type: entry.Type, Content: entry.Content}
@@ -563,10 +1398,26 @@ func (c *Client) Subscribe(sessionID string, opts executor.SubscribeOptions) (<-
 				if evt.Seq > 0 && evt.Seq <= lastEmittedSeq {
 					continue
 				}
-				if !emit(evt) {
+				if opts.CoalesceProgress > 0 && evt.Type == "progress" {
+					pending = &evt
+					if windowC == nil {
+						if window == nil {
+							window = time.NewTimer(opts.CoalesceProgress)
+						} else {
+							window.Reset(opts.CoalesceProgress)
+						}
+						windowC = window.C
+					}
+					continue
+				}
+				if !flush() || !emit(evt) {
+					return
+				}
+				if evt.Type == "done" || evt.Type == EventTypeDeleted {
 					return
 				}
-				if evt.Type == "done" {
+			case <-windowC:
+				if !flush() {
 					return
 				}
 			case <-stop:
@@ -584,24 +1435,174 @@ func (c *Client) Subscribe(sessionID string, opts executor.SubscribeOptions) (<-
 	return out, cancel
 }
 
-// Shutdown closes all active sessions.
+// DefaultShutdownDrainTimeout is how long Shutdown waits for interrupted
+// sessions to finish before force-closing them.
+const DefaultShutdownDrainTimeout = 3 * time.Second
+
+// replayMissed emits the stored events after afterSeq once a live
+// subscription fell behind and the stream started dropping its entries. When
+// the store cannot list them, a lag event is emitted so the subscriber knows
+// about the gap. It returns false when the subscription should end.
+func (c *Client) replayMissed(sessionID string, afterSeq uint64, filter store.ListOptions, emit func(executor.Event) bool) bool {
+	missed, err := c.store.List(context.Background(), sessionID, store.ListOptions{AfterSeq: afterSeq, Types: filter.Types})
+	if err != nil {
+		log.Warningf("replay missed events failed: session=%s err=%v", sessionID, err)
+		return emit(executor.Event{SessionID: sessionID, Type: streaming.LagEntryType, Content: map[string]any{}})
+	}
+	for _, evt := range missed {
+		if !emit(evt) {
+			return false
+		}
+		if evt.Type == "done" || evt.Type == EventTypeDeleted {
+			return false
+		}
+	}
+	return true
+}
+
+// ShutdownReport counts how active sessions ended during ShutdownContext.
+type ShutdownReport struct {
+	// Drained sessions finished on their own and had all events stored.
+	Drained int `json:"drained"`
+	// ForceClosed sessions were still running when ctx was done.
+	ForceClosed int `json:"force_closed"`
+}
+
+// ErrSessionsNotStopped is returned by Close when force-closed sessions did
+// not finish within executor.DefaultShutdownTimeout.
+var ErrSessionsNotStopped = errors.New("sessions did not stop after being closed")
+
+// Shutdown drains active sessions for up to DefaultShutdownDrainTimeout and
+// then closes the rest. It is the fire-and-forget form of Close: errors are
+// logged, not returned. See ShutdownContext.
 func (c *Client) Shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultShutdownDrainTimeout)
+	defer cancel()
+	report := c.ShutdownContext(ctx)
+	if report.Drained+report.ForceClosed > 0 {
+		log.Infof("sdk shutdown: drained=%d force_closed=%d", report.Drained, report.ForceClosed)
+	}
+}
+
+// ShutdownContext interrupts every active session and waits until each has
+// finished and stored its final events, or until ctx is done. Sessions still
+// running then are force-closed. The event store is closed last.
+func (c *Client) ShutdownContext(ctx context.Context) ShutdownReport {
+	report, err := c.shutdown(ctx)
+	if err != nil {
+		log.Warningf("sdk shutdown: %v", err)
+	}
+	return report
+}
+
+// Close tears the client down deterministically: it interrupts every active
+// session, waits until each has finished and stored its final events or ctx
+// is done, force-closes the rest and waits for them to end too, then closes
+// the event store. When Close returns, every session started through the
+// client is terminal. The error joins ctx.Err() when sessions had to be
+// force-closed, ErrSessionsNotStopped and the event store's Close error.
+func (c *Client) Close(ctx context.Context) error {
+	report, err := c.shutdown(ctx)
+	if report.ForceClosed > 0 {
+		err = errors.Join(fmt.Errorf("%d sessions force-closed: %w", report.ForceClosed, ctx.Err()), err)
+	}
+	return err
+}
+
+// shutdown implements ShutdownContext and Close.
+func (c *Client) shutdown(ctx context.Context) (ShutdownReport, error) {
+	var report ShutdownReport
+	var pending []<-chan struct{}
+	for _, sessionID := range c.registry.SessionIDs() {
+		exec, ok := c.registry.GetSession(sessionID)
+		if !ok {
+			continue
+		}
+		_ = exec.Interrupt()
+
+		c.sessionsMu.RLock()
+		finished, ok := c.finished[sessionID]
+		c.sessionsMu.RUnlock()
+		if ok {
+			pending = append(pending, finished)
+		} else {
+			// Not started through this client, so there is nothing to drain.
+			report.ForceClosed++
+		}
+	}
+
+	var forced []<-chan struct{}
+	for _, finished := range pending {
+		select {
+		case <-finished:
+			report.Drained++
+		case <-ctx.Done():
+			select {
+			case <-finished:
+				report.Drained++
+			default:
+				report.ForceClosed++
+				forced = append(forced, finished)
+			}
+		}
+	}
+
 	c.registry.ShutdownAll()
-	if closer, ok := c.store.(storeCloser); ok {
-		closer.Close()
+
+	// Closed executors end their logs; wait for their sessions to record
+	// that before the store goes away.
+	var errs []error
+	waitCtx, cancel := context.WithTimeout(context.Background(), executor.DefaultShutdownTimeout)
+	defer cancel()
+	stuck := 0
+	for _, finished := range forced {
+		select {
+		case <-finished:
+		case <-waitCtx.Done():
+			stuck++
+		}
+	}
+	if stuck > 0 {
+		errs = append(errs, fmt.Errorf("%w: %d", ErrSessionsNotStopped, stuck))
+	}
+
+	c.flushPendingPersists()
+	if err := c.store.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("close event store: %w", err))
 	}
+	return report, errors.Join(errs...)
 }
 
-type ExecutorMeta struct {
-	Name string `json:"name"`
+// ExecutorInfo describes a registered executor and the optional operations it
+// supports.
+type ExecutorInfo struct {
+	Name                string `json:"name"`
+	SupportsResume      bool   `json:"supports_resume"`
+	SupportsInteractive bool   `json:"supports_interactive"`
+	SupportsControl     bool   `json:"supports_control"`
 }
 
-// Executors returns a list of meta information for all registered executors.
-func (c *Client) Executors() []ExecutorMeta {
-	names := c.registry.Executors()
-	meta := make([]ExecutorMeta, 0, len(names))
+// ExecutorMeta is the previous name of ExecutorInfo.
+//
+// Deprecated: use ExecutorInfo.
+type ExecutorMeta = ExecutorInfo
+
+// Executors returns information about all registered executors sessions may
+// use, sorted by name.
+func (c *Client) Executors() []ExecutorInfo {
+	names := c.registry.List()
+	infos := make([]ExecutorInfo, 0, len(names))
 	for _, name := range names {
-		meta = append(meta, ExecutorMeta{Name: name})
+		if !c.executorAllowed(executor.ExecutorType(name)) {
+			continue
+		}
+		info := ExecutorInfo{Name: name}
+		if caps, err := c.registry.Capabilities(name); err == nil {
+			info.SupportsResume = caps.Resume
+			info.SupportsInteractive = caps.Interactive
+			info.SupportsControl = caps.Control
+		}
+		infos = append(infos, info)
 	}
-	return meta
+	return infos
 }
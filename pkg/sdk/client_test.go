@@ -2,13 +2,27 @@ package sdk
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/supremeagent/executor/pkg/executor"
+	"github.com/supremeagent/executor/pkg/executor/claude"
+	"github.com/supremeagent/executor/pkg/executor/codex"
+	"github.com/supremeagent/executor/pkg/executor/droid"
+	"github.com/supremeagent/executor/pkg/pricing"
 	"github.com/supremeagent/executor/pkg/store"
 	"github.com/supremeagent/executor/pkg/streaming"
 )
@@ -137,6 +151,76 @@ func TestExecuteValidation(t *testing.T) {
 	}
 }
 
+func TestExecuteRejectsInvalidOptions(t *testing.T) {
+	registry := executor.NewRegistry()
+	registry.Register(string(executor.ExecutorCodex), executor.FactoryFunc(func() (executor.Executor, error) {
+		return codex.NewClient(), nil
+	}))
+	registry.Register(string(executor.ExecutorClaudeCode), executor.FactoryFunc(func() (executor.Executor, error) {
+		return &testExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}, nil
+	}))
+	client := NewWithRegistry(registry, streaming.NewManager())
+
+	_, err := client.Execute(context.Background(), executor.ExecuteRequest{
+		Prompt:   "hello",
+		Executor: executor.ExecutorCodex,
+		Sandbox:  "everything",
+	})
+	if !errors.Is(err, executor.ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption, got %v", err)
+	}
+	if len(client.ListSessions(context.Background())) != 0 {
+		t.Fatal("expected rejected request to leave no session behind")
+	}
+
+	// Claude has no sandbox option, so the value is ignored.
+	if _, err := client.Execute(context.Background(), executor.ExecuteRequest{
+		Prompt:   "hello",
+		Executor: executor.ExecutorClaudeCode,
+		Sandbox:  "everything",
+	}); err != nil {
+		t.Fatalf("expected claude to ignore sandbox, got %v", err)
+	}
+}
+
+func TestExecuteRetriesTransientStartErrors(t *testing.T) {
+	newClient := func(startErr error, failures int) (*Client, *atomic.Int32) {
+		var attempts atomic.Int32
+		registry := executor.NewRegistry()
+		registry.Register("flaky", executor.FactoryFunc(func() (executor.Executor, error) {
+			exec := &failingStartExecutor{testExecutor: testExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}}
+			if int(attempts.Add(1)) <= failures {
+				exec.startErr = startErr
+			}
+			return exec, nil
+		}))
+		return NewWithOptions(ClientOptions{
+			Registry:        registry,
+			StreamManager:   streaming.NewManager(),
+			EventStore:      store.NewMemoryEventStore(),
+			StartRetries:    3,
+			StartRetryDelay: time.Millisecond,
+		}), &attempts
+	}
+
+	missing := &os.PathError{Op: "fork/exec", Path: "/usr/bin/codex", Err: os.ErrNotExist}
+	client, attempts := newClient(missing, 10)
+	if _, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hi", Executor: "flaky"}); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected ENOENT error, got %v", err)
+	}
+	if attempts.Load() != 1 {
+		t.Fatalf("expected missing binary not to be retried, got %d attempts", attempts.Load())
+	}
+
+	client, attempts = newClient(errors.New("npm ERR! network ECONNRESET"), 2)
+	if _, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hi", Executor: "flaky"}); err != nil {
+		t.Fatalf("expected transient error to be retried, got %v", err)
+	}
+	if attempts.Load() != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts.Load())
+	}
+}
+
 func TestListSessions(t *testing.T) {
 	registry := executor.NewRegistry()
 	streamMgr := streaming.NewManager()
@@ -145,295 +229,3018 @@ func TestListSessions(t *testing.T) {
 	mock := &testExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
 	registry.Register("test", executor.FactoryFunc(func() (executor.Executor, error) { return mock, nil }))
 
-	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "list sessions", Executor: "test"})
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "list sessions", Executor: "test"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	sessions := client.ListSessions(context.Background())
+	if len(sessions) != 1 {
+		t.Fatalf("expected 1 session, got %d", len(sessions))
+	}
+
+	session := sessions[0]
+	if session.SessionID != resp.SessionID {
+		t.Fatalf("unexpected session id: %s", session.SessionID)
+	}
+	if session.Status != executor.SessionStatusDone {
+		t.Fatalf("expected done status, got %s", session.Status)
+	}
+	if session.Title != "list sessions" {
+		t.Fatalf("unexpected session title: %q", session.Title)
+	}
+	if session.Executor != "test" {
+		t.Fatalf("unexpected executor: %s", session.Executor)
+	}
+}
+
+func TestExecuteStoresSessionStartedEvent(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register("custom", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hi", Executor: "custom"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	// Before the executor logs anything, history and live tail already see the start event.
+	ch, cancel := client.Subscribe(resp.SessionID, executor.SubscribeOptions{ReturnAll: true})
+	defer cancel()
+	select {
+	case evt := <-ch:
+		content, ok := evt.Content.(executor.UnifiedContent)
+		if evt.Seq != 1 || evt.Type != "progress" || !ok || content.SourceType != SessionStartedSourceType || content.Phase != "started" {
+			t.Fatalf("expected seq 1 to be the session-started event, got %+v", evt)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the start event before any executor output")
+	}
+
+	exec.logs <- executor.Log{Type: "stdout", Content: "first output"}
+	exec.logs <- executor.Log{Type: "done", Content: "done"}
+	_ = exec.Close()
+	_ = client.WaitContext(context.Background(), resp.SessionID)
+
+	events, _ := client.ListEvents(context.Background(), resp.SessionID, 0, 0)
+	if len(events) != 3 || events[0].Seq != 1 || events[1].Content != "first output" {
+		t.Fatalf("expected start event followed by executor output, got %+v", events)
+	}
+}
+
+func TestBenignStderrPatternsPassedToExecutor(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &resumeExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
+	registry.Register("custom", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	client := NewWithOptions(ClientOptions{Registry: registry, BenignStderrPatterns: []string{"Loaded cached credentials"}})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hi", Executor: "custom"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	_ = client.WaitContext(context.Background(), resp.SessionID)
+	if got := exec.startOpts.ClassifyStderr("Loaded cached credentials."); got != "debug" {
+		t.Fatalf("expected client patterns in executor options, got %q for %v", got, exec.startOpts.BenignStderrPatterns)
+	}
+}
+
+func TestGetSession(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register("custom", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "one session", Executor: "custom"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	exec.logs <- executor.Log{Type: "stdout", Content: "working"}
+	exec.logs <- executor.Log{Type: "done", Content: "finished"}
+	_ = exec.Close()
+	_ = client.WaitContext(context.Background(), resp.SessionID)
+
+	session, ok := client.GetSession(resp.SessionID)
+	if !ok {
+		t.Fatal("expected session to be found")
+	}
+	if session.Title != "one session" || session.Status != executor.SessionStatusDone {
+		t.Fatalf("unexpected session summary: %+v", session)
+	}
+	if session.EventCount != 3 || session.LastEventType != "done" {
+		t.Fatalf("expected 3 events ending with done, got count=%d last=%q", session.EventCount, session.LastEventType)
+	}
+
+	if _, ok := client.GetSession("missing"); ok {
+		t.Fatal("expected unknown session to be reported missing")
+	}
+}
+
+func TestSessionMetrics(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register("custom", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "measure me", Executor: "custom"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if _, err := client.SessionMetrics(resp.SessionID); !errors.Is(err, ErrMetricsUnavailable) {
+		t.Fatalf("expected metrics to be unavailable while running, got %v", err)
+	}
+
+	tool := func(phase string) executor.Log {
+		return executor.Log{Type: "tool", Content: executor.UnifiedContent{Category: "tool", ToolName: "bash", Phase: phase}}
+	}
+	exec.logs <- executor.Log{Type: "message", Content: executor.UnifiedContent{Category: "message", Text: "looking"}}
+	exec.logs <- tool("started")
+	exec.logs <- tool("completed")
+	exec.logs <- executor.Log{Type: "approval", Content: executor.UnifiedContent{Category: "approval", RequestID: "req-1"}}
+	exec.logs <- tool("started")
+	exec.logs <- tool("failed")
+	time.Sleep(20 * time.Millisecond)
+	exec.logs <- executor.Log{Type: "done", Content: "finished"}
+	_ = client.WaitContext(context.Background(), resp.SessionID)
+
+	metrics, err := client.SessionMetrics(resp.SessionID)
+	if err != nil {
+		t.Fatalf("expected metrics after the session ended, got %v", err)
+	}
+	if metrics.Events != 8 || metrics.ToolCalls != 2 || metrics.Approvals != 1 {
+		t.Fatalf("unexpected counts: %+v", metrics)
+	}
+	wantCategories := map[string]int{"progress": 1, "message": 1, "tool": 4, "approval": 1, "done": 1}
+	if !reflect.DeepEqual(metrics.Categories, wantCategories) {
+		t.Fatalf("expected categories %v, got %v", wantCategories, metrics.Categories)
+	}
+	if metrics.Duration < 20*time.Millisecond || metrics.Duration > time.Second {
+		t.Fatalf("expected the duration to span the run, got %s", metrics.Duration)
+	}
+	if session, _ := client.GetSession(resp.SessionID); session.Metrics == nil || session.Metrics.Events != 8 {
+		t.Fatalf("expected metrics on the session summary, got %+v", session.Metrics)
+	}
+
+	if _, err := client.SessionMetrics("missing"); !errors.Is(err, executor.ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestSessionTools(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register("custom", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "use tools", Executor: "custom"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	tool := func(name, phase string) executor.Log {
+		return executor.Log{Type: "tool", Content: executor.UnifiedContent{Category: "tool", ToolName: name, Phase: phase}}
+	}
+	exec.logs <- tool("read", "started")
+	exec.logs <- tool("read", "completed")
+	exec.logs <- tool("bash", "started")
+	exec.logs <- executor.Log{Type: "approval", Content: executor.UnifiedContent{Category: "approval", ToolName: "write"}}
+	exec.logs <- tool("", "completed")
+	exec.logs <- tool("edit", "started")
+	exec.logs <- tool("bash", "failed")
+	exec.logs <- executor.Log{Type: "done", Content: "finished"}
+	_ = client.WaitContext(context.Background(), resp.SessionID)
+
+	tools, err := client.SessionTools(context.Background(), resp.SessionID)
+	if err != nil {
+		t.Fatalf("session tools failed: %v", err)
+	}
+	if want := []string{"bash", "edit", "read"}; !reflect.DeepEqual(tools, want) {
+		t.Fatalf("expected %v, got %v", want, tools)
+	}
+	if _, err := client.SessionTools(context.Background(), "missing"); !errors.Is(err, executor.ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestPanickingTransformerFallsBack(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register(string(executor.ExecutorClaudeCode), executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+
+	var mu sync.Mutex
+	var failures []any
+	client := NewWithOptions(ClientOptions{
+		Registry:      registry,
+		StreamManager: streaming.NewManager(),
+		EventStore:    store.NewMemoryEventStore(),
+		Transformers: map[string]executor.EventTransformer{
+			string(executor.ExecutorClaudeCode): func(input executor.TransformInput) executor.Event {
+				if input.Log.Type == "stdout" {
+					panic("bad transformer")
+				}
+				return claude.EventTransformer(input)
+			},
+		},
+		TransformerChains: map[string][]executor.EventTransformer{
+			string(executor.ExecutorClaudeCode): {func(input executor.TransformInput) executor.Event {
+				if input.Log.Type == "done" {
+					panic("bad stage")
+				}
+				return executor.Event{Type: input.Log.Type, Content: input.Log.Content}
+			}},
+		},
+		Hooks: executor.Hooks{OnTransformError: func(ctx context.Context, sessionID string, log executor.Log, recovered any) {
+			mu.Lock()
+			failures = append(failures, recovered)
+			mu.Unlock()
+		}},
+	})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: executor.ExecutorClaudeCode})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	exec.logs <- executor.Log{Type: "stdout", Content: "still here"}
+	exec.logs <- executor.Log{Type: "done", Content: "Claude execution finished"}
+	_ = client.WaitContext(context.Background(), resp.SessionID)
+
+	if status := sessionStatus(client, resp.SessionID); status != executor.SessionStatusDone {
+		t.Fatalf("expected the session to complete, got %s", status)
+	}
+	mu.Lock()
+	got := slices.Clone(failures)
+	mu.Unlock()
+	if !reflect.DeepEqual(got, []any{"bad transformer", "bad stage"}) {
+		t.Fatalf("expected the hook to report both panics, got %v", got)
+	}
+	events, _ := client.ListEventsWithOptions(context.Background(), resp.SessionID, store.ListOptions{Types: []string{"message"}})
+	if len(events) != 1 || !events[0].Normalized {
+		t.Fatalf("expected the built-in transformer to normalize the log, got %+v", events)
+	}
+	if content, _ := transcriptContent(events[0]); content.Text != "still here" {
+		t.Fatalf("expected the log text, got %+v", content)
+	}
+}
+
+func TestNilLogsChannelFailsSession(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &nilLogsExecutor{blockingExecutor: &blockingExecutor{logs: make(chan executor.Log)}}
+	registry.Register("custom", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: "custom"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := client.WaitContext(ctx, resp.SessionID); err != nil {
+		t.Fatalf("expected the session to end instead of hanging: %v", err)
+	}
+
+	if status := sessionStatus(client, resp.SessionID); status != executor.SessionStatusFailed {
+		t.Fatalf("expected failed status, got %s", status)
+	}
+	if !exec.closed.Load() {
+		t.Fatal("expected the executor to be closed")
+	}
+	events, _ := client.ListEventsWithOptions(context.Background(), resp.SessionID, store.ListOptions{Types: []string{"error"}})
+	if len(events) != 1 {
+		t.Fatalf("expected one error event, got %+v", events)
+	}
+	if content, _ := transcriptContent(events[0]); content.SourceType != NoLogsSourceType {
+		t.Fatalf("expected a no_logs error, got %+v", content)
+	}
+
+	retransformed, err := client.Retransform(context.Background(), resp.SessionID, func(input executor.TransformInput) executor.Event {
+		return executor.Event{Type: "custom", Content: executor.UnifiedContent{SourceType: input.Log.Type, Raw: input.Log.Content}}
+	})
+	if err != nil {
+		t.Fatalf("retransform failed: %v", err)
+	}
+	last := retransformed[len(retransformed)-1]
+	if content, _ := transcriptContent(last); last.Type != "error" || content.SourceType != NoLogsSourceType {
+		t.Fatalf("expected the no_logs error to be kept, got %#v", last)
+	}
+}
+
+func TestSessionCost(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register("custom", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	client := NewWithOptions(ClientOptions{
+		Registry:      registry,
+		StreamManager: streaming.NewManager(),
+		EventStore:    store.NewMemoryEventStore(),
+		PriceTable:    pricing.PriceTable{"house-model": {Input: 2, Output: 8}},
+	})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "price me", Executor: "custom", Model: "house-model"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	usage := func(u ...executor.TokenUsage) executor.Log {
+		return executor.Log{Type: "progress", Content: executor.UnifiedContent{Category: "progress", Usage: u}}
+	}
+	exec.logs <- usage(executor.TokenUsage{InputTokens: 500_000, OutputTokens: 100_000})
+	waitFor(t, func() bool {
+		cost, _ := client.SessionCost(resp.SessionID)
+		return cost.InputTokens == 500_000
+	})
+	if cost, _ := client.SessionCost(resp.SessionID); math.Abs(cost.USD-1.8) > 1e-9 || cost.Unpriced {
+		t.Fatalf("expected a live $1.80 estimate, got %+v", cost)
+	}
+
+	exec.logs <- usage(executor.TokenUsage{Model: "mystery-1", OutputTokens: 1000})
+	exec.logs <- executor.Log{Type: "done", Content: "finished"}
+	_ = client.WaitContext(context.Background(), resp.SessionID)
+
+	session, _ := client.GetSession(resp.SessionID)
+	if session.Cost == nil || math.Abs(session.Cost.USD-1.8) > 1e-9 || session.Cost.OutputTokens != 101_000 {
+		t.Fatalf("expected the cost on the session summary, got %+v", session.Cost)
+	}
+	if !session.Cost.Unpriced || !reflect.DeepEqual(session.Cost.UnknownModels, []string{"mystery-1"}) {
+		t.Fatalf("expected the unknown model to be flagged, got %+v", session.Cost)
+	}
+	if cost, ok := client.SessionCost(resp.SessionID); !ok || !reflect.DeepEqual(*session.Cost, cost) {
+		t.Fatalf("expected the recorded cost once the session ended, got %+v %v", cost, ok)
+	}
+	if _, ok := client.SessionCost("missing"); ok {
+		t.Fatal("expected no cost for an unknown session")
+	}
+
+	quiet := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register("quiet", executor.FactoryFunc(func() (executor.Executor, error) { return quiet, nil }))
+	silent, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "no usage", Executor: "quiet"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if _, ok := client.SessionCost(silent.SessionID); ok {
+		t.Fatal("expected no cost while a session has reported no usage")
+	}
+	quiet.logs <- executor.Log{Type: "done", Content: "finished"}
+	_ = client.WaitContext(context.Background(), silent.SessionID)
+	if _, ok := client.SessionCost(silent.SessionID); ok {
+		t.Fatal("expected no cost for a session that reported no usage")
+	}
+}
+
+func TestAuthFailureMarksSessionFailed(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register(string(executor.ExecutorClaudeCode), executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: executor.ExecutorClaudeCode})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	exec.logs <- executor.Log{Type: "error", Content: "Invalid API key · Please run /login"}
+	exec.logs <- executor.Log{Type: "done", Content: "Claude execution finished"}
+	_ = client.WaitContext(context.Background(), resp.SessionID)
+
+	if status := sessionStatus(client, resp.SessionID); status != executor.SessionStatusFailed {
+		t.Fatalf("expected failed status, got %s", status)
+	}
+	events, _ := client.ListEventsWithOptions(context.Background(), resp.SessionID, store.ListOptions{Types: []string{"error"}})
+	if len(events) != 1 {
+		t.Fatalf("expected one error event, got %+v", events)
+	}
+	if content, _ := transcriptContent(events[0]); content.ErrorKind != executor.ErrorKindAuth {
+		t.Fatalf("expected an auth error event, got %+v", content)
+	}
+}
+
+func TestAuthFailureFollowedByWorkEndsDone(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register(string(executor.ExecutorClaudeCode), executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: executor.ExecutorClaudeCode})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	// An MCP server that failed to authenticate does not stop the agent.
+	exec.logs <- executor.Log{Type: "error", Content: "API Error: 401 from the tracker MCP server; not logged in"}
+	exec.logs <- executor.Log{Type: "stdout", Content: map[string]any{
+		"type":    "assistant",
+		"message": map[string]any{"content": []any{map[string]any{"type": "text", "text": "Done without the tracker."}}},
+	}}
+	exec.logs <- executor.Log{Type: "done", Content: "Claude execution finished"}
+	_ = client.WaitContext(context.Background(), resp.SessionID)
+
+	if status := sessionStatus(client, resp.SessionID); status != executor.SessionStatusDone {
+		t.Fatalf("expected done status, got %s", status)
+	}
+}
+
+func TestPlanEventsUpdateSession(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register("custom", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "plan it", Executor: "custom"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	plan := func(steps ...executor.PlanStep) executor.Log {
+		return executor.Log{Type: "plan", Content: executor.UnifiedContent{Category: "plan", PlanSteps: steps}}
+	}
+	exec.logs <- plan(
+		executor.PlanStep{Content: "Read the code", Status: executor.PlanStepInProgress},
+		executor.PlanStep{Content: "Write the fix", Status: executor.PlanStepPending},
+	)
+	exec.logs <- executor.Log{Type: "stdout", Content: "reading"}
+	latest := []executor.PlanStep{
+		{Content: "Read the code", Status: executor.PlanStepCompleted},
+		{Content: "Write the fix", Status: executor.PlanStepInProgress},
+		{Content: "Run tests", Status: executor.PlanStepPending},
+	}
+	// A plan written by a tool counts as a tool call, and its result
+	// without steps keeps the plan.
+	exec.logs <- executor.Log{Type: "plan", Content: executor.UnifiedContent{Category: "plan", Phase: "started", ToolName: "TodoWrite", ToolCallID: "toolu_1", PlanSteps: latest}}
+	exec.logs <- executor.Log{Type: "plan", Content: executor.UnifiedContent{Category: "plan", Phase: "completed", ToolName: "TodoWrite", ToolCallID: "toolu_1"}}
+	exec.logs <- executor.Log{Type: "done", Content: "finished"}
+	_ = client.WaitContext(context.Background(), resp.SessionID)
+
+	session, _ := client.GetSession(resp.SessionID)
+	if !reflect.DeepEqual(session.Plan, latest) {
+		t.Fatalf("expected the session to hold the newest plan, got %+v", session.Plan)
+	}
+	if session.Metrics == nil || session.Metrics.ToolCalls != 1 {
+		t.Fatalf("expected the TodoWrite plan to count as one tool call, got %+v", session.Metrics)
+	}
+}
+
+func TestAllowedExecutors(t *testing.T) {
+	registry := executor.NewRegistry()
+	for _, name := range []string{"allowed", "blocked"} {
+		registry.Register(name, executor.FactoryFunc(func() (executor.Executor, error) {
+			return &blockingExecutor{logs: make(chan executor.Log, 10)}, nil
+		}))
+	}
+	client := NewWithOptions(ClientOptions{
+		Registry:         registry,
+		StreamManager:    streaming.NewManager(),
+		EventStore:       store.NewMemoryEventStore(),
+		AllowedExecutors: []executor.ExecutorType{"allowed"},
+	})
+
+	_, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: "blocked"})
+	var notAllowed *ExecutorNotAllowedError
+	if !errors.As(err, &notAllowed) || notAllowed.Executor != "blocked" || !errors.Is(err, ErrExecutorNotAllowed) {
+		t.Fatalf("expected an ExecutorNotAllowedError, got %v", err)
+	}
+	if _, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: "allowed"}); err != nil {
+		t.Fatalf("expected the allowed executor to start, got %v", err)
+	}
+	if infos := client.Executors(); len(infos) != 1 || infos[0].Name != "allowed" {
+		t.Fatalf("expected only the allowed executor to be listed, got %+v", infos)
+	}
+	if _, err := client.Models(context.Background(), "blocked"); !errors.Is(err, ErrExecutorNotAllowed) {
+		t.Fatalf("expected listing a blocked executor's models to fail, got %v", err)
+	}
+}
+
+func TestEventIDGenerator(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register("custom", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	client := NewWithOptions(ClientOptions{
+		Registry:         registry,
+		StreamManager:    streaming.NewManager(),
+		EventStore:       store.NewMemoryEventStore(),
+		EventIDGenerator: uuid.NewString,
+	})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: "custom"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	events, unsubscribe := client.Subscribe(resp.SessionID, executor.SubscribeOptions{ReturnAll: true})
+	defer unsubscribe()
+	exec.logs <- executor.Log{Type: "stdout", Content: "one"}
+	exec.logs <- executor.Log{Type: "stdout", Content: "two"}
+	exec.logs <- executor.Log{Type: "done", Content: "finished"}
+	_ = client.WaitContext(context.Background(), resp.SessionID)
+
+	stored, err := client.ListEvents(context.Background(), resp.SessionID, 0, 0)
+	if err != nil || len(stored) < 4 {
+		t.Fatalf("expected the session's events, got %d (%v)", len(stored), err)
+	}
+	seen := make(map[string]bool)
+	for _, evt := range stored {
+		if evt.EventID == "" || seen[evt.EventID] {
+			t.Fatalf("expected a unique event id on every event, got %q at seq %d", evt.EventID, evt.Seq)
+		}
+		seen[evt.EventID] = true
+	}
+	for evt := range events {
+		if !seen[evt.EventID] {
+			t.Fatalf("expected subscribers to see the stored event id, got %q", evt.EventID)
+		}
+	}
+}
+
+func TestExecuteSessionID(t *testing.T) {
+	newClient := func(opts ClientOptions) *Client {
+		registry := executor.NewRegistry()
+		registry.Register("custom", executor.FactoryFunc(func() (executor.Executor, error) {
+			return &blockingExecutor{logs: make(chan executor.Log, 10)}, nil
+		}))
+		opts.Registry = registry
+		opts.StreamManager = streaming.NewManager()
+		opts.EventStore = store.NewMemoryEventStore()
+		return NewWithOptions(opts)
+	}
+
+	t.Run("Supplied", func(t *testing.T) {
+		client := newClient(ClientOptions{})
+		resp, err := client.Execute(context.Background(), executor.ExecuteRequest{SessionID: "ticket-42", Prompt: "hello", Executor: "custom"})
+		if err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		if resp.SessionID != "ticket-42" {
+			t.Fatalf("expected the supplied id, got %q", resp.SessionID)
+		}
+		if _, ok := client.GetSession("ticket-42"); !ok {
+			t.Fatal("expected the session under the supplied id")
+		}
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		client := newClient(ClientOptions{})
+		req := executor.ExecuteRequest{SessionID: "ticket-42", Prompt: "hello", Executor: "custom"}
+		if _, err := client.Execute(context.Background(), req); err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		if _, err := client.Execute(context.Background(), req); !errors.Is(err, ErrSessionExists) {
+			t.Fatalf("expected ErrSessionExists, got %v", err)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		client := newClient(ClientOptions{})
+		_, err := client.Execute(context.Background(), executor.ExecuteRequest{SessionID: "../escape", Prompt: "hello", Executor: "custom"})
+		if !errors.Is(err, executor.ErrInvalidOption) {
+			t.Fatalf("expected ErrInvalidOption, got %v", err)
+		}
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		client := newClient(ClientOptions{})
+		resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: "custom"})
+		if err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		if _, err := uuid.Parse(resp.SessionID); err != nil {
+			t.Fatalf("expected a UUID session id, got %q", resp.SessionID)
+		}
+	})
+
+	t.Run("Generator", func(t *testing.T) {
+		next := 0
+		client := newClient(ClientOptions{IDGenerator: func() string {
+			next++
+			return fmt.Sprintf("run-%d", next)
+		}})
+		for _, want := range []string{"run-1", "run-2"} {
+			resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: "custom"})
+			if err != nil {
+				t.Fatalf("execute failed: %v", err)
+			}
+			if resp.SessionID != want {
+				t.Fatalf("expected %q, got %q", want, resp.SessionID)
+			}
+		}
+	})
+}
+
+func TestMaxToolCallsFailsSession(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register("custom", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "loop", Executor: "custom", MaxToolCalls: 2})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	tool := func(phase string) executor.Log {
+		return executor.Log{Type: "tool", Content: executor.UnifiedContent{Category: "tool", ToolName: "bash", Phase: phase}}
+	}
+	for i := 0; i < 3; i++ {
+		exec.logs <- tool("started")
+		exec.logs <- tool("completed")
+	}
+	_ = client.WaitContext(context.Background(), resp.SessionID)
+
+	if status := sessionStatus(client, resp.SessionID); status != executor.SessionStatusFailed {
+		t.Fatalf("expected failed status, got %s", status)
+	}
+	if !exec.closed.Load() {
+		t.Fatal("expected the executor to be closed")
+	}
+	events, _ := client.ListEventsWithOptions(context.Background(), resp.SessionID, store.ListOptions{Types: []string{"error"}})
+	if len(events) != 1 {
+		t.Fatalf("expected one error event, got %+v", events)
+	}
+	content, _ := transcriptContent(events[0])
+	if content.SourceType != ToolLimitSourceType || !strings.Contains(strings.ToLower(content.Summary), "tool call limit exceeded") {
+		t.Fatalf("expected a tool limit error, got %+v", content)
+	}
+	tools, _ := client.ListEventsWithOptions(context.Background(), resp.SessionID, store.ListOptions{Types: []string{"tool"}})
+	if len(tools) != 5 {
+		t.Fatalf("expected the run to stop at the third call, got %d tool events", len(tools))
+	}
+
+	// The SDK's own error has no executor log to replay.
+	retransformed, err := client.Retransform(context.Background(), resp.SessionID, func(input executor.TransformInput) executor.Event {
+		return executor.Event{Type: "custom", Content: executor.UnifiedContent{SourceType: input.Log.Type, Raw: input.Log.Content}}
+	})
+	if err != nil {
+		t.Fatalf("retransform failed: %v", err)
+	}
+	last := retransformed[len(retransformed)-1]
+	if content, _ := transcriptContent(last); last.Type != "error" || content.SourceType != ToolLimitSourceType {
+		t.Fatalf("expected the tool limit error to be kept, got %#v", last)
+	}
+}
+
+func TestSessionResultCombinesResultEvents(t *testing.T) {
+	run := func(t *testing.T, mode ResultMode) executor.Session {
+		registry := executor.NewRegistry()
+		exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+		registry.Register(string(executor.ExecutorClaudeCode), executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+		client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore(), ResultMode: mode})
+
+		resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "answer", Executor: executor.ExecutorClaudeCode})
+		if err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		exec.logs <- executor.Log{Type: "result", Content: "Part one. "}
+		exec.logs <- executor.Log{Type: "result", Content: "Part two."}
+		exec.logs <- executor.Log{Type: "done", Content: map[string]any{"type": "result"}}
+		_ = client.WaitContext(context.Background(), resp.SessionID)
+
+		session, _ := client.GetSession(resp.SessionID)
+		return session
+	}
+
+	t.Run("Append", func(t *testing.T) {
+		if session := run(t, ""); session.Result != "Part one. Part two." {
+			t.Fatalf("expected both results to be combined, got %q", session.Result)
+		}
+	})
+
+	t.Run("Last", func(t *testing.T) {
+		if session := run(t, ResultModeLast); session.Result != "Part two." {
+			t.Fatalf("expected only the last result, got %q", session.Result)
+		}
+	})
+}
+
+func TestStallMonitor(t *testing.T) {
+	newClient := func(opts ClientOptions) (*Client, *blockingExecutor) {
+		registry := executor.NewRegistry()
+		exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+		registry.Register("custom", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+		opts.Registry = registry
+		opts.StreamManager = streaming.NewManager()
+		opts.EventStore = store.NewMemoryEventStore()
+		return NewWithOptions(opts), exec
+	}
+	stalledEvents := func(client *Client, sessionID string) int {
+		events, _ := client.GetSessionEvents(sessionID)
+		count := 0
+		for _, evt := range events {
+			if content, ok := evt.Content.(executor.UnifiedContent); ok && content.SourceType == StalledSourceType {
+				count++
+			}
+		}
+		return count
+	}
+
+	t.Run("WarnsWithoutEndingSession", func(t *testing.T) {
+		client, exec := newClient(ClientOptions{StallThreshold: 30 * time.Millisecond})
+		resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "stall", Executor: "custom"})
+		if err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		exec.logs <- executor.Log{Type: "stdout", Content: "working"}
+
+		waitFor(t, func() bool { return stalledEvents(client, resp.SessionID) == 1 })
+		if !client.SessionRunning(resp.SessionID) || sessionStatus(client, resp.SessionID) != executor.SessionStatusRunning {
+			t.Fatal("expected a stalled session to keep running")
+		}
+		events, _ := client.GetSessionEvents(resp.SessionID)
+		if last := events[len(events)-1]; last.Type != "progress" || last.Content.(executor.UnifiedContent).Phase != "stalled" {
+			t.Fatalf("expected a progress/stalled event, got %#v", last)
+		}
+
+		exec.logs <- executor.Log{Type: "stdout", Content: "back again"}
+		waitFor(t, func() bool { return stalledEvents(client, resp.SessionID) == 2 })
+
+		exec.logs <- executor.Log{Type: "done", Content: "finished"}
+		_ = client.WaitContext(context.Background(), resp.SessionID)
+		if status := sessionStatus(client, resp.SessionID); status != executor.SessionStatusDone {
+			t.Fatalf("expected the session to finish normally, got %s", status)
+		}
+	})
+
+	t.Run("TimeoutStopsSession", func(t *testing.T) {
+		client, _ := newClient(ClientOptions{StallTimeout: 30 * time.Millisecond})
+		resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "stall", Executor: "custom"})
+		if err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := client.WaitContext(ctx, resp.SessionID); err != nil {
+			t.Fatalf("expected the stalled session to be stopped: %v", err)
+		}
+		if status := sessionStatus(client, resp.SessionID); status != executor.SessionStatusInterrupted {
+			t.Fatalf("expected an interrupted session, got %s", status)
+		}
+		if stalledEvents(client, resp.SessionID) != 1 {
+			t.Fatal("expected a stalled event before stopping")
+		}
+	})
+}
+
+func TestDeleteSessionEndsSubscriptions(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register("custom", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "delete me", Executor: "custom"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	ch, cancel := client.Subscribe(resp.SessionID, executor.SubscribeOptions{})
+	defer cancel()
+	// Wait until the subscription is following live events.
+	exec.logs <- executor.Log{Type: "stdout", Content: "working"}
+	for evt := range ch {
+		if evt.Content == "working" {
+			break
+		}
+	}
+
+	if err := client.DeleteSession(context.Background(), resp.SessionID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if !exec.closed.Load() {
+		t.Fatal("expected running executor to be closed")
+	}
+
+	var last executor.Event
+	deadline := time.After(time.Second)
+	for open := true; open; {
+		select {
+		case evt, ok := <-ch:
+			if ok {
+				last = evt
+			}
+			open = ok
+		case <-deadline:
+			t.Fatal("expected subscription to end after delete")
+		}
+	}
+	if last.Type != EventTypeDeleted {
+		t.Fatalf("expected the subscription to end with a deleted event, got %+v", last)
+	}
+
+	if _, ok := client.GetSession(resp.SessionID); ok {
+		t.Fatal("expected session summary to be removed")
+	}
+	if events, _ := client.ListEvents(context.Background(), resp.SessionID, 0, 0); len(events) != 0 {
+		t.Fatalf("expected stored events to be removed, got %d", len(events))
+	}
+	if err := client.DeleteSession(context.Background(), resp.SessionID); !errors.Is(err, executor.ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound on second delete, got %v", err)
+	}
+}
+
+func TestListSessionsByKind(t *testing.T) {
+	registry := executor.NewRegistry()
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+	registry.Register("test", executor.FactoryFunc(func() (executor.Executor, error) {
+		return &testExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}, nil
+	}))
+
+	ids := map[string]string{}
+	for _, kind := range []string{"review", "bugfix", ""} {
+		resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "kind " + kind, Executor: "test", Kind: kind})
+		if err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		ids[kind] = resp.SessionID
+	}
+
+	reviews := client.ListSessionsWithOptions(context.Background(), ListSessionsOptions{Kind: "review"})
+	if len(reviews) != 1 || reviews[0].SessionID != ids["review"] || reviews[0].Kind != "review" {
+		t.Fatalf("expected only the review session, got %+v", reviews)
+	}
+	if all := client.ListSessions(context.Background()); len(all) != 3 {
+		t.Fatalf("expected unfiltered list to return every session, got %d", len(all))
+	}
+	if none := client.ListSessionsWithOptions(context.Background(), ListSessionsOptions{Kind: "docs"}); len(none) != 0 {
+		t.Fatalf("expected no docs sessions, got %+v", none)
+	}
+}
+
+func TestListSessionsByLabels(t *testing.T) {
+	registry := executor.NewRegistry()
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+	registry.Register("test", executor.FactoryFunc(func() (executor.Executor, error) {
+		return &testExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}, nil
+	}))
+
+	ids := map[string]string{}
+	for name, labels := range map[string]map[string]string{
+		"alice-web": {"user": "alice", "project": "web"},
+		"alice-api": {"user": "alice", "project": "api"},
+		"bob-web":   {"user": "bob", "project": "web"},
+	} {
+		resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: name, Executor: "test", Labels: labels})
+		if err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		ids[name] = resp.SessionID
+	}
+
+	alice := client.ListSessionsWithOptions(context.Background(), ListSessionsOptions{Labels: map[string]string{"user": "alice"}})
+	if len(alice) != 2 {
+		t.Fatalf("expected alice's two sessions, got %+v", alice)
+	}
+	for _, session := range alice {
+		if session.Labels["user"] != "alice" {
+			t.Fatalf("unexpected session in alice's list: %+v", session)
+		}
+	}
+	both := client.ListSessionsWithOptions(context.Background(), ListSessionsOptions{Labels: map[string]string{"user": "alice", "project": "web"}})
+	if len(both) != 1 || both[0].SessionID != ids["alice-web"] {
+		t.Fatalf("expected only alice's web session, got %+v", both)
+	}
+	if none := client.ListSessionsWithOptions(context.Background(), ListSessionsOptions{Labels: map[string]string{"ticket": "1"}}); len(none) != 0 {
+		t.Fatalf("expected no sessions for an unknown label, got %+v", none)
+	}
+}
+
+func TestDefaultTransformer_NormalizesCodexAndClaude(t *testing.T) {
+	registry := executor.NewRegistry()
+	client := NewWithOptions(ClientOptions{
+		Registry:      registry,
+		StreamManager: streaming.NewManager(),
+		EventStore:    store.NewMemoryEventStore(),
+	})
+
+	// Register fake executors under built-in names so default transformers apply.
+	registry.Register(string(executor.ExecutorCodex), executor.FactoryFunc(func() (executor.Executor, error) {
+		return &testExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}, nil
+	}))
+	registry.Register(string(executor.ExecutorClaudeCode), executor.FactoryFunc(func() (executor.Executor, error) {
+		return &testExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}, nil
+	}))
+
+	for _, execName := range []executor.ExecutorType{executor.ExecutorCodex, executor.ExecutorClaudeCode} {
+		resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: execName})
+		if err != nil {
+			t.Fatalf("execute failed for %s: %v", execName, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+
+		events, err := client.ListEvents(context.Background(), resp.SessionID, 0, 0)
+		if err != nil {
+			t.Fatalf("list events failed for %s: %v", execName, err)
+		}
+		if len(events) < 3 {
+			t.Fatalf("expected at least 3 events for %s, got %d", execName, len(events))
+		}
+
+		// events[0] is the session-started lifecycle event.
+		first := events[1]
+		content, ok := first.Content.(executor.UnifiedContent)
+		if !ok {
+			t.Fatalf("expected UnifiedContent for %s, got %T", execName, first.Content)
+		}
+		if first.Type != "message" {
+			t.Fatalf("expected normalized type=message for %s, got %s", execName, first.Type)
+		}
+		if content.Source != string(execName) || content.SourceType != "stdout" || content.Category != "message" {
+			t.Fatalf("unexpected normalized content for %s: %#v", execName, content)
+		}
+	}
+}
+
+func TestCustomTransformer_OverridesDefault(t *testing.T) {
+	registry := executor.NewRegistry()
+	client := NewWithOptions(ClientOptions{
+		Registry:      registry,
+		StreamManager: streaming.NewManager(),
+		EventStore:    store.NewMemoryEventStore(),
+		Transformers: map[string]executor.EventTransformer{
+			string(executor.ExecutorCodex): func(input executor.TransformInput) executor.Event {
+				return executor.Event{
+					Type: "custom",
+					Content: map[string]any{
+						"text": fmt.Sprintf("%s:%v", input.Log.Type, input.Log.Content),
+					},
+				}
+			},
+		},
+	})
+
+	registry.Register(string(executor.ExecutorCodex), executor.FactoryFunc(func() (executor.Executor, error) {
+		return &testExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}, nil
+	}))
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: executor.ExecutorCodex})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	events, err := client.ListEvents(context.Background(), resp.SessionID, 0, 0)
+	if err != nil {
+		t.Fatalf("list events failed: %v", err)
+	}
+	if len(events) < 2 {
+		t.Fatalf("expected events")
+	}
+	if events[1].Type != "custom" {
+		t.Fatalf("expected custom transformed type, got %s", events[1].Type)
+	}
+}
+
+func TestTransformerChain_RunsAfterDefaultInOrder(t *testing.T) {
+	var order []string
+	client := NewWithOptions(ClientOptions{
+		Registry: executor.NewRegistry(),
+		TransformerChains: map[string][]executor.EventTransformer{
+			string(executor.ExecutorClaudeCode): {
+				func(input executor.TransformInput) executor.Event {
+					order = append(order, "redact")
+					content, ok := input.Log.Content.(executor.UnifiedContent)
+					if !ok {
+						t.Fatalf("expected default-normalized content, got %T", input.Log.Content)
+					}
+					if !strings.Contains(content.Text, "sk-secret") {
+						t.Fatalf("expected default content to carry the raw text, got %q", content.Text)
+					}
+					content.Text = strings.ReplaceAll(content.Text, "sk-secret", "***")
+					return executor.Event{Content: content}
+				},
+				func(input executor.TransformInput) executor.Event {
+					order = append(order, "tag")
+					content := input.Log.Content.(executor.UnifiedContent)
+					content.Action = "tagged:" + content.Action
+					return executor.Event{Type: input.Log.Type, Content: content}
+				},
+			},
+		},
+	})
+
+	evt := client.transformEvent("s1", string(executor.ExecutorClaudeCode), executor.Log{Type: "result", Content: "token sk-secret"})
+	if strings.Join(order, ",") != "redact,tag" {
+		t.Fatalf("unexpected stage order: %v", order)
+	}
+	content, ok := evt.Content.(executor.UnifiedContent)
+	if !ok {
+		t.Fatalf("expected unified content, got %T", evt.Content)
+	}
+	if strings.Contains(content.Text, "sk-secret") || !strings.HasPrefix(content.Action, "tagged:") {
+		t.Fatalf("expected both stages applied, got %#v", content)
+	}
+	if evt.SessionID != "s1" || evt.Executor != string(executor.ExecutorClaudeCode) || evt.Type == "" {
+		t.Fatalf("expected event defaults to be filled, got %#v", evt)
+	}
+}
+
+func TestExecutorTimestampsPreservedThroughStore(t *testing.T) {
+	upstream := time.UnixMilli(1700000000123)
+	for _, ignore := range []bool{false, true} {
+		registry := executor.NewRegistry()
+		exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+		exec.logs <- executor.Log{Type: "droid_message", Content: droid.DroidEvent{
+			Type:      droid.EventTypeMessage,
+			Role:      "assistant",
+			Text:      "hello",
+			Timestamp: uint64(upstream.UnixMilli()),
+		}}
+		registry.Register(string(executor.ExecutorDroid), executor.FactoryFunc(func() (executor.Executor, error) {
+			return exec, nil
+		}))
+		client := NewWithOptions(ClientOptions{
+			Registry:                 registry,
+			StreamManager:            streaming.NewManager(),
+			EventStore:               store.NewMemoryEventStore(),
+			IgnoreExecutorTimestamps: ignore,
+		})
+
+		resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hi", Executor: executor.ExecutorDroid})
+		if err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		var events []executor.Event
+		waitFor(t, func() bool {
+			events, _ = client.ListEvents(context.Background(), resp.SessionID, 0, 0)
+			return len(events) == 2
+		})
+		if preserved := events[1].Timestamp.Equal(upstream); preserved == ignore {
+			t.Fatalf("ignore=%v: unexpected stored timestamp %v", ignore, events[1].Timestamp)
+		}
+		_ = exec.Close()
+		_ = client.WaitContext(context.Background(), resp.SessionID)
+	}
+}
+
+func TestTransformEvent_SetsNormalized(t *testing.T) {
+	client := NewWithOptions(ClientOptions{Registry: executor.NewRegistry()})
+
+	parsed := client.transformEvent("s1", string(executor.ExecutorClaudeCode), executor.Log{Type: "stdout", Content: `{"type":"assistant"}`})
+	if !parsed.Normalized {
+		t.Fatalf("expected normalized claude event, got %#v", parsed)
+	}
+
+	raw := client.transformEvent("s1", "custom", executor.Log{Type: "stdout", Content: "plain line"})
+	if raw.Normalized || raw.Content != "plain line" {
+		t.Fatalf("expected raw passthrough stdout, got %#v", raw)
+	}
+}
+
+func TestRawModeSkipsTransformers(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register(string(executor.ExecutorClaudeCode), executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	chained := false
+	client := NewWithOptions(ClientOptions{
+		Registry:      registry,
+		StreamManager: streaming.NewManager(),
+		EventStore:    store.NewMemoryEventStore(),
+		RawMode:       true,
+		TransformerChains: map[string][]executor.EventTransformer{
+			string(executor.ExecutorClaudeCode): {func(input executor.TransformInput) executor.Event {
+				chained = true
+				return executor.Event{}
+			}},
+		},
+	})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "raw", Executor: executor.ExecutorClaudeCode})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	assistant := map[string]any{"type": "assistant", "message": map[string]any{"content": []any{}}}
+	exec.logs <- executor.Log{Type: "stdout", Content: assistant}
+	exec.logs <- executor.Log{Type: "result", Content: "the answer"}
+	exec.logs <- executor.Log{Type: "done", Content: "finished"}
+	_ = client.WaitContext(context.Background(), resp.SessionID)
+
+	events, err := client.ListEvents(context.Background(), resp.SessionID, 1, 0)
+	if err != nil {
+		t.Fatalf("list events failed: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 raw events after session_started, got %d", len(events))
+	}
+	if events[0].Type != "stdout" || events[0].Normalized || !reflect.DeepEqual(events[0].Content, assistant) {
+		t.Fatalf("expected the original map content, got %#v", events[0])
+	}
+	if content, ok := events[1].Content.(string); !ok || events[1].Type != "result" || content != "the answer" {
+		t.Fatalf("expected the original string content, got %#v", events[1])
+	}
+	if events[2].Type != "done" {
+		t.Fatalf("expected done last, got %#v", events[2])
+	}
+	if chained {
+		t.Fatal("expected transformer chains to be skipped in raw mode")
+	}
+}
+
+func TestExecute_WorkingDirRoot(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	sub := filepath.Join(root, "project")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{sub, outside} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir failed: %v", err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	newClient := func(root string) (*Client, *resumeExecutor) {
+		exec := &resumeExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
+		registry := executor.NewRegistry()
+		registry.Register("mock", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+		return NewWithOptions(ClientOptions{Registry: registry, WorkingDirRoot: root}), exec
+	}
+
+	t.Run("AllowedSubdir", func(t *testing.T) {
+		client, exec := newClient(root)
+		resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hi", Executor: "mock", WorkingDir: filepath.Join(root, "project", "..", "project")})
+		if err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		_ = client.WaitContext(context.Background(), resp.SessionID)
+		want, _ := filepath.EvalSymlinks(sub)
+		if exec.startOpts.WorkingDir != want {
+			t.Fatalf("expected the normalized directory %q, got %q", want, exec.startOpts.WorkingDir)
+		}
+	})
+
+	t.Run("Traversal", func(t *testing.T) {
+		client, _ := newClient(root)
+		_, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hi", Executor: "mock", WorkingDir: filepath.Join(root, "..", "outside")})
+		if !errors.Is(err, ErrWorkingDirNotAllowed) {
+			t.Fatalf("expected ErrWorkingDirNotAllowed, got %v", err)
+		}
+	})
+
+	t.Run("SymlinkEscape", func(t *testing.T) {
+		client, _ := newClient(root)
+		_, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hi", Executor: "mock", WorkingDir: filepath.Join(root, "escape")})
+		if !errors.Is(err, ErrWorkingDirNotAllowed) {
+			t.Fatalf("expected ErrWorkingDirNotAllowed, got %v", err)
+		}
+	})
+
+	t.Run("NoRoot", func(t *testing.T) {
+		client, exec := newClient("")
+		dir := filepath.Join(root, "escape")
+		resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hi", Executor: "mock", WorkingDir: dir})
+		if err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		_ = client.WaitContext(context.Background(), resp.SessionID)
+		if exec.startOpts.WorkingDir != dir {
+			t.Fatalf("expected the directory unchanged without a root, got %q", exec.startOpts.WorkingDir)
+		}
+	})
+}
+
+func TestExecute_WorkingDirLimit(t *testing.T) {
+	newClient := func(mode executor.LimitMode) (*Client, chan *blockingExecutor) {
+		registry := executor.NewRegistry()
+		created := make(chan *blockingExecutor, 10)
+		registry.Register("mock", executor.FactoryFunc(func() (executor.Executor, error) {
+			exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+			created <- exec
+			return exec, nil
+		}))
+		client := NewWithOptions(ClientOptions{
+			Registry:                 registry,
+			MaxSessionsPerWorkingDir: 1,
+			WorkingDirLimitMode:      mode,
+		})
+		return client, created
+	}
+
+	t.Run("Reject", func(t *testing.T) {
+		client, created := newClient(executor.LimitReject)
+		dir := t.TempDir()
+		req := executor.ExecuteRequest{Prompt: "one", Executor: "mock", WorkingDir: dir}
+
+		first, err := client.Execute(context.Background(), req)
+		if err != nil {
+			t.Fatalf("first execute failed: %v", err)
+		}
+		firstExec := <-created
+
+		if _, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "two", Executor: "mock", WorkingDir: dir + "/."}); !errors.Is(err, ErrWorkingDirBusy) {
+			t.Fatalf("expected ErrWorkingDirBusy for the same directory, got %v", err)
+		}
+		if _, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "other", Executor: "mock", WorkingDir: t.TempDir()}); err != nil {
+			t.Fatalf("expected another directory to be allowed, got %v", err)
+		}
+
+		_ = firstExec.Close()
+		if err := client.WaitContext(context.Background(), first.SessionID); err != nil {
+			t.Fatalf("wait failed: %v", err)
+		}
+		waitFor(t, func() bool {
+			_, err := client.Execute(context.Background(), req)
+			return err == nil
+		})
+	})
+
+	t.Run("Block", func(t *testing.T) {
+		client, created := newClient(executor.LimitBlock)
+		dir := t.TempDir()
+		req := executor.ExecuteRequest{Prompt: "one", Executor: "mock", WorkingDir: dir}
+
+		if _, err := client.Execute(context.Background(), req); err != nil {
+			t.Fatalf("first execute failed: %v", err)
+		}
+		firstExec := <-created
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, err := client.Execute(ctx, req); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected queued execute to wait until its deadline, got %v", err)
+		}
+
+		result := make(chan error, 1)
+		go func() {
+			_, err := client.Execute(context.Background(), req)
+			result <- err
+		}()
+		select {
+		case err := <-result:
+			t.Fatalf("expected second execute to be queued, got %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		detachedCtx, cancelDetached := context.WithTimeout(DetachSession(context.Background()), 50*time.Millisecond)
+		defer cancelDetached()
+		if _, err := client.Execute(detachedCtx, req); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected a detached execute to stop waiting at its deadline, got %v", err)
+		}
+
+		_ = firstExec.Close()
+		select {
+		case err := <-result:
+			if err != nil {
+				t.Fatalf("queued execute failed: %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("queued execute did not start after the first session ended")
+		}
+	})
+}
+
+func TestDetachSessionOutlivesContext(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register("mock", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+
+	ctx, cancel := context.WithCancel(DetachSession(context.Background()))
+	resp, err := client.Execute(ctx, executor.ExecuteRequest{Prompt: "hello", Executor: "mock"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	if exec.closed.Load() || !client.SessionRunning(resp.SessionID) {
+		t.Fatal("expected the detached session to keep running after its context was cancelled")
+	}
+	_ = exec.Close()
+}
+
+func TestRetransform(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register(string(executor.ExecutorCodex), executor.FactoryFunc(func() (executor.Executor, error) {
+		return exec, nil
+	}))
+	client := NewWithOptions(ClientOptions{Registry: registry})
+
+	exec.logs <- executor.Log{Type: "codex/event/agent_message", Content: map[string]any{"msg": map[string]any{"type": "agent_message", "message": "hello"}}}
+	exec.logs <- executor.Log{Type: "codex/event/task_complete", Content: map[string]any{"msg": map[string]any{"type": "task_complete"}}}
+	exec.logs <- executor.Log{Type: "done", Content: "Codex execution finished"}
+	_ = exec.Close()
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hi", Executor: executor.ExecutorCodex})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if err := client.WaitContext(context.Background(), resp.SessionID); err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+	stored, _ := client.ListEvents(context.Background(), resp.SessionID, 0, 0)
+
+	custom := func(input executor.TransformInput) executor.Event {
+		return executor.Event{Type: "custom", Content: executor.UnifiedContent{
+			SourceType: input.Log.Type,
+			Category:   "custom",
+			Summary:    "v2 " + input.Log.Type,
+			Raw:        input.Log.Content,
+		}}
+	}
+	events, err := client.Retransform(context.Background(), resp.SessionID, custom)
+	if err != nil {
+		t.Fatalf("retransform failed: %v", err)
+	}
+	if len(events) != len(stored) || len(events) != 4 {
+		t.Fatalf("expected one event per stored event, got %d of %d", len(events), len(stored))
+	}
+	if first := events[0].Content.(executor.UnifiedContent); first.SourceType != SessionStartedSourceType || events[0].Type != "progress" {
+		t.Fatalf("expected session-started event to be kept, got %#v", events[0])
+	}
+	for i, evt := range events[1:] {
+		content := evt.Content.(executor.UnifiedContent)
+		if evt.Type != "custom" || evt.Seq != stored[i+1].Seq || !evt.Normalized || evt.SessionID != resp.SessionID {
+			t.Fatalf("unexpected retransformed event %d: %#v", i+1, evt)
+		}
+		if want := "v2 " + stored[i+1].Content.(executor.UnifiedContent).SourceType; content.Summary != want {
+			t.Fatalf("expected summary %q, got %q", want, content.Summary)
+		}
+	}
+	if msg := events[1].Content.(executor.UnifiedContent).Raw.(map[string]any)["msg"].(map[string]any)["message"]; msg != "hello" {
+		t.Fatalf("expected original raw log to be replayed, got %v", msg)
+	}
+
+	after, _ := client.ListEvents(context.Background(), resp.SessionID, 0, 0)
+	if after[1].Type != "progress" || after[1].Content.(executor.UnifiedContent).Category == "custom" {
+		t.Fatalf("expected store to be unchanged, got %#v", after[1])
+	}
+
+	decoded := executor.Event{Type: "progress", Normalized: true, Content: map[string]any{"source_type": "codex/event/agent_message", "raw": map[string]any{"msg": "hi"}}}
+	if logEntry, ok := sourceLog(decoded); !ok || logEntry.Type != "codex/event/agent_message" || logEntry.Content.(map[string]any)["msg"] != "hi" {
+		t.Fatalf("expected source log from decoded JSON content, got %#v", logEntry)
+	}
+
+	if _, err := client.Retransform(context.Background(), "missing", custom); !errors.Is(err, executor.ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestSubscribe_CoalesceProgress(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 200)}
+	registry.Register("mock", executor.FactoryFunc(func() (executor.Executor, error) {
+		return exec, nil
+	}))
+	client := NewWithOptions(ClientOptions{Registry: registry})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hi", Executor: "mock"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	events, unsubscribe := client.Subscribe(resp.SessionID, executor.SubscribeOptions{CoalesceProgress: 200 * time.Millisecond})
+	defer unsubscribe()
+
+	exec.logs <- executor.Log{Type: "message", Content: "ready"}
+	if evt := <-events; evt.Type != "message" {
+		t.Fatalf("expected live message first, got %#v", evt)
+	}
+
+	for i := 0; i < 100; i++ {
+		exec.logs <- executor.Log{Type: "progress", Content: fmt.Sprintf("p%d", i)}
+		if i%20 == 19 {
+			// Stay under the stream manager's per-subscriber buffer.
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	exec.logs <- executor.Log{Type: "message", Content: "reply"}
+	exec.logs <- executor.Log{Type: "done", Content: "finished"}
+
+	var got []executor.Event
+	for evt := range events {
+		got = append(got, evt)
+	}
+	if len(got) < 3 || got[len(got)-1].Type != "done" || got[len(got)-2].Type != "message" {
+		t.Fatalf("expected message and done to pass through, got %d events", len(got))
+	}
+	progress := got[:len(got)-2]
+	if len(progress) == 0 || len(progress) > 10 {
+		t.Fatalf("expected progress events to be coalesced, got %d", len(progress))
+	}
+	for _, evt := range progress {
+		if evt.Type != "progress" {
+			t.Fatalf("expected only progress before the reply, got %s", evt.Type)
+		}
+	}
+	if last := progress[len(progress)-1].Content; last != "p99" {
+		t.Fatalf("expected the final progress event to be kept, got %v", last)
+	}
+}
+
+func TestSubscribe_CoalesceTools(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register("mock", executor.FactoryFunc(func() (executor.Executor, error) {
+		return exec, nil
+	}))
+	client := NewWithOptions(ClientOptions{
+		Registry: registry,
+		Transformers: map[string]executor.EventTransformer{
+			"mock": func(input executor.TransformInput) executor.Event {
+				if content, ok := input.Log.Content.(executor.UnifiedContent); ok {
+					return executor.Event{Type: "tool", Content: content}
+				}
+				return executor.Event{Type: input.Log.Type, Content: input.Log.Content}
+			},
+		},
+	})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hi", Executor: "mock"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	tool := func(id, phase string) executor.Log {
+		return executor.Log{Type: "tool", Content: executor.UnifiedContent{Category: "tool", Phase: phase, ToolName: "Read", ToolCallID: id}}
+	}
+	exec.logs <- tool("call-1", "started")
+	exec.logs <- tool("call-2", "started")
+	time.Sleep(30 * time.Millisecond)
+	exec.logs <- tool("call-1", "completed")
+	exec.logs <- tool("call-2", "failed")
+	exec.logs <- executor.Log{Type: "done", Content: "finished"}
+	waitFor(t, func() bool { return sessionStatus(client, resp.SessionID) == executor.SessionStatusDone })
+
+	events, unsubscribe := client.Subscribe(resp.SessionID, executor.SubscribeOptions{ReturnAll: true, CoalesceTools: true, Categories: []string{"tool"}})
+	defer unsubscribe()
+
+	var got []executor.UnifiedContent
+	for evt := range events {
+		content, ok := transcriptContent(evt)
+		if !ok {
+			t.Fatalf("expected normalized tool content, got %#v", evt)
+		}
+		got = append(got, content)
+	}
+	if len(got) != 2 || got[0].ToolCallID != "call-1" || got[0].Phase != "completed" || got[1].ToolCallID != "call-2" || got[1].Phase != "failed" {
+		t.Fatalf("expected only the completed and failed tool events, got %+v", got)
+	}
+	for _, content := range got {
+		if content.DurationMS < 30 || content.DurationMS > 1000 {
+			t.Fatalf("expected a duration from the matching start, got %dms for %s", content.DurationMS, content.ToolCallID)
+		}
+	}
+}
+
+func TestTranscript(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register(string(executor.ExecutorClaudeCode), executor.FactoryFunc(func() (executor.Executor, error) {
+		return exec, nil
+	}))
+	client := NewWithOptions(ClientOptions{Registry: registry})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "List the files", Executor: executor.ExecutorClaudeCode})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	exec.logs <- executor.Log{Type: "stdout", Content: map[string]any{
+		"type":    "assistant",
+		"message": map[string]any{"content": []any{map[string]any{"type": "text", "text": "Let me look."}}},
+	}}
+	exec.logs <- executor.Log{Type: "stdout", Content: map[string]any{"type": "tool_use", "tool_name": "Bash", "input": map[string]any{"command": "ls"}}}
+	waitFor(t, func() bool {
+		events, _ := client.ListEvents(context.Background(), resp.SessionID, 0, 0)
+		return len(events) == 3
+	})
+
+	running, err := client.Transcript(context.Background(), resp.SessionID, TranscriptFormatMarkdown)
+	if err != nil {
+		t.Fatalf("transcript failed: %v", err)
+	}
+	for _, want := range []string{"## Prompt\n\nList the files", "still running", "## Assistant\n\nLet me look.", "<details>\n<summary>Calling tool: Bash</summary>"} {
+		if !strings.Contains(string(running), want) {
+			t.Fatalf("expected running transcript to contain %q, got:\n%s", want, running)
+		}
+	}
+
+	exec.logs <- executor.Log{Type: "result", Content: "Found 3 files."}
+	exec.logs <- executor.Log{Type: "done", Content: "Claude execution finished"}
+	if err := client.WaitContext(context.Background(), resp.SessionID); err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+
+	md, err := client.Transcript(context.Background(), resp.SessionID, TranscriptFormatMarkdown)
+	if err != nil {
+		t.Fatalf("transcript failed: %v", err)
+	}
+	if strings.Contains(string(md), "still running") || !strings.HasSuffix(string(md), "## Result\n\nFound 3 files.\n") {
+		t.Fatalf("expected finished transcript ending with the result, got:\n%s", md)
+	}
+
+	data, err := client.Transcript(context.Background(), resp.SessionID, TranscriptFormatJSON)
+	if err != nil {
+		t.Fatalf("json transcript failed: %v", err)
+	}
+	var transcript Transcript
+	if err := json.Unmarshal(data, &transcript); err != nil {
+		t.Fatalf("decode transcript: %v", err)
+	}
+	if transcript.Prompt != "List the files" || transcript.Session.Status != executor.SessionStatusDone || len(transcript.Events) != 5 {
+		t.Fatalf("unexpected JSON transcript: %s", data)
+	}
+	for i, evt := range transcript.Events {
+		if evt.Seq != uint64(i+1) {
+			t.Fatalf("expected ordered events, got seq %d at %d", evt.Seq, i)
+		}
+	}
+
+	if _, err := client.Transcript(context.Background(), resp.SessionID, "html"); !errors.Is(err, ErrUnsupportedTranscriptFormat) {
+		t.Fatalf("expected ErrUnsupportedTranscriptFormat, got %v", err)
+	}
+	if _, err := client.Transcript(context.Background(), "missing", TranscriptFormatJSON); !errors.Is(err, executor.ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestTranscriptMarkdownEscaping(t *testing.T) {
+	md := renderTranscriptMarkdown(Transcript{
+		Session: executor.Session{SessionID: "s1"},
+		Events: []executor.Event{{Type: "tool", Content: executor.UnifiedContent{
+			Summary: "Calling tool: <img src=x onerror=alert(1)>",
+			Text:    "cat README.md\n```go\nfmt.Println(\"````\")\n```",
+		}}},
+	})
+	if !strings.Contains(md, "<summary>Calling tool: &lt;img src=x onerror=alert(1)&gt;</summary>") {
+		t.Fatalf("expected an escaped summary, got:\n%s", md)
+	}
+	if !strings.Contains(md, "\n`````\ncat README.md\n") || !strings.Contains(md, "\n```\n`````\n</details>") {
+		t.Fatalf("expected a fence longer than the backtick runs in the output, got:\n%s", md)
+	}
+}
+
+func TestTransformEvent_AppliesErrorDetails(t *testing.T) {
+	client := NewWithOptions(ClientOptions{Registry: executor.NewRegistry()})
+
+	err := fmt.Errorf("failed to initialize: %w", &executor.RPCError{Code: -32000, Message: "boom"})
+	evt := client.transformEvent("s1", string(executor.ExecutorCodex), executor.Log{Type: "error", Content: err.Error(), Err: err})
+	content, ok := evt.Content.(executor.UnifiedContent)
+	if !ok || evt.Type != "error" || content.ErrorKind != executor.ErrorKindRPC || content.ErrorCode != "-32000" {
+		t.Fatalf("expected rpc error details, got %#v", evt)
+	}
+
+	evt = client.transformEvent("s1", string(executor.ExecutorCodex), executor.Log{Type: "error", Content: "plain failure"})
+	if content := evt.Content.(executor.UnifiedContent); content.ErrorKind != "" || content.ErrorCode != "" {
+		t.Fatalf("expected no error details without Err, got %#v", content)
+	}
+}
+
+func TestContinueTask_ResumeFromStoredRuntime(t *testing.T) {
+	registry := executor.NewRegistry()
+	streamMgr := streaming.NewManager()
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streamMgr, EventStore: store.NewMemoryEventStore()})
+
+	re := &resumeExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
+	registry.Register(string(executor.ExecutorCodex), executor.FactoryFunc(func() (executor.Executor, error) {
+		return re, nil
+	}))
+
+	sessionID := "resume-session"
+	client.requests[sessionID] = executor.ExecuteRequest{
+		Executor:   executor.ExecutorCodex,
+		WorkingDir: ".",
+	}
+	client.resumeInfo[sessionID] = executor.ResumeState{SessionID: "conv-123"}
+
+	if err := client.ContinueTask(context.Background(), sessionID, "resume me"); err != nil {
+		t.Fatalf("continue failed: %v", err)
+	}
+	if re.startPrompt != "resume me" {
+		t.Fatalf("expected resume message as prompt, got %q", re.startPrompt)
+	}
+	if re.startOpts.ResumeSessionID != "conv-123" {
+		t.Fatalf("expected resume session id, got %+v", re.startOpts)
+	}
+	if re.startOpts.WorkingDir != "." {
+		t.Fatalf("expected original working dir, got %q", re.startOpts.WorkingDir)
+	}
+}
+
+func TestModelReasoningEffortReachesOptions(t *testing.T) {
+	registry := executor.NewRegistry()
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+
+	re := &resumeExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
+	registry.Register(string(executor.ExecutorCodex), executor.FactoryFunc(func() (executor.Executor, error) {
+		return re, nil
+	}))
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{
+		Prompt:               "plan it",
+		Executor:             executor.ExecutorCodex,
+		Sandbox:              "read-only",
+		ModelReasoningEffort: "high",
+	})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if re.startOpts.ModelReasoningEffort != "high" || re.startOpts.Sandbox != "read-only" {
+		t.Fatalf("expected effort and sandbox in the start options, got %+v", re.startOpts)
+	}
+	_ = client.WaitContext(context.Background(), resp.SessionID)
+
+	client.resumeInfo[resp.SessionID] = executor.ResumeState{SessionID: "conv-123"}
+	if err := client.ContinueTask(context.Background(), resp.SessionID, "again"); err != nil {
+		t.Fatalf("continue failed: %v", err)
+	}
+	if re.startOpts.ModelReasoningEffort != "high" {
+		t.Fatalf("expected the resumed run to keep the session's effort, got %q", re.startOpts.ModelReasoningEffort)
+	}
+	_ = client.WaitContext(context.Background(), resp.SessionID)
+
+	if err := client.ContinueTaskWithOptions(context.Background(), resp.SessionID, executor.ContinueRequest{Message: "quick", ModelReasoningEffort: "low"}); err != nil {
+		t.Fatalf("continue failed: %v", err)
+	}
+	if re.startOpts.ModelReasoningEffort != "low" {
+		t.Fatalf("expected the per-turn override, got %q", re.startOpts.ModelReasoningEffort)
+	}
+}
+
+func TestDuplicateDoneStoredOnce(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	exec.logs <- executor.Log{Type: "stdout", Content: "working"}
+	exec.logs <- executor.Log{Type: "done", Content: "result"}
+	exec.logs <- executor.Log{Type: "done", Content: "exited"}
+	exec.logs <- executor.Log{Type: "stdout", Content: "late"}
+	registry.Register("custom", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: "custom"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if err := client.WaitContext(context.Background(), resp.SessionID); err != nil {
+		t.Fatalf("wait failed: %v", err)
+	}
+
+	events, err := client.ListEvents(context.Background(), resp.SessionID, 0, 0)
+	if err != nil {
+		t.Fatalf("get events failed: %v", err)
+	}
+	dones := 0
+	for _, evt := range events {
+		if evt.Type == "done" {
+			dones++
+		}
+	}
+	if dones != 1 || events[len(events)-1].Type != "done" {
+		t.Fatalf("expected exactly one done, stored last, got %+v", events)
+	}
+	if status := sessionStatus(client, resp.SessionID); status != executor.SessionStatusDone {
+		t.Fatalf("expected session done, got %s", status)
+	}
+}
+
+func TestLauncherAndCommandOverrides(t *testing.T) {
+	registry := executor.NewRegistry()
+	client := NewWithOptions(ClientOptions{
+		Registry:         registry,
+		StreamManager:    streaming.NewManager(),
+		EventStore:       store.NewMemoryEventStore(),
+		Launcher:         "pnpm dlx",
+		CommandOverrides: map[executor.ExecutorType][]string{executor.ExecutorQwen: {"/opt/qwen", "{prompt}"}},
+	})
+	executors := map[executor.ExecutorType]*resumeExecutor{}
+	for _, name := range []executor.ExecutorType{executor.ExecutorCodex, executor.ExecutorQwen} {
+		re := &resumeExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
+		executors[name] = re
+		registry.Register(string(name), executor.FactoryFunc(func() (executor.Executor, error) { return re, nil }))
+	}
+
+	for name, re := range executors {
+		resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: name})
+		if err != nil {
+			t.Fatalf("execute %s failed: %v", name, err)
+		}
+		if re.startOpts.Launcher != "pnpm dlx" {
+			t.Fatalf("expected the client launcher for %s, got %q", name, re.startOpts.Launcher)
+		}
+		_ = client.WaitContext(context.Background(), resp.SessionID)
+	}
+	if got := executors[executor.ExecutorCodex].startOpts.CommandOverride; got != nil {
+		t.Fatalf("expected no override for codex, got %v", got)
+	}
+	if got := executors[executor.ExecutorQwen].startOpts.CommandOverride; strings.Join(got, " ") != "/opt/qwen {prompt}" {
+		t.Fatalf("expected the qwen override, got %v", got)
+	}
+}
+
+func TestContinueTask_WorkingDirOverride(t *testing.T) {
+	registry := executor.NewRegistry()
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+
+	re := &resumeExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
+	registry.Register(string(executor.ExecutorCodex), executor.FactoryFunc(func() (executor.Executor, error) {
+		return re, nil
+	}))
+
+	sessionID := "moved-session"
+	client.requests[sessionID] = executor.ExecuteRequest{Executor: executor.ExecutorCodex, WorkingDir: "/old/repo"}
+	client.resumeInfo[sessionID] = executor.ResumeState{SessionID: "conv-123"}
+
+	err := client.ContinueTaskWithOptions(context.Background(), sessionID, executor.ContinueRequest{
+		Message:    "resume me",
+		WorkingDir: filepath.Join(t.TempDir(), "missing"),
+	})
+	if !errors.Is(err, ErrInvalidWorkingDir) {
+		t.Fatalf("expected ErrInvalidWorkingDir, got %v", err)
+	}
+
+	newDir := t.TempDir()
+	if err := client.ContinueTaskWithOptions(context.Background(), sessionID, executor.ContinueRequest{
+		Message:    "resume me",
+		WorkingDir: newDir,
+	}); err != nil {
+		t.Fatalf("continue failed: %v", err)
+	}
+	if re.startOpts.WorkingDir != newDir {
+		t.Fatalf("expected overridden working dir %q, got %q", newDir, re.startOpts.WorkingDir)
+	}
+	if req, _, _ := client.getSessionRuntime(sessionID); req.WorkingDir != newDir {
+		t.Fatalf("expected override to be stored for later resumes, got %q", req.WorkingDir)
+	}
+}
+
+func TestContinueTask_ReasoningEffortOverride(t *testing.T) {
+	t.Run("LiveSession", func(t *testing.T) {
+		registry := executor.NewRegistry()
+		client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+		exec := &turnExecutor{blockingExecutor: blockingExecutor{logs: make(chan executor.Log, 10)}}
+		registry.Register("turns", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+
+		resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: "turns"})
+		if err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		if err := client.ContinueTaskWithOptions(context.Background(), resp.SessionID, executor.ContinueRequest{Message: "harder", ModelReasoningEffort: "high"}); err != nil {
+			t.Fatalf("continue failed: %v", err)
+		}
+		if exec.turn.ModelReasoningEffort != "high" || exec.turnMessage != "harder" {
+			t.Fatalf("expected the override on the continued turn, got %+v %q", exec.turn, exec.turnMessage)
+		}
+		_ = exec.Close()
+	})
+
+	t.Run("ResumedSession", func(t *testing.T) {
+		registry := executor.NewRegistry()
+		client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+		re := &resumeExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
+		registry.Register(string(executor.ExecutorCodex), executor.FactoryFunc(func() (executor.Executor, error) { return re, nil }))
+
+		sessionID := "effort-session"
+		client.requests[sessionID] = executor.ExecuteRequest{Executor: executor.ExecutorCodex}
+		client.resumeInfo[sessionID] = executor.ResumeState{SessionID: "conv-123"}
+		if err := client.ContinueTaskWithOptions(context.Background(), sessionID, executor.ContinueRequest{Message: "harder", ModelReasoningEffort: "high"}); err != nil {
+			t.Fatalf("continue failed: %v", err)
+		}
+		if re.startOpts.ModelReasoningEffort != "high" {
+			t.Fatalf("expected the override on the resumed run, got %q", re.startOpts.ModelReasoningEffort)
+		}
+	})
+}
+
+type turnExecutor struct {
+	blockingExecutor
+	turnMessage string
+	turn        executor.TurnOptions
+}
+
+func (m *turnExecutor) SendTurn(ctx context.Context, message string, turn executor.TurnOptions) error {
+	m.turnMessage = message
+	m.turn = turn
+	return nil
+}
+
+func TestSessionStore_RestoresSessionsAfterRestart(t *testing.T) {
+	sessionStore, err := store.NewFileSessionStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new session store failed: %v", err)
+	}
+
+	registry := executor.NewRegistry()
+	running := &codexResumeExecutor{blockingExecutor: &blockingExecutor{logs: make(chan executor.Log, 10)}}
+	running.logs <- executor.Log{Type: "output", Content: `{"id":3,"result":{"conversationId":"conv-restored"}}`}
+	registry.Register(string(executor.ExecutorCodex), executor.FactoryFunc(func() (executor.Executor, error) {
+		return running, nil
+	}))
+	client := NewWithOptions(ClientOptions{Registry: registry, SessionStore: sessionStore})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{
+		Prompt:   "persist me",
+		Executor: executor.ExecutorCodex,
+		Labels:   map[string]string{"team": "a"},
+		Kind:     "review",
+	})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	waitFor(t, func() bool {
+		records, _ := sessionStore.Load(context.Background())
+		return len(records) == 1 && len(records[0].Resume) > 0
+	})
+
+	// Simulate a restart: a new client with fresh registry and the same store.
+	restartedRegistry := executor.NewRegistry()
+	re := &resumeExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
+	restartedRegistry.Register(string(executor.ExecutorCodex), executor.FactoryFunc(func() (executor.Executor, error) {
+		return re, nil
+	}))
+	restarted := NewWithOptions(ClientOptions{Registry: restartedRegistry, SessionStore: sessionStore})
+
+	sessions := restarted.ListSessions(context.Background())
+	if len(sessions) != 1 || sessions[0].SessionID != resp.SessionID || sessions[0].Labels["team"] != "a" || sessions[0].Kind != "review" {
+		t.Fatalf("expected restored session, got %+v", sessions)
+	}
+	if sessions[0].Status != executor.SessionStatusInterrupted {
+		t.Fatalf("expected running session to restore as interrupted, got %s", sessions[0].Status)
+	}
+	if err := restarted.WaitContext(context.Background(), resp.SessionID); err != nil {
+		t.Fatalf("expected restored session to be waitable, got %v", err)
+	}
+
+	if err := restarted.ContinueTask(context.Background(), resp.SessionID, "resume me"); err != nil {
+		t.Fatalf("continue after restart failed: %v", err)
+	}
+	if re.startOpts.ResumeSessionID != "conv-restored" {
+		t.Fatalf("expected restored resume state, got %+v", re.startOpts)
+	}
+	_ = running.Close()
+	// Let both runs finish persisting before the store directory is removed.
+	_ = restarted.WaitContext(context.Background(), resp.SessionID)
+	_ = client.WaitContext(context.Background(), resp.SessionID)
+}
+
+func TestResumeTokenAcrossClients(t *testing.T) {
+	key := []byte("shared-secret")
+
+	registry := executor.NewRegistry()
+	running := &codexResumeExecutor{blockingExecutor: &blockingExecutor{logs: make(chan executor.Log, 10)}}
+	registry.Register(string(executor.ExecutorCodex), executor.FactoryFunc(func() (executor.Executor, error) {
+		return running, nil
+	}))
+	origin := NewWithOptions(ClientOptions{Registry: registry, ResumeTokenKey: key})
+
+	resp, err := origin.Execute(context.Background(), executor.ExecuteRequest{
+		Prompt:   "hand me over",
+		Executor: executor.ExecutorCodex,
+		Model:    "gpt-5-codex",
+		Kind:     "review",
+	})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	running.logs <- executor.Log{Type: "output", Content: `{"id":3,"result":{"conversationId":"conv-token","rolloutPath":"/tmp/rollout.jsonl"}}`}
+	running.logs <- executor.Log{Type: "done", Content: "finished"}
+	_ = origin.WaitContext(context.Background(), resp.SessionID)
+
+	token, err := origin.ExportResumeToken(resp.SessionID)
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if _, err := origin.ExportResumeToken("missing"); !errors.Is(err, executor.ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+
+	newClient := func(key []byte) (*Client, *resumeExecutor) {
+		re := &resumeExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
+		registry := executor.NewRegistry()
+		registry.Register(string(executor.ExecutorCodex), executor.FactoryFunc(func() (executor.Executor, error) {
+			return re, nil
+		}))
+		return NewWithOptions(ClientOptions{Registry: registry, ResumeTokenKey: key}), re
+	}
+
+	target, re := newClient(key)
+	resumed, err := target.ExecuteFromResumeToken(context.Background(), token, "keep going")
+	if err != nil {
+		t.Fatalf("execute from token failed: %v", err)
+	}
+	if resumed.SessionID != resp.SessionID {
+		t.Fatalf("expected session %s, got %s", resp.SessionID, resumed.SessionID)
+	}
+	_ = target.WaitContext(context.Background(), resumed.SessionID)
+	if re.startPrompt != "keep going" || re.startOpts.ResumeSessionID != "conv-token" || re.startOpts.ResumePath != "/tmp/rollout.jsonl" || re.startOpts.Model != "gpt-5-codex" {
+		t.Fatalf("expected a codex resume of the original request, got %q %+v", re.startPrompt, re.startOpts)
+	}
+	session, ok := target.GetSession(resumed.SessionID)
+	if !ok || session.Executor != executor.ExecutorCodex || session.Kind != "review" || session.Status != executor.SessionStatusDone {
+		t.Fatalf("expected the session to be registered and finished, got %+v", session)
+	}
+
+	other, _ := newClient([]byte("other-secret"))
+	if _, err := other.ExecuteFromResumeToken(context.Background(), token, "keep going"); !errors.Is(err, ErrInvalidResumeToken) {
+		t.Fatalf("expected ErrInvalidResumeToken for another key, got %v", err)
+	}
+	if _, err := target.ExecuteFromResumeToken(context.Background(), token[1:], "keep going"); !errors.Is(err, ErrInvalidResumeToken) {
+		t.Fatalf("expected ErrInvalidResumeToken for a tampered token, got %v", err)
+	}
+	unkeyed, _ := newClient(nil)
+	if _, err := unkeyed.ExecuteFromResumeToken(context.Background(), token, "keep going"); !errors.Is(err, ErrResumeTokenKeyRequired) {
+		t.Fatalf("expected ErrResumeTokenKeyRequired, got %v", err)
+	}
+
+	// The target has moved on since the token was issued, so replaying the
+	// token must not roll its state back.
+	target.sessionsMu.Lock()
+	target.resumeInfo[resp.SessionID] = executor.ResumeState{SessionID: "conv-newer"}
+	target.sessionsMu.Unlock()
+	payload, err := target.decodeResumeToken(token)
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	target.adoptResumeToken(payload)
+	if _, resume, _ := target.getSessionRuntime(resp.SessionID); resume.SessionID != "conv-newer" {
+		t.Fatalf("expected an older token not to overwrite the session, got %+v", resume)
+	}
+
+	expired := payload
+	expired.IssuedAt = time.Now().Add(-2 * time.Hour)
+	expired.ExpiresAt = time.Now().Add(-time.Hour)
+	data, _ := json.Marshal(expired)
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	token = encoded + "." + base64.RawURLEncoding.EncodeToString(target.signResumeToken(encoded))
+	if _, err := target.ExecuteFromResumeToken(context.Background(), token, "keep going"); !errors.Is(err, ErrResumeTokenExpired) {
+		t.Fatalf("expected ErrResumeTokenExpired, got %v", err)
+	}
+}
+
+func TestSessionStore_DebouncesSummaryUpdates(t *testing.T) {
+	fileStore, err := store.NewFileSessionStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new session store failed: %v", err)
+	}
+	sessionStore := &countingSessionStore{SessionStore: fileStore}
+
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 100)}
+	registry.Register("custom", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	client := NewWithOptions(ClientOptions{
+		Registry:               registry,
+		SessionStore:           sessionStore,
+		SessionPersistInterval: 50 * time.Millisecond,
+	})
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "persist me", Executor: "custom"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		exec.logs <- executor.Log{Type: "stdout", Content: fmt.Sprintf("line %d", i)}
+	}
+	waitFor(t, func() bool {
+		events, _ := client.ListEvents(context.Background(), resp.SessionID, 0, 0)
+		return len(events) == 51
+	})
+	lastUpdate := client.ListSessions(context.Background())[0].UpdatedAt
+
+	// The running summary is flushed once the interval passes.
+	waitFor(t, func() bool {
+		records, _ := fileStore.Load(context.Background())
+		return len(records) == 1 && records[0].Session.UpdatedAt.Equal(lastUpdate)
+	})
+
+	exec.logs <- executor.Log{Type: "done", Content: "finished"}
+	_ = exec.Close()
+	_ = client.WaitContext(context.Background(), resp.SessionID)
+
+	if saves := sessionStore.saves.Load(); saves >= 20 {
+		t.Fatalf("expected summary writes to be debounced, got %d saves for 52 events", saves)
+	}
+
+	records, err := fileStore.Load(context.Background())
+	if err != nil || len(records) != 1 || records[0].Session.Status != executor.SessionStatusDone {
+		t.Fatalf("expected terminal status to be persisted, got %+v err=%v", records, err)
+	}
+	restarted := NewWithOptions(ClientOptions{Registry: executor.NewRegistry(), SessionStore: fileStore})
+	sessions := restarted.ListSessions(context.Background())
+	if len(sessions) != 1 || sessions[0].SessionID != resp.SessionID || sessions[0].Status != executor.SessionStatusDone {
+		t.Fatalf("expected done session to be recovered, got %+v", sessions)
+	}
+}
+
+type countingSessionStore struct {
+	store.SessionStore
+	saves atomic.Int32
+}
+
+func (s *countingSessionStore) Save(ctx context.Context, record store.SessionRecord) error {
+	s.saves.Add(1)
+	return s.SessionStore.Save(ctx, record)
+}
+
+func TestContinueTask_ResumeUnavailable(t *testing.T) {
+	registry := executor.NewRegistry()
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+	sessionID := "resume-unavailable"
+	client.requests[sessionID] = executor.ExecuteRequest{
+		Executor: executor.ExecutorClaudeCode,
+	}
+
+	err := client.ContinueTask(context.Background(), sessionID, "resume")
+	if err != ErrResumeUnavailable {
+		t.Fatalf("expected ErrResumeUnavailable, got %v", err)
+	}
+}
+
+func TestCaptureResumeState(t *testing.T) {
+	client := NewWithOptions(ClientOptions{
+		Registry:      executor.NewRegistry(),
+		StreamManager: streaming.NewManager(),
+		EventStore:    store.NewMemoryEventStore(),
+	})
+
+	client.captureResumeState("s1", claude.NewClient(), executor.Log{
+		Type:    "stdout",
+		Content: `{"type":"result","session_id":"claude-sid-1","result":"ok"}`,
+	})
+	if client.resumeInfo["s1"].SessionID != "claude-sid-1" {
+		t.Fatalf("expected claude session id captured, got %+v", client.resumeInfo["s1"])
+	}
+	client.captureResumeState("s1", claude.NewClient(), executor.Log{Type: "stderr", Content: "[claude] not json"})
+	if client.resumeInfo["s1"].SessionID != "claude-sid-1" {
+		t.Fatalf("expected unrelated output to keep claude session id, got %+v", client.resumeInfo["s1"])
+	}
+
+	client.captureResumeState("s2", codex.NewClient(), executor.Log{
+		Type:    "output",
+		Content: `{"id":3,"result":{"conversationId":"conv-1","rolloutPath":"/tmp/rollout.jsonl"}}`,
+	})
+	if client.resumeInfo["s2"].SessionID != "conv-1" {
+		t.Fatalf("expected codex conversation captured, got %+v", client.resumeInfo["s2"])
+	}
+	if client.resumeInfo["s2"].Path != "/tmp/rollout.jsonl" {
+		t.Fatalf("expected codex rollout path captured, got %+v", client.resumeInfo["s2"])
+	}
+	upstream, ok := client.ResumeState("s2")
+	if want := (UpstreamSession{ID: "conv-1", RolloutPath: "/tmp/rollout.jsonl"}); !ok || upstream != want {
+		t.Fatalf("expected public resume state %+v, got %+v (ok=%v)", want, upstream, ok)
+	}
+
+	droidClient := droid.NewClient(nil)
+	client.captureResumeState("s3", droidClient, executor.Log{
+		Type:    "droid_system",
+		Content: droid.DroidEvent{Type: droid.EventTypeSystem, SessionID: "droid-sid-1"},
+	})
+	if client.resumeInfo["s3"].SessionID != "droid-sid-1" {
+		t.Fatalf("expected droid session id captured, got %+v", client.resumeInfo["s3"])
+	}
+	client.captureResumeState("s3", droidClient, executor.Log{
+		Type:    "droid_message",
+		Content: map[string]any{"type": "message", "session_id": "droid-sid-2"},
+	})
+	if client.resumeInfo["s3"].SessionID != "droid-sid-2" {
+		t.Fatalf("expected droid session id from message, got %+v", client.resumeInfo["s3"])
+	}
+	client.captureResumeState("s3", droidClient, executor.Log{
+		Type:    "droid_tool_call",
+		Content: droid.DroidEvent{Type: droid.EventTypeToolCall, SessionID: "ignored"},
+	})
+	if client.resumeInfo["s3"].SessionID != "droid-sid-2" {
+		t.Fatalf("expected tool calls not to change droid session id, got %+v", client.resumeInfo["s3"])
+	}
+
+	client.captureResumeState("s4", &testExecutor{}, executor.Log{Type: "stdout", Content: `{"session_id":"x"}`})
+	if _, ok := client.resumeInfo["s4"]; ok {
+		t.Fatal("expected executors without ResumeCapturer to be skipped")
+	}
+	if _, ok := client.ResumeState("s4"); ok {
+		t.Fatal("expected no resume state without an upstream id")
+	}
+}
+
+func TestCaptureExecutorVersion(t *testing.T) {
+	client := NewWithOptions(ClientOptions{
+		Registry:      executor.NewRegistry(),
+		StreamManager: streaming.NewManager(),
+		EventStore:    store.NewMemoryEventStore(),
+	})
+	for _, id := range []string{"claude", "codex", "droid", "custom"} {
+		client.upsertSession(executor.Session{SessionID: id})
+	}
+
+	cases := []struct {
+		sessionID string
+		exec      executor.Executor
+		log       executor.Log
+		want      string
+	}{
+		{"claude", claude.NewClient(), executor.Log{
+			Type:    "stdout",
+			Content: `{"type":"system","subtype":"init","session_id":"sid","claude_code_version":"2.0.14"}`,
+		}, "2.0.14"},
+		{"codex", codex.NewClient(), executor.Log{
+			Type:    "output",
+			Content: `{"id":1,"result":{"userAgent":"codex_cli_rs/0.46.0 (Mac OS 15.0.0; arm64) xterm"}}`,
+		}, "0.46.0"},
+		{"droid", droid.NewClient(nil), executor.Log{
+			Type:    "droid_system",
+			Content: droid.DroidEvent{Type: droid.EventTypeSystem, SessionID: "sid", Version: "0.19.3"},
+		}, "0.19.3"},
+		{"custom", &testExecutor{}, executor.Log{Type: "stdout", Content: `{"version":"1.0"}`}, ""},
+	}
+	for _, tc := range cases {
+		client.captureExecutorVersion(tc.sessionID, tc.exec, tc.log)
+		client.captureExecutorVersion(tc.sessionID, tc.exec, executor.Log{Type: "stdout", Content: "unrelated"})
+		if got := client.sessions[tc.sessionID].ExecutorVersion; got != tc.want {
+			t.Fatalf("%s: expected version %q, got %q", tc.sessionID, tc.want, got)
+		}
+	}
+}
+
+func TestContinueTask_ResumeDroid(t *testing.T) {
+	registry := executor.NewRegistry()
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
+
+	re := &resumeExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
+	registry.Register(string(executor.ExecutorDroid), executor.FactoryFunc(func() (executor.Executor, error) {
+		return re, nil
+	}))
+
+	sessionID := "droid-resume-session"
+	client.requests[sessionID] = executor.ExecuteRequest{Executor: executor.ExecutorDroid}
+	if err := client.ContinueTask(context.Background(), sessionID, "resume"); err != ErrResumeUnavailable {
+		t.Fatalf("expected ErrResumeUnavailable without a captured id, got %v", err)
+	}
+
+	client.resumeInfo[sessionID] = executor.ResumeState{SessionID: "droid-sid"}
+	if err := client.ContinueTask(context.Background(), sessionID, "resume"); err != nil {
+		t.Fatalf("continue failed: %v", err)
+	}
+	if re.startOpts.ResumeSessionID != "droid-sid" {
+		t.Fatalf("expected droid resume session id, got %+v", re.startOpts)
+	}
+}
+
+func TestSetAndGetSessionRuntime(t *testing.T) {
+	client := NewWithOptions(ClientOptions{
+		Registry:      executor.NewRegistry(),
+		StreamManager: streaming.NewManager(),
+		EventStore:    store.NewMemoryEventStore(),
+	})
+
+	req := executor.ExecuteRequest{Prompt: "hello", Executor: executor.ExecutorCodex}
+	client.setSessionRequest("sid", req)
+	client.resumeInfo["sid"] = executor.ResumeState{SessionID: "conv-x"}
+
+	gotReq, gotResume, ok := client.getSessionRuntime("sid")
+	if !ok {
+		t.Fatalf("expected session runtime found")
+	}
+	if gotReq.Executor != executor.ExecutorCodex || gotResume.SessionID != "conv-x" {
+		t.Fatalf("unexpected runtime: req=%+v resume=%+v", gotReq, gotResume)
+	}
+}
+
+func TestNewAndRegisterExecutor(t *testing.T) {
+	client := New()
+	client.RegisterExecutor("x", executor.FactoryFunc(func() (executor.Executor, error) {
+		return &testExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}, nil
+	}))
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{
+		Prompt:   "hello",
+		Executor: "x",
+	})
+	if err != nil {
+		t.Fatalf("execute with registered executor failed: %v", err)
+	}
+	if resp.SessionID == "" {
+		t.Fatalf("expected session id")
+	}
+}
+
+func TestExecutorsReportsCapabilities(t *testing.T) {
+	client := NewWithOptions(ClientOptions{Registry: executor.NewRegistry()})
+	client.RegisterExecutor("plain", executor.FactoryFunc(func() (executor.Executor, error) {
+		return &testExecutor{logs: make(chan executor.Log, 1), done: make(chan struct{})}, nil
+	}))
+	client.RegisterExecutor("resumable", executor.FactoryFunc(func() (executor.Executor, error) {
+		return &resumeExecutor{logs: make(chan executor.Log, 1), done: make(chan struct{})}, nil
+	}))
+
+	infos := client.Executors()
+	if len(infos) != 2 || infos[0].Name != "plain" || infos[1].Name != "resumable" {
+		t.Fatalf("unexpected executors: %+v", infos)
+	}
+	if infos[0].SupportsResume || infos[0].SupportsControl || infos[0].SupportsInteractive {
+		t.Fatalf("expected no capabilities by default, got %+v", infos[0])
+	}
+	if !infos[1].SupportsResume || infos[1].SupportsInteractive {
+		t.Fatalf("expected resume capability, got %+v", infos[1])
+	}
+}
+
+func TestModelsCachesListings(t *testing.T) {
+	client := NewWithOptions(ClientOptions{Registry: executor.NewRegistry(), ModelsCacheTTL: 50 * time.Millisecond, Launcher: "bunx"})
+	factory := &modelsFactory{models: []string{"model-a", "model-b"}}
+	client.RegisterExecutor("listing", factory)
+	client.RegisterExecutor(string(executor.ExecutorGemini), executor.FactoryFunc(func() (executor.Executor, error) {
+		return &testExecutor{logs: make(chan executor.Log, 1), done: make(chan struct{})}, nil
+	}))
+
+	for i := 0; i < 3; i++ {
+		models, err := client.Models(context.Background(), "listing")
+		if err != nil || !reflect.DeepEqual(models, factory.models) {
+			t.Fatalf("unexpected models %v (%v)", models, err)
+		}
+	}
+	if factory.calls.Load() != 1 {
+		t.Fatalf("expected one listing within the TTL, got %d", factory.calls.Load())
+	}
+	if factory.launcher != "bunx" {
+		t.Fatalf("expected the listing to use the client's launcher, got %q", factory.launcher)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, err := client.Models(context.Background(), "listing"); err != nil || factory.calls.Load() != 2 {
+		t.Fatalf("expected the list to be refreshed after the TTL, got %d calls (%v)", factory.calls.Load(), err)
+	}
+
+	models, err := client.Models(context.Background(), string(executor.ExecutorGemini))
+	if err != nil || !reflect.DeepEqual(models, executor.DefaultModels[executor.ExecutorGemini]) {
+		t.Fatalf("expected the default Gemini models, got %v (%v)", models, err)
+	}
+	if _, err := client.Models(context.Background(), "missing"); !errors.Is(err, executor.ErrUnknownExecutorType) {
+		t.Fatalf("expected ErrUnknownExecutorType, got %v", err)
+	}
+}
+
+type modelsFactory struct {
+	models   []string
+	calls    atomic.Int32
+	launcher string
+}
+
+func (f *modelsFactory) Create() (executor.Executor, error) {
+	return &testExecutor{logs: make(chan executor.Log, 1), done: make(chan struct{})}, nil
+}
+
+func (f *modelsFactory) Models(ctx context.Context, opts executor.Options) ([]string, error) {
+	f.calls.Add(1)
+	f.launcher = opts.Launcher
+	return f.models, nil
+}
+
+func TestResumeRespondGetEventsAndShutdown(t *testing.T) {
+	registry := executor.NewRegistry()
+	client := NewWithOptions(ClientOptions{
+		Registry:      registry,
+		StreamManager: streaming.NewManager(),
+		EventStore:    store.NewMemoryEventStore(),
+	})
+
+	mock := &testExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
+	registry.Register("test", executor.FactoryFunc(func() (executor.Executor, error) { return mock, nil }))
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "x", Executor: "test"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	if err := client.ResumeTask(context.Background(), resp.SessionID, "resume"); err != nil {
+		t.Fatalf("resume failed: %v", err)
+	}
+	if err := client.RespondControl(context.Background(), resp.SessionID, executor.ControlResponse{
+		RequestID: "req-1",
+		Decision:  executor.ControlDecisionApprove,
+	}); err != nil {
+		t.Fatalf("respond control failed: %v", err)
+	}
+
+	events, ok := client.GetSessionEvents(resp.SessionID)
+	if !ok || len(events) == 0 {
+		time.Sleep(50 * time.Millisecond)
+		events, ok = client.GetSessionEvents(resp.SessionID)
+		if !ok || len(events) == 0 {
+			t.Fatalf("expected session events")
+		}
+	}
+
+	client.Shutdown()
+}
+
+func TestShutdownContextDrainsSessions(t *testing.T) {
+	registry := executor.NewRegistry()
+	draining := &drainingExecutor{blockingExecutor: &blockingExecutor{logs: make(chan executor.Log, 10)}}
+	stuck := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register("draining", executor.FactoryFunc(func() (executor.Executor, error) { return draining, nil }))
+	registry.Register("stuck", executor.FactoryFunc(func() (executor.Executor, error) { return stuck, nil }))
+	client := NewWithOptions(ClientOptions{
+		Registry:      registry,
+		StreamManager: streaming.NewManager(),
+		EventStore:    store.NewMemoryEventStore(),
+	})
+
+	drained, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "a", Executor: "draining"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if _, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "b", Executor: "stuck"}); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	report := client.ShutdownContext(ctx)
+
+	if report.Drained != 1 || report.ForceClosed != 1 {
+		t.Fatalf("expected 1 drained and 1 force-closed session, got %+v", report)
+	}
+	if !stuck.interrupted.Load() || !stuck.closed.Load() {
+		t.Fatal("expected stuck session to be interrupted and then closed")
+	}
+	events, _ := client.ListEvents(context.Background(), drained.SessionID, 0, 0)
+	if len(events) == 0 || events[len(events)-1].Type != "done" {
+		t.Fatalf("expected drained session to store its final done event, got %+v", events)
+	}
+}
+
+func TestCloseWaitsForTerminalSessions(t *testing.T) {
+	registry := executor.NewRegistry()
+	draining := &drainingExecutor{blockingExecutor: &blockingExecutor{logs: make(chan executor.Log, 10)}}
+	stuck := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register("draining", executor.FactoryFunc(func() (executor.Executor, error) { return draining, nil }))
+	registry.Register("stuck", executor.FactoryFunc(func() (executor.Executor, error) { return stuck, nil }))
+	eventStore := &closingEventStore{EventStore: store.NewMemoryEventStore()}
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: eventStore})
+
+	drained, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "a", Executor: "draining"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	forced, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "b", Executor: "stuck"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = client.Close(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the force-close to report the deadline, got %v", err)
+	}
+
+	// No waiting: every session must already be terminal.
+	if status := sessionStatus(client, drained.SessionID); status != executor.SessionStatusDone {
+		t.Fatalf("expected drained session to be done, got %s", status)
+	}
+	if status := sessionStatus(client, forced.SessionID); status != executor.SessionStatusInterrupted {
+		t.Fatalf("expected force-closed session to be interrupted, got %s", status)
+	}
+	if client.SessionRunning(drained.SessionID) || client.SessionRunning(forced.SessionID) {
+		t.Fatal("expected no running sessions after Close")
+	}
+	if got := eventStore.closed.Load(); got != 1 {
+		t.Fatalf("expected Close to close the event store once, got %d", got)
+	}
+
+	idle := NewWithOptions(ClientOptions{Registry: executor.NewRegistry()})
+	if err := idle.Close(context.Background()); err != nil {
+		t.Fatalf("expected a clean Close without sessions, got %v", err)
+	}
+}
+
+func TestShutdownClosesEventStore(t *testing.T) {
+	eventStore := &closingEventStore{EventStore: store.NewMemoryEventStore()}
+	client := NewWithOptions(ClientOptions{
+		Registry:      executor.NewRegistry(),
+		StreamManager: streaming.NewManager(),
+		EventStore:    eventStore,
+	})
+
+	client.Shutdown()
+	if got := eventStore.closed.Load(); got != 1 {
+		t.Fatalf("expected Shutdown to close the event store once, got %d", got)
+	}
+}
+
+func TestStoreFailurePolicy(t *testing.T) {
+	start := func(t *testing.T, policy StoreFailurePolicy) (*Client, *blockingExecutor, *fullEventStore, string) {
+		registry := executor.NewRegistry()
+		exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+		registry.Register("mock", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+		events := &fullEventStore{EventStore: store.NewMemoryEventStore()}
+		client := NewWithOptions(ClientOptions{Registry: registry, EventStore: events, StoreFailurePolicy: policy})
+		resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hi", Executor: "mock"})
+		if err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		return client, exec, events, resp.SessionID
+	}
+
+	t.Run("DropEvents", func(t *testing.T) {
+		client, exec, events, sessionID := start(t, "")
+		events.full.Store(true)
+		exec.logs <- executor.Log{Type: "stdout", Content: "lost 1"}
+		exec.logs <- executor.Log{Type: "stdout", Content: "lost 2"}
+		waitFor(t, func() bool {
+			session, _ := client.GetSession(sessionID)
+			return session.DroppedEvents == 2
+		})
+		events.full.Store(false)
+		exec.logs <- executor.Log{Type: "done", Content: "finished"}
+		waitFor(t, func() bool { return sessionStatus(client, sessionID) == executor.SessionStatusDone })
+
+		stored, _ := client.ListEvents(context.Background(), sessionID, 0, 0)
+		if len(stored) != 2 || stored[1].Type != "done" {
+			t.Fatalf("expected the started and done events only, got %+v", stored)
+		}
+	})
+
+	t.Run("FailSession", func(t *testing.T) {
+		client, exec, events, sessionID := start(t, StoreFailureFailSession)
+		live, unsubscribe := client.Subscribe(sessionID, executor.SubscribeOptions{})
+		defer unsubscribe()
+		exec.logs <- executor.Log{Type: "stdout", Content: "stored"}
+		if evt := <-live; evt.Content != "stored" {
+			t.Fatalf("expected the stored event first, got %+v", evt)
+		}
+
+		events.full.Store(true)
+		exec.logs <- executor.Log{Type: "stdout", Content: "lost"}
+		waitFor(t, func() bool { return sessionStatus(client, sessionID) == executor.SessionStatusFailed })
+		if !exec.closed.Load() {
+			t.Fatal("expected the executor to be closed")
+		}
+		select {
+		case evt := <-live:
+			content, _ := transcriptContent(evt)
+			if evt.Type != "error" || content.Category != "error" || evt.Seq != 0 {
+				t.Fatalf("expected an unstored error event, got %+v", evt)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected live subscribers to be told about the failure")
+		}
+		if session, _ := client.GetSession(sessionID); session.DroppedEvents != 0 {
+			t.Fatalf("expected no dropped events to be counted, got %d", session.DroppedEvents)
+		}
+	})
+
+	t.Run("DegradeToMemory", func(t *testing.T) {
+		client, exec, events, sessionID := start(t, StoreFailureDegradeToMemory)
+		events.full.Store(true)
+		exec.logs <- executor.Log{Type: "stdout", Content: "kept 1"}
+		exec.logs <- executor.Log{Type: "stdout", Content: "kept 2"}
+		waitFor(t, func() bool {
+			stored, _ := client.ListEvents(context.Background(), sessionID, 0, 0)
+			return len(stored) == 3
+		})
+		events.full.Store(false)
+		exec.logs <- executor.Log{Type: "done", Content: "finished"}
+		waitFor(t, func() bool { return sessionStatus(client, sessionID) == executor.SessionStatusDone })
+
+		stored, err := client.ListEvents(context.Background(), sessionID, 0, 0)
+		if err != nil || len(stored) != 4 {
+			t.Fatalf("expected every event to be listed, got %d (%v)", len(stored), err)
+		}
+		for i, evt := range stored {
+			if evt.Seq != uint64(i+1) {
+				t.Fatalf("expected contiguous seqs, got %d at %d", evt.Seq, i)
+			}
+		}
+		if stored[1].Content != "kept 1" || stored[3].Type != "done" {
+			t.Fatalf("unexpected events: %+v", stored)
+		}
+		if tail, _ := client.ListEventsWithOptions(context.Background(), sessionID, store.ListOptions{Tail: 4}); len(tail) != 4 || tail[0].Seq != 1 || tail[3].Seq != 4 {
+			t.Fatalf("expected the tail to span the durable and in-memory events, got %+v", tail)
+		}
+		if durable, _ := events.EventStore.List(context.Background(), sessionID, store.ListOptions{}); len(durable) != 1 {
+			t.Fatalf("expected only the started event in the durable store, got %d", len(durable))
+		}
+		if failures := events.failures.Load(); failures != 1 {
+			t.Fatalf("expected the store to be skipped once degraded, got %d failed appends", failures)
+		}
+	})
+
+	t.Run("DegradeToMemoryForgetsHealthySessions", func(t *testing.T) {
+		client, exec, _, sessionID := start(t, StoreFailureDegradeToMemory)
+		exec.logs <- executor.Log{Type: "done", Content: "finished"}
+		if err := client.WaitContext(context.Background(), sessionID); err != nil {
+			t.Fatalf("wait failed: %v", err)
+		}
+		overlay := client.store.(*overlayStore)
+		overlay.mu.RLock()
+		_, tracked := overlay.lastSeq[sessionID]
+		overlay.mu.RUnlock()
+		if tracked {
+			t.Fatal("expected the overlay to forget a healthy session once it ended")
+		}
+		if seq, _ := client.store.LatestSeq(context.Background(), sessionID); seq != 2 {
+			t.Fatalf("expected the store's latest seq, got %d", seq)
+		}
+	})
+}
+
+func TestOnRawLogHook(t *testing.T) {
+	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register("mock", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
+	events := &fullEventStore{EventStore: store.NewMemoryEventStore()}
+
+	var mu sync.Mutex
+	var raw []executor.Log
+	client := NewWithOptions(ClientOptions{
+		Registry:   registry,
+		EventStore: events,
+		Hooks: executor.Hooks{
+			OnRawLog: func(ctx context.Context, sessionID, executorName string, log executor.Log) {
+				mu.Lock()
+				defer mu.Unlock()
+				if executorName != "mock" {
+					t.Errorf("expected the executor name, got %q", executorName)
+				}
+				raw = append(raw, log)
+			},
+		},
+	})
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hi", Executor: "mock"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+
+	emitted := []executor.Log{
+		{Type: "stdout", Content: `{"type":"assistant","text":"lost"}`},
+		{Type: "stderr", Content: "also lost"},
+		{Type: "stdout", Content: `{"type":"assistant","text":"kept"}`},
+		{Type: "done", Content: "finished"},
+	}
+	events.full.Store(true)
+	exec.logs <- emitted[0]
+	exec.logs <- emitted[1]
+	waitFor(t, func() bool { return events.failures.Load() == 2 })
+	events.full.Store(false)
+	exec.logs <- emitted[2]
+	exec.logs <- emitted[3]
+	_ = client.WaitContext(context.Background(), resp.SessionID)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(raw, emitted) {
+		t.Fatalf("expected every emitted log, including rejected ones, got %+v", raw)
+	}
+}
+
+// fullEventStore rejects appends while full is set, like a store whose disk
+// has filled up.
+type fullEventStore struct {
+	store.EventStore
+	full     atomic.Bool
+	failures atomic.Int32
+}
+
+func (s *fullEventStore) Append(ctx context.Context, evt executor.Event) (executor.Event, error) {
+	if s.full.Load() {
+		s.failures.Add(1)
+		return executor.Event{}, errors.New("no space left on device")
+	}
+	return s.EventStore.Append(ctx, evt)
+}
+
+type closingEventStore struct {
+	store.EventStore
+	closed atomic.Int32
+}
+
+func (s *closingEventStore) Close() error {
+	s.closed.Add(1)
+	return s.EventStore.Close()
+}
+
+func TestExecutePromptTemplates(t *testing.T) {
+	registry := executor.NewRegistry()
+	client := NewWithOptions(ClientOptions{
+		Registry:      registry,
+		StreamManager: streaming.NewManager(),
+		EventStore:    store.NewMemoryEventStore(),
+		PromptTemplates: map[executor.ExecutorType]PromptTemplate{
+			"framed": {Prefix: "You are reviewing Go code.\n\n", Suffix: "\n\nReply in English."},
+		},
+	})
+	executors := map[string]*resumeExecutor{}
+	for _, name := range []string{"framed", "plain"} {
+		re := &resumeExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
+		executors[name] = re
+		registry.Register(name, executor.FactoryFunc(func() (executor.Executor, error) { return re, nil }))
+	}
+
+	for _, name := range []string{"framed", "plain"} {
+		resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "check the parser", Executor: executor.ExecutorType(name)})
+		if err != nil {
+			t.Fatalf("execute %s failed: %v", name, err)
+		}
+		if session, _ := client.GetSession(resp.SessionID); session.Title != "check the parser" {
+			t.Fatalf("expected the title from the original prompt, got %q", session.Title)
+		}
+	}
+
+	if got, want := executors["framed"].startPrompt, "You are reviewing Go code.\n\ncheck the parser\n\nReply in English."; got != want {
+		t.Fatalf("expected templated prompt %q, got %q", want, got)
+	}
+	if got := executors["plain"].startPrompt; got != "check the parser" {
+		t.Fatalf("expected the prompt unchanged for executors without a template, got %q", got)
+	}
+}
+
+func TestExecuteContextFiles(t *testing.T) {
+	registry := executor.NewRegistry()
+	client := NewWithOptions(ClientOptions{
+		Registry:        registry,
+		StreamManager:   streaming.NewManager(),
+		EventStore:      store.NewMemoryEventStore(),
+		MaxContextBytes: 64,
+	})
+	re := &resumeExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
+	registry.Register("test", executor.FactoryFunc(func() (executor.Executor, error) { return re, nil }))
+
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, "notes.md"), []byte("remember the milk"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{
+		Prompt:       "summarize notes",
+		Executor:     "test",
+		WorkingDir:   workDir,
+		ContextFiles: []string{"notes.md"},
+	})
+	if err != nil {
+		t.Fatalf("execute with context files failed: %v", err)
+	}
+	if !strings.Contains(re.startPrompt, "remember the milk") || !strings.HasSuffix(re.startPrompt, "summarize notes") {
+		t.Fatalf("expected file contents prepended to prompt, got %q", re.startPrompt)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if session := client.ListSessions(context.Background())[0]; session.SessionID != resp.SessionID || session.Title != "summarize notes" {
+		t.Fatalf("expected title from original prompt, got %+v", session)
+	}
+
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	_ = os.WriteFile(outside, []byte("secret"), 0o644)
+	for _, path := range []string{outside, "../" + filepath.Base(filepath.Dir(outside)) + "/secret.txt"} {
+		_, err = client.Execute(context.Background(), executor.ExecuteRequest{
+			Prompt:       "leak",
+			Executor:     "test",
+			WorkingDir:   workDir,
+			ContextFiles: []string{path},
+		})
+		if !errors.Is(err, ErrContextFileNotAllowed) {
+			t.Fatalf("expected ErrContextFileNotAllowed for %s, got %v", path, err)
+		}
+	}
+
+	_ = os.WriteFile(filepath.Join(workDir, "big.txt"), []byte(strings.Repeat("x", 128)), 0o644)
+	_, err = client.Execute(context.Background(), executor.ExecuteRequest{
+		Prompt:       "too big",
+		Executor:     "test",
+		WorkingDir:   workDir,
+		ContextFiles: []string{"big.txt"},
+	})
+	if !errors.Is(err, ErrContextFileTooLarge) {
+		t.Fatalf("expected ErrContextFileTooLarge, got %v", err)
+	}
+}
+
+func TestPathRedactorMasksAbsolutePaths(t *testing.T) {
+	registry := executor.NewRegistry()
+	client := NewWithOptions(ClientOptions{
+		Registry:      registry,
+		StreamManager: streaming.NewManager(),
+		EventStore:    store.NewMemoryEventStore(),
+		PathRedactor:  MaskAbsolutePath,
+		Transformers: map[string]executor.EventTransformer{
+			"test": func(input executor.TransformInput) executor.Event {
+				return executor.Event{Type: "tool", Content: executor.UnifiedContent{
+					Category: "tool",
+					Target:   "/srv/secret/layout/main.go",
+					Summary:  "Reading /srv/secret/layout/main.go",
+					Text:     "opened /srv/secret/layout/main.go",
+					Files: []executor.FileChange{
+						{Path: "/work/pkg/a.go", Op: executor.FileOpModify},
+						{Path: "/srv/secret/layout/b.go", Op: executor.FileOpCreate},
+						{Path: "docs/c.md", Op: executor.FileOpDelete},
+					},
+					Scope: &executor.ApprovalScope{
+						Command: "cat /srv/secret/layout/main.go",
+						Cwd:     "/work/pkg",
+						Paths:   []string{"/srv/secret/layout/main.go"},
+					},
+					Raw: map[string]any{"changes": map[string]any{"/srv/secret/layout/b.go": []any{"/work/pkg/a.go", 3}}},
+				}}
+			},
+		},
+	})
+	mock := &testExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
+	registry.Register("test", executor.FactoryFunc(func() (executor.Executor, error) { return mock, nil }))
+
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "read", Executor: "test", WorkingDir: "/work"})
 	if err != nil {
 		t.Fatalf("execute failed: %v", err)
 	}
-
 	time.Sleep(50 * time.Millisecond)
-	sessions := client.ListSessions(context.Background())
-	if len(sessions) != 1 {
-		t.Fatalf("expected 1 session, got %d", len(sessions))
-	}
 
-	session := sessions[0]
-	if session.SessionID != resp.SessionID {
-		t.Fatalf("unexpected session id: %s", session.SessionID)
+	events, _ := client.ListEvents(context.Background(), resp.SessionID, 1, 1)
+	if len(events) != 1 {
+		t.Fatalf("expected stored event")
 	}
-	if session.Status != executor.SessionStatusDone {
-		t.Fatalf("expected done status, got %s", session.Status)
+	content := events[0].Content.(executor.UnifiedContent)
+	if content.Target != ".../main.go" || content.Summary != "Reading .../main.go" || content.Text != "opened .../main.go" {
+		t.Fatalf("expected masked paths, got target=%q summary=%q text=%q", content.Target, content.Summary, content.Text)
 	}
-	if session.Title != "list sessions" {
-		t.Fatalf("unexpected session title: %q", session.Title)
+	wantRaw := map[string]any{"changes": map[string]any{".../b.go": []any{"pkg/a.go", float64(3)}}}
+	if !reflect.DeepEqual(content.Raw, wantRaw) {
+		t.Fatalf("expected masked raw payload, got %#v", content.Raw)
 	}
-	if session.Executor != "test" {
-		t.Fatalf("unexpected executor: %s", session.Executor)
+	if len(content.Files) != 3 || content.Files[0].Path != "pkg/a.go" || content.Files[1].Path != ".../b.go" || content.Files[2].Path != "docs/c.md" {
+		t.Fatalf("expected masked file change paths, got %+v", content.Files)
+	}
+	if scope := content.Scope; scope == nil || scope.Command != "cat .../main.go" || scope.Cwd != "pkg" || len(scope.Paths) != 1 || scope.Paths[0] != ".../main.go" {
+		t.Fatalf("expected masked approval scope, got %+v", content.Scope)
+	}
+
+	if got := MaskAbsolutePath("/work/pkg/a.go", "/work"); got != "pkg/a.go" {
+		t.Fatalf("expected path relative to working dir, got %q", got)
+	}
+	text := "Fetching https://example.com/docs/api into /srv/secret/api.md"
+	if got := redactPathsInText(text, "/work", MaskAbsolutePath); got != "Fetching https://example.com/docs/api into .../api.md" {
+		t.Fatalf("expected URLs to be left alone, got %q", got)
 	}
 }
 
-func TestDefaultTransformer_NormalizesCodexAndClaude(t *testing.T) {
+func TestPathRedactorMasksBuiltinTransformerEvents(t *testing.T) {
 	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register(string(executor.ExecutorClaudeCode), executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
 	client := NewWithOptions(ClientOptions{
 		Registry:      registry,
 		StreamManager: streaming.NewManager(),
 		EventStore:    store.NewMemoryEventStore(),
+		PathRedactor:  MaskAbsolutePath,
 	})
 
-	// Register fake executors under built-in names so default transformers apply.
-	registry.Register(string(executor.ExecutorCodex), executor.FactoryFunc(func() (executor.Executor, error) {
-		return &testExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}, nil
-	}))
-	registry.Register(string(executor.ExecutorClaudeCode), executor.FactoryFunc(func() (executor.Executor, error) {
-		return &testExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}, nil
-	}))
-
-	for _, execName := range []executor.ExecutorType{executor.ExecutorCodex, executor.ExecutorClaudeCode} {
-		resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: execName})
-		if err != nil {
-			t.Fatalf("execute failed for %s: %v", execName, err)
-		}
-		time.Sleep(50 * time.Millisecond)
-
-		events, err := client.ListEvents(context.Background(), resp.SessionID, 0, 0)
-		if err != nil {
-			t.Fatalf("list events failed for %s: %v", execName, err)
-		}
-		if len(events) < 2 {
-			t.Fatalf("expected at least 2 events for %s, got %d", execName, len(events))
-		}
-
-		first := events[0]
-		content, ok := first.Content.(executor.UnifiedContent)
-		if !ok {
-			t.Fatalf("expected UnifiedContent for %s, got %T", execName, first.Content)
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "read", Executor: executor.ExecutorClaudeCode, WorkingDir: "/work"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	stream, unsubscribe := client.Subscribe(resp.SessionID, executor.SubscribeOptions{ReturnAll: true})
+	defer unsubscribe()
+	exec.logs <- executor.Log{Type: "stdout", Content: `{"type":"tool_use","id":"toolu_1","tool_name":"Read","input":{"file_path":"/srv/secret/layout/main.go"}}`}
+
+	var streamed executor.Event
+	timeout := time.After(2 * time.Second)
+	for streamed.Type != "tool" {
+		select {
+		case streamed = <-stream:
+		case <-timeout:
+			t.Fatal("timed out waiting for the tool event")
 		}
-		if first.Type != "message" {
-			t.Fatalf("expected normalized type=message for %s, got %s", execName, first.Type)
+	}
+	stored, _ := client.ListEventsWithOptions(context.Background(), resp.SessionID, store.ListOptions{Types: []string{"tool"}})
+	if len(stored) != 1 {
+		t.Fatalf("expected one stored tool event, got %d", len(stored))
+	}
+	for name, evt := range map[string]executor.Event{"streamed": streamed, "stored": stored[0]} {
+		data, _ := json.Marshal(evt)
+		if strings.Contains(string(data), "/srv/secret") {
+			t.Fatalf("expected the %s event to hide the absolute path, got %s", name, data)
 		}
-		if content.Source != string(execName) || content.SourceType != "stdout" || content.Category != "message" {
-			t.Fatalf("unexpected normalized content for %s: %#v", execName, content)
+		if !strings.Contains(string(data), `"raw"`) || !strings.Contains(string(data), ".../main.go") {
+			t.Fatalf("expected the %s event to keep a masked raw payload, got %s", name, data)
 		}
 	}
 }
 
-func TestCustomTransformer_OverridesDefault(t *testing.T) {
+func TestExecuteCancelContextClosesSession(t *testing.T) {
 	registry := executor.NewRegistry()
-	client := NewWithOptions(ClientOptions{
-		Registry:      registry,
-		StreamManager: streaming.NewManager(),
-		EventStore:    store.NewMemoryEventStore(),
-		Transformers: map[string]executor.EventTransformer{
-			string(executor.ExecutorCodex): func(input executor.TransformInput) executor.Event {
-				return executor.Event{
-					Type: "custom",
-					Content: map[string]any{
-						"text": fmt.Sprintf("%s:%v", input.Log.Type, input.Log.Content),
-					},
-				}
-			},
-		},
-	})
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
 
-	registry.Register(string(executor.ExecutorCodex), executor.FactoryFunc(func() (executor.Executor, error) {
-		return &testExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}, nil
-	}))
+	blocking := &blockingExecutor{logs: make(chan executor.Log, 1)}
+	registry.Register("blocking", executor.FactoryFunc(func() (executor.Executor, error) { return blocking, nil }))
+	finishing := &testExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
+	registry.Register("test", executor.FactoryFunc(func() (executor.Executor, error) { return finishing, nil }))
 
-	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: executor.ExecutorCodex})
+	ctx, cancel := context.WithCancel(context.Background())
+	resp, err := client.Execute(ctx, executor.ExecuteRequest{Prompt: "hello", Executor: "blocking"})
 	if err != nil {
 		t.Fatalf("execute failed: %v", err)
 	}
-	time.Sleep(50 * time.Millisecond)
+	cancel()
+	waitFor(t, func() bool { return !client.SessionRunning(resp.SessionID) })
+	if !blocking.interrupted.Load() || !blocking.closed.Load() {
+		t.Fatal("expected cancelled session to be interrupted and closed")
+	}
+	if status := sessionStatus(client, resp.SessionID); status != executor.SessionStatusInterrupted {
+		t.Fatalf("expected interrupted status, got %q", status)
+	}
 
-	events, err := client.ListEvents(context.Background(), resp.SessionID, 0, 0)
+	ctx, cancel = context.WithCancel(context.Background())
+	resp, err = client.Execute(ctx, executor.ExecuteRequest{Prompt: "hello", Executor: "test"})
 	if err != nil {
-		t.Fatalf("list events failed: %v", err)
+		t.Fatalf("execute failed: %v", err)
 	}
-	if len(events) == 0 {
-		t.Fatalf("expected events")
+	waitFor(t, func() bool { return !client.SessionRunning(resp.SessionID) })
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	if finishing.interrupted {
+		t.Fatal("expected completed session not to be interrupted on cancel")
 	}
-	if events[0].Type != "custom" {
-		t.Fatalf("expected custom transformed type, got %s", events[0].Type)
+	if status := sessionStatus(client, resp.SessionID); status != executor.SessionStatusDone {
+		t.Fatalf("expected done status, got %q", status)
 	}
 }
 
-func TestContinueTask_ResumeFromStoredRuntime(t *testing.T) {
+func TestClientWaitContext(t *testing.T) {
 	registry := executor.NewRegistry()
-	streamMgr := streaming.NewManager()
-	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streamMgr, EventStore: store.NewMemoryEventStore()})
+	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
 
-	re := &resumeExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
-	registry.Register(string(executor.ExecutorCodex), executor.FactoryFunc(func() (executor.Executor, error) {
-		return re, nil
-	}))
+	blocking := &blockingExecutor{logs: make(chan executor.Log, 1)}
+	registry.Register("blocking", executor.FactoryFunc(func() (executor.Executor, error) { return blocking, nil }))
 
-	sessionID := "resume-session"
-	client.requests[sessionID] = executor.ExecuteRequest{
-		Executor:   executor.ExecutorCodex,
-		WorkingDir: ".",
+	if err := client.WaitContext(context.Background(), "missing"); err != executor.ErrSessionNotFound {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
 	}
-	client.resumeInfo[sessionID] = sessionResumeInfo{CodexConversation: "conv-123"}
 
-	if err := client.ContinueTask(context.Background(), sessionID, "resume me"); err != nil {
-		t.Fatalf("continue failed: %v", err)
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hello", Executor: "blocking"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
 	}
-	if re.startPrompt != "resume me" {
-		t.Fatalf("expected resume message as prompt, got %q", re.startPrompt)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if err := client.WaitContext(ctx, resp.SessionID); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
 	}
-	if re.startOpts.ResumeSessionID != "conv-123" {
-		t.Fatalf("expected resume session id, got %+v", re.startOpts)
+
+	_ = blocking.Close()
+	if err := client.WaitContext(context.Background(), resp.SessionID); err != nil {
+		t.Fatalf("expected wait to return after close, got %v", err)
+	}
+	if client.SessionRunning(resp.SessionID) {
+		t.Fatal("expected session to be finished after WaitContext")
 	}
 }
 
-func TestContinueTask_ResumeUnavailable(t *testing.T) {
+func TestInterruptByLabel(t *testing.T) {
 	registry := executor.NewRegistry()
 	client := NewWithOptions(ClientOptions{Registry: registry, StreamManager: streaming.NewManager(), EventStore: store.NewMemoryEventStore()})
-	sessionID := "resume-unavailable"
-	client.requests[sessionID] = executor.ExecuteRequest{
-		Executor: executor.ExecutorClaudeCode,
-	}
-
-	err := client.ContinueTask(context.Background(), sessionID, "resume")
-	if err != ErrResumeUnavailable {
-		t.Fatalf("expected ErrResumeUnavailable, got %v", err)
-	}
-}
 
-func TestCaptureResumeState(t *testing.T) {
-	client := NewWithOptions(ClientOptions{
-		Registry:      executor.NewRegistry(),
-		StreamManager: streaming.NewManager(),
-		EventStore:    store.NewMemoryEventStore(),
-	})
+	execs := make(map[string]*blockingExecutor)
+	registry.Register("blocking", executor.FactoryFunc(func() (executor.Executor, error) {
+		return &blockingExecutor{logs: make(chan executor.Log, 1)}, nil
+	}))
 
-	client.captureResumeState("s1", string(executor.ExecutorClaudeCode), executor.Log{
-		Type:    "stdout",
-		Content: `{"type":"result","session_id":"claude-sid-1","result":"ok"}`,
-	})
-	if client.resumeInfo["s1"].ClaudeSessionID != "claude-sid-1" {
-		t.Fatalf("expected claude session id captured, got %+v", client.resumeInfo["s1"])
+	start := func(tenant string) string {
+		resp, err := client.Execute(context.Background(), executor.ExecuteRequest{
+			Prompt:   "hello",
+			Executor: "blocking",
+			Labels:   map[string]string{"tenant": tenant},
+		})
+		if err != nil {
+			t.Fatalf("execute failed: %v", err)
+		}
+		exec, _ := registry.GetSession(resp.SessionID)
+		execs[resp.SessionID] = exec.(*blockingExecutor)
+		return resp.SessionID
 	}
+	a1, a2, b1 := start("a"), start("a"), start("b")
 
-	client.captureResumeState("s2", string(executor.ExecutorCodex), executor.Log{
-		Type:    "output",
-		Content: `{"id":3,"result":{"conversationId":"conv-1","rolloutPath":"/tmp/rollout.jsonl"}}`,
-	})
-	if client.resumeInfo["s2"].CodexConversation != "conv-1" {
-		t.Fatalf("expected codex conversation captured, got %+v", client.resumeInfo["s2"])
+	affected := client.InterruptByLabel(context.Background(), "tenant", "a")
+	if len(affected) != 2 || !containsString(affected, a1) || !containsString(affected, a2) {
+		t.Fatalf("expected tenant a sessions to be interrupted, got %v", affected)
 	}
-	if client.resumeInfo["s2"].CodexRolloutPath != "/tmp/rollout.jsonl" {
-		t.Fatalf("expected codex rollout path captured, got %+v", client.resumeInfo["s2"])
+	for _, id := range []string{a1, a2} {
+		if !execs[id].interrupted.Load() || !execs[id].closed.Load() {
+			t.Fatalf("expected session %s to be interrupted and closed", id)
+		}
+		if status := sessionStatus(client, id); status != executor.SessionStatusInterrupted {
+			t.Fatalf("expected interrupted status for %s, got %q", id, status)
+		}
+	}
+	if execs[b1].interrupted.Load() || !client.SessionRunning(b1) {
+		t.Fatal("expected tenant b session to keep running")
 	}
+	if got := client.InterruptByLabel(context.Background(), "tenant", "missing"); len(got) != 0 {
+		t.Fatalf("expected no sessions for unknown label, got %v", got)
+	}
+	_ = execs[b1].Close()
 }
 
-func TestDecodeJSONObjectHelpers(t *testing.T) {
-	obj, ok := decodeJSONObject(map[string]any{"k": "v"})
-	if !ok || obj["k"] != "v" {
-		t.Fatalf("decode map failed: %#v", obj)
+func containsString(list []string, want string) bool {
+	for _, item := range list {
+		if item == want {
+			return true
+		}
 	}
+	return false
+}
 
-	raw := json.RawMessage(`{"n":1}`)
-	obj, ok = decodeJSONObject(raw)
-	if !ok || obj["n"].(float64) != 1 {
-		t.Fatalf("decode raw failed: %#v", obj)
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+		time.Sleep(5 * time.Millisecond)
 	}
+}
 
-	obj, ok = decodeJSONObjectFromLine("prefix {\"a\":\"b\"}")
-	if !ok || obj["a"] != "b" {
-		t.Fatalf("decode from line failed: %#v", obj)
+func sessionStatus(client *Client, sessionID string) executor.SessionStatus {
+	for _, session := range client.ListSessions(context.Background()) {
+		if session.SessionID == sessionID {
+			return session.Status
+		}
 	}
+	return ""
 }
 
-func TestSetAndGetSessionRuntime(t *testing.T) {
+func TestSubscribeBranches(t *testing.T) {
+	registry := executor.NewRegistry()
 	client := NewWithOptions(ClientOptions{
-		Registry:      executor.NewRegistry(),
+		Registry:      registry,
 		StreamManager: streaming.NewManager(),
 		EventStore:    store.NewMemoryEventStore(),
 	})
 
-	req := executor.ExecuteRequest{Prompt: "hello", Executor: executor.ExecutorCodex}
-	client.setSessionRequest("sid", req)
-	client.resumeInfo["sid"] = sessionResumeInfo{CodexConversation: "conv-x"}
-
-	gotReq, gotResume, ok := client.getSessionRuntime("sid")
-	if !ok {
-		t.Fatalf("expected session runtime found")
-	}
-	if gotReq.Executor != executor.ExecutorCodex || gotResume.CodexConversation != "conv-x" {
-		t.Fatalf("unexpected runtime: req=%+v resume=%+v", gotReq, gotResume)
-	}
-}
-
-func TestNewAndRegisterExecutor(t *testing.T) {
-	client := New()
-	client.RegisterExecutor("x", executor.FactoryFunc(func() (executor.Executor, error) {
-		return &testExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}, nil
-	}))
-
-	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{
-		Prompt:   "hello",
-		Executor: "x",
-	})
-	if err != nil {
-		t.Fatalf("execute with registered executor failed: %v", err)
-	}
-	if resp.SessionID == "" {
-		t.Fatalf("expected session id")
+	// non-running session with no history should emit synthetic done
+	ch, cancel := client.Subscribe("not-found", executor.SubscribeOptions{})
+	defer cancel()
+	first := <-ch
+	if first.Type != "done" {
+		t.Fatalf("expected synthetic done, got %s", first.Type)
 	}
 }
 
-func TestResumeRespondGetEventsAndShutdown(t *testing.T) {
+func TestSubscribeCategories(t *testing.T) {
 	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register("custom", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
 	client := NewWithOptions(ClientOptions{
 		Registry:      registry,
 		StreamManager: streaming.NewManager(),
 		EventStore:    store.NewMemoryEventStore(),
 	})
 
-	mock := &testExecutor{logs: make(chan executor.Log, 10), done: make(chan struct{})}
-	registry.Register("test", executor.FactoryFunc(func() (executor.Executor, error) { return mock, nil }))
-
-	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "x", Executor: "test"})
+	exec.logs <- executor.Log{Type: "progress", Content: "thinking"}
+	exec.logs <- executor.Log{Type: "approval", Content: "history approval"}
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hi", Executor: "custom"})
 	if err != nil {
 		t.Fatalf("execute failed: %v", err)
 	}
+	waitFor(t, func() bool {
+		events, _ := client.ListEvents(context.Background(), resp.SessionID, 0, 0)
+		return len(events) == 3
+	})
 
-	if err := client.ResumeTask(context.Background(), resp.SessionID, "resume"); err != nil {
-		t.Fatalf("resume failed: %v", err)
-	}
-	if err := client.RespondControl(context.Background(), resp.SessionID, executor.ControlResponse{
-		RequestID: "req-1",
-		Decision:  executor.ControlDecisionApprove,
-	}); err != nil {
-		t.Fatalf("respond control failed: %v", err)
-	}
+	ch, cancel := client.Subscribe(resp.SessionID, executor.SubscribeOptions{ReturnAll: true, Categories: []string{"approval"}})
+	defer cancel()
 
-	events, ok := client.GetSessionEvents(resp.SessionID)
-	if !ok || len(events) == 0 {
-		time.Sleep(50 * time.Millisecond)
-		events, ok = client.GetSessionEvents(resp.SessionID)
-		if !ok || len(events) == 0 {
-			t.Fatalf("expected session events")
+	exec.logs <- executor.Log{Type: "progress", Content: "still thinking"}
+	exec.logs <- executor.Log{Type: "approval", Content: "live approval"}
+	exec.logs <- executor.Log{Type: "done", Content: "done"}
+
+	var got []string
+	for evt := range ch {
+		if evt.Type != "approval" {
+			t.Fatalf("expected only approval events, got %s", evt.Type)
 		}
+		got = append(got, evt.Content.(string))
 	}
-
-	client.Shutdown()
+	if len(got) != 2 || got[0] != "history approval" || got[1] != "live approval" {
+		t.Fatalf("expected history and live approvals, got %v", got)
+	}
+	_ = exec.Close()
 }
 
-func TestSubscribeBranches(t *testing.T) {
+func TestSubscribeReplaysEventsMissedByLag(t *testing.T) {
 	registry := executor.NewRegistry()
+	exec := &blockingExecutor{logs: make(chan executor.Log, 10)}
+	registry.Register("custom", executor.FactoryFunc(func() (executor.Executor, error) { return exec, nil }))
 	client := NewWithOptions(ClientOptions{
 		Registry:      registry,
-		StreamManager: streaming.NewManager(),
+		StreamManager: streaming.NewManagerWithOptions(streaming.ManagerOptions{SubscriberBuffer: 1}),
 		EventStore:    store.NewMemoryEventStore(),
 	})
 
-	// non-running session with no history should emit synthetic done
-	ch, cancel := client.Subscribe("not-found", executor.SubscribeOptions{})
+	resp, err := client.Execute(context.Background(), executor.ExecuteRequest{Prompt: "hi", Executor: "custom"})
+	if err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	ch, cancel := client.Subscribe(resp.SessionID, executor.SubscribeOptions{})
 	defer cancel()
-	first := <-ch
-	if first.Type != "done" {
-		t.Fatalf("expected synthetic done, got %s", first.Type)
+
+	// Nothing reads ch until every log is stored, so the subscription's
+	// stream buffer overflows.
+	for i := 0; i < 300; i++ {
+		exec.logs <- executor.Log{Type: "stdout", Content: fmt.Sprintf("line %d", i)}
+	}
+	exec.logs <- executor.Log{Type: "done", Content: "done"}
+	waitFor(t, func() bool { return sessionStatus(client, resp.SessionID) == executor.SessionStatusDone })
+
+	var last uint64
+	var got int
+	for evt := range ch {
+		if evt.Type == streaming.LagEntryType {
+			t.Fatalf("expected missed events to be replayed, got %+v", evt)
+		}
+		if last != 0 && evt.Seq != last+1 {
+			t.Fatalf("expected contiguous seqs, got %d after %d", evt.Seq, last)
+		}
+		last = evt.Seq
+		if evt.Type == "stdout" {
+			got++
+		}
+	}
+	if got != 300 {
+		t.Fatalf("expected 300 stdout events, got %d", got)
 	}
+	_ = exec.Close()
 }
 
 type testExecutor struct {
@@ -473,6 +3280,76 @@ func (m *testExecutor) Logs() <-chan executor.Log { return m.logs }
 func (m *testExecutor) Done() <-chan struct{}     { return m.done }
 func (m *testExecutor) Close() error              { return nil }
 
+// failingStartExecutor is a testExecutor whose Start fails with startErr.
+type failingStartExecutor struct {
+	testExecutor
+	startErr error
+}
+
+func (m *failingStartExecutor) Start(ctx context.Context, prompt string, opts executor.Options) error {
+	if m.startErr != nil {
+		return m.startErr
+	}
+	return m.testExecutor.Start(ctx, prompt, opts)
+}
+
+// blockingExecutor emits nothing until it is closed.
+type blockingExecutor struct {
+	logs        chan executor.Log
+	closeOnce   sync.Once
+	interrupted atomic.Bool
+	closed      atomic.Bool
+}
+
+func (m *blockingExecutor) Start(ctx context.Context, prompt string, opts executor.Options) error {
+	return nil
+}
+func (m *blockingExecutor) Interrupt() error {
+	m.interrupted.Store(true)
+	return nil
+}
+func (m *blockingExecutor) SendMessage(ctx context.Context, message string) error { return nil }
+func (m *blockingExecutor) RespondControl(ctx context.Context, response executor.ControlResponse) error {
+	return nil
+}
+func (m *blockingExecutor) Wait() error               { return nil }
+func (m *blockingExecutor) Logs() <-chan executor.Log { return m.logs }
+func (m *blockingExecutor) Done() <-chan struct{}     { return nil }
+func (m *blockingExecutor) Close() error {
+	m.closed.Store(true)
+	m.closeOnce.Do(func() { close(m.logs) })
+	return nil
+}
+
+// drainingExecutor is a blockingExecutor that finishes with a done event
+// when interrupted.
+type drainingExecutor struct {
+	*blockingExecutor
+}
+
+func (m *drainingExecutor) Interrupt() error {
+	_ = m.blockingExecutor.Interrupt()
+	m.logs <- executor.Log{Type: "done", Content: "interrupted"}
+	return nil
+}
+
+// nilLogsExecutor is a blockingExecutor whose Logs channel is nil.
+type nilLogsExecutor struct {
+	*blockingExecutor
+}
+
+func (m *nilLogsExecutor) Logs() <-chan executor.Log { return nil }
+
+// codexResumeExecutor is a blockingExecutor that captures resume state the
+// way the Codex executor does.
+type codexResumeExecutor struct {
+	*blockingExecutor
+}
+
+func (m *codexResumeExecutor) CaptureResume(entry executor.Log, prior executor.ResumeState) executor.ResumeState {
+	return codex.NewClient().CaptureResume(entry, prior)
+}
+
 type resumeExecutor struct {
 	logs        chan executor.Log
 	done        chan struct{}
@@ -498,3 +3375,6 @@ func (m *resumeExecutor) Wait() error               { return nil }
 func (m *resumeExecutor) Logs() <-chan executor.Log { return m.logs }
 func (m *resumeExecutor) Done() <-chan struct{}     { return m.done }
 func (m *resumeExecutor) Close() error              { return nil }
+func (m *resumeExecutor) Capabilities() executor.Capabilities {
+	return executor.Capabilities{Resume: true}
+}
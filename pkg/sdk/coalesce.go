@@ -0,0 +1,50 @@
+package sdk
+
+import (
+	"time"
+
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+// toolCoalescer implements SubscribeOptions.CoalesceTools for one
+// subscription: it drops tool events for started calls and stamps the
+// matching completion with the call's duration.
+type toolCoalescer struct {
+	started map[string]time.Time
+}
+
+func newToolCoalescer() *toolCoalescer {
+	return &toolCoalescer{started: make(map[string]time.Time)}
+}
+
+// filter returns the event to emit for evt, or false when evt is dropped.
+// Events other than normalized tool events pass through unchanged.
+func (t *toolCoalescer) filter(evt executor.Event) (executor.Event, bool) {
+	if evt.Type != "tool" {
+		return evt, true
+	}
+	content, ok := transcriptContent(evt)
+	if !ok {
+		return evt, true
+	}
+	switch content.Phase {
+	case "started":
+		// ACP reports progress of a running call as further started events;
+		// the first one marks the start.
+		if _, seen := t.started[content.ToolCallID]; content.ToolCallID != "" && !seen {
+			t.started[content.ToolCallID] = evt.Timestamp
+		}
+		return evt, false
+	case "completed", "failed":
+		start, ok := t.started[content.ToolCallID]
+		if !ok {
+			return evt, true
+		}
+		delete(t.started, content.ToolCallID)
+		if !start.IsZero() && evt.Timestamp.After(start) {
+			content.DurationMS = evt.Timestamp.Sub(start).Milliseconds()
+			evt.Content = content
+		}
+	}
+	return evt, true
+}
@@ -0,0 +1,94 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+// DefaultMaxContextBytes is the default total size limit for ExecuteRequest.ContextFiles.
+const DefaultMaxContextBytes int64 = 1 << 20
+
+var ErrContextFileNotAllowed = errors.New("context file is outside the working directory")
+var ErrContextFileTooLarge = errors.New("context files exceed size limit")
+
+// buildPrompt prepends the contents of req.ContextFiles to req.Prompt.
+// Files must resolve (after following symlinks) inside req.WorkingDir.
+func (c *Client) buildPrompt(req executor.ExecuteRequest) (string, error) {
+	if len(req.ContextFiles) == 0 {
+		return req.Prompt, nil
+	}
+
+	root := req.WorkingDir
+	if root == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		root = wd
+	}
+	root, err := resolvePath(root)
+	if err != nil {
+		return "", fmt.Errorf("resolve working dir: %w", err)
+	}
+
+	var total int64
+	var b strings.Builder
+	for _, name := range req.ContextFiles {
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+		resolved, err := resolvePath(path)
+		if err != nil {
+			return "", fmt.Errorf("context file %s: %w", name, err)
+		}
+		if !pathWithin(root, resolved) {
+			return "", fmt.Errorf("%w: %s", ErrContextFileNotAllowed, name)
+		}
+
+		info, err := os.Stat(resolved)
+		if err != nil {
+			return "", fmt.Errorf("context file %s: %w", name, err)
+		}
+		if info.IsDir() {
+			return "", fmt.Errorf("context file %s: is a directory", name)
+		}
+		total += info.Size()
+		if total > c.maxContextBytes {
+			return "", fmt.Errorf("%w: %d bytes allowed", ErrContextFileTooLarge, c.maxContextBytes)
+		}
+
+		data, err := os.ReadFile(resolved)
+		if err != nil {
+			return "", fmt.Errorf("context file %s: %w", name, err)
+		}
+		rel, _ := filepath.Rel(root, resolved)
+		fmt.Fprintf(&b, "<context_file path=%q>\n%s\n</context_file>\n\n", filepath.ToSlash(rel), data)
+	}
+
+	b.WriteString(req.Prompt)
+	return b.String(), nil
+}
+
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// pathWithin reports whether path is root or a descendant of root.
+// Both paths must already be absolute and cleaned.
+func pathWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
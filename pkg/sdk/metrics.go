@@ -0,0 +1,175 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/mylxsw/asteria/log"
+	"github.com/supremeagent/executor/pkg/executor"
+	"github.com/supremeagent/executor/pkg/store"
+)
+
+// ErrMetricsUnavailable is returned by Client.SessionMetrics for sessions
+// that have not ended yet.
+var ErrMetricsUnavailable = errors.New("session metrics are recorded when the session ends")
+
+// SessionMetrics returns the metrics recorded when sessionID last ended.
+func (c *Client) SessionMetrics(sessionID string) (executor.SessionMetrics, error) {
+	session, ok := c.GetSession(sessionID)
+	if !ok {
+		return executor.SessionMetrics{}, executor.ErrSessionNotFound
+	}
+	if session.Metrics == nil {
+		return executor.SessionMetrics{}, ErrMetricsUnavailable
+	}
+	return *session.Metrics, nil
+}
+
+// SessionTools returns the distinct tool names used by sessionID's stored tool
+// events, sorted by name. Unlike SessionMetrics it also works while the
+// session is running.
+func (c *Client) SessionTools(ctx context.Context, sessionID string) ([]string, error) {
+	if _, ok := c.GetSession(sessionID); !ok {
+		return nil, executor.ErrSessionNotFound
+	}
+	events, err := c.store.List(ctx, sessionID, store.ListOptions{Types: []string{"tool"}})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	tools := []string{}
+	for _, evt := range events {
+		content, ok := transcriptContent(evt)
+		if !ok || content.ToolName == "" || seen[content.ToolName] {
+			continue
+		}
+		seen[content.ToolName] = true
+		tools = append(tools, content.ToolName)
+	}
+	sort.Strings(tools)
+	return tools, nil
+}
+
+// SessionCost estimates the USD cost of sessionID from the token usage on its
+// stored events, priced with the client's price table. Usage that does not
+// name its model is priced as the session's requested Model. It also works
+// while the session is running; once the session has ended, the cost recorded
+// on its summary is returned. ok is false for unknown sessions and sessions
+// that reported no usage, whose cost is unknown rather than zero.
+func (c *Client) SessionCost(sessionID string) (executor.SessionCost, bool) {
+	c.sessionsMu.RLock()
+	session, ok := c.sessions[sessionID]
+	req := c.requests[sessionID]
+	c.sessionsMu.RUnlock()
+	if !ok {
+		return executor.SessionCost{}, false
+	}
+	if session.Metrics != nil {
+		// The run has ended; its stored events may since have been trimmed.
+		if session.Cost == nil {
+			return executor.SessionCost{}, false
+		}
+		return *session.Cost, true
+	}
+	events, err := c.store.List(context.Background(), sessionID, store.ListOptions{})
+	if err != nil {
+		log.Errorf("list events for cost failed: session=%s err=%v", sessionID, err)
+		return executor.SessionCost{}, false
+	}
+	return c.computeSessionCost(req, events)
+}
+
+// computeSessionCost sums the usage on events. It returns false when none of
+// them reports usage.
+func (c *Client) computeSessionCost(req executor.ExecuteRequest, events []executor.Event) (executor.SessionCost, bool) {
+	var cost executor.SessionCost
+	reported := false
+	for _, evt := range events {
+		content, ok := transcriptContent(evt)
+		if !ok {
+			continue
+		}
+		for _, usage := range content.Usage {
+			reported = true
+			c.prices.Estimate(&cost, usage, req.Model)
+		}
+	}
+	return cost, reported
+}
+
+// recordSessionMetrics computes the session's metrics from its stored events
+// and saves them on the session summary. Events trimmed by a capped store are
+// not counted.
+func (c *Client) recordSessionMetrics(sessionID string) {
+	events, err := c.store.List(context.Background(), sessionID, store.ListOptions{})
+	if err != nil {
+		log.Errorf("list events for metrics failed: session=%s err=%v", sessionID, err)
+		return
+	}
+
+	c.sessionsMu.Lock()
+	session, ok := c.sessions[sessionID]
+	if !ok {
+		c.sessionsMu.Unlock()
+		return
+	}
+	metrics := computeSessionMetrics(session, events)
+	session.Metrics = &metrics
+	if cost, ok := c.computeSessionCost(c.requests[sessionID], events); ok {
+		session.Cost = &cost
+	}
+	c.sessions[sessionID] = session
+	c.sessionsMu.Unlock()
+
+	c.persistSession(sessionID)
+}
+
+func computeSessionMetrics(session executor.Session, events []executor.Event) executor.SessionMetrics {
+	metrics := executor.SessionMetrics{
+		Events:     len(events),
+		Categories: make(map[string]int),
+	}
+	start := session.CreatedAt
+	for _, evt := range events {
+		metrics.Categories[evt.Type]++
+		switch evt.Type {
+		case "tool", "plan":
+			if isToolCall(evt) {
+				metrics.ToolCalls++
+			}
+		case "approval":
+			metrics.Approvals++
+		}
+		if start.IsZero() || (!evt.Timestamp.IsZero() && evt.Timestamp.Before(start)) {
+			start = evt.Timestamp
+		}
+	}
+	if len(events) > 0 && !start.IsZero() {
+		if end := events[len(events)-1].Timestamp; end.After(start) {
+			metrics.Duration = end.Sub(start)
+		}
+	}
+	return metrics
+}
+
+// isToolCall reports whether a tool event starts a call rather than reporting
+// its progress or result. Tool events without a phase are one-shot calls.
+// Plan events count only when a tool wrote the plan, like Claude's TodoWrite.
+func isToolCall(evt executor.Event) bool {
+	content, ok := transcriptContent(evt)
+	if evt.Type == "plan" {
+		return ok && content.ToolCallID != "" && content.Phase == "started"
+	}
+	if evt.Type != "tool" {
+		return false
+	}
+	if !ok {
+		return true
+	}
+	if content.SourceType == "ToolUpdate" {
+		return false
+	}
+	return content.Phase == "" || content.Phase == "started"
+}
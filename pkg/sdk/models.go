@@ -0,0 +1,47 @@
+package sdk
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+// DefaultModelsCacheTTL is how long Models reuses a model list when
+// ClientOptions.ModelsCacheTTL is not set. Listing may spawn the agent CLI,
+// so a UI refreshing its model picker should not do it on every request.
+const DefaultModelsCacheTTL = 5 * time.Minute
+
+type cachedModels struct {
+	models  []string
+	expires time.Time
+}
+
+// Models returns the model names accepted by executor name, from the CLI
+// where it can list them, launched like its sessions (ClientOptions.Launcher
+// and CommandOverrides), and from executor.DefaultModels otherwise. Lists
+// are cached for ClientOptions.ModelsCacheTTL; errors are not cached.
+// Executors outside ClientOptions.AllowedExecutors fail with an
+// ExecutorNotAllowedError.
+func (c *Client) Models(ctx context.Context, name string) ([]string, error) {
+	if err := c.checkExecutorAllowed(executor.ExecutorType(name)); err != nil {
+		return nil, err
+	}
+	c.modelsMu.Lock()
+	cached, ok := c.models[name]
+	c.modelsMu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return slices.Clone(cached.models), nil
+	}
+
+	models, err := c.registry.Models(ctx, name, c.launchOptions(name, executor.Options{}))
+	if err != nil {
+		return nil, err
+	}
+
+	c.modelsMu.Lock()
+	c.models[name] = cachedModels{models: models, expires: time.Now().Add(c.modelsTTL)}
+	c.modelsMu.Unlock()
+	return slices.Clone(models), nil
+}
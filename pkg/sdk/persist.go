@@ -0,0 +1,64 @@
+package sdk
+
+import (
+	"time"
+
+	"github.com/supremeagent/executor/pkg/store"
+)
+
+// DefaultSessionPersistInterval is how long summary updates that do not
+// change a session's status wait before they are written to the SessionStore.
+const DefaultSessionPersistInterval = 2 * time.Second
+
+// schedulePersist saves sessionID's summary once the persist interval has
+// passed. Calls while a save is already pending are coalesced into it.
+func (c *Client) schedulePersist(sessionID string) {
+	if _, nop := c.sessionStore.(store.NopSessionStore); nop {
+		return
+	}
+
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	if c.closed {
+		return
+	}
+	if _, ok := c.pendingPersist[sessionID]; ok {
+		return
+	}
+	c.pendingPersist[sessionID] = time.AfterFunc(c.persistInterval, func() {
+		c.pendingMu.Lock()
+		delete(c.pendingPersist, sessionID)
+		c.pendingMu.Unlock()
+		c.persistSession(sessionID)
+	})
+}
+
+// cancelPendingPersist drops a scheduled save, e.g. because the caller is
+// about to save the latest state itself.
+func (c *Client) cancelPendingPersist(sessionID string) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	if timer, ok := c.pendingPersist[sessionID]; ok {
+		timer.Stop()
+		delete(c.pendingPersist, sessionID)
+	}
+}
+
+// flushPendingPersists writes every scheduled save immediately and stops
+// scheduling new ones. It is called during shutdown.
+func (c *Client) flushPendingPersists() {
+	c.pendingMu.Lock()
+	c.closed = true
+	sessionIDs := make([]string, 0, len(c.pendingPersist))
+	for sessionID, timer := range c.pendingPersist {
+		if timer.Stop() {
+			sessionIDs = append(sessionIDs, sessionID)
+		}
+		delete(c.pendingPersist, sessionID)
+	}
+	c.pendingMu.Unlock()
+
+	for _, sessionID := range sessionIDs {
+		c.persistSession(sessionID)
+	}
+}
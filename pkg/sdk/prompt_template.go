@@ -0,0 +1,21 @@
+package sdk
+
+import "github.com/supremeagent/executor/pkg/executor"
+
+// PromptTemplate frames the prompt of every session started with one
+// executor, e.g. to add standard guidance for that agent. Prefix and Suffix
+// are added verbatim, so include any separating newlines.
+type PromptTemplate struct {
+	Prefix string
+	Suffix string
+}
+
+// applyPromptTemplate wraps prompt in the template configured for
+// executorType, if any.
+func (c *Client) applyPromptTemplate(executorType executor.ExecutorType, prompt string) string {
+	tmpl, ok := c.promptTemplates[executorType]
+	if !ok {
+		return prompt
+	}
+	return tmpl.Prefix + prompt + tmpl.Suffix
+}
@@ -0,0 +1,153 @@
+package sdk
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+// PathRedactor rewrites an absolute path before it is stored or streamed.
+// workingDir is the session's working directory (may be empty).
+type PathRedactor func(path, workingDir string) string
+
+// absPathPattern matches absolute filesystem paths embedded in free text. A
+// path must follow whitespace, a quote, '(' or '='; not ':', which would take
+// the "//host/..." of a URL for a path.
+var absPathPattern = regexp.MustCompile(`(^|[\s"'(=])(/[^\s"'()]+)`)
+
+// MaskAbsolutePath is the default PathRedactor. Paths inside workingDir become
+// relative to it; any other absolute path is reduced to ".../<filename>".
+func MaskAbsolutePath(path, workingDir string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	clean := filepath.Clean(path)
+	if workingDir != "" {
+		if root, err := filepath.Abs(workingDir); err == nil && pathWithin(root, clean) {
+			rel, _ := filepath.Rel(root, clean)
+			return filepath.ToSlash(rel)
+		}
+	}
+	return ".../" + filepath.Base(clean)
+}
+
+// redactPaths rewrites absolute paths in the Target, Summary, Text, file
+// changes, approval scope and Raw payload of unified event content. Raw is
+// rewritten string by string, map keys included, after a JSON round trip for
+// typed payloads, so Retransform replays the redacted payload. Events whose
+// content is not UnifiedContent, plan steps, and the logs passed to
+// Hooks.OnRawLog are left unredacted.
+func (c *Client) redactPaths(sessionID string, evt executor.Event) executor.Event {
+	if c.pathRedactor == nil {
+		return evt
+	}
+	var content executor.UnifiedContent
+	switch v := evt.Content.(type) {
+	case executor.UnifiedContent:
+		content = v
+	case *executor.UnifiedContent:
+		if v == nil {
+			return evt
+		}
+		content = *v
+	default:
+		return evt
+	}
+
+	c.sessionsMu.RLock()
+	workingDir := c.requests[sessionID].WorkingDir
+	c.sessionsMu.RUnlock()
+
+	content.Target = redactPathsInText(content.Target, workingDir, c.pathRedactor)
+	content.Summary = redactPathsInText(content.Summary, workingDir, c.pathRedactor)
+	content.Text = redactPathsInText(content.Text, workingDir, c.pathRedactor)
+	content.Raw = redactPathsInValue(content.Raw, workingDir, c.pathRedactor)
+	if len(content.Files) > 0 {
+		// Files may be shared with the executor's own event; copy it.
+		files := make([]executor.FileChange, len(content.Files))
+		for i, file := range content.Files {
+			file.Path = redactPath(file.Path, workingDir, c.pathRedactor)
+			files[i] = file
+		}
+		content.Files = files
+	}
+	if content.Scope != nil {
+		scope := *content.Scope
+		scope.Command = redactPathsInText(scope.Command, workingDir, c.pathRedactor)
+		scope.Cwd = redactPath(scope.Cwd, workingDir, c.pathRedactor)
+		if len(scope.Paths) > 0 {
+			paths := make([]string, len(scope.Paths))
+			for i, path := range scope.Paths {
+				paths[i] = redactPath(path, workingDir, c.pathRedactor)
+			}
+			scope.Paths = paths
+		}
+		content.Scope = &scope
+	}
+	evt.Content = content
+	return evt
+}
+
+// redactPath redacts path when it is absolute.
+func redactPath(path, workingDir string, redact PathRedactor) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	return redact(path, workingDir)
+}
+
+// redactPathsInValue returns a copy of v with absolute paths redacted in every
+// string and map key. Values other than strings, maps and slices of decoded
+// JSON are converted through JSON first; a value that does not round-trip is
+// dropped rather than passed on unredacted.
+func redactPathsInValue(v any, workingDir string, redact PathRedactor) any {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return redactPathsInText(val, workingDir, redact)
+	case bool, float64, json.Number:
+		return val
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for key, item := range val {
+			out[redactPathsInText(key, workingDir, redact)] = redactPathsInValue(item, workingDir, redact)
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = redactPathsInValue(item, workingDir, redact)
+		}
+		return out
+	case json.RawMessage:
+		var decoded any
+		if err := json.Unmarshal(val, &decoded); err != nil {
+			return nil
+		}
+		return redactPathsInValue(decoded, workingDir, redact)
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return nil
+		}
+		var decoded any
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return nil
+		}
+		return redactPathsInValue(decoded, workingDir, redact)
+	}
+}
+
+func redactPathsInText(text, workingDir string, redact PathRedactor) string {
+	if !strings.Contains(text, "/") {
+		return text
+	}
+	return absPathPattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := absPathPattern.FindStringSubmatch(match)
+		return parts[1] + redact(parts[2], workingDir)
+	})
+}
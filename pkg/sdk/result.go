@@ -0,0 +1,41 @@
+package sdk
+
+import (
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+// ResultMode decides how the result events of one run are combined into
+// executor.Session.Result. Claude emits a single result before done, while
+// other agents stream their final answer as several result chunks.
+type ResultMode string
+
+const (
+	// ResultModeAppend concatenates every result of the run in order. It is
+	// the behaviour when ResultMode is empty.
+	ResultModeAppend ResultMode = "append"
+	// ResultModeLast keeps only the most recent result.
+	ResultModeLast ResultMode = "last"
+)
+
+// combine merges the next result text into the session's current result.
+func (m ResultMode) combine(current, next string) string {
+	if m == ResultModeLast {
+		return next
+	}
+	return current + next
+}
+
+// resultText returns the answer text carried by a result event: a normalized
+// event whose source type is "result", or a raw event of type "result".
+func resultText(evt executor.Event) (string, bool) {
+	if content, ok := transcriptContent(evt); ok {
+		if content.SourceType != "result" {
+			return "", false
+		}
+		return content.Text, true
+	}
+	if evt.Type != "result" {
+		return "", false
+	}
+	return executor.StringifyContent(evt.Content), true
+}
@@ -0,0 +1,162 @@
+package sdk
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+var (
+	// ErrResumeTokenKeyRequired is returned by ExportResumeToken and
+	// ExecuteFromResumeToken when ClientOptions.ResumeTokenKey is empty.
+	ErrResumeTokenKeyRequired = errors.New("resume token key is not configured")
+	// ErrInvalidResumeToken is returned for a token that is malformed or was
+	// not signed with this client's ResumeTokenKey.
+	ErrInvalidResumeToken = errors.New("invalid resume token")
+	// ErrResumeTokenExpired is returned for a validly signed token whose
+	// ResumeTokenTTL has passed.
+	ErrResumeTokenExpired = errors.New("resume token expired")
+)
+
+// DefaultResumeTokenTTL is how long resume tokens can be used when
+// ClientOptions.ResumeTokenTTL is not set.
+const DefaultResumeTokenTTL = 24 * time.Hour
+
+// resumeTokenVersion is bumped when the token payload changes incompatibly.
+const resumeTokenVersion = 2
+
+// resumeToken is the signed payload of a resume token.
+type resumeToken struct {
+	Version   int                     `json:"v"`
+	SessionID string                  `json:"session_id"`
+	IssuedAt  time.Time               `json:"iat"`
+	ExpiresAt time.Time               `json:"exp"`
+	Request   executor.ExecuteRequest `json:"request"`
+	Resume    executor.ResumeState    `json:"resume"`
+}
+
+// ExportResumeToken returns an opaque token holding what is needed to resume
+// sessionID elsewhere: its original request and the upstream resume state.
+// Another client configured with the same ResumeTokenKey can resume it with
+// ExecuteFromResumeToken until ClientOptions.ResumeTokenTTL has passed. The
+// token is signed, not encrypted, so the request, including Env, can be read
+// by whoever holds it.
+func (c *Client) ExportResumeToken(sessionID string) (string, error) {
+	if len(c.resumeTokenKey) == 0 {
+		return "", ErrResumeTokenKeyRequired
+	}
+	req, resume, ok := c.getSessionRuntime(sessionID)
+	if !ok {
+		return "", executor.ErrSessionNotFound
+	}
+	if resume == (executor.ResumeState{}) {
+		return "", ErrResumeUnavailable
+	}
+
+	now := time.Now()
+	payload, err := json.Marshal(resumeToken{
+		Version:   resumeTokenVersion,
+		SessionID: sessionID,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(c.resumeTokenTTL),
+		Request:   req,
+		Resume:    resume,
+	})
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(c.signResumeToken(encoded)), nil
+}
+
+// ExecuteFromResumeToken resumes the session of a token made by
+// ExportResumeToken with message, like ContinueTask, without needing the
+// session to be known to this client. A session this client does not know is
+// registered under the token's session id first; a known one only takes the
+// token's state when the token is newer than the session's last update.
+// Expired tokens fail with ErrResumeTokenExpired.
+func (c *Client) ExecuteFromResumeToken(ctx context.Context, token, message string) (executor.ExecuteResponse, error) {
+	if len(c.resumeTokenKey) == 0 {
+		return executor.ExecuteResponse{}, ErrResumeTokenKeyRequired
+	}
+	payload, err := c.decodeResumeToken(token)
+	if err != nil {
+		return executor.ExecuteResponse{}, err
+	}
+	// A live executor already has the conversation; just send it the message.
+	if _, running := c.registry.GetSession(payload.SessionID); !running {
+		c.adoptResumeToken(payload)
+	}
+	if err := c.ContinueTaskWithOptions(ctx, payload.SessionID, executor.ContinueRequest{Message: message}); err != nil {
+		return executor.ExecuteResponse{}, err
+	}
+	return executor.ExecuteResponse{SessionID: payload.SessionID, Status: "running"}, nil
+}
+
+func (c *Client) signResumeToken(encoded string) []byte {
+	mac := hmac.New(sha256.New, c.resumeTokenKey)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+func (c *Client) decodeResumeToken(token string) (resumeToken, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return resumeToken{}, ErrInvalidResumeToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, c.signResumeToken(encoded)) {
+		return resumeToken{}, ErrInvalidResumeToken
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return resumeToken{}, ErrInvalidResumeToken
+	}
+	var payload resumeToken
+	if err := json.Unmarshal(data, &payload); err != nil || payload.Version != resumeTokenVersion || payload.SessionID == "" ||
+		payload.IssuedAt.IsZero() || payload.ExpiresAt.IsZero() {
+		return resumeToken{}, ErrInvalidResumeToken
+	}
+	if !time.Now().Before(payload.ExpiresAt) {
+		return resumeToken{}, ErrResumeTokenExpired
+	}
+	return payload, nil
+}
+
+// adoptResumeToken makes the token's request and resume state the session's
+// runtime state, registering the session when this client does not know it.
+// A known session updated since the token was issued keeps its own state,
+// which is at least as recent.
+func (c *Client) adoptResumeToken(payload resumeToken) {
+	c.sessionsMu.Lock()
+	if session, ok := c.sessions[payload.SessionID]; ok && !payload.IssuedAt.After(session.UpdatedAt) {
+		c.sessionsMu.Unlock()
+		return
+	}
+	if _, ok := c.sessions[payload.SessionID]; !ok {
+		now := time.Now()
+		c.sessions[payload.SessionID] = executor.Session{
+			SessionID: payload.SessionID,
+			Title:     truncateTitle(payload.Request.Prompt, 36),
+			Status:    executor.SessionStatusInterrupted,
+			Executor:  payload.Request.Executor,
+			Labels:    maps.Clone(payload.Request.Labels),
+			Kind:      payload.Request.Kind,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+	}
+	c.requests[payload.SessionID] = payload.Request
+	c.resumeInfo[payload.SessionID] = payload.Resume
+	c.sessionsMu.Unlock()
+
+	c.persistSession(payload.SessionID)
+}
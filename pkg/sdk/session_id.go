@@ -0,0 +1,41 @@
+package sdk
+
+import (
+	"regexp"
+
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+// sessionIDPattern restricts caller-supplied session ids to characters that
+// are safe in file names, since file-backed stores name files after them.
+var sessionIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// reserveSessionID claims id, or a generated id when it is empty, for a
+// session Execute is about to start. It fails with ErrSessionExists when the
+// id belongs to a known session or another Execute call. The caller must
+// releaseSessionID once the session is registered or has failed to start.
+func (c *Client) reserveSessionID(id string) (string, error) {
+	if id == "" {
+		id = c.newSessionID()
+	} else if !sessionIDPattern.MatchString(id) {
+		return "", &executor.OptionError{Field: "session_id", Value: id}
+	}
+
+	c.sessionsMu.Lock()
+	defer c.sessionsMu.Unlock()
+	if _, ok := c.sessions[id]; ok {
+		return "", ErrSessionExists
+	}
+	if _, ok := c.reserved[id]; ok {
+		return "", ErrSessionExists
+	}
+	c.reserved[id] = struct{}{}
+	return id, nil
+}
+
+// releaseSessionID drops a reservation made by reserveSessionID.
+func (c *Client) releaseSessionID(id string) {
+	c.sessionsMu.Lock()
+	delete(c.reserved, id)
+	c.sessionsMu.Unlock()
+}
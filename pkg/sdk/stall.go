@@ -0,0 +1,135 @@
+package sdk
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+// StalledSourceType is the content.source_type of the progress event the SDK
+// records when a running session has been quiet for
+// ClientOptions.StallThreshold or ClientOptions.StallTimeout.
+const StalledSourceType = "stalled"
+
+// stallWatch tracks the time since a session's last executor log. Its
+// channel fires once when the stall threshold passes and again when the stall
+// timeout passes; any new log re-arms it.
+type stallWatch struct {
+	threshold time.Duration
+	timeout   time.Duration
+	last      time.Time
+	warned    bool
+	timer     *time.Timer
+}
+
+// newStallWatch returns a watch for the given limits. Both may be 0, in
+// which case the watch never fires.
+func newStallWatch(threshold, timeout time.Duration) *stallWatch {
+	w := &stallWatch{threshold: threshold, timeout: timeout, last: time.Now()}
+	if wait, ok := w.next(); ok {
+		w.timer = time.NewTimer(wait)
+	}
+	return w
+}
+
+// C fires at the next deadline. It is nil, blocking forever in a select,
+// when the watch is disabled.
+func (w *stallWatch) C() <-chan time.Time {
+	if w.timer == nil {
+		return nil
+	}
+	return w.timer.C
+}
+
+// next returns how long to wait for the next deadline, or false when none is
+// left.
+func (w *stallWatch) next() (time.Duration, bool) {
+	elapsed := time.Since(w.last)
+	switch {
+	case w.threshold > 0 && !w.warned:
+		return w.threshold - elapsed, true
+	case w.timeout > 0:
+		return w.timeout - elapsed, true
+	}
+	return 0, false
+}
+
+// activity records an executor log and re-arms the watch.
+func (w *stallWatch) activity() {
+	if w.timer == nil {
+		return
+	}
+	w.last = time.Now()
+	w.warned = false
+	if !w.timer.Stop() {
+		select {
+		case <-w.timer.C:
+		default:
+		}
+	}
+	if wait, ok := w.next(); ok {
+		w.timer.Reset(wait)
+	}
+}
+
+// fire is called after C fired. It reports whether the session should be
+// warned about and whether it has reached the stall timeout, and re-arms the
+// watch for the next deadline.
+func (w *stallWatch) fire() (warn, timedOut bool) {
+	if w.timeout > 0 && time.Since(w.last) >= w.timeout {
+		return false, true
+	}
+	if w.threshold > 0 && !w.warned {
+		w.warned = true
+		warn = true
+	}
+	if wait, ok := w.next(); ok {
+		w.timer.Reset(wait)
+	}
+	return warn, false
+}
+
+// quietFor returns the time since the last executor log.
+func (w *stallWatch) quietFor() time.Duration {
+	return time.Since(w.last).Round(time.Second)
+}
+
+func (w *stallWatch) stop() {
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+}
+
+// waitingOnCaller reports whether a session is quiet because it waits for the
+// caller: it was paused, or its latest event is an approval request.
+func (c *Client) waitingOnCaller(sessionID string) bool {
+	c.sessionsMu.RLock()
+	defer c.sessionsMu.RUnlock()
+	session := c.sessions[sessionID]
+	return session.Status != executor.SessionStatusRunning || session.LastEventType == "approval"
+}
+
+// recordStalled stores the non-terminal event telling subscribers that the
+// executor has gone quiet, and whether the SDK is stopping it.
+func (c *Client) recordStalled(sessionID, executorName string, quiet time.Duration, stopping bool) {
+	summary := fmt.Sprintf("No output for %s", quiet)
+	if stopping {
+		summary = fmt.Sprintf("No output for %s; stopping the executor", quiet)
+	}
+	c.recordEvent(sessionID, executor.Event{
+		SessionID: sessionID,
+		Executor:  executorName,
+		Timestamp: time.Now(),
+		Type:      "progress",
+		Content: executor.UnifiedContent{
+			Source:     executorName,
+			SourceType: StalledSourceType,
+			Category:   "lifecycle",
+			Action:     "waiting",
+			Phase:      "stalled",
+			Summary:    summary,
+		},
+		Normalized: true,
+	})
+}
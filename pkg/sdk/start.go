@@ -0,0 +1,105 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os/exec"
+	"slices"
+	"time"
+
+	"github.com/mylxsw/asteria/log"
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+// DefaultStartRetryDelay is the pause between start attempts when
+// ClientOptions.StartRetryDelay is not set.
+const DefaultStartRetryDelay = 500 * time.Millisecond
+
+// StartErrorClassifier reports whether a failed executor start is worth
+// retrying.
+type StartErrorClassifier func(err error) bool
+
+// DefaultStartErrorClassifier treats failures that will not change on their
+// own as permanent: a missing or non-executable binary, rejected options, a
+// closed executor and cancelled contexts. Everything else (e.g. a transient
+// network error while npx fetches a package) is retriable.
+func DefaultStartErrorClassifier(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, executor.ErrExecutorNotInstalled), errors.Is(err, fs.ErrNotExist),
+		errors.Is(err, fs.ErrPermission), errors.Is(err, exec.ErrNotFound):
+		return false
+	case errors.Is(err, executor.ErrInvalidOption), errors.Is(err, executor.ErrExecutorClosed),
+		errors.Is(err, executor.ErrUnknownExecutorType), errors.Is(err, executor.ErrTooManySessions):
+		return false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+	return true
+}
+
+// launchOptions applies ClientOptions.Launcher and the CommandOverrides entry
+// of executorName to opts.
+func (c *Client) launchOptions(executorName string, opts executor.Options) executor.Options {
+	if c.launcher != "" {
+		opts.Launcher = c.launcher
+	}
+	if override := c.commandOverrides[executor.ExecutorType(executorName)]; len(override) > 0 {
+		opts.CommandOverride = slices.Clone(override)
+	}
+	return opts
+}
+
+// startSession creates and starts an executor for sessionID, retrying failed
+// starts up to c.startRetries times while the classifier deems them
+// retriable. Each attempt uses a fresh executor instance.
+func (c *Client) startSession(ctx context.Context, sessionID, executorName, prompt string, opts executor.Options) (executor.Executor, error) {
+	if len(c.benignStderr) > 0 {
+		opts.BenignStderrPatterns = append(slices.Clone(opts.BenignStderrPatterns), c.benignStderr...)
+	}
+	if len(c.interactivePrompts) > 0 {
+		opts.InteractivePromptPatterns = append(slices.Clone(opts.InteractivePromptPatterns), c.interactivePrompts...)
+	}
+	opts = c.launchOptions(executorName, opts)
+	for attempt := 0; ; attempt++ {
+		exec, err := c.registry.CreateSessionContext(ctx, sessionID, executorName, opts)
+		if err != nil {
+			return nil, err
+		}
+		if err := validateOptions(exec, opts); err != nil {
+			_ = exec.Close()
+			c.registry.RemoveSession(sessionID)
+			return nil, err
+		}
+
+		err = exec.Start(ctx, prompt, opts)
+		if err == nil {
+			return exec, nil
+		}
+		_ = exec.Close()
+		c.registry.RemoveSession(sessionID)
+
+		if attempt >= c.startRetries || !c.startClassifier(err) {
+			return nil, err
+		}
+		log.Warningf("executor start failed, retrying: session=%s executor=%s attempt=%d err=%v", sessionID, executorName, attempt+1, err)
+
+		timer := time.NewTimer(c.startRetryDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		}
+	}
+}
+
+// validateOptions runs the executor's own option checks, if it has any.
+func validateOptions(exec executor.Executor, opts executor.Options) error {
+	if validator, ok := exec.(executor.OptionValidator); ok {
+		return validator.ValidateOptions(opts)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package sdk
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mylxsw/asteria/log"
+	"github.com/supremeagent/executor/pkg/executor"
+	"github.com/supremeagent/executor/pkg/store"
+	"github.com/supremeagent/executor/pkg/streaming"
+)
+
+// StoreFailurePolicy decides what happens to a session when the event store
+// rejects one of its events, for example because a durable store ran out of
+// disk space or database connections.
+type StoreFailurePolicy string
+
+const (
+	// StoreFailureDropEvents drops the event and counts it in
+	// Session.DroppedEvents; the session keeps running. It is the default.
+	StoreFailureDropEvents StoreFailurePolicy = "drop_events"
+	// StoreFailureFailSession stops the session, which ends as failed. Live
+	// subscribers receive an error event that is not stored.
+	StoreFailureFailSession StoreFailurePolicy = "fail_session"
+	// StoreFailureDegradeToMemory keeps the event, and every later event of
+	// the session, in memory on top of the store. Reads merge both, so
+	// subscribers see no gap, but the in-memory events are lost on restart.
+	StoreFailureDegradeToMemory StoreFailurePolicy = "degrade_to_memory"
+)
+
+// reportStoreError runs the OnStoreError hook and logs a rejected event.
+func (c *Client) reportStoreError(evt executor.Event, err error) {
+	if c.hooks.OnStoreError != nil {
+		c.hooks.OnStoreError(context.Background(), evt.SessionID, evt, err)
+	}
+	log.Errorf("store append failed: session=%s type=%s err=%v", evt.SessionID, evt.Type, err)
+}
+
+// countDroppedEvent records an event lost under StoreFailureDropEvents.
+func (c *Client) countDroppedEvent(sessionID string) {
+	c.sessionsMu.Lock()
+	defer c.sessionsMu.Unlock()
+	if session, ok := c.sessions[sessionID]; ok {
+		session.DroppedEvents++
+		c.sessions[sessionID] = session
+	}
+}
+
+// publishStoreFailure tells live subscribers that a session is stopped under
+// StoreFailureFailSession. The event is not stored, since the store is what
+// failed.
+func (c *Client) publishStoreFailure(sessionID, executorName string) {
+	c.stream.AppendLog(sessionID, streaming.LogEntry{
+		Type: "error",
+		Content: executor.Event{
+			SessionID: sessionID,
+			Executor:  executorName,
+			Timestamp: time.Now(),
+			Type:      "error",
+			Content: executor.UnifiedContent{
+				Source:   executorName,
+				Category: "error",
+				Action:   "failed",
+				Phase:    "failed",
+				Summary:  "Event store unavailable; stopping the session",
+			},
+			Normalized: true,
+		},
+	})
+}
+
+// overlayStore implements StoreFailureDegradeToMemory. Once an Append for a
+// session fails, that session's events are kept in memory, numbered on from
+// the last seq the store assigned, and merged into List and LatestSeq.
+type overlayStore struct {
+	store.EventStore
+	onFailure func(evt executor.Event, err error)
+
+	mu       sync.RWMutex
+	lastSeq  map[string]uint64
+	degraded map[string][]executor.Event
+}
+
+func newOverlayStore(primary store.EventStore, onFailure func(evt executor.Event, err error)) *overlayStore {
+	return &overlayStore{
+		EventStore: primary,
+		onFailure:  onFailure,
+		lastSeq:    make(map[string]uint64),
+		degraded:   make(map[string][]executor.Event),
+	}
+}
+
+func (s *overlayStore) Append(ctx context.Context, evt executor.Event) (executor.Event, error) {
+	s.mu.RLock()
+	_, degraded := s.degraded[evt.SessionID]
+	s.mu.RUnlock()
+
+	if !degraded {
+		stored, err := s.EventStore.Append(ctx, evt)
+		if err == nil {
+			s.mu.Lock()
+			if stored.Seq > s.lastSeq[evt.SessionID] {
+				s.lastSeq[evt.SessionID] = stored.Seq
+			}
+			s.mu.Unlock()
+			return stored, nil
+		}
+		s.onFailure(evt, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.degraded[evt.SessionID]; !ok {
+		if seq, err := s.EventStore.LatestSeq(ctx, evt.SessionID); err == nil && seq > s.lastSeq[evt.SessionID] {
+			s.lastSeq[evt.SessionID] = seq
+		}
+		s.degraded[evt.SessionID] = nil
+	}
+	evt.Seq = s.lastSeq[evt.SessionID] + 1
+	s.lastSeq[evt.SessionID] = evt.Seq
+	if evt.Timestamp.IsZero() {
+		evt.Timestamp = time.Now()
+	}
+	s.degraded[evt.SessionID] = append(s.degraded[evt.SessionID], evt)
+	return evt, nil
+}
+
+func (s *overlayStore) List(ctx context.Context, sessionID string, opts store.ListOptions) ([]executor.Event, error) {
+	s.mu.RLock()
+	overlay, degraded := s.degraded[sessionID]
+	s.mu.RUnlock()
+
+	if !degraded {
+		return s.EventStore.List(ctx, sessionID, opts)
+	}
+	// The tail may span both stores, so read the stored part in full.
+	primaryOpts := opts
+	if opts.Tail > 0 {
+		primaryOpts = store.ListOptions{UntilSeq: opts.UntilSeq, Types: opts.Types}
+	}
+	events, err := s.EventStore.List(ctx, sessionID, primaryOpts)
+	if err != nil {
+		log.Warningf("list stored events of degraded session failed: session=%s err=%v", sessionID, err)
+		events = nil
+	}
+	for _, evt := range overlay {
+		if opts.Tail <= 0 && opts.Limit > 0 && len(events) >= opts.Limit {
+			break
+		}
+		if (opts.Tail <= 0 && evt.Seq <= opts.AfterSeq) || (opts.UntilSeq > 0 && evt.Seq > opts.UntilSeq) || !opts.MatchType(evt.Type) {
+			continue
+		}
+		events = append(events, evt)
+	}
+	return opts.ApplyTail(events), nil
+}
+
+func (s *overlayStore) LatestSeq(ctx context.Context, sessionID string) (uint64, error) {
+	s.mu.RLock()
+	_, degraded := s.degraded[sessionID]
+	seq := s.lastSeq[sessionID]
+	s.mu.RUnlock()
+	if degraded {
+		return seq, nil
+	}
+	return s.EventStore.LatestSeq(ctx, sessionID)
+}
+
+// endSession forgets the seq of a session whose run ended without the store
+// failing; a degraded session keeps its overlay until it is deleted.
+func (s *overlayStore) endSession(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, degraded := s.degraded[sessionID]; !degraded {
+		delete(s.lastSeq, sessionID)
+	}
+}
+
+func (s *overlayStore) DeleteSession(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	delete(s.degraded, sessionID)
+	delete(s.lastSeq, sessionID)
+	s.mu.Unlock()
+	if deleter, ok := s.EventStore.(store.SessionDeleter); ok {
+		return deleter.DeleteSession(ctx, sessionID)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package sdk
+
+import "fmt"
+
+// ToolLimitSourceType is the content.source_type of the error event the SDK
+// records when a run exceeds Options.MaxToolCalls.
+const ToolLimitSourceType = "tool_limit"
+
+// recordToolLimitExceeded stores the error event telling subscribers that the
+// SDK is stopping a run for making more than limit tool calls.
+func (c *Client) recordToolLimitExceeded(sessionID, executorName string, limit int) {
+	c.recordSessionError(sessionID, executorName, ToolLimitSourceType, fmt.Sprintf("Tool call limit exceeded (%d); stopping the executor", limit))
+}
@@ -0,0 +1,175 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/supremeagent/executor/pkg/executor"
+	"github.com/supremeagent/executor/pkg/store"
+)
+
+// Transcript formats accepted by Client.Transcript.
+const (
+	TranscriptFormatJSON     = "json"
+	TranscriptFormatMarkdown = "md"
+)
+
+// ErrUnsupportedTranscriptFormat is returned by Client.Transcript for formats
+// other than TranscriptFormatJSON and TranscriptFormatMarkdown.
+var ErrUnsupportedTranscriptFormat = errors.New("unsupported transcript format")
+
+// Transcript is the JSON form of a session transcript.
+type Transcript struct {
+	Session executor.Session `json:"session"`
+	Prompt  string           `json:"prompt,omitempty"`
+	Events  []executor.Event `json:"events"`
+}
+
+// Transcript renders a session's stored events as TranscriptFormatJSON (the
+// session, its prompt and the ordered events) or TranscriptFormatMarkdown (a
+// readable document of the prompt, assistant messages, tool calls and
+// result). A running session is rendered with the events stored so far.
+func (c *Client) Transcript(ctx context.Context, sessionID, format string) ([]byte, error) {
+	if format == "" {
+		format = TranscriptFormatJSON
+	}
+	if format != TranscriptFormatJSON && format != TranscriptFormatMarkdown {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedTranscriptFormat, format)
+	}
+
+	session, ok := c.GetSession(sessionID)
+	if !ok {
+		return nil, executor.ErrSessionNotFound
+	}
+	events, err := c.store.List(ctx, sessionID, store.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	req, _, _ := c.getSessionRuntime(sessionID)
+	transcript := Transcript{Session: session, Prompt: req.Prompt, Events: events}
+	if transcript.Events == nil {
+		transcript.Events = []executor.Event{}
+	}
+
+	if format == TranscriptFormatJSON {
+		return json.MarshalIndent(transcript, "", "  ")
+	}
+	return []byte(renderTranscriptMarkdown(transcript)), nil
+}
+
+func renderTranscriptMarkdown(t Transcript) string {
+	var b strings.Builder
+
+	title := t.Session.Title
+	if title == "" {
+		title = t.Session.SessionID
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	fmt.Fprintf(&b, "- Session: `%s`\n", t.Session.SessionID)
+	fmt.Fprintf(&b, "- Executor: %s\n", t.Session.Executor)
+	fmt.Fprintf(&b, "- Status: %s\n", t.Session.Status)
+	if !t.Session.CreatedAt.IsZero() {
+		fmt.Fprintf(&b, "- Started: %s\n", t.Session.CreatedAt.UTC().Format(time.RFC3339))
+	}
+	if t.Session.Status == executor.SessionStatusRunning {
+		b.WriteString("\n_The session is still running; this transcript shows the events so far._\n")
+	}
+
+	if t.Prompt != "" {
+		fmt.Fprintf(&b, "\n## Prompt\n\n%s\n", strings.TrimSpace(t.Prompt))
+	}
+
+	lastSection := ""
+	section := func(name string) {
+		if lastSection != name {
+			fmt.Fprintf(&b, "\n## %s\n", name)
+			lastSection = name
+		}
+	}
+
+	for _, evt := range t.Events {
+		content, ok := transcriptContent(evt)
+		if !ok {
+			continue
+		}
+		text := strings.TrimSpace(content.Text)
+
+		switch {
+		case content.SourceType == "result" && text != "":
+			section("Result")
+			fmt.Fprintf(&b, "\n%s\n", text)
+		case evt.Type == "message" && text != "":
+			section("Assistant")
+			fmt.Fprintf(&b, "\n%s\n", text)
+		case evt.Type == "tool":
+			section("Assistant")
+			summary := content.Summary
+			if summary == "" {
+				summary = content.ToolName
+			}
+			fmt.Fprintf(&b, "\n<details>\n<summary>%s</summary>\n\n", html.EscapeString(summary))
+			if text != "" {
+				fence := codeFence(text)
+				fmt.Fprintf(&b, "%s\n%s\n%s\n", fence, text, fence)
+			}
+			b.WriteString("</details>\n")
+		case evt.Type == "approval":
+			section("Assistant")
+			fmt.Fprintf(&b, "\n> %s\n", content.Summary)
+		case evt.Type == "error":
+			section("Assistant")
+			if text == "" {
+				text = content.Summary
+			}
+			fmt.Fprintf(&b, "\n> **Error:** %s\n", text)
+		}
+	}
+	return b.String()
+}
+
+// codeFence returns a backtick fence longer than any backtick run in text,
+// so tool output containing its own fences cannot close the block early.
+func codeFence(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// transcriptContent returns the normalized content of evt, decoding the JSON
+// object form read back from a persistent store.
+func transcriptContent(evt executor.Event) (executor.UnifiedContent, bool) {
+	switch content := evt.Content.(type) {
+	case executor.UnifiedContent:
+		return content, true
+	case *executor.UnifiedContent:
+		if content != nil {
+			return *content, true
+		}
+	case map[string]any:
+		if !evt.Normalized {
+			return executor.UnifiedContent{}, false
+		}
+		data, err := json.Marshal(content)
+		if err != nil {
+			return executor.UnifiedContent{}, false
+		}
+		var out executor.UnifiedContent
+		if err := json.Unmarshal(data, &out); err != nil {
+			return executor.UnifiedContent{}, false
+		}
+		return out, true
+	}
+	return executor.UnifiedContent{}, false
+}
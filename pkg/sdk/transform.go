@@ -1,6 +1,10 @@
 package sdk
 
 import (
+	"context"
+	"time"
+
+	"github.com/mylxsw/asteria/log"
 	"github.com/supremeagent/executor/pkg/executor"
 	"github.com/supremeagent/executor/pkg/executor/claude"
 	"github.com/supremeagent/executor/pkg/executor/codex"
@@ -8,6 +12,7 @@ import (
 	"github.com/supremeagent/executor/pkg/executor/droid"
 	"github.com/supremeagent/executor/pkg/executor/gemini"
 	"github.com/supremeagent/executor/pkg/executor/qwen"
+	"github.com/supremeagent/executor/pkg/store"
 )
 
 func defaultEventTransformers() map[string]executor.EventTransformer {
@@ -28,29 +33,184 @@ func (c *Client) transformEvent(sessionID, executorName string, logEntry executo
 		Type:      logEntry.Type,
 		Content:   logEntry.Content,
 	}
+	if c.rawMode {
+		return evt
+	}
 
 	if tf, ok := c.transforms[executorName]; ok && tf != nil {
-		transformed := tf(executor.TransformInput{
-			SessionID: sessionID,
-			Executor:  executorName,
-			Log:       logEntry,
-		})
+		if transformed, ok := c.safeTransform(tf, sessionID, executorName, logEntry); ok {
+			evt = transformed
+		} else if fallback := c.fallbacks[executorName]; fallback != nil {
+			if transformed, ok := c.safeTransform(fallback, sessionID, executorName, logEntry); ok {
+				evt = transformed
+			}
+		}
+	}
+	if logEntry.Err != nil {
+		evt.Content = applyErrorDetails(evt.Content, logEntry.Err)
+	}
+	evt.Content = keepRawLog(evt.Content, logEntry)
+	for _, tf := range c.chains[executorName] {
+		timestamp := evt.Timestamp
+		// A panicking stage is skipped.
+		transformed, ok := c.safeTransform(tf, sessionID, executorName, executor.Log{Type: evt.Type, Content: evt.Content})
+		if !ok {
+			continue
+		}
+		evt = transformed
+		if evt.Timestamp.IsZero() {
+			evt.Timestamp = timestamp
+		}
+	}
+	if c.ignoreExecutorTimestamps {
+		evt.Timestamp = time.Time{}
+	}
 
-		if transformed.SessionID == "" {
-			transformed.SessionID = sessionID
+	switch evt.Content.(type) {
+	case executor.UnifiedContent, *executor.UnifiedContent:
+		evt.Normalized = true
+	}
+	return evt
+}
+
+// safeTransform runs applyTransformer, recovering from a panic in tf so that
+// one bad transformer cannot kill the session. It reports false after a
+// panic, which is logged and passed to the OnTransformError hook.
+func (c *Client) safeTransform(tf executor.EventTransformer, sessionID, executorName string, logEntry executor.Log) (evt executor.Event, ok bool) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
 		}
-		if transformed.Executor == "" {
-			transformed.Executor = executorName
+		ok = false
+		log.Errorf("event transformer panicked: session=%s executor=%s type=%s panic=%v", sessionID, executorName, logEntry.Type, recovered)
+		if c.hooks.OnTransformError != nil {
+			c.hooks.OnTransformError(context.Background(), sessionID, logEntry, recovered)
 		}
-		if transformed.Type == "" {
-			transformed.Type = logEntry.Type
+	}()
+	return applyTransformer(tf, sessionID, executorName, logEntry), true
+}
+
+// applyTransformer runs tf and fills any fields it left empty from the input.
+func applyTransformer(tf executor.EventTransformer, sessionID, executorName string, logEntry executor.Log) executor.Event {
+	transformed := tf(executor.TransformInput{
+		SessionID: sessionID,
+		Executor:  executorName,
+		Log:       logEntry,
+	})
+
+	if transformed.SessionID == "" {
+		transformed.SessionID = sessionID
+	}
+	if transformed.Executor == "" {
+		transformed.Executor = executorName
+	}
+	if transformed.Type == "" {
+		transformed.Type = logEntry.Type
+	}
+	if transformed.Content == nil {
+		transformed.Content = logEntry.Content
+	}
+	return transformed
+}
+
+// applyErrorDetails classifies a log's error onto normalized content.
+func applyErrorDetails(content any, err error) any {
+	switch c := content.(type) {
+	case executor.UnifiedContent:
+		c.ApplyError(err)
+		return c
+	case *executor.UnifiedContent:
+		c.ApplyError(err)
+	}
+	return content
+}
+
+// keepRawLog fills in the source log type and content on normalized content
+// whose transformer left them empty, so Retransform can replay the event.
+func keepRawLog(content any, logEntry executor.Log) any {
+	switch c := content.(type) {
+	case executor.UnifiedContent:
+		if c.SourceType == "" {
+			c.SourceType = logEntry.Type
+		}
+		if c.Raw == nil {
+			c.Raw = logEntry.Content
 		}
-		if transformed.Content == nil {
-			transformed.Content = logEntry.Content
+		return c
+	case *executor.UnifiedContent:
+		if c.SourceType == "" {
+			c.SourceType = logEntry.Type
 		}
+		if c.Raw == nil {
+			c.Raw = logEntry.Content
+		}
+	}
+	return content
+}
 
-		evt = transformed
+// Retransform replays a stored session through tf and returns the resulting
+// events, keeping each event's seq. The source log of every event is rebuilt
+// from its source_type and raw content; events the SDK generated itself, such
+// as the session-started event, are returned unchanged. The store is not
+// modified. It returns executor.ErrSessionNotFound when nothing is stored for
+// an unknown session.
+func (c *Client) Retransform(ctx context.Context, sessionID string, tf executor.EventTransformer) ([]executor.Event, error) {
+	events, err := c.store.List(ctx, sessionID, store.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		if _, ok := c.GetSession(sessionID); !ok {
+			return nil, executor.ErrSessionNotFound
+		}
 	}
 
-	return evt
+	out := make([]executor.Event, 0, len(events))
+	for _, stored := range events {
+		logEntry, ok := sourceLog(stored)
+		if !ok {
+			out = append(out, stored)
+			continue
+		}
+		evt := applyTransformer(tf, sessionID, stored.Executor, logEntry)
+		evt.Seq = stored.Seq
+		if evt.Timestamp.IsZero() || c.ignoreExecutorTimestamps {
+			evt.Timestamp = stored.Timestamp
+		}
+		switch evt.Content.(type) {
+		case executor.UnifiedContent, *executor.UnifiedContent:
+			evt.Normalized = true
+		}
+		out = append(out, c.redactPaths(sessionID, evt))
+	}
+	return out, nil
+}
+
+// sourceLog rebuilds the executor log a stored event was transformed from.
+// Normalized content may be a UnifiedContent or, after a round trip through a
+// persistent store, its decoded JSON object.
+func sourceLog(evt executor.Event) (executor.Log, bool) {
+	var sourceType string
+	var raw any
+	switch content := evt.Content.(type) {
+	case executor.UnifiedContent:
+		sourceType, raw = content.SourceType, content.Raw
+	case *executor.UnifiedContent:
+		sourceType, raw = content.SourceType, content.Raw
+	case map[string]any:
+		if !evt.Normalized {
+			return executor.Log{Type: evt.Type, Content: evt.Content}, true
+		}
+		sourceType, _ = content["source_type"].(string)
+		raw = content["raw"]
+	default:
+		return executor.Log{Type: evt.Type, Content: evt.Content}, true
+	}
+	switch sourceType {
+	case "", SessionStartedSourceType, StalledSourceType, ToolLimitSourceType, NoLogsSourceType:
+		// Events the SDK recorded itself have no executor log to replay.
+		return executor.Log{}, false
+	}
+	return executor.Log{Type: sourceType, Content: raw}, true
 }
@@ -0,0 +1,89 @@
+package sdk
+
+import (
+	"maps"
+
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+// View reshapes an event on its way to a subscriber. Views never change what
+// is stored, only what a subscription emits.
+type View func(evt executor.Event) executor.Event
+
+// Built-in view names.
+const (
+	ViewFull    = "full"
+	ViewCompact = "compact"
+)
+
+// compactTextLimit bounds content.text in the compact view, in runes.
+const compactTextLimit = 280
+
+func defaultViews() map[string]View {
+	return map[string]View{
+		ViewFull:    FullView,
+		ViewCompact: CompactView,
+	}
+}
+
+// FullView returns events unchanged.
+func FullView(evt executor.Event) executor.Event { return evt }
+
+// CompactView drops the raw executor payload and shortens long text, keeping
+// summaries and routing fields. Non-normalized content is left unchanged.
+func CompactView(evt executor.Event) executor.Event {
+	switch content := evt.Content.(type) {
+	case executor.UnifiedContent:
+		content.Raw = nil
+		content.Text = truncateText(content.Text, compactTextLimit)
+		evt.Content = content
+	case *executor.UnifiedContent:
+		if content != nil {
+			compact := *content
+			compact.Raw = nil
+			compact.Text = truncateText(compact.Text, compactTextLimit)
+			evt.Content = compact
+		}
+	case map[string]any:
+		// Content decoded from a persistent store.
+		if !evt.Normalized {
+			return evt
+		}
+		compact := maps.Clone(content)
+		delete(compact, "raw")
+		if text, ok := compact["text"].(string); ok {
+			compact["text"] = truncateText(text, compactTextLimit)
+		}
+		evt.Content = compact
+	}
+	return evt
+}
+
+// truncateText shortens text to limit runes, marking the cut with "…".
+func truncateText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "…"
+}
+
+// RegisterView adds or replaces a named view for Subscribe.
+func (c *Client) RegisterView(name string, view View) {
+	c.viewsMu.Lock()
+	defer c.viewsMu.Unlock()
+	c.views[name] = view
+}
+
+// HasView reports whether a view with the given name is registered.
+func (c *Client) HasView(name string) bool {
+	_, ok := c.view(name)
+	return ok
+}
+
+func (c *Client) view(name string) (View, bool) {
+	c.viewsMu.RLock()
+	defer c.viewsMu.RUnlock()
+	view, ok := c.views[name]
+	return view, ok
+}
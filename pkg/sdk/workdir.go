@@ -0,0 +1,163 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+// ErrWorkingDirBusy is returned by Execute and ContinueTask when
+// ClientOptions.MaxSessionsPerWorkingDir sessions are already running in the
+// requested working directory and WorkingDirLimitMode is LimitReject.
+var ErrWorkingDirBusy = errors.New("working directory already in use by another session")
+
+// ErrWorkingDirNotAllowed is returned by Execute and ContinueTask when the
+// working directory resolves, after following symlinks, outside
+// ClientOptions.WorkingDirRoot.
+var ErrWorkingDirNotAllowed = errors.New("working directory is outside the allowed root")
+
+// resolveWorkingDirRoot returns root as an absolute path with symlinks
+// resolved, so that it compares with resolved working directories.
+func resolveWorkingDirRoot(root string) string {
+	if root == "" {
+		return ""
+	}
+	if resolved, err := resolvePath(root); err == nil {
+		return resolved
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return filepath.Clean(root)
+	}
+	return abs
+}
+
+// confineWorkingDir checks that dir (the process directory when empty)
+// resolves inside ClientOptions.WorkingDirRoot and returns the resolved path.
+// Without a root, dir is returned unchanged.
+func (c *Client) confineWorkingDir(dir string) (string, error) {
+	if c.workDirRoot == "" {
+		return dir, nil
+	}
+	resolved, err := resolvePath(dir)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidWorkingDir, dir)
+	}
+	if !pathWithin(c.workDirRoot, resolved) {
+		return "", fmt.Errorf("%w: %s", ErrWorkingDirNotAllowed, dir)
+	}
+	return resolved, nil
+}
+
+// workDirLimiter caps concurrent runs per resolved working directory.
+type workDirLimiter struct {
+	limit int
+	mode  executor.LimitMode
+
+	mu   sync.Mutex
+	dirs map[string]*workDirSlots
+}
+
+// workDirSlots holds one token per running session in a directory. refs
+// counts holders and waiters so the entry can be dropped once unused.
+type workDirSlots struct {
+	tokens chan struct{}
+	refs   int
+}
+
+func newWorkDirLimiter(limit int, mode executor.LimitMode) *workDirLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &workDirLimiter{limit: limit, mode: mode, dirs: make(map[string]*workDirSlots)}
+}
+
+// acquire takes a slot for dir, failing with ErrWorkingDirBusy or waiting
+// until ctx is done depending on the mode. The returned func releases the
+// slot and is safe to call more than once.
+func (l *workDirLimiter) acquire(ctx context.Context, dir string) (func(), error) {
+	key := workDirKey(dir)
+
+	l.mu.Lock()
+	slots, ok := l.dirs[key]
+	if !ok {
+		slots = &workDirSlots{tokens: make(chan struct{}, l.limit)}
+		l.dirs[key] = slots
+	}
+	slots.refs++
+	l.mu.Unlock()
+
+	var err error
+	if l.mode == executor.LimitBlock {
+		select {
+		case slots.tokens <- struct{}{}:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	} else {
+		select {
+		case slots.tokens <- struct{}{}:
+		default:
+			err = fmt.Errorf("%w: %s", ErrWorkingDirBusy, key)
+		}
+	}
+	if err != nil {
+		l.unref(key, slots)
+		return nil, err
+	}
+
+	return sync.OnceFunc(func() {
+		<-slots.tokens
+		l.unref(key, slots)
+	}), nil
+}
+
+func (l *workDirLimiter) unref(key string, slots *workDirSlots) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots.refs--
+	if slots.refs == 0 {
+		delete(l.dirs, key)
+	}
+}
+
+// workDirKey resolves dir to an absolute path without symlinks, so different
+// spellings of one directory share a slot. It falls back to the cleaned path
+// when the directory cannot be resolved.
+func workDirKey(dir string) string {
+	if dir == "" {
+		dir = "."
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return filepath.Clean(dir)
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	return abs
+}
+
+// lockWorkingDir takes a working directory slot for a new run. It returns a
+// nil release func when no per-directory limit is configured.
+func (c *Client) lockWorkingDir(ctx context.Context, dir string) (func(), error) {
+	if c.workDirs == nil {
+		return nil, nil
+	}
+	return c.workDirs.acquire(ctx, dir)
+}
+
+// releaseWhenFinished frees a working directory slot once the run ends.
+func releaseWhenFinished(finished <-chan struct{}, release func()) {
+	if release == nil {
+		return
+	}
+	go func() {
+		<-finished
+		release()
+	}()
+}
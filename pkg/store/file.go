@@ -0,0 +1,333 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+// ErrStoreClosed is returned when appending to a store that has been closed.
+var ErrStoreClosed = errors.New("event store closed")
+
+const fileEventStoreExt = ".jsonl"
+
+// FileEventStore persists events as newline-delimited JSON, one append-only
+// file per session (<dir>/<sessionID>.jsonl).
+//
+// Event content is stored in its JSON form, so typed content such as
+// executor.UnifiedContent is returned as map[string]any by List.
+type FileEventStore struct {
+	dir string
+
+	mu       sync.Mutex
+	sessions map[string]*fileSession
+	closed   bool
+}
+
+type fileSession struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	seq  uint64
+}
+
+// NewFileEventStore creates a file-backed event store rooted at dir.
+// The directory is created if missing, and sequence counters are recovered
+// from any existing session files. A trailing partial line left by a crash
+// mid-write is cut off so that later appends start on a fresh line.
+func NewFileEventStore(dir string) (*FileEventStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create event store dir: %w", err)
+	}
+
+	store := &FileEventStore{
+		dir:      dir,
+		sessions: make(map[string]*fileSession),
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read event store dir: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), fileEventStoreExt) {
+			continue
+		}
+
+		sessionID := strings.TrimSuffix(entry.Name(), fileEventStoreExt)
+		path := filepath.Join(dir, entry.Name())
+		if err := trimPartialLine(path); err != nil {
+			return nil, fmt.Errorf("recover session %s: %w", sessionID, err)
+		}
+		seq, err := lastSeqInFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("recover session %s: %w", sessionID, err)
+		}
+		store.sessions[sessionID] = &fileSession{path: path, seq: seq}
+	}
+
+	return store, nil
+}
+
+func (s *FileEventStore) Append(ctx context.Context, evt executor.Event) (executor.Event, error) {
+	sess, err := s.session(evt.SessionID, true)
+	if err != nil {
+		return executor.Event{}, err
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if sess.file == nil {
+		file, err := os.OpenFile(sess.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return executor.Event{}, fmt.Errorf("open session file: %w", err)
+		}
+		sess.file = file
+	}
+
+	evt.Seq = sess.seq + 1
+	if evt.Timestamp.IsZero() {
+		evt.Timestamp = time.Now()
+	}
+
+	data, err := json.Marshal(evt)
+	if err != nil {
+		return executor.Event{}, fmt.Errorf("marshal event: %w", err)
+	}
+	if _, err := sess.file.Write(append(data, '\n')); err != nil {
+		return executor.Event{}, fmt.Errorf("write event: %w", err)
+	}
+
+	sess.seq = evt.Seq
+	return evt, nil
+}
+
+func (s *FileEventStore) List(ctx context.Context, sessionID string, opts ListOptions) ([]executor.Event, error) {
+	sess, err := s.session(sessionID, false)
+	if err != nil || sess == nil {
+		return nil, err
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	file, err := os.Open(sess.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("open session file: %w", err)
+	}
+	defer file.Close()
+
+	tail := opts.Tail > 0
+	var out []executor.Event
+	err = readEventLines(file, func(evt executor.Event) bool {
+		if !tail && opts.AfterSeq > 0 && evt.Seq <= opts.AfterSeq {
+			return true
+		}
+		if opts.UntilSeq > 0 && evt.Seq > opts.UntilSeq {
+			return false
+		}
+		if !opts.MatchType(evt.Type) {
+			return true
+		}
+		out = append(out, evt)
+		return tail || opts.Limit <= 0 || len(out) < opts.Limit
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return opts.ApplyTail(out), nil
+}
+
+func (s *FileEventStore) LatestSeq(ctx context.Context, sessionID string) (uint64, error) {
+	sess, err := s.session(sessionID, false)
+	if err != nil || sess == nil {
+		return 0, err
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.seq, nil
+}
+
+// DeleteSession closes and removes a session's file.
+func (s *FileEventStore) DeleteSession(ctx context.Context, sessionID string) error {
+	if err := validateFileSessionID(sessionID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	sess, ok := s.sessions[sessionID]
+	delete(s.sessions, sessionID)
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.file != nil {
+		_ = sess.file.Close()
+		sess.file = nil
+	}
+	if err := os.Remove(sess.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("delete session file: %w", err)
+	}
+	return nil
+}
+
+// Close flushes and closes all open session files, returning the first
+// failure. Appends after Close fail with ErrStoreClosed; List and LatestSeq
+// keep working.
+func (s *FileEventStore) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	sessions := make([]*fileSession, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.mu.Unlock()
+
+	var firstErr error
+	for _, sess := range sessions {
+		sess.mu.Lock()
+		if sess.file != nil {
+			if err := sess.file.Sync(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			if err := sess.file.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			sess.file = nil
+		}
+		sess.mu.Unlock()
+	}
+	return firstErr
+}
+
+// session returns the per-session state, creating it when create is true.
+// A nil session with a nil error means the session does not exist.
+func (s *FileEventStore) session(sessionID string, create bool) (*fileSession, error) {
+	if err := validateFileSessionID(sessionID); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if create && s.closed {
+		return nil, ErrStoreClosed
+	}
+
+	sess, ok := s.sessions[sessionID]
+	if !ok && create {
+		sess = &fileSession{path: filepath.Join(s.dir, sessionID+fileEventStoreExt)}
+		s.sessions[sessionID] = sess
+	}
+	return sess, nil
+}
+
+// validateFileSessionID rejects IDs that cannot safely be used as a file name.
+func validateFileSessionID(sessionID string) error {
+	if sessionID == "" || sessionID != filepath.Base(sessionID) || sessionID == "." || sessionID == ".." {
+		return fmt.Errorf("invalid session id for file store: %q", sessionID)
+	}
+	return nil
+}
+
+// lastSeqInFile scans a session file and returns the highest seq it contains.
+func lastSeqInFile(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var seq uint64
+	err = readEventLines(file, func(evt executor.Event) bool {
+		if evt.Seq > seq {
+			seq = evt.Seq
+		}
+		return true
+	})
+	return seq, err
+}
+
+// trimPartialLine truncates path after its last newline, dropping a final
+// line that was only partly written.
+func trimPartialLine(path string) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	end := info.Size()
+	buf := make([]byte, 4096)
+	for offset := end; offset > 0; {
+		n := int64(len(buf))
+		if offset < n {
+			n = offset
+		}
+		offset -= n
+		if _, err := file.ReadAt(buf[:n], offset); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			if keep := offset + int64(i) + 1; keep < end {
+				return file.Truncate(keep)
+			}
+			return nil
+		}
+	}
+	if end > 0 {
+		return file.Truncate(0)
+	}
+	return nil
+}
+
+// readEventLines decodes one event per line and passes it to fn until fn
+// returns false. A trailing partial line (e.g. from a crash mid-write) is skipped.
+func readEventLines(r io.Reader, fn func(evt executor.Event) bool) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			line = bytes.TrimSpace(line)
+			if len(line) > 0 {
+				var evt executor.Event
+				if jsonErr := json.Unmarshal(line, &evt); jsonErr != nil {
+					return fmt.Errorf("decode event: %w", jsonErr)
+				}
+				if !fn(evt) {
+					return nil
+				}
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
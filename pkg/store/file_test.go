@@ -0,0 +1,229 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+func TestFileEventStoreAppendAndList(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileEventStore(dir)
+	if err != nil {
+		t.Fatalf("new file store failed: %v", err)
+	}
+	defer store.Close()
+
+	sessionID := "file-session"
+	for _, typ := range []string{"stdout", "tool", "done"} {
+		if _, err := store.Append(context.Background(), executor.Event{SessionID: sessionID, Type: typ, Content: typ}); err != nil {
+			t.Fatalf("append failed: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, sessionID+".jsonl"))
+	if err != nil {
+		t.Fatalf("read session file failed: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 3 {
+		t.Fatalf("expected 3 json lines, got %d", lines)
+	}
+
+	events, err := store.List(context.Background(), sessionID, ListOptions{AfterSeq: 1, UntilSeq: 3, Limit: 1})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(events) != 1 || events[0].Seq != 2 || events[0].Type != "tool" {
+		t.Fatalf("unexpected filtered events: %#v", events)
+	}
+
+	events, err = store.List(context.Background(), sessionID, ListOptions{AfterSeq: 2, Tail: 2})
+	if err != nil || len(events) != 2 || events[0].Seq != 2 || events[1].Seq != 3 {
+		t.Fatalf("expected the last two events, got %#v (err=%v)", events, err)
+	}
+	events, _ = store.List(context.Background(), sessionID, ListOptions{Tail: 5, Types: []string{"stdout", "tool"}})
+	if len(events) != 2 || events[0].Seq != 1 || events[1].Seq != 2 {
+		t.Fatalf("expected every stdout/tool event for a large tail, got %#v", events)
+	}
+
+	seq, err := store.LatestSeq(context.Background(), sessionID)
+	if err != nil || seq != 3 {
+		t.Fatalf("expected latest seq 3, got %d (err=%v)", seq, err)
+	}
+
+	missing, err := store.List(context.Background(), "missing", ListOptions{})
+	if err != nil || len(missing) != 0 {
+		t.Fatalf("expected no events for unknown session, got %v (err=%v)", missing, err)
+	}
+}
+
+func TestFileEventStoreDeleteSession(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileEventStore(dir)
+	if err != nil {
+		t.Fatalf("new file store failed: %v", err)
+	}
+	defer store.Close()
+
+	if _, err := store.Append(context.Background(), executor.Event{SessionID: "doomed", Type: "stdout"}); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+	if err := store.DeleteSession(context.Background(), "doomed"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "doomed.jsonl")); !os.IsNotExist(err) {
+		t.Fatalf("expected session file to be removed, got %v", err)
+	}
+	if seq, _ := store.LatestSeq(context.Background(), "doomed"); seq != 0 {
+		t.Fatalf("expected no events after delete, got seq %d", seq)
+	}
+	if err := store.DeleteSession(context.Background(), "doomed"); err != nil {
+		t.Fatalf("expected deleting a missing session to succeed, got %v", err)
+	}
+}
+
+func TestFileEventStoreRecoversSeqOnStartup(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileEventStore(dir)
+	if err != nil {
+		t.Fatalf("new file store failed: %v", err)
+	}
+	sessionID := "recover-session"
+	_, _ = store.Append(context.Background(), executor.Event{SessionID: sessionID, Type: "stdout", Content: "one"})
+	_, _ = store.Append(context.Background(), executor.Event{SessionID: sessionID, Type: "stdout", Content: "two"})
+	if err := store.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("second close failed: %v", err)
+	}
+
+	if _, err := store.Append(context.Background(), executor.Event{SessionID: sessionID, Type: "stdout"}); err != ErrStoreClosed {
+		t.Fatalf("expected ErrStoreClosed after close, got %v", err)
+	}
+
+	reopened, err := NewFileEventStore(dir)
+	if err != nil {
+		t.Fatalf("reopen file store failed: %v", err)
+	}
+	defer reopened.Close()
+
+	seq, _ := reopened.LatestSeq(context.Background(), sessionID)
+	if seq != 2 {
+		t.Fatalf("expected recovered seq 2, got %d", seq)
+	}
+	evt, err := reopened.Append(context.Background(), executor.Event{SessionID: sessionID, Type: "done", Content: "done"})
+	if err != nil {
+		t.Fatalf("append after reopen failed: %v", err)
+	}
+	if evt.Seq != 3 {
+		t.Fatalf("expected seq to continue at 3, got %d", evt.Seq)
+	}
+}
+
+func TestFileEventStoreTrimsPartialLineOnStartup(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileEventStore(dir)
+	if err != nil {
+		t.Fatalf("new file store failed: %v", err)
+	}
+	sessionID := "torn-session"
+	_, _ = store.Append(context.Background(), executor.Event{SessionID: sessionID, Type: "stdout", Content: "one"})
+	_, _ = store.Append(context.Background(), executor.Event{SessionID: sessionID, Type: "stdout", Content: "two"})
+	if err := store.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	// Cut the second event off mid-line, as a crash during the write would.
+	path := filepath.Join(dir, sessionID+".jsonl")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	if err := os.Truncate(path, info.Size()-10); err != nil {
+		t.Fatalf("truncate failed: %v", err)
+	}
+
+	reopened, err := NewFileEventStore(dir)
+	if err != nil {
+		t.Fatalf("reopen file store failed: %v", err)
+	}
+	defer reopened.Close()
+	evt, err := reopened.Append(context.Background(), executor.Event{SessionID: sessionID, Type: "done", Content: "done"})
+	if err != nil {
+		t.Fatalf("append after reopen failed: %v", err)
+	}
+	if evt.Seq != 2 {
+		t.Fatalf("expected seq 2 after the torn event, got %d", evt.Seq)
+	}
+
+	events, err := reopened.List(context.Background(), sessionID, ListOptions{})
+	if err != nil {
+		t.Fatalf("list after append failed: %v", err)
+	}
+	if len(events) != 2 || events[0].Content != "one" || events[1].Type != "done" {
+		t.Fatalf("expected the first event and the new done, got %+v", events)
+	}
+	if _, err := NewFileEventStore(dir); err != nil {
+		t.Fatalf("reopen after append failed: %v", err)
+	}
+}
+
+func TestFileEventStoreRejectsPathSessionID(t *testing.T) {
+	store, err := NewFileEventStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new file store failed: %v", err)
+	}
+	defer store.Close()
+
+	if _, err := store.Append(context.Background(), executor.Event{SessionID: "../escape", Type: "stdout"}); err == nil {
+		t.Fatal("expected session id with path separators to be rejected")
+	}
+}
+
+func TestFileSessionStoreSaveLoadDelete(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileSessionStore(dir)
+	if err != nil {
+		t.Fatalf("new session store failed: %v", err)
+	}
+
+	record := SessionRecord{
+		Session: executor.Session{SessionID: "s1", Title: "first", Status: executor.SessionStatusDone},
+		Request: executor.ExecuteRequest{Prompt: "hello", Executor: executor.ExecutorCodex},
+		Resume:  []byte(`{"codex_conversation":"conv-1"}`),
+	}
+	if err := store.Save(context.Background(), record); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	record.Session.Title = "updated"
+	if err := store.Save(context.Background(), record); err != nil {
+		t.Fatalf("overwrite failed: %v", err)
+	}
+	if err := store.Save(context.Background(), SessionRecord{Session: executor.Session{SessionID: "../escape"}}); err == nil {
+		t.Fatal("expected path session id to be rejected")
+	}
+
+	reopened, _ := NewFileSessionStore(dir)
+	records, err := reopened.Load(context.Background())
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if len(records) != 1 || records[0].Session.Title != "updated" || records[0].Request.Prompt != "hello" || string(records[0].Resume) != `{"codex_conversation":"conv-1"}` {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+
+	if err := reopened.Delete(context.Background(), "s1"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if err := reopened.Delete(context.Background(), "s1"); err != nil {
+		t.Fatalf("deleting a missing record should succeed, got %v", err)
+	}
+	if records, _ := reopened.Load(context.Background()); len(records) != 0 {
+		t.Fatalf("expected no records after delete, got %+v", records)
+	}
+}
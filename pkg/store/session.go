@@ -0,0 +1,145 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/supremeagent/executor/pkg/executor"
+)
+
+const fileSessionStoreExt = ".json"
+
+// SessionRecord is the persisted metadata of one SDK session: its summary, the
+// request that started it and executor resume state, which is opaque to stores.
+type SessionRecord struct {
+	Session executor.Session        `json:"session"`
+	Request executor.ExecuteRequest `json:"request"`
+	Resume  json.RawMessage         `json:"resume,omitempty"`
+}
+
+// SessionStore persists session metadata so it survives a restart.
+type SessionStore interface {
+	// Save creates or replaces the record for record.Session.SessionID.
+	Save(ctx context.Context, record SessionRecord) error
+	// Load returns every stored record.
+	Load(ctx context.Context) ([]SessionRecord, error)
+	// Delete removes a record. Deleting a missing record is not an error.
+	Delete(ctx context.Context, sessionID string) error
+}
+
+// NopSessionStore keeps nothing; sessions live only in the client's memory.
+type NopSessionStore struct{}
+
+func (NopSessionStore) Save(context.Context, SessionRecord) error     { return nil }
+func (NopSessionStore) Load(context.Context) ([]SessionRecord, error) { return nil, nil }
+func (NopSessionStore) Delete(context.Context, string) error          { return nil }
+
+// FileSessionStore persists each session as a JSON document
+// (<dir>/<sessionID>.json). Records include the original request, so files
+// are created readable only by the owner.
+type FileSessionStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileSessionStore creates a file-backed session store rooted at dir,
+// creating the directory if missing.
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create session store dir: %w", err)
+	}
+	return &FileSessionStore{dir: dir}, nil
+}
+
+// Save writes the record to a temporary file and renames it into place, so a
+// crash never leaves a half-written record behind.
+func (s *FileSessionStore) Save(ctx context.Context, record SessionRecord) error {
+	sessionID := record.Session.SessionID
+	if err := validateFileSessionID(sessionID); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal session: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := os.CreateTemp(s.dir, sessionID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create session file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write session file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write session file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(sessionID)); err != nil {
+		return fmt.Errorf("replace session file: %w", err)
+	}
+	return nil
+}
+
+// Load reads all session records, ordered by session ID.
+func (s *FileSessionStore) Load(ctx context.Context) ([]SessionRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read session store dir: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), fileSessionStoreExt) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	records := make([]SessionRecord, 0, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(s.dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("read session file %s: %w", name, err)
+		}
+		var record SessionRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, fmt.Errorf("decode session file %s: %w", name, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (s *FileSessionStore) Delete(ctx context.Context, sessionID string) error {
+	if err := validateFileSessionID(sessionID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.path(sessionID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("delete session file: %w", err)
+	}
+	return nil
+}
+
+func (s *FileSessionStore) path(sessionID string) string {
+	return filepath.Join(s.dir, sessionID+fileSessionStoreExt)
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"slices"
 	"sync"
 	"time"
 
@@ -13,6 +14,37 @@ type ListOptions struct {
 	AfterSeq uint64
 	UntilSeq uint64
 	Limit    int
+	// Types keeps only events whose Type is in the list. Empty means all.
+	// Limit counts events after this filter.
+	Types []string
+	// Tail, when positive, returns the last Tail events that pass UntilSeq
+	// and Types, oldest first. It takes precedence over AfterSeq and Limit,
+	// which are ignored.
+	Tail int
+}
+
+// ApplyTail keeps the last Tail events of events, which must already be
+// filtered by UntilSeq and Types. It returns events unchanged when Tail is
+// not set. Stores that cannot read from the end can List without Tail and
+// finish with ApplyTail.
+func (o ListOptions) ApplyTail(events []executor.Event) []executor.Event {
+	if o.Tail <= 0 || len(events) <= o.Tail {
+		return events
+	}
+	return events[len(events)-o.Tail:]
+}
+
+// MatchType reports whether an event type passes the Types filter.
+func (o ListOptions) MatchType(eventType string) bool {
+	if len(o.Types) == 0 {
+		return true
+	}
+	for _, t := range o.Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
 }
 
 // EventStore persists execution events.
@@ -20,28 +52,49 @@ type EventStore interface {
 	Append(ctx context.Context, evt executor.Event) (executor.Event, error)
 	List(ctx context.Context, sessionID string, opts ListOptions) ([]executor.Event, error)
 	LatestSeq(ctx context.Context, sessionID string) (uint64, error)
+	// Close flushes pending writes and releases background resources. It is
+	// called once by Client.Shutdown and must be safe to call more than once.
+	Close() error
+}
+
+// SessionDeleter is implemented by event stores that can drop every event of
+// a session. Deleting an unknown session is not an error.
+type SessionDeleter interface {
+	DeleteSession(ctx context.Context, sessionID string) error
 }
 
 // MemoryEventStore is the default in-memory EventStore implementation.
 type MemoryEventStore struct {
 	mu              sync.RWMutex
 	events          map[string][]executor.Event
+	pinnedDone      map[string]executor.Event
 	nextSeq         map[string]uint64
 	sessionDoneAt   map[string]time.Time
 	expireAfterDone time.Duration
 	cleanupInterval time.Duration
+	maxEvents       int
 	stopCleanup     chan struct{}
 	stopOnce        sync.Once
 }
 
+// DefaultCleanupInterval is the upper bound of the cleanup interval applied
+// when MemoryEventStoreOptions.CleanupInterval is not set.
+const DefaultCleanupInterval = 30 * time.Second
+
 // MemoryEventStoreOptions controls in-memory store lifecycle behavior.
 type MemoryEventStoreOptions struct {
 	// ExpireAfterDone removes a session after it has been in done state for this long.
 	// Set to 0 to disable automatic cleanup.
 	ExpireAfterDone time.Duration
 	// CleanupInterval controls how often expired sessions are scanned and removed.
-	// If <= 0 and ExpireAfterDone > 0, a sensible default is applied.
+	// A positive value is used as-is, with no minimum, so short intervals are
+	// suitable for tests. If <= 0 and ExpireAfterDone > 0, it defaults to
+	// min(DefaultCleanupInterval, ExpireAfterDone).
 	CleanupInterval time.Duration
+	// MaxEventsPerSession caps the events retained per session. Append trims
+	// the oldest events beyond the cap, except the most recent done event, and
+	// marks the oldest retained event as Truncated. 0 means unlimited.
+	MaxEventsPerSession int
 }
 
 // NewMemoryEventStore creates an in-memory event store.
@@ -60,16 +113,18 @@ func NewMemoryEventStoreWithExpiration(expireAfterDone time.Duration) *MemoryEve
 func NewMemoryEventStoreWithOptions(opts MemoryEventStoreOptions) *MemoryEventStore {
 	store := &MemoryEventStore{
 		events:          make(map[string][]executor.Event),
+		pinnedDone:      make(map[string]executor.Event),
 		nextSeq:         make(map[string]uint64),
 		sessionDoneAt:   make(map[string]time.Time),
 		expireAfterDone: opts.ExpireAfterDone,
 		cleanupInterval: opts.CleanupInterval,
+		maxEvents:       opts.MaxEventsPerSession,
 		stopCleanup:     make(chan struct{}),
 	}
 
 	if store.expireAfterDone > 0 {
 		if store.cleanupInterval <= 0 {
-			store.cleanupInterval = minDuration(30*time.Second, store.expireAfterDone)
+			store.cleanupInterval = minDuration(DefaultCleanupInterval, store.expireAfterDone)
 		}
 		go store.cleanupLoop()
 	}
@@ -90,19 +145,78 @@ func (s *MemoryEventStore) Append(ctx context.Context, evt executor.Event) (exec
 	s.events[evt.SessionID] = append(s.events[evt.SessionID], evt)
 	if evt.Type == "done" {
 		s.sessionDoneAt[evt.SessionID] = evt.Timestamp
+		if _, ok := s.pinnedDone[evt.SessionID]; ok {
+			// The new done supersedes the pinned one, which leaves the
+			// oldest event of the tail as the first retained.
+			delete(s.pinnedDone, evt.SessionID)
+			s.events[evt.SessionID][0].Truncated = true
+		}
+	}
+	if s.maxEvents > 0 {
+		s.trimLocked(evt.SessionID)
 	}
 	return evt, nil
 }
 
+// trimLocked drops the oldest events of a session so at most maxEvents
+// remain, keeping the most recent done event, and marks the first retained
+// event as Truncated. Dropping re-slices, so the next append that outgrows
+// the backing array copies only the retained events; a done event older than
+// the whole window moves to pinnedDone instead. s.mu must be held.
+func (s *MemoryEventStore) trimLocked(sessionID string) {
+	events := s.events[sessionID]
+	limit := s.maxEvents
+	if _, ok := s.pinnedDone[sessionID]; ok {
+		limit--
+	}
+	if len(events) <= limit {
+		return
+	}
+	for len(events) > limit {
+		oldest := events[0]
+		events = events[1:]
+		if oldest.Type == "done" && !slices.ContainsFunc(events, isDone) {
+			oldest.Truncated = true
+			s.pinnedDone[sessionID] = oldest
+			limit--
+		}
+	}
+	if len(events) > 0 {
+		if _, ok := s.pinnedDone[sessionID]; !ok {
+			events[0].Truncated = true
+		}
+	}
+	s.events[sessionID] = events
+}
+
+func isDone(evt executor.Event) bool {
+	return evt.Type == "done"
+}
+
+// retainedLocked returns the events of a session in order, including a
+// pinned done event. s.mu must be held.
+func (s *MemoryEventStore) retainedLocked(sessionID string) []executor.Event {
+	events := s.events[sessionID]
+	done, ok := s.pinnedDone[sessionID]
+	if !ok {
+		return events
+	}
+	return append([]executor.Event{done}, events...)
+}
+
 func (s *MemoryEventStore) List(ctx context.Context, sessionID string, opts ListOptions) ([]executor.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	src := s.events[sessionID]
+	src := s.retainedLocked(sessionID)
 	if len(src) == 0 {
 		return nil, nil
 	}
 
+	if opts.Tail > 0 {
+		return listTail(src, opts), nil
+	}
+
 	out := make([]executor.Event, 0, len(src))
 	for _, evt := range src {
 		if opts.AfterSeq > 0 && evt.Seq <= opts.AfterSeq {
@@ -111,6 +225,9 @@ func (s *MemoryEventStore) List(ctx context.Context, sessionID string, opts List
 		if opts.UntilSeq > 0 && evt.Seq > opts.UntilSeq {
 			continue
 		}
+		if !opts.MatchType(evt.Type) {
+			continue
+		}
 		out = append(out, evt)
 		if opts.Limit > 0 && len(out) >= opts.Limit {
 			break
@@ -120,6 +237,24 @@ func (s *MemoryEventStore) List(ctx context.Context, sessionID string, opts List
 	return out, nil
 }
 
+// listTail walks src from the end, so a short tail of a long session does not
+// copy the whole session.
+func listTail(src []executor.Event, opts ListOptions) []executor.Event {
+	out := make([]executor.Event, 0, min(opts.Tail, len(src)))
+	for i := len(src) - 1; i >= 0 && len(out) < opts.Tail; i-- {
+		evt := src[i]
+		if opts.UntilSeq > 0 && evt.Seq > opts.UntilSeq {
+			continue
+		}
+		if !opts.MatchType(evt.Type) {
+			continue
+		}
+		out = append(out, evt)
+	}
+	slices.Reverse(out)
+	return out
+}
+
 func (s *MemoryEventStore) LatestSeq(ctx context.Context, sessionID string) (uint64, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -131,11 +266,34 @@ func (s *MemoryEventStore) LatestSeq(ctx context.Context, sessionID string) (uin
 	return seq, nil
 }
 
-// Close stops the cleanup goroutine for stores created with expiration options.
-func (s *MemoryEventStore) Close() {
+// CleanupInterval returns the effective interval between expiry scans, or 0
+// when automatic cleanup is disabled.
+func (s *MemoryEventStore) CleanupInterval() time.Duration {
+	if s.expireAfterDone <= 0 {
+		return 0
+	}
+	return s.cleanupInterval
+}
+
+// DeleteSession drops all events of a session.
+func (s *MemoryEventStore) DeleteSession(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.events, sessionID)
+	delete(s.pinnedDone, sessionID)
+	delete(s.nextSeq, sessionID)
+	delete(s.sessionDoneAt, sessionID)
+	return nil
+}
+
+// Close stops the cleanup goroutine for stores created with expiration
+// options. It is a no-op otherwise and always returns nil.
+func (s *MemoryEventStore) Close() error {
 	s.stopOnce.Do(func() {
 		close(s.stopCleanup)
 	})
+	return nil
 }
 
 func (s *MemoryEventStore) cleanupLoop() {
@@ -162,6 +320,7 @@ func (s *MemoryEventStore) cleanupExpiredSessions(now time.Time) {
 		}
 
 		delete(s.events, sessionID)
+		delete(s.pinnedDone, sessionID)
 		delete(s.nextSeq, sessionID)
 		delete(s.sessionDoneAt, sessionID)
 	}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -63,3 +64,169 @@ func TestMemoryEventStoreWithExpirationHelper(t *testing.T) {
 		t.Fatalf("expected expireAfterDone to be set")
 	}
 }
+
+func TestMemoryEventStoreShortCleanupInterval(t *testing.T) {
+	store := NewMemoryEventStoreWithOptions(MemoryEventStoreOptions{
+		ExpireAfterDone: 50 * time.Millisecond,
+		CleanupInterval: 10 * time.Millisecond,
+	})
+	defer store.Close()
+
+	if got := store.CleanupInterval(); got != 10*time.Millisecond {
+		t.Fatalf("expected explicit 10ms interval to be kept, got %v", got)
+	}
+
+	sessionID := "session-fast-expire"
+	_, _ = store.Append(context.Background(), executor.Event{SessionID: sessionID, Type: "done", Content: "done"})
+
+	time.Sleep(100 * time.Millisecond)
+	events, err := store.List(context.Background(), sessionID, ListOptions{})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("expected session to expire within 100ms, still has %d events", len(events))
+	}
+}
+
+func TestMemoryEventStoreDefaultCleanupInterval(t *testing.T) {
+	short := NewMemoryEventStoreWithExpiration(50 * time.Millisecond)
+	defer short.Close()
+	if got := short.CleanupInterval(); got != 50*time.Millisecond {
+		t.Fatalf("expected interval to follow short TTL, got %v", got)
+	}
+
+	long := NewMemoryEventStoreWithExpiration(time.Hour)
+	defer long.Close()
+	if got := long.CleanupInterval(); got != DefaultCleanupInterval {
+		t.Fatalf("expected default interval for long TTL, got %v", got)
+	}
+
+	if got := NewMemoryEventStore().CleanupInterval(); got != 0 {
+		t.Fatalf("expected no cleanup without TTL, got %v", got)
+	}
+}
+
+func TestMemoryEventStoreListTypesWithLimit(t *testing.T) {
+	store := NewMemoryEventStore()
+	sessionID := "session-types"
+	for _, typ := range []string{"stdout", "tool", "message", "error", "tool", "done"} {
+		_, _ = store.Append(context.Background(), executor.Event{SessionID: sessionID, Type: typ})
+	}
+
+	events, err := store.List(context.Background(), sessionID, ListOptions{Types: []string{"tool", "error"}, Limit: 2})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(events) != 2 || events[0].Seq != 2 || events[1].Seq != 4 {
+		t.Fatalf("expected filtered seqs 2 and 4, got %#v", events)
+	}
+
+	events, _ = store.List(context.Background(), sessionID, ListOptions{Types: []string{"tool", "error"}, AfterSeq: 4})
+	if len(events) != 1 || events[0].Seq != 5 {
+		t.Fatalf("expected remaining tool event at seq 5, got %#v", events)
+	}
+}
+
+func TestMemoryEventStoreListTail(t *testing.T) {
+	store := NewMemoryEventStore()
+	sessionID := "session-tail"
+	for _, typ := range []string{"stdout", "tool", "message", "error", "tool", "done"} {
+		_, _ = store.Append(context.Background(), executor.Event{SessionID: sessionID, Type: typ})
+	}
+
+	seqs := func(opts ListOptions) string {
+		events, err := store.List(context.Background(), sessionID, opts)
+		if err != nil {
+			t.Fatalf("list failed: %v", err)
+		}
+		var out []uint64
+		for _, evt := range events {
+			out = append(out, evt.Seq)
+		}
+		return fmt.Sprint(out)
+	}
+
+	if got := seqs(ListOptions{Tail: 2}); got != "[5 6]" {
+		t.Fatalf("expected the last two events, got %s", got)
+	}
+	if got := seqs(ListOptions{Tail: 20}); got != "[1 2 3 4 5 6]" {
+		t.Fatalf("expected every event for a tail beyond the total, got %s", got)
+	}
+	if got := seqs(ListOptions{Tail: 2, Types: []string{"tool", "error"}}); got != "[4 5]" {
+		t.Fatalf("expected the last two tool/error events, got %s", got)
+	}
+	if got := seqs(ListOptions{Tail: 2, UntilSeq: 4}); got != "[3 4]" {
+		t.Fatalf("expected the tail to end at until seq, got %s", got)
+	}
+	if got := seqs(ListOptions{Tail: 3, AfterSeq: 5, Limit: 1}); got != "[4 5 6]" {
+		t.Fatalf("expected tail to override after seq and limit, got %s", got)
+	}
+}
+
+func TestMemoryEventStoreDeleteSession(t *testing.T) {
+	store := NewMemoryEventStore()
+	_, _ = store.Append(context.Background(), executor.Event{SessionID: "doomed", Type: "stdout"})
+	_, _ = store.Append(context.Background(), executor.Event{SessionID: "kept", Type: "stdout"})
+
+	if err := store.DeleteSession(context.Background(), "doomed"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if events, _ := store.List(context.Background(), "doomed", ListOptions{}); len(events) != 0 {
+		t.Fatalf("expected deleted session to have no events, got %v", events)
+	}
+	if seq, _ := store.LatestSeq(context.Background(), "kept"); seq != 1 {
+		t.Fatalf("expected other sessions to be untouched, got seq %d", seq)
+	}
+}
+
+func TestMemoryEventStoreMaxEventsPerSession(t *testing.T) {
+	store := NewMemoryEventStoreWithOptions(MemoryEventStoreOptions{MaxEventsPerSession: 3})
+	sessionID := "session-capped"
+	for _, typ := range []string{"stdout", "done", "stdout", "stdout", "stdout"} {
+		_, _ = store.Append(context.Background(), executor.Event{SessionID: sessionID, Type: typ})
+	}
+
+	events, err := store.List(context.Background(), sessionID, ListOptions{})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(events) != 3 || events[0].Seq != 2 || events[1].Seq != 4 || events[2].Seq != 5 {
+		t.Fatalf("expected done at seq 2 plus the newest events, got %#v", events)
+	}
+	if !events[0].Truncated || events[1].Truncated {
+		t.Fatalf("expected only the oldest retained event to be marked truncated, got %#v", events)
+	}
+	if seq, _ := store.LatestSeq(context.Background(), sessionID); seq != 5 {
+		t.Fatalf("expected seq to stay monotonic, got %d", seq)
+	}
+
+	evt, _ := store.Append(context.Background(), executor.Event{SessionID: sessionID, Type: "done"})
+	events, _ = store.List(context.Background(), sessionID, ListOptions{})
+	if len(events) != 3 || events[0].Seq != 4 || events[2].Seq != evt.Seq || !events[0].Truncated {
+		t.Fatalf("expected older done to be trimmed once a newer one exists, got %#v", events)
+	}
+
+	// Many appends past the cap keep the window and the newest done.
+	for i := 0; i < 100; i++ {
+		_, _ = store.Append(context.Background(), executor.Event{SessionID: sessionID, Type: "stdout"})
+	}
+	events, _ = store.List(context.Background(), sessionID, ListOptions{})
+	if len(events) != 3 || events[0].Seq != evt.Seq || !events[0].Truncated || events[1].Truncated || events[2].Seq != evt.Seq+100 {
+		t.Fatalf("expected the newest done plus the newest events, got %#v", events)
+	}
+	tail, _ := store.List(context.Background(), sessionID, ListOptions{Tail: 1})
+	if len(tail) != 1 || tail[0].Seq != evt.Seq+100 {
+		t.Fatalf("expected the tail to be the newest event, got %#v", tail)
+	}
+}
+
+func BenchmarkMemoryEventStoreAppendCapped(b *testing.B) {
+	store := NewMemoryEventStoreWithOptions(MemoryEventStoreOptions{MaxEventsPerSession: 10000})
+	ctx := context.Background()
+	_, _ = store.Append(ctx, executor.Event{SessionID: "bench", Type: "done"})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = store.Append(ctx, executor.Event{SessionID: "bench", Type: "stdout"})
+	}
+}
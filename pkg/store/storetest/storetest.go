@@ -0,0 +1,160 @@
+// Package storetest checks store.EventStore implementations against the
+// behavior the SDK relies on.
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/supremeagent/executor/pkg/executor"
+	"github.com/supremeagent/executor/pkg/store"
+)
+
+// TestEventStore checks the EventStore behavior the SDK relies on, so
+// that new implementations can be tested with one call from their own
+// tests. factory must return a new, empty store for the (sub)test it is
+// given, e.g. in t.TempDir(); the test closes it. Covered are seq assignment
+// (contiguous per session, across interleaved and concurrent appends, and
+// across a resumed run after done), the AfterSeq, UntilSeq, Limit, Types and
+// Tail list options, and LatestSeq.
+func TestEventStore(t *testing.T, factory func(t *testing.T) store.EventStore) {
+	t.Helper()
+	ctx := context.Background()
+
+	newStore := func(t *testing.T) store.EventStore {
+		t.Helper()
+		s := factory(t)
+		t.Cleanup(func() { _ = s.Close() })
+		return s
+	}
+	appendEvent := func(t *testing.T, s store.EventStore, sessionID, eventType, content string) executor.Event {
+		t.Helper()
+		stored, err := s.Append(ctx, executor.Event{SessionID: sessionID, Type: eventType, Content: content})
+		if err != nil {
+			t.Fatalf("append %s to %s failed: %v", eventType, sessionID, err)
+		}
+		return stored
+	}
+	list := func(t *testing.T, s store.EventStore, sessionID string, opts store.ListOptions) []executor.Event {
+		t.Helper()
+		events, err := s.List(ctx, sessionID, opts)
+		if err != nil {
+			t.Fatalf("list %s with %+v failed: %v", sessionID, opts, err)
+		}
+		return events
+	}
+	expectSeqs := func(t *testing.T, events []executor.Event, want ...uint64) {
+		t.Helper()
+		got := make([]uint64, len(events))
+		for i, evt := range events {
+			got[i] = evt.Seq
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("expected seqs %v, got %v", want, got)
+		}
+	}
+
+	t.Run("InterleavedSessions", func(t *testing.T) {
+		s := newStore(t)
+		for i := 1; i <= 3; i++ {
+			for _, sessionID := range []string{"session-a", "session-b"} {
+				stored := appendEvent(t, s, sessionID, "stdout", fmt.Sprintf("%s %d", sessionID, i))
+				if stored.Seq != uint64(i) || stored.SessionID != sessionID {
+					t.Fatalf("expected seq %d for %s, got %+v", i, sessionID, stored)
+				}
+				if stored.Timestamp.IsZero() {
+					t.Fatal("expected Append to set a timestamp")
+				}
+			}
+		}
+		for _, sessionID := range []string{"session-a", "session-b"} {
+			events := list(t, s, sessionID, store.ListOptions{})
+			expectSeqs(t, events, 1, 2, 3)
+			for i, evt := range events {
+				if want := fmt.Sprintf("%s %d", sessionID, i+1); evt.Content != want {
+					t.Fatalf("expected content %q at seq %d, got %v", want, evt.Seq, evt.Content)
+				}
+			}
+		}
+	})
+
+	t.Run("ConcurrentAppends", func(t *testing.T) {
+		s := newStore(t)
+		const appends = 50
+		var wg sync.WaitGroup
+		for i := 0; i < appends; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if _, err := s.Append(ctx, executor.Event{SessionID: "busy", Type: "stdout", Content: fmt.Sprint(i)}); err != nil {
+					t.Errorf("concurrent append failed: %v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		events := list(t, s, "busy", store.ListOptions{})
+		if len(events) != appends {
+			t.Fatalf("expected %d events, got %d", appends, len(events))
+		}
+		for i, evt := range events {
+			if evt.Seq != uint64(i+1) {
+				t.Fatalf("expected contiguous seqs in order, got seq %d at index %d", evt.Seq, i)
+			}
+		}
+	})
+
+	t.Run("ResumeAfterDone", func(t *testing.T) {
+		s := newStore(t)
+		appendEvent(t, s, "resumed", "stdout", "first run")
+		done := appendEvent(t, s, "resumed", "done", "done")
+		resumed := appendEvent(t, s, "resumed", "stdout", "second run")
+		if resumed.Seq <= done.Seq {
+			t.Fatalf("expected the resumed run to continue after seq %d, got %d", done.Seq, resumed.Seq)
+		}
+		expectSeqs(t, list(t, s, "resumed", store.ListOptions{AfterSeq: done.Seq}), resumed.Seq)
+	})
+
+	t.Run("ListOptions", func(t *testing.T) {
+		s := newStore(t)
+		for _, eventType := range []string{"stdout", "tool", "stdout", "tool", "done"} {
+			appendEvent(t, s, "listed", eventType, eventType)
+		}
+		expectSeqs(t, list(t, s, "listed", store.ListOptions{AfterSeq: 2}), 3, 4, 5)
+		expectSeqs(t, list(t, s, "listed", store.ListOptions{UntilSeq: 2}), 1, 2)
+		expectSeqs(t, list(t, s, "listed", store.ListOptions{AfterSeq: 1, UntilSeq: 4}), 2, 3, 4)
+		expectSeqs(t, list(t, s, "listed", store.ListOptions{Limit: 2}), 1, 2)
+		expectSeqs(t, list(t, s, "listed", store.ListOptions{AfterSeq: 1, Limit: 2}), 2, 3)
+		expectSeqs(t, list(t, s, "listed", store.ListOptions{Types: []string{"tool"}}), 2, 4)
+		expectSeqs(t, list(t, s, "listed", store.ListOptions{Types: []string{"stdout", "done"}, Limit: 2}), 1, 3)
+		expectSeqs(t, list(t, s, "listed", store.ListOptions{Tail: 2}), 4, 5)
+		expectSeqs(t, list(t, s, "listed", store.ListOptions{Tail: 2, UntilSeq: 4, Types: []string{"stdout", "tool"}}), 3, 4)
+		expectSeqs(t, list(t, s, "listed", store.ListOptions{AfterSeq: 5}))
+		expectSeqs(t, list(t, s, "missing", store.ListOptions{}))
+	})
+
+	t.Run("LatestSeq", func(t *testing.T) {
+		s := newStore(t)
+		if seq, err := s.LatestSeq(ctx, "latest"); err != nil || seq != 0 {
+			t.Fatalf("expected seq 0 for an unknown session, got %d (err=%v)", seq, err)
+		}
+		for i := 1; i <= 3; i++ {
+			appendEvent(t, s, "latest", "stdout", "line")
+			if seq, err := s.LatestSeq(ctx, "latest"); err != nil || seq != uint64(i) {
+				t.Fatalf("expected latest seq %d, got %d (err=%v)", i, seq, err)
+			}
+		}
+	})
+
+	t.Run("CloseTwice", func(t *testing.T) {
+		s := factory(t)
+		if err := s.Close(); err != nil {
+			t.Fatalf("close failed: %v", err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatalf("second close failed: %v", err)
+		}
+	})
+}
@@ -0,0 +1,21 @@
+package storetest
+
+import (
+	"testing"
+
+	"github.com/supremeagent/executor/pkg/store"
+)
+
+func TestMemoryEventStore(t *testing.T) {
+	TestEventStore(t, func(t *testing.T) store.EventStore { return store.NewMemoryEventStore() })
+}
+
+func TestFileEventStore(t *testing.T) {
+	TestEventStore(t, func(t *testing.T) store.EventStore {
+		s, err := store.NewFileEventStore(t.TempDir())
+		if err != nil {
+			t.Fatalf("new file store failed: %v", err)
+		}
+		return s
+	})
+}
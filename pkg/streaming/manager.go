@@ -12,18 +12,52 @@ type LogEntry struct {
 	Content any    `json:"content"`
 }
 
+// DefaultSubscriberBuffer is the number of entries a subscriber may fall
+// behind when ManagerOptions.SubscriberBuffer is not set.
+const DefaultSubscriberBuffer = 100
+
+// LagEntryType is the Type of the entry a subscriber receives in place of the
+// first entry dropped for it because its channel was full. Entries after it
+// are dropped too until the subscriber has room again.
+const LagEntryType = "lag"
+
+// ManagerOptions configures a Manager.
+type ManagerOptions struct {
+	// SubscriberBuffer is the number of entries each subscriber may fall
+	// behind. Entries arriving while a subscriber is that far behind are
+	// dropped for that subscriber only. Defaults to DefaultSubscriberBuffer when <= 0.
+	SubscriberBuffer int
+}
+
 // Manager manages SSE streams for executor sessions
 type Manager struct {
 	sessions    map[string][]LogEntry
-	subscribers map[string][]chan LogEntry
+	subscribers map[string][]*subscriber
+	buffer      int
 	mu          sync.RWMutex
 }
 
+// subscriber is one Subscribe channel. The channel has one slot more than
+// the manager's buffer, so the lag entry always fits.
+type subscriber struct {
+	ch      chan LogEntry
+	lagging bool
+}
+
 // NewManager creates a new SSE manager
 func NewManager() *Manager {
+	return NewManagerWithOptions(ManagerOptions{})
+}
+
+// NewManagerWithOptions creates a new SSE manager with opts applied.
+func NewManagerWithOptions(opts ManagerOptions) *Manager {
+	if opts.SubscriberBuffer <= 0 {
+		opts.SubscriberBuffer = DefaultSubscriberBuffer
+	}
 	return &Manager{
 		sessions:    make(map[string][]LogEntry),
-		subscribers: make(map[string][]chan LogEntry),
+		subscribers: make(map[string][]*subscriber),
+		buffer:      opts.SubscriberBuffer,
 	}
 }
 
@@ -34,23 +68,31 @@ func (m *Manager) StoreLogs(sessionID string, logs []LogEntry) {
 	m.sessions[sessionID] = logs
 }
 
-// AppendLog appends a log entry to a session and notifies subscribers
+// AppendLog appends a log entry to a session and notifies subscribers.
+// Sends never block, so they happen under the lock; this keeps them from
+// racing with UnregisterSession closing the channels. A subscriber whose
+// buffer is full gets a LagEntryType entry instead and misses entries until
+// it has room again.
 func (m *Manager) AppendLog(sessionID string, entry LogEntry) {
 	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.sessions[sessionID] = append(m.sessions[sessionID], entry)
 
-	// Copy subscribers to avoid holding lock during send
-	subs := make([]chan LogEntry, len(m.subscribers[sessionID]))
-	copy(subs, m.subscribers[sessionID])
-	m.mu.Unlock()
-
 	// Notify all subscribers
-	for i, ch := range subs {
+	for i, sub := range m.subscribers[sessionID] {
+		if len(sub.ch) < m.buffer {
+			sub.lagging = false
+			sub.ch <- entry
+			continue
+		}
+		if sub.lagging {
+			continue
+		}
+		log.Warningf("AppendLog: subscriber %d of session %s is full, dropping entries until it catches up", i, sessionID)
+		sub.lagging = true
 		select {
-		case ch <- entry:
-			// log.Debugf("AppendLog: sent to subscriber %d", i)
+		case sub.ch <- LogEntry{Type: LagEntryType}:
 		default:
-			log.Warningf("AppendLog: subscriber %d channel full, skipped", i)
 		}
 	}
 }
@@ -60,15 +102,15 @@ func (m *Manager) Subscribe(sessionID string) (<-chan LogEntry, func()) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	ch := make(chan LogEntry, 100)
-	m.subscribers[sessionID] = append(m.subscribers[sessionID], ch)
+	ch := make(chan LogEntry, m.buffer+1)
+	m.subscribers[sessionID] = append(m.subscribers[sessionID], &subscriber{ch: ch})
 
 	unsubscribe := func() {
 		m.mu.Lock()
 		defer m.mu.Unlock()
 		subs := m.subscribers[sessionID]
 		for i, sub := range subs {
-			if sub == ch {
+			if sub.ch == ch {
 				m.subscribers[sessionID] = append(subs[:i], subs[i+1:]...)
 				close(ch)
 				break
@@ -95,8 +137,8 @@ func (m *Manager) UnregisterSession(sessionID string) {
 
 	delete(m.sessions, sessionID)
 
-	for _, ch := range m.subscribers[sessionID] {
-		close(ch)
+	for _, sub := range m.subscribers[sessionID] {
+		close(sub.ch)
 	}
 	delete(m.subscribers, sessionID)
 }
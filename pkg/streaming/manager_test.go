@@ -71,3 +71,46 @@ func TestManager_StoreLogs(t *testing.T) {
 		t.Errorf("expected 2 logs, got %d", len(retrieved))
 	}
 }
+
+func TestManager_SlowSubscriberDoesNotBlock(t *testing.T) {
+	m := NewManagerWithOptions(ManagerOptions{SubscriberBuffer: 2})
+	sessionID := "test-session-slow"
+
+	stuck, unsubscribeStuck := m.Subscribe(sessionID)
+	defer unsubscribeStuck()
+	live, unsubscribeLive := m.Subscribe(sessionID)
+	defer unsubscribeLive()
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for i := 0; i < 1000; i++ {
+			m.AppendLog(sessionID, LogEntry{Type: "stdout", Content: i})
+			if entry := <-live; entry.Content != i {
+				t.Errorf("expected entry %d for the draining subscriber, got %+v", i, entry)
+				return
+			}
+		}
+	}()
+	select {
+	case <-finished:
+	case <-time.After(2 * time.Second):
+		t.Fatal("AppendLog blocked on a subscriber that is not reading")
+	}
+
+	for i := 0; i < 2; i++ {
+		if entry := <-stuck; entry.Content != i {
+			t.Fatalf("expected buffered entry %d, got %v", i, entry.Content)
+		}
+	}
+	if lag := <-stuck; lag.Type != LagEntryType {
+		t.Fatalf("expected a lag entry after the buffered entries, got %+v", lag)
+	}
+	m.AppendLog(sessionID, LogEntry{Type: "stdout", Content: "next"})
+	if entry := <-stuck; entry.Content != "next" {
+		t.Fatalf("expected the next entry after the lag marker, got %+v", entry)
+	}
+	if entry := <-live; entry.Type == LagEntryType {
+		t.Fatal("expected the draining subscriber never to lag")
+	}
+}